@@ -9,6 +9,7 @@ require (
 	github.com/VividCortex/gohistogram v1.0.0 // indirect
 	github.com/aws/aws-sdk-go v1.19.28 // indirect
 	github.com/c9s/goprocinfo v0.0.0-20190309065803-0b2ad9ac246b // indirect
+	github.com/fsnotify/fsnotify v1.4.7
 	github.com/go-kit/kit v0.8.0
 	github.com/goph/emperror v0.17.1
 	github.com/gorilla/mux v1.7.1
@@ -17,8 +18,11 @@ require (
 	github.com/justinas/alice v0.0.0-20171023064455-03f45bd4b7da
 	github.com/miekg/dns v1.1.9 // indirect
 	github.com/prometheus/client_golang v0.9.2 // indirect
+	github.com/spf13/cast v1.3.0
 	github.com/spf13/pflag v1.0.3
 	github.com/spf13/viper v1.3.2
 	github.com/stretchr/testify v1.3.0
+	github.com/ugorji/go/codec v0.0.0-20181204163529-d75b2dcb6bc8
+	golang.org/x/sync v0.0.0-20190423024810-112230192c58
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0 // indirect
 )
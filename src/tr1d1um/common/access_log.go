@@ -0,0 +1,134 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//clfTimeFormat is the format of the timestamps of the Common Log Format
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+//OpenAccessLog opens the destination of the access log: stdout, stderr or the path of a file which is appended to
+//(rotate it with copytruncate). An empty destination disables the access log, in which case the writer is nil
+func OpenAccessLog(destination string) (io.Writer, error) {
+	switch destination {
+	case "":
+		return nil, nil
+	case "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	}
+
+	return os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+}
+
+//AccessLog is a middleware which writes a line in the Apache Combined Log Format to out for every request
+//It is separate from the structured bookkeeping logs, for tools which only ingest CLF
+func AccessLog(out io.Writer) func(http.Handler) http.Handler {
+	var lock sync.Mutex
+
+	return func(next http.Handler) http.Handler {
+		if out == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				start    = time.Now()
+				recorder = &accessLogWriter{ResponseWriter: w, code: http.StatusOK}
+			)
+
+			next.ServeHTTP(recorder, r)
+
+			var line = combinedLogLine(r, start, recorder.code, recorder.size)
+
+			lock.Lock()
+			io.WriteString(out, line)
+			lock.Unlock()
+		})
+	}
+}
+
+//combinedLogLine formats a request as: host ident authuser [date] "request" status bytes "referer" "user-agent"
+func combinedLogLine(r *http.Request, start time.Time, code, size int) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	var bytes = "-"
+	if size > 0 {
+		bytes = fmt.Sprint(size)
+	}
+
+	return fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %s \"%s\" \"%s\"\n",
+		clfValue(host),
+		start.Format(clfTimeFormat),
+		r.Method, clfEscape(r.RequestURI), r.Proto,
+		code, bytes,
+		clfValue(clfEscape(r.Referer())), clfValue(clfEscape(r.UserAgent())),
+	)
+}
+
+//clfValue returns "-", the CLF placeholder for missing values, if the given value is empty
+func clfValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+//clfEscape escapes the quotes, backslashes and control characters of request values so they can't forge lines or fields
+func clfEscape(value string) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+//accessLogWriter records the status code and size of responses
+type accessLogWriter struct {
+	http.ResponseWriter
+	code        int
+	size        int
+	wroteHeader bool
+}
+
+func (a *accessLogWriter) WriteHeader(code int) {
+	if !a.wroteHeader {
+		a.code, a.wroteHeader = code, true
+	}
+
+	a.ResponseWriter.WriteHeader(code)
+}
+
+func (a *accessLogWriter) Write(data []byte) (int, error) {
+	a.wroteHeader = true
+
+	n, err := a.ResponseWriter.Write(data)
+	a.size += n
+	return n, err
+}
+
+//Flush keeps streamed responses streaming
+func (a *accessLogWriter) Flush() {
+	if flusher, ok := a.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
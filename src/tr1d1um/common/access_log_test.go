@@ -0,0 +1,75 @@
+package common
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAccessLog(t *testing.T) {
+	assert := assert.New(t)
+
+	out, err := OpenAccessLog("")
+	assert.Nil(out)
+	assert.Nil(err)
+
+	out, err = OpenAccessLog("stdout")
+	assert.Equal(os.Stdout, out)
+	assert.Nil(err)
+
+	dir, _ := ioutil.TempDir("", "tr1d1um")
+	defer os.RemoveAll(dir)
+
+	out, err = OpenAccessLog(filepath.Join(dir, "access.log"))
+	if assert.Nil(err) {
+		out.(*os.File).Close()
+	}
+
+	_, err = OpenAccessLog(filepath.Join(dir, "missing", "access.log"))
+	assert.NotNil(err)
+}
+
+func TestAccessLog(t *testing.T) {
+	var (
+		out     bytes.Buffer
+		handler = AccessLog(&out)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("accepted"))
+		}))
+	)
+
+	t.Run("Combined", func(t *testing.T) {
+		out.Reset()
+
+		r := httptest.NewRequest(http.MethodPost, "/api/v2/device/mac:112233445566/config?names=a", nil)
+		r.RemoteAddr = "10.0.0.1:52000"
+		r.Header.Set("User-Agent", "curl/7.64.1")
+
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		assert.Regexp(t, regexp.MustCompile(`^10\.0\.0\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] `+
+			`"POST /api/v2/device/mac:112233445566/config\?names=a HTTP/1\.1" 202 8 "-" "curl/7\.64\.1"\n$`), out.String())
+	})
+
+	t.Run("Escaped", func(t *testing.T) {
+		out.Reset()
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("User-Agent", "evil\" \n10.0.0.2 - - \"GET /")
+
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		assert.Contains(t, out.String(), `"evil\" \x0a10.0.0.2 - - \"GET /"`)
+		assert.Equal(t, 1, bytes.Count(out.Bytes(), []byte("\n")))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		next := http.NotFoundHandler()
+		assert.IsType(t, next, AccessLog(nil)(next))
+	})
+}
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Comcast/comcast-bascule/bascule"
+	"github.com/justinas/alice"
+)
+
+//DefaultAdminCapability is the capability API consumers need to use the admin routes by default
+const DefaultAdminCapability = "x1:webpa:tr1d1um:admin"
+
+//AdminOptions configures the admin routes (drain, support bundle, canary and targets) which change how the instance
+//serves every API consumer, so being authenticated isn't enough to use them
+type AdminOptions struct {
+	//Capability is the capability the tokens of administrators hold. Defaults to DefaultAdminCapability
+	Capability string
+
+	//Principals are the principals which may use the admin routes without the capability, i.e. the users of
+	//dedicated Basic credentials (optional)
+	Principals []string
+
+	//DrainRetryAfter is the Retry-After of the requests rejected while the instance drains. Defaults to
+	//DefaultDrainRetryAfter
+	DrainRetryAfter time.Duration
+}
+
+//RequireAdmin returns a middleware which answers 403 to the requests of API consumers who are neither granted
+//the admin capability nor one of the admin principals. It goes after the authentication of requests
+func RequireAdmin(o AdminOptions) alice.Constructor {
+	if o.Capability == "" {
+		o.Capability = DefaultAdminCapability
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isAdmin(r, o) {
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(http.StatusForbidden)
+
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"message": "admin routes require the " + o.Capability + " capability",
+					"code":    "admin_forbidden",
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func isAdmin(r *http.Request, o AdminOptions) bool {
+	auth, ok := bascule.FromContext(r.Context())
+	if !ok || auth.Token == nil {
+		return false
+	}
+
+	for _, principal := range o.Principals {
+		if principal != "" && principal == auth.Token.Principal() {
+			return true
+		}
+	}
+
+	for _, capability := range Claim(r.Context(), CapabilitiesClaim) {
+		if capability == o.Capability {
+			return true
+		}
+	}
+
+	return false
+}
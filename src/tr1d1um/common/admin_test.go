@@ -0,0 +1,48 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/comcast-bascule/bascule"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	var next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	request := func(principal string, capabilities ...string) *http.Request {
+		r := httptest.NewRequest(http.MethodPut, "/admin/drain", nil)
+		return r.WithContext(bascule.WithAuthentication(r.Context(), bascule.Authentication{
+			Token: bascule.NewToken("jwt", principal, bascule.Attributes{CapabilitiesClaim: capabilities}),
+		}))
+	}
+
+	tests := []struct {
+		name     string
+		options  AdminOptions
+		request  *http.Request
+		expected int
+	}{
+		{"Unauthenticated", AdminOptions{}, httptest.NewRequest(http.MethodPut, "/admin/drain", nil), http.StatusForbidden},
+		{"OrdinaryToken", AdminOptions{}, request("partner", "x1:webpa:api:.*:all"), http.StatusForbidden},
+		{"DefaultCapability", AdminOptions{}, request("partner", DefaultAdminCapability), http.StatusOK},
+		{"Capability", AdminOptions{Capability: "admin"}, request("partner", "admin"), http.StatusOK},
+		{"OtherCapability", AdminOptions{Capability: "admin"}, request("partner", DefaultAdminCapability), http.StatusForbidden},
+		{"Principal", AdminOptions{Principals: []string{"operator"}}, request("operator"), http.StatusOK},
+		{"EmptyPrincipal", AdminOptions{Principals: []string{""}}, request(""), http.StatusForbidden},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			RequireAdmin(testCase.options)(next).ServeHTTP(recorder, testCase.request)
+
+			assert.Equal(t, testCase.expected, recorder.Code)
+			if testCase.expected == http.StatusForbidden {
+				assert.Contains(t, recorder.Body.String(), `"code":"admin_forbidden"`)
+			}
+		})
+	}
+}
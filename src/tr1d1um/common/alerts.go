@@ -0,0 +1,266 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
+)
+
+//States of error rate alerts
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+//alertBuckets is the number of buckets the window of error rates is split into
+const alertBuckets = 10
+
+//AlertOptions configure the monitor of the error rates of routes and backends
+type AlertOptions struct {
+	//Threshold is the error rate (0 to 1) above which alerts fire. Alerts are disabled if zero
+	Threshold float64
+
+	//Window is the sliding window error rates are computed over. Defaults to 1m
+	Window time.Duration
+
+	//MinRequests is the number of requests a window needs for its error rate to be considered. Defaults to 20
+	MinRequests int
+
+	//WebhookURL is the URL alert events are POSTed to, as JSON, in addition to being logged (optional)
+	WebhookURL string
+}
+
+//Validate returns an error if the threshold is not a rate
+func (o AlertOptions) Validate() error {
+	if o.Threshold < 0 || o.Threshold > 1 {
+		return errors.New("the alert threshold should be an error rate between 0 and 1")
+	}
+	return nil
+}
+
+//AlertEvent is emitted when the error rate of a route or backend crosses the threshold and when it recovers
+type AlertEvent struct {
+	//Key is the route (i.e. "route GET /api/v2/device/{deviceid}/stat") or backend (i.e. "backend scytale:6000")
+	Key string `json:"key"`
+
+	//State is AlertFiring or AlertResolved
+	State string `json:"state"`
+
+	ErrorRate float64   `json:"errorRate"`
+	Requests  int       `json:"requests"`
+	Threshold float64   `json:"threshold"`
+	Time      time.Time `json:"time"`
+}
+
+//rateWindow counts requests and errors in buckets which together span the window
+type rateWindow struct {
+	starts   [alertBuckets]time.Time
+	requests [alertBuckets]int
+	errors   [alertBuckets]int
+	firing   bool
+}
+
+//ErrorRateMonitor keeps the error rates of routes and backends over a sliding window and emits alert events
+//when they cross the threshold and when they go back under it
+type ErrorRateMonitor struct {
+	o       AlertOptions
+	lock    sync.Mutex
+	windows map[string]*rateWindow
+
+	logger kitlog.Logger
+	client *http.Client
+	now    func() time.Time
+
+	//emit delivers events. It is called without the lock held
+	emit func(AlertEvent)
+}
+
+//NewErrorRateMonitor builds a monitor out of the given options. It is nil if alerts are disabled
+//Events are delivered to the webhook with the given client (http.DefaultClient if nil)
+func NewErrorRateMonitor(o AlertOptions, logger kitlog.Logger, client *http.Client) (*ErrorRateMonitor, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	if o.Threshold == 0 {
+		return nil, nil
+	}
+
+	if o.Window <= 0 {
+		o.Window = time.Minute
+	}
+
+	if o.MinRequests <= 0 {
+		o.MinRequests = 20
+	}
+
+	if logger == nil {
+		logger = kitlog.NewNopLogger()
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	m := &ErrorRateMonitor{o: o, windows: make(map[string]*rateWindow), logger: logger, client: client, now: time.Now}
+	m.emit = m.deliver
+	return m, nil
+}
+
+//Observe records the outcome of a request of the route or backend of the given key
+func (m *ErrorRateMonitor) Observe(key string, failed bool) {
+	var (
+		now    = m.now()
+		width  = m.o.Window / alertBuckets
+		bucket = int(now.UnixNano()/int64(width)) % alertBuckets
+		start  = now.Truncate(width)
+	)
+
+	m.lock.Lock()
+
+	w, ok := m.windows[key]
+	if !ok {
+		w = new(rateWindow)
+		m.windows[key] = w
+	}
+
+	//buckets are reused once they fall out of the window
+	if !w.starts[bucket].Equal(start) {
+		w.starts[bucket], w.requests[bucket], w.errors[bucket] = start, 0, 0
+	}
+
+	w.requests[bucket]++
+	if failed {
+		w.errors[bucket]++
+	}
+
+	var requests, failures int
+	for i := range w.starts {
+		if now.Sub(w.starts[i]) < m.o.Window {
+			requests, failures = requests+w.requests[i], failures+w.errors[i]
+		}
+	}
+
+	var (
+		rate  = float64(failures) / float64(requests)
+		event *AlertEvent
+	)
+
+	switch {
+	case !w.firing && requests >= m.o.MinRequests && rate > m.o.Threshold:
+		w.firing = true
+		event = &AlertEvent{Key: key, State: AlertFiring}
+	case w.firing && rate <= m.o.Threshold:
+		w.firing = false
+		event = &AlertEvent{Key: key, State: AlertResolved}
+	}
+
+	m.lock.Unlock()
+
+	if event != nil {
+		event.ErrorRate, event.Requests, event.Threshold, event.Time = rate, requests, m.o.Threshold, now
+		m.emit(*event)
+	}
+}
+
+//deliver logs the given event and sends it to the webhook, if any, in the background
+func (m *ErrorRateMonitor) deliver(event AlertEvent) {
+	var logger = logging.Warn(m.logger)
+	if event.State == AlertResolved {
+		logger = logging.Info(m.logger)
+	}
+
+	logger.Log(logging.MessageKey(), "Error rate alert", "key", event.Key, "state", event.State,
+		"errorRate", event.ErrorRate, "requests", event.Requests, "threshold", event.Threshold)
+
+	if m.o.WebhookURL == "" {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		body, _ := json.Marshal(event)
+		request, err := http.NewRequest(http.MethodPost, m.o.WebhookURL, bytes.NewReader(body))
+		if err == nil {
+			request.Header.Set("Content-Type", "application/json")
+
+			var response *http.Response
+			if response, err = m.client.Do(request.WithContext(ctx)); err == nil {
+				response.Body.Close()
+			}
+		}
+
+		if err != nil {
+			logging.Error(m.logger).Log(logging.MessageKey(), "Error rate alert could not be delivered", "key", event.Key, logging.ErrorKey(), err)
+		}
+	}()
+}
+
+//Track is a mux middleware which observes the responses of the routes of a router
+//Responses with a 5xx status code are errors
+func (m *ErrorRateMonitor) Track(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.Method + " " + r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = r.Method + " " + template
+			}
+		}
+
+		recorder := &accessLogWriter{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		m.Observe("route "+route, recorder.code >= http.StatusInternalServerError)
+	})
+}
+
+//NewMonitoredTransactor decorates the given transactor so that the error rates of the backends (by host) it sends
+//requests to are monitored. Failed requests and 5xx responses are errors, unless the API consumer went away
+func NewMonitoredTransactor(next Tr1d1umTransactor, m *ErrorRateMonitor) Tr1d1umTransactor {
+	if m == nil {
+		return next
+	}
+
+	return &monitoredTransactor{next: next, monitor: m}
+}
+
+type monitoredTransactor struct {
+	next    Tr1d1umTransactor
+	monitor *ErrorRateMonitor
+}
+
+func (t *monitoredTransactor) Transact(req *http.Request) (*XmidtResponse, error) {
+	result, err := t.next.Transact(req)
+
+	if err != ErrClientCanceled {
+		t.monitor.Observe("backend "+backendHost(req.URL), err != nil || result.Code >= http.StatusInternalServerError)
+	}
+
+	return result, err
+}
+
+//backendHost returns the host of the given URL of an XMiDT API request
+//Target URLs may have no scheme (i.e. scytale:6000) in which case the host is parsed as a scheme
+func backendHost(u *url.URL) string {
+	if u.Host != "" {
+		return u.Host
+	}
+
+	return u.Scheme + ":" + strings.SplitN(u.Opaque, "/", 2)[0]
+}
@@ -0,0 +1,127 @@
+package common
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMonitor(o AlertOptions) (*ErrorRateMonitor, *time.Time, *[]AlertEvent) {
+	m, _ := NewErrorRateMonitor(o, nil, nil)
+
+	var (
+		now    = time.Unix(1000, 0)
+		events []AlertEvent
+	)
+
+	m.now = func() time.Time { return now }
+	m.emit = func(event AlertEvent) { events = append(events, event) }
+	return m, &now, &events
+}
+
+func TestAlertOptionsValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(AlertOptions{}.Validate())
+	assert.Nil(AlertOptions{Threshold: 0.5}.Validate())
+	assert.NotNil(AlertOptions{Threshold: -0.1}.Validate())
+	assert.NotNil(AlertOptions{Threshold: 50}.Validate())
+
+	m, err := NewErrorRateMonitor(AlertOptions{}, nil, nil)
+	assert.Nil(m)
+	assert.Nil(err)
+
+	_, err = NewErrorRateMonitor(AlertOptions{Threshold: 2}, nil, nil)
+	assert.NotNil(err)
+}
+
+func TestErrorRateMonitorObserve(t *testing.T) {
+	assert := assert.New(t)
+	m, now, events := newTestMonitor(AlertOptions{Threshold: 0.5, Window: 10 * time.Second, MinRequests: 4})
+
+	//too few requests to fire
+	for i := 0; i < 3; i++ {
+		m.Observe("backend scytale:6000", true)
+	}
+	assert.Empty(*events)
+
+	m.Observe("backend scytale:6000", true)
+	assert.Len(*events, 1)
+	assert.EqualValues(AlertFiring, (*events)[0].State)
+	assert.EqualValues("backend scytale:6000", (*events)[0].Key)
+	assert.EqualValues(1, (*events)[0].ErrorRate)
+	assert.EqualValues(4, (*events)[0].Requests)
+
+	//firing alerts don't fire again
+	m.Observe("backend scytale:6000", true)
+	assert.Len(*events, 1)
+
+	//other keys are independent
+	m.Observe("route GET /api/v2/device/{deviceid}/stat", false)
+	assert.Len(*events, 1)
+
+	//errors fall out of the window
+	*now = now.Add(11 * time.Second)
+	m.Observe("backend scytale:6000", false)
+	assert.Len(*events, 2)
+	assert.EqualValues(AlertResolved, (*events)[1].State)
+	assert.EqualValues(0, (*events)[1].ErrorRate)
+	assert.EqualValues(1, (*events)[1].Requests)
+}
+
+func TestErrorRateMonitorTrack(t *testing.T) {
+	assert := assert.New(t)
+	m, _, events := newTestMonitor(AlertOptions{Threshold: 0.1, MinRequests: 1})
+
+	var nilMonitor *ErrorRateMonitor
+	assert.NotNil(nilMonitor.Track(http.NotFoundHandler()))
+
+	router := mux.NewRouter()
+	router.Use(m.Track)
+	router.HandleFunc("/device/{deviceid}/stat", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/device/mac:112233445566/stat", nil))
+	assert.Len(*events, 1)
+	assert.EqualValues("route GET /device/{deviceid}/stat", (*events)[0].Key)
+}
+
+func TestMonitoredTransactor(t *testing.T) {
+	assert := assert.New(t)
+	m, _, events := newTestMonitor(AlertOptions{Threshold: 0.1, MinRequests: 1})
+
+	var (
+		err        error
+		transactor = NewMonitoredTransactor(transactFunc(func(*http.Request) (*XmidtResponse, error) {
+			return nil, err
+		}), m)
+	)
+
+	assert.IsType(transactFunc(nil), NewMonitoredTransactor(transactFunc(nil), nil))
+
+	err = ErrClientCanceled
+	transactor.Transact(httptest.NewRequest(http.MethodGet, "http://scytale:6000/api/v2/device", nil))
+	assert.Empty(*events)
+
+	err = errors.New("connection refused")
+	transactor.Transact(httptest.NewRequest(http.MethodGet, "http://scytale:6000/api/v2/device", nil))
+	assert.Len(*events, 1)
+	assert.EqualValues("backend scytale:6000", (*events)[0].Key)
+}
+
+func TestBackendHost(t *testing.T) {
+	assert := assert.New(t)
+
+	u, _ := url.Parse("http://scytale.example.com:6000/api/v2/device")
+	assert.EqualValues("scytale.example.com:6000", backendHost(u))
+
+	u, _ = url.Parse("scytale:6000/api/v2/device")
+	assert.EqualValues("scytale:6000", backendHost(u))
+}
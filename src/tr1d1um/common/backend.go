@@ -0,0 +1,169 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+//DefaultBackend is the name of the backend which sends requests to the XMiDT API (scytale) over HTTP
+const DefaultBackend = "xmidt"
+
+//WRPRequest is a WRP message on its way to a device
+type WRPRequest struct {
+	Message *wrp.Message
+
+	//QoS is the WRP quality of service of the message. Only meaningful if HasQoS
+	QoS    int
+	HasQoS bool
+
+	//Authorization is the value of the Authorization header of the API request
+	Authorization string
+}
+
+//Backend delivers the requests of the tr1d1um services to devices
+//The XMiDT API is the default one but others (i.e. talaria, kafka or a mock) may be registered through RegisterBackend
+type Backend interface {
+	//SendWRP sends a WRP message to its destination device and returns the response, if any
+	SendWRP(context.Context, *WRPRequest) (*XmidtResponse, error)
+
+	//RequestStat returns the statistics of a device
+	RequestStat(ctx context.Context, authValue, deviceID string) (*XmidtResponse, error)
+}
+
+//BackendOptions are the parameters backends are built with
+type BackendOptions struct {
+	//TargetURL is the base URL of the XMiDT API
+	TargetURL string
+
+	//Transactor sends HTTP requests on behalf of tr1d1um, for backends which need to
+	Transactor Tr1d1umTransactor
+
+	//WRPFormat is the encoding of the WRP messages sent to the XMiDT API. Defaults to msgpack
+	WRPFormat wrp.Format
+
+	//Config is the configuration specific to the backend (optional)
+	Config map[string]interface{}
+}
+
+//BackendFactory builds a backend
+type BackendFactory func(BackendOptions) (Backend, error)
+
+var (
+	backendsLock sync.RWMutex
+	backends     = map[string]BackendFactory{
+		DefaultBackend: func(o BackendOptions) (Backend, error) {
+			return NewXmidtBackend(
+				fmt.Sprintf("%s/api/v2/device", o.TargetURL),
+				fmt.Sprintf("%s/api/v2/device/${device}/stat", o.TargetURL),
+				o.WRPFormat,
+				o.Transactor,
+			), nil
+		},
+	}
+)
+
+//RegisterBackend makes a backend available under the given name, replacing any backend of the same name
+func RegisterBackend(name string, factory BackendFactory) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+	backends[strings.ToLower(name)] = factory
+}
+
+//NewBackend builds the backend registered under the given name. An empty name means DefaultBackend
+func NewBackend(name string, o BackendOptions) (Backend, error) {
+	if name == "" {
+		name = DefaultBackend
+	}
+
+	backendsLock.RLock()
+	factory, ok := backends[strings.ToLower(name)]
+	backendsLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown backend '%s'", name)
+	}
+
+	return factory(o)
+}
+
+//NewXmidtBackend returns the backend which sends requests to the XMiDT API
+//statURL is a template where ${device} stands for the device ID. WRP messages are sent in the given format
+func NewXmidtBackend(wrpURL, statURL string, format wrp.Format, transactor Tr1d1umTransactor) Backend {
+	return &xmidtBackend{wrpURL: wrpURL, statURL: statURL, format: format, transactor: transactor}
+}
+
+type xmidtBackend struct {
+	wrpURL     string
+	statURL    string
+	format     wrp.Format
+	transactor Tr1d1umTransactor
+}
+
+func (x *xmidtBackend) SendWRP(ctx context.Context, r *WRPRequest) (result *XmidtResponse, err error) {
+	var message interface{} = r.Message
+	if r.HasQoS {
+		message = newWRPMessage(r.Message, r.QoS)
+	}
+
+	var payload []byte
+	if payload, err = EncodeWRPFormat(message, x.format); err == nil {
+		var req *http.Request
+		if req, err = http.NewRequest(http.MethodPost, x.wrpURL, bytes.NewBuffer(payload)); err == nil {
+
+			req.Header.Add("Content-Type", x.format.ContentType())
+			req.Header.Add("Accept", x.format.ContentType())
+			req.Header.Add("Authorization", r.Authorization)
+
+			if result, err = x.transactor.Transact(req.WithContext(ctx)); err == nil {
+				err = toMsgpack(result)
+			}
+		}
+	}
+	return
+}
+
+//toMsgpack re-encodes the WRP messages of successful responses which came in JSON into msgpack,
+//which is what the services decode. Bodies of other content types are left alone but JSON bodies which aren't
+//WRP messages fail with ErrMalformedWRPResponse, as services couldn't decode them
+func toMsgpack(result *XmidtResponse) error {
+	if result == nil || result.Code != http.StatusOK {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(result.ContentType)
+	if format, err := wrp.FormatFromContentType(mediaType); err != nil || format != wrp.JSON {
+		return nil
+	}
+
+	var message wrp.Message
+	if DecodeWRPFormat(result.Body, wrp.JSON, &message) != nil {
+		return ErrMalformedWRPResponse
+	}
+
+	body, err := EncodeWRP(&message)
+	if err != nil {
+		return err
+	}
+
+	result.Body, result.ContentType = body, wrp.Msgpack.ContentType()
+	result.Compressed, result.ContentEncoding = nil, ""
+	return nil
+}
+
+func (x *xmidtBackend) RequestStat(ctx context.Context, authValue, deviceID string) (result *XmidtResponse, err error) {
+	var r *http.Request
+
+	if r, err = http.NewRequest(http.MethodGet, strings.Replace(x.statURL, "${device}", deviceID, 1), nil); err == nil {
+		r.Header.Add("Authorization", authValue)
+
+		result, err = x.transactor.Transact(r.WithContext(ctx))
+	}
+	return
+}
@@ -0,0 +1,134 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ugorji/go/codec"
+)
+
+func TestNewBackend(t *testing.T) {
+	assert := assert.New(t)
+
+	backend, err := NewBackend("", BackendOptions{TargetURL: "http://localhost"})
+	assert.Nil(err)
+	assert.Equal(&xmidtBackend{wrpURL: "http://localhost/api/v2/device", statURL: "http://localhost/api/v2/device/${device}/stat"}, backend)
+
+	var mock = new(MockBackend)
+	RegisterBackend("Mock", func(o BackendOptions) (Backend, error) {
+		assert.Equal("value", o.Config["key"])
+		return mock, nil
+	})
+
+	backend, err = NewBackend("mock", BackendOptions{Config: map[string]interface{}{"key": "value"}})
+	assert.Nil(err)
+	assert.Equal(mock, backend)
+
+	backend, err = NewBackend("kafka", BackendOptions{})
+	assert.Nil(backend)
+	assert.NotNil(err)
+}
+
+func TestXmidtBackendSendWRP(t *testing.T) {
+	var (
+		m       = new(MockTr1d1umTransactor)
+		backend = NewXmidtBackend("http://localhost/wrp", "", wrp.Msgpack, m)
+		sent    map[string]interface{}
+	)
+
+	m.On("Transact", mock.MatchedBy(func(r *http.Request) bool {
+		data, _ := ioutil.ReadAll(r.Body)
+		sent = nil
+		return r.URL.String() == "http://localhost/wrp" &&
+			r.Header.Get("Authorization") == "token" &&
+			r.Header.Get("Content-Type") == wrp.Msgpack.ContentType() &&
+			codec.NewDecoderBytes(data, new(codec.MsgpackHandle)).Decode(&sent) == nil
+	})).Return(&XmidtResponse{Code: http.StatusOK}, nil)
+
+	t.Run("QoS", func(t *testing.T) {
+		assert := assert.New(t)
+		response, err := backend.SendWRP(context.TODO(), &WRPRequest{Message: &wrp.Message{Source: "local"}, QoS: 50, HasQoS: true, Authorization: "token"})
+
+		assert.Nil(err)
+		assert.Equal(http.StatusOK, response.Code)
+		assert.EqualValues(50, sent["qos"])
+		assert.EqualValues("local", sent["source"])
+	})
+
+	t.Run("NoQoS", func(t *testing.T) {
+		_, err := backend.SendWRP(context.TODO(), &WRPRequest{Message: &wrp.Message{}, Authorization: "token"})
+		assert.Nil(t, err)
+		assert.NotContains(t, sent, "qos")
+	})
+}
+
+func TestXmidtBackendSendWRPJSON(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		m       = new(MockTr1d1umTransactor)
+		backend = NewXmidtBackend("http://localhost/wrp", "", wrp.JSON, m)
+		sent    map[string]interface{}
+	)
+
+	m.On("Transact", mock.MatchedBy(func(r *http.Request) bool {
+		data, _ := ioutil.ReadAll(r.Body)
+		return r.Header.Get("Content-Type") == wrp.JSON.ContentType() &&
+			r.Header.Get("Accept") == wrp.JSON.ContentType() &&
+			json.Unmarshal(data, &sent) == nil
+	})).Return(&XmidtResponse{
+		Code:        http.StatusOK,
+		ContentType: "application/json; charset=utf-8",
+		Body:        []byte(`{"msg_type":3,"source":"mac:112233445566/config","payload":"e30="}`),
+	}, nil)
+
+	response, err := backend.SendWRP(context.TODO(), &WRPRequest{Message: &wrp.Message{Source: "local"}, QoS: 50, HasQoS: true})
+	assert.Nil(err)
+	assert.EqualValues("local", sent["source"])
+	assert.EqualValues(50, sent["qos"])
+
+	//services always get msgpack
+	var received wrp.Message
+	assert.Equal(wrp.Msgpack.ContentType(), response.ContentType)
+	assert.Nil(DecodeWRP(response.Body, &received))
+	assert.Equal("mac:112233445566/config", received.Source)
+	assert.Equal([]byte("{}"), received.Payload)
+}
+
+func TestToMsgpack(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, result := range []*XmidtResponse{
+		{Code: http.StatusNotFound, ContentType: "application/json", Body: []byte(`{"message":"device not found"}`)},
+		{Code: http.StatusOK, ContentType: "text/plain", Body: []byte("ok")},
+	} {
+		body := result.Body
+		assert.Nil(toMsgpack(result))
+		assert.Equal(body, result.Body)
+	}
+
+	//services couldn't decode JSON bodies which aren't WRP messages
+	malformed := &XmidtResponse{Code: http.StatusOK, ContentType: "application/json", Body: []byte("[")}
+	assert.Equal(ErrMalformedWRPResponse, toMsgpack(malformed))
+	assert.Equal([]byte("["), malformed.Body)
+}
+
+func TestXmidtBackendRequestStat(t *testing.T) {
+	var (
+		m       = new(MockTr1d1umTransactor)
+		backend = NewXmidtBackend("", "http://localhost/stat/${device}", wrp.Msgpack, m)
+	)
+
+	m.On("Transact", mock.MatchedBy(func(r *http.Request) bool {
+		return r.Method == http.MethodGet && r.URL.String() == "http://localhost/stat/mac:1122334455" && r.Header.Get("Authorization") == "token"
+	})).Return(&XmidtResponse{Code: http.StatusOK}, nil)
+
+	response, err := backend.RequestStat(context.TODO(), "token", "mac:1122334455")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.Code)
+}
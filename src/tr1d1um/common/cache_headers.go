@@ -0,0 +1,100 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+)
+
+//CacheHeaders are the caching headers of the successful responses of a route
+type CacheHeaders struct {
+	//CacheControl is the value of the Cache-Control header (i.e. no-store or max-age=30)
+	CacheControl string
+
+	//Expires is how long after they are sent responses expire, for HTTP/1.0 caches. There is no Expires header if zero
+	Expires time.Duration
+}
+
+//CachePolicy holds the caching headers of routes. Keys are route groups (i.e. stat or device), optionally followed
+//by a space and an HTTP method (i.e. "device PATCH") which takes precedence. Keys are case insensitive
+type CachePolicy map[string]CacheHeaders
+
+//headers returns the caching headers of the responses of the given route group to requests of the given method
+func (p CachePolicy) headers(group, method string) (CacheHeaders, bool) {
+	var byGroup *CacheHeaders
+
+	for key, headers := range p {
+		switch key = strings.ToLower(key); key {
+		case strings.ToLower(group + " " + method):
+			return headers, true
+		case strings.ToLower(group):
+			h := headers
+			byGroup = &h
+		}
+	}
+
+	if byGroup != nil {
+		return *byGroup, true
+	}
+
+	return CacheHeaders{}, false
+}
+
+//CacheHeadersEncoder decorates the response encoder of the routes of the given group so that their 2xx responses
+//carry the caching headers of the policy. Other responses are left alone as they are usually transient
+func CacheHeadersEncoder(p CachePolicy, group string, next kithttp.EncodeResponseFunc) kithttp.EncodeResponseFunc {
+	if len(p) == 0 {
+		return next
+	}
+
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		method := RequestMethod(ctx)
+
+		headers, ok := p.headers(group, method)
+		if !ok {
+			return next(ctx, w, response)
+		}
+
+		return next(ctx, &cacheHeadersWriter{ResponseWriter: w, headers: headers}, response)
+	}
+}
+
+//cacheHeadersWriter adds the caching headers to the response once its status code is known
+type cacheHeadersWriter struct {
+	http.ResponseWriter
+	headers     CacheHeaders
+	wroteHeader bool
+}
+
+func (c *cacheHeadersWriter) WriteHeader(code int) {
+	if !c.wroteHeader && code >= http.StatusOK && code < http.StatusMultipleChoices {
+		if c.headers.CacheControl != "" {
+			c.Header().Set("Cache-Control", c.headers.CacheControl)
+		}
+
+		if c.headers.Expires > 0 {
+			c.Header().Set("Expires", time.Now().Add(c.headers.Expires).UTC().Format(http.TimeFormat))
+		}
+	}
+
+	c.wroteHeader = true
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *cacheHeadersWriter) Write(data []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+
+	return c.ResponseWriter.Write(data)
+}
+
+//Flush keeps streamed responses streaming
+func (c *cacheHeadersWriter) Flush() {
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
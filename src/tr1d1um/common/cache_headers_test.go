@@ -0,0 +1,75 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachePolicyHeaders(t *testing.T) {
+	assert := assert.New(t)
+
+	policy := CachePolicy{
+		"device":       {CacheControl: "max-age=30"},
+		"device patch": {CacheControl: "no-store"},
+	}
+
+	headers, ok := policy.headers("device", http.MethodGet)
+	assert.True(ok)
+	assert.Equal("max-age=30", headers.CacheControl)
+
+	headers, ok = policy.headers("device", http.MethodPatch)
+	assert.True(ok)
+	assert.Equal("no-store", headers.CacheControl)
+
+	_, ok = policy.headers("stat", http.MethodGet)
+	assert.False(ok)
+}
+
+func TestCacheHeadersEncoder(t *testing.T) {
+	var (
+		policy = CachePolicy{"stat": {CacheControl: "max-age=30", Expires: 30 * time.Second}}
+		ctx    = context.WithValue(context.Background(), ContextKeyRequestMethod, http.MethodGet)
+	)
+
+	encode := func(code int) func(context.Context, http.ResponseWriter, interface{}) error {
+		return func(_ context.Context, w http.ResponseWriter, _ interface{}) error {
+			w.WriteHeader(code)
+			_, err := w.Write([]byte("{}"))
+			return err
+		}
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		assert.Nil(CacheHeadersEncoder(policy, "stat", encode(http.StatusOK))(ctx, recorder, nil))
+		assert.Equal("max-age=30", recorder.Header().Get("Cache-Control"))
+
+		expires, err := http.ParseTime(recorder.Header().Get("Expires"))
+		assert.Nil(err)
+		assert.WithinDuration(time.Now().Add(30*time.Second), expires, 2*time.Second)
+		assert.Equal("{}", recorder.Body.String())
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		assert.Nil(CacheHeadersEncoder(policy, "stat", encode(http.StatusNotFound))(ctx, recorder, nil))
+		assert.Empty(recorder.Header().Get("Cache-Control"))
+		assert.Empty(recorder.Header().Get("Expires"))
+	})
+
+	t.Run("OtherGroup", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+
+		assert.Nil(t, CacheHeadersEncoder(policy, "device", encode(http.StatusOK))(ctx, recorder, nil))
+		assert.Empty(t, recorder.Header().Get("Cache-Control"))
+	})
+}
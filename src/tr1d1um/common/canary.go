@@ -0,0 +1,189 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"sync"
+
+	"github.com/Comcast/webpa-common/device"
+)
+
+//Labels of the backends traffic is split between
+const (
+	PrimaryTarget = "primary"
+	CanaryTarget  = "canary"
+)
+
+//Outcomes of canary routed requests
+const (
+	CanarySuccess = "success"
+	CanaryFailure = "failure"
+)
+
+//CanarySplit describes how traffic is split between the primary backend and the canary one
+type CanarySplit struct {
+	//Percentage is the share (0 to 100) of traffic sent to the canary backend
+	Percentage float64 `json:"percentage"`
+
+	//DeviceHash indicates whether the backend is picked from a hash of the device ID so that all the requests
+	//for a device go to the same backend. Requests for no particular device are split randomly
+	DeviceHash bool `json:"deviceHash"`
+}
+
+//Validate returns an error if the split is invalid
+func (s CanarySplit) Validate() error {
+	if s.Percentage < 0 || s.Percentage > 100 {
+		return fmt.Errorf("canary percentage should be between 0 and 100 but was %v", s.Percentage)
+	}
+	return nil
+}
+
+//CanaryOptions configures the canary backend
+type CanaryOptions struct {
+	//TargetURL is the base URL of the canary XMiDT API. It has the same format as the default targetURL
+	//Canary routing is disabled if empty
+	TargetURL string
+
+	CanarySplit `mapstructure:",squash"`
+}
+
+//Canary holds the current split of traffic between the primary and canary backends
+//The split may be updated at any time
+type Canary struct {
+	lock  sync.RWMutex
+	split CanarySplit
+
+	targetURL string
+
+	//sample returns a number in [0, 1) used to pick the backend of requests split randomly
+	sample func() float64
+}
+
+//NewCanary builds a Canary out of the given options
+func NewCanary(o CanaryOptions) (*Canary, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Canary{split: o.CanarySplit, targetURL: o.TargetURL, sample: rand.Float64}, nil
+}
+
+//Split returns the current traffic split
+func (c *Canary) Split() CanarySplit {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.split
+}
+
+//Update replaces the traffic split. An invalid split is rejected and leaves the current one untouched
+func (c *Canary) Update(s CanarySplit) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	c.split = s
+	c.lock.Unlock()
+	return nil
+}
+
+//routed returns true if the request for the given device (which may be empty) should be sent to the canary backend
+func (c *Canary) routed(deviceID string) bool {
+	var split = c.Split()
+
+	if c.targetURL == "" || split.Percentage <= 0 {
+		return false
+	}
+
+	if split.DeviceHash && deviceID != "" {
+		if canonicalID, err := device.ParseID(deviceID); err == nil {
+			deviceID = string(canonicalID)
+		}
+
+		h := fnv.New32a()
+		h.Write([]byte(deviceID))
+		return float64(h.Sum32()%10000) < split.Percentage*100
+	}
+
+	return c.sample()*100 < split.Percentage
+}
+
+//NewCanaryTransactor decorates the given transactor so that a share of the requests to defaultTarget are sent to
+//the canary backend instead. The outcome of requests is counted by backend so that the canary can be evaluated
+func NewCanaryTransactor(next Tr1d1umTransactor, canary *Canary, defaultTarget string, measures *Measures) Tr1d1umTransactor {
+	if canary == nil || canary.targetURL == "" {
+		return next
+	}
+
+	if measures == nil {
+		measures = NewMeasures(nil)
+	}
+
+	return &canaryTransactor{next: next, canary: canary, defaultTarget: defaultTarget, measures: measures}
+}
+
+type canaryTransactor struct {
+	next          Tr1d1umTransactor
+	canary        *Canary
+	defaultTarget string
+	measures      *Measures
+}
+
+func (c *canaryTransactor) Transact(req *http.Request) (*XmidtResponse, error) {
+	if !targets(req, c.defaultTarget) {
+		return c.next.Transact(req)
+	}
+
+	var (
+		deviceID = RequestDeviceID(req.Context())
+		target   = PrimaryTarget
+	)
+
+	if c.canary.routed(deviceID) {
+		if err := retarget(req, c.defaultTarget, c.canary.targetURL); err != nil {
+			return nil, err
+		}
+		target = CanaryTarget
+	}
+
+	result, err := c.next.Transact(req)
+
+	var outcome = CanarySuccess
+	if err != nil || result.Code >= http.StatusInternalServerError {
+		outcome = CanaryFailure
+	}
+
+	//the API consumer going away says nothing about the backend
+	if err != ErrClientCanceled {
+		c.measures.CanaryRequests.With(TargetLabel, target, OutcomeLabel, outcome).Add(1)
+	}
+
+	return result, err
+}
+
+//CanaryHandler returns the handler through which the traffic split of the given canary is read (GET) and updated (PUT)
+func CanaryHandler(canary *Canary) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		if r.Method == http.MethodPut {
+			var split CanarySplit
+			if err := json.NewDecoder(r.Body).Decode(&split); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{"message": "invalid canary split: " + err.Error()})
+				return
+			}
+
+			if err := canary.Update(split); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{"message": err.Error()})
+				return
+			}
+		}
+
+		json.NewEncoder(w).Encode(canary.Split())
+	})
+}
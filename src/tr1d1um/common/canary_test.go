@@ -0,0 +1,140 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+//splitCounter counts observations by the values of their labels
+type splitCounter struct {
+	counts      map[string]float64
+	labelValues string
+}
+
+func (c *splitCounter) With(labelValues ...string) metrics.Counter {
+	return &splitCounter{counts: c.counts, labelValues: labelValues[1] + "/" + labelValues[3]}
+}
+
+func (c *splitCounter) Add(delta float64) {
+	c.counts[c.labelValues] += delta
+}
+
+func TestCanarySplitValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(CanarySplit{Percentage: 10}.Validate())
+	assert.NotNil(CanarySplit{Percentage: 101}.Validate())
+
+	_, err := NewCanary(CanaryOptions{TargetURL: "http://canary:6000", CanarySplit: CanarySplit{Percentage: -5}})
+	assert.NotNil(err)
+}
+
+func TestCanaryRouted(t *testing.T) {
+	t.Run("Random", func(t *testing.T) {
+		assert := assert.New(t)
+		canary, _ := NewCanary(CanaryOptions{TargetURL: "http://canary:6000", CanarySplit: CanarySplit{Percentage: 25}})
+
+		canary.sample = func() float64 { return 0.2 }
+		assert.True(canary.routed("mac:112233445566"))
+
+		canary.sample = func() float64 { return 0.3 }
+		assert.False(canary.routed("mac:112233445566"))
+	})
+
+	t.Run("DeviceHash", func(t *testing.T) {
+		assert := assert.New(t)
+		canary, _ := NewCanary(CanaryOptions{TargetURL: "http://canary:6000", CanarySplit: CanarySplit{Percentage: 50, DeviceHash: true}})
+
+		var routed int
+		for i := 0; i < 1000; i++ {
+			deviceID := "mac:" + string([]byte{byte('a' + i%26), byte('a' + i/26%26), byte('a' + i/676)})
+			if canary.routed(deviceID) {
+				routed++
+			}
+
+			//sticky regardless of the form of the ID
+			assert.Equal(canary.routed(deviceID), canary.routed(deviceID))
+		}
+
+		assert.InDelta(500, routed, 100)
+		assert.Equal(canary.routed("mac:112233445566"), canary.routed("MAC:11:22:33:44:55:66"))
+
+		assert.Nil(canary.Update(CanarySplit{Percentage: 100, DeviceHash: true}))
+		assert.True(canary.routed("mac:112233445566"))
+
+		assert.Nil(canary.Update(CanarySplit{}))
+		assert.False(canary.routed("mac:112233445566"))
+	})
+}
+
+func TestCanaryTransactor(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		counter = &splitCounter{counts: make(map[string]float64)}
+		sent    *http.Request
+		next    = transactFunc(func(r *http.Request) (*XmidtResponse, error) {
+			sent = r
+			if r.Host == "canary:6000" {
+				return nil, errors.New("network")
+			}
+			return &XmidtResponse{Code: http.StatusOK}, nil
+		})
+	)
+
+	canary, _ := NewCanary(CanaryOptions{TargetURL: "http://canary:6000", CanarySplit: CanarySplit{Percentage: 50}})
+	transactor := NewCanaryTransactor(next, canary, "scytale:6000", &Measures{CanaryRequests: counter})
+
+	newRequest := func() *http.Request {
+		r, _ := http.NewRequest(http.MethodGet, "scytale:6000/api/v2/device/mac:112233445566/stat", nil)
+		return r.WithContext(context.WithValue(context.Background(), ContextKeyRequestDeviceID, "mac:112233445566"))
+	}
+
+	canary.sample = func() float64 { return 0.1 }
+	_, err := transactor.Transact(newRequest())
+	assert.NotNil(err)
+	assert.EqualValues("http://canary:6000/api/v2/device/mac:112233445566/stat", sent.URL.String())
+
+	canary.sample = func() float64 { return 0.9 }
+	_, err = transactor.Transact(newRequest())
+	assert.Nil(err)
+	assert.EqualValues("scytale:6000/api/v2/device/mac:112233445566/stat", sent.URL.String())
+
+	assert.EqualValues(map[string]float64{"canary/failure": 1, "primary/success": 1}, counter.counts)
+}
+
+func TestCanaryHandler(t *testing.T) {
+	canary, _ := NewCanary(CanaryOptions{TargetURL: "http://canary:6000", CanarySplit: CanarySplit{Percentage: 5}})
+	handler := CanaryHandler(canary)
+
+	t.Run("Get", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/canary", nil))
+		assert.JSONEq(t, `{"percentage": 5, "deviceHash": false}`, recorder.Body.String())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/admin/canary", bytes.NewBufferString(`{"percentage": 500}`)))
+		assert.EqualValues(http.StatusBadRequest, recorder.Code)
+		assert.EqualValues(5, canary.Split().Percentage)
+	})
+
+	t.Run("Put", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/admin/canary", bytes.NewBufferString(`{"percentage": 20, "deviceHash": true}`)))
+		assert.EqualValues(http.StatusOK, recorder.Code)
+		assert.EqualValues(CanarySplit{Percentage: 20, DeviceHash: true}, canary.Split())
+	})
+}
@@ -0,0 +1,120 @@
+package common
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/justinas/alice"
+)
+
+//Headers API consumers may send with the checksum of their request bodies for end-to-end integrity
+const (
+	//HeaderContentMD5 is the base64 encoded MD5 digest of the body (RFC 1864)
+	HeaderContentMD5 = "Content-MD5"
+
+	//HeaderChecksumSHA256 is the hex or base64 encoded SHA-256 digest of the body
+	HeaderChecksumSHA256 = "X-Checksum-SHA256"
+)
+
+//VerifyChecksum returns a middleware which verifies the bodies of requests against the checksums in their
+//Content-MD5 and X-Checksum-SHA256 headers before they are decoded. Mismatches are rejected with a 400
+//Requests without either header are let through. As the body is read whole to be verified, bodies larger than
+//maxBodySize bytes are rejected with a 413 (no limit if 0). It goes after the authentication of requests
+func VerifyChecksum(maxBodySize int) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				md5Checksum    = strings.TrimSpace(r.Header.Get(HeaderContentMD5))
+				sha256Checksum = strings.TrimSpace(r.Header.Get(HeaderChecksumSHA256))
+			)
+
+			if md5Checksum == "" && sha256Checksum == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, ok := readChecksummedBody(w, r, maxBodySize)
+			if !ok {
+				return
+			}
+
+			if md5Checksum != "" {
+				digest := md5.Sum(body)
+				if !checksumMatches(md5Checksum, digest[:], false) {
+					checksumError(w, http.StatusBadRequest, HeaderContentMD5+" does not match the request body", "checksum_mismatch")
+					return
+				}
+			}
+
+			if sha256Checksum != "" {
+				digest := sha256.Sum256(body)
+				if !checksumMatches(sha256Checksum, digest[:], true) {
+					checksumError(w, http.StatusBadRequest, HeaderChecksumSHA256+" does not match the request body", "checksum_mismatch")
+					return
+				}
+			}
+
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+//readChecksummedBody reads the body of r, up to maxBodySize bytes if set. The response is written if it fails
+func readChecksummedBody(w http.ResponseWriter, r *http.Request, maxBodySize int) ([]byte, bool) {
+	if r.Body == nil {
+		return nil, true
+	}
+
+	defer r.Body.Close()
+
+	in := r.Body
+	if maxBodySize > 0 {
+		in = http.MaxBytesReader(w, r.Body, int64(maxBodySize))
+	}
+
+	body, err := ioutil.ReadAll(in)
+	switch {
+	case err != nil && maxBodySize > 0 && len(body) >= maxBodySize:
+		checksumError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds the limit of %d bytes", maxBodySize), "body_too_large")
+		return nil, false
+
+	case err != nil:
+		checksumError(w, http.StatusBadRequest, "request body could not be read", "body_unreadable")
+		return nil, false
+	}
+
+	return body, true
+}
+
+//checksumMatches reports whether the encoded checksum is the given digest
+//Checksums are base64 encoded, or hex encoded if allowed
+func checksumMatches(checksum string, digest []byte, allowHex bool) bool {
+	if allowHex && len(checksum) == hex.EncodedLen(len(digest)) {
+		if decoded, err := hex.DecodeString(checksum); err == nil {
+			return subtle.ConstantTimeCompare(decoded, digest) == 1
+		}
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(checksum)
+	return err == nil && subtle.ConstantTimeCompare(decoded, digest) == 1
+}
+
+func checksumError(w http.ResponseWriter, status int, message, code string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": message,
+		"code":    code,
+	})
+}
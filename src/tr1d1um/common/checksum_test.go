@@ -0,0 +1,92 @@
+package common
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	const body = `{"parameters":[{"name":"Device.DeviceInfo.UpTime"}]}`
+
+	var received string
+	handler := VerifyChecksum(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		received = string(data)
+	}))
+
+	testCases := []struct {
+		name   string
+		header string
+		value  string
+		code   int
+	}{
+		{"None", "", "", http.StatusOK},
+		{"MD5", HeaderContentMD5, "4qdh6HwoKd2+sBArJj2d2Q==", http.StatusOK},
+		{"MD5Mismatch", HeaderContentMD5, "1B2M2Y8AsgTpgAmY7PhCfg==", http.StatusBadRequest},
+		{"MD5Malformed", HeaderContentMD5, "not base64", http.StatusBadRequest},
+		{"SHA256Hex", HeaderChecksumSHA256, "03e25bfa99ea29a2c6d0a40073783d8fc7c32fdbce4cb6f95e4526ce6e5cfacb", http.StatusOK},
+		{"SHA256Base64", HeaderChecksumSHA256, "A+Jb+pnqKaLG0KQAc3g9j8fDL9vOTLb5XkUmzm5c+ss=", http.StatusOK},
+		{"SHA256Mismatch", HeaderChecksumSHA256, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", http.StatusBadRequest},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert := assert.New(t)
+			received = ""
+
+			r := httptest.NewRequest(http.MethodPatch, "/", bytes.NewBufferString(body))
+			if testCase.header != "" {
+				r.Header.Set(testCase.header, testCase.value)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, r)
+
+			assert.EqualValues(testCase.code, recorder.Code)
+			if testCase.code == http.StatusOK {
+				assert.EqualValues(body, received)
+			} else {
+				assert.Empty(received)
+				assert.Contains(recorder.Body.String(), "checksum_mismatch")
+			}
+		})
+	}
+}
+
+func TestVerifyChecksumMaxBodySize(t *testing.T) {
+	const body = `{"parameters":[{"name":"Device.DeviceInfo.UpTime"}]}`
+
+	var called bool
+	handler := VerifyChecksum(len(body))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	t.Run("Within", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPatch, "/", bytes.NewBufferString(body))
+		r.Header.Set(HeaderContentMD5, "4qdh6HwoKd2+sBArJj2d2Q==")
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.True(t, called)
+	})
+
+	t.Run("Exceeded", func(t *testing.T) {
+		assert := assert.New(t)
+		called = false
+
+		r := httptest.NewRequest(http.MethodPatch, "/", bytes.NewBufferString(body+" "))
+		r.Header.Set(HeaderContentMD5, "4qdh6HwoKd2+sBArJj2d2Q==")
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+		assert.Equal(http.StatusRequestEntityTooLarge, recorder.Code)
+		assert.Contains(recorder.Body.String(), "body_too_large")
+		assert.False(called)
+	})
+}
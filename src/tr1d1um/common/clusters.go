@@ -0,0 +1,147 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+//HeaderXmidtCluster is the header through which trusted API consumers select the XMiDT cluster of their request
+const HeaderXmidtCluster = "X-Xmidt-Cluster"
+
+//CapabilitiesClaim is the JWT claim holding the capabilities of API consumers
+const CapabilitiesClaim = "capabilities"
+
+//Cluster is an alternate XMiDT cluster API consumers may send their requests to (i.e. a staging environment)
+type Cluster struct {
+	//Name is the value of HeaderXmidtCluster which selects the cluster
+	Name string
+
+	//TargetURL is the base URL of the XMiDT API of the cluster. It has the same format as the default targetURL
+	TargetURL string
+
+	//Authorization is the Authorization header value used against the XMiDT API of the cluster (optional)
+	//If empty, the one of the API consumer is forwarded
+	Authorization string
+}
+
+//ClusterOptions lets API consumers holding a capability target an alternate XMiDT cluster per request
+type ClusterOptions struct {
+	//Capability is the capability API consumers need to select a cluster. Selecting one is disabled if empty
+	Capability string
+
+	//Clusters are the clusters which may be selected
+	Clusters []Cluster
+}
+
+//Validate returns an error if the clusters are misconfigured
+func (o ClusterOptions) Validate() error {
+	var names = make(map[string]bool, len(o.Clusters))
+
+	for _, cluster := range o.Clusters {
+		if cluster.Name == "" {
+			return errors.New("clusters must have a name")
+		}
+
+		if names[strings.ToLower(cluster.Name)] {
+			return fmt.Errorf("cluster '%s' is configured more than once", cluster.Name)
+		}
+		names[strings.ToLower(cluster.Name)] = true
+
+		if _, err := url.Parse(cluster.TargetURL); err != nil || cluster.TargetURL == "" {
+			return fmt.Errorf("cluster '%s' has an invalid targetURL '%s'", cluster.Name, cluster.TargetURL)
+		}
+	}
+
+	return nil
+}
+
+//clusterError is the CodedError returned when a request can't be sent to the cluster it selects
+type clusterError struct {
+	message string
+	status  int
+	code    string
+}
+
+func (c *clusterError) Error() string {
+	return c.message
+}
+
+func (c *clusterError) StatusCode() int {
+	return c.status
+}
+
+func (c *clusterError) ErrorCode() string {
+	return c.code
+}
+
+//Cluster selection errors
+var (
+	ErrClusterForbidden = &clusterError{message: "not allowed to select the XMiDT cluster", status: http.StatusForbidden, code: "cluster_forbidden"}
+	ErrUnknownCluster   = &clusterError{message: "unknown XMiDT cluster", status: http.StatusBadRequest, code: "unknown_cluster"}
+)
+
+//NewClusterTransactor decorates the given transactor so that requests to the default target are sent to the cluster
+//selected through HeaderXmidtCluster instead, provided the API consumer holds the required capability
+//defaultTarget is the base URL outgoing requests are built with
+func NewClusterTransactor(next Tr1d1umTransactor, o ClusterOptions, defaultTarget string) Tr1d1umTransactor {
+	var clusters = make(map[string]Cluster, len(o.Clusters))
+	for _, cluster := range o.Clusters {
+		clusters[strings.ToLower(cluster.Name)] = cluster
+	}
+
+	return &clusterTransactor{
+		next:          next,
+		capability:    o.Capability,
+		clusters:      clusters,
+		defaultTarget: defaultTarget,
+	}
+}
+
+type clusterTransactor struct {
+	next          Tr1d1umTransactor
+	capability    string
+	clusters      map[string]Cluster
+	defaultTarget string
+}
+
+func (t *clusterTransactor) Transact(req *http.Request) (*XmidtResponse, error) {
+	inboundHeaders := RequestHeaders(req.Context())
+
+	var name = strings.TrimSpace(inboundHeaders.Get(HeaderXmidtCluster))
+	if name == "" || !targets(req, t.defaultTarget) {
+		return t.next.Transact(req)
+	}
+
+	if t.capability == "" || !t.capable(req) {
+		return nil, ErrClusterForbidden
+	}
+
+	cluster, ok := t.clusters[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrUnknownCluster
+	}
+
+	if err := retarget(req, t.defaultTarget, cluster.TargetURL); err != nil {
+		return nil, err
+	}
+
+	if cluster.Authorization != "" {
+		req.Header.Set("Authorization", cluster.Authorization)
+	}
+
+	return t.next.Transact(req)
+}
+
+//capable returns true if the API consumer holds the capability required to select a cluster
+func (t *clusterTransactor) capable(req *http.Request) bool {
+	for _, capability := range Claim(req.Context(), CapabilitiesClaim) {
+		if capability == t.capability {
+			return true
+		}
+	}
+
+	return false
+}
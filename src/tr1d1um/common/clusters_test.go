@@ -0,0 +1,70 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Comcast/comcast-bascule/bascule"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterOptionsValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(ClusterOptions{}.Validate())
+	assert.Nil(ClusterOptions{Clusters: []Cluster{{Name: "staging", TargetURL: "http://staging:6000"}}}.Validate())
+	assert.NotNil(ClusterOptions{Clusters: []Cluster{{TargetURL: "http://staging:6000"}}}.Validate())
+	assert.NotNil(ClusterOptions{Clusters: []Cluster{{Name: "staging"}}}.Validate())
+	assert.NotNil(ClusterOptions{Clusters: []Cluster{{Name: "staging", TargetURL: "http://staging:6000"}, {Name: "Staging", TargetURL: "http://lab:6000"}}}.Validate())
+}
+
+func TestClusterTransactor(t *testing.T) {
+	var sent *http.Request
+	transactor := NewClusterTransactor(transactFunc(func(r *http.Request) (*XmidtResponse, error) {
+		sent = r
+		return &XmidtResponse{}, nil
+	}), ClusterOptions{
+		Capability: "x1:webpa:api:cluster:all",
+		Clusters:   []Cluster{{Name: "staging", TargetURL: "http://staging:6000", Authorization: "Basic c3RhZ2luZw=="}},
+	}, "scytale:6000")
+
+	newRequest := func(cluster string, capabilities ...interface{}) *http.Request {
+		r, _ := http.NewRequest(http.MethodPost, "scytale:6000/api/v2/device", nil)
+		r.Header.Set("Authorization", "Bearer consumer")
+
+		ctx := context.WithValue(context.Background(), ContextKeyRequestHeaders, http.Header{HeaderXmidtCluster: []string{cluster}})
+		ctx = bascule.WithAuthentication(ctx, bascule.Authentication{
+			Token: bascule.NewToken("jwt", "engineer", bascule.Attributes{CapabilitiesClaim: capabilities}),
+		})
+
+		return r.WithContext(ctx)
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		assert := assert.New(t)
+		_, err := transactor.Transact(newRequest(""))
+		assert.Nil(err)
+		assert.EqualValues("scytale:6000/api/v2/device", sent.URL.String())
+	})
+
+	t.Run("Selected", func(t *testing.T) {
+		assert := assert.New(t)
+		_, err := transactor.Transact(newRequest("Staging", "x1:webpa:api:cluster:all"))
+		assert.Nil(err)
+		assert.EqualValues("http://staging:6000/api/v2/device", sent.URL.String())
+		assert.EqualValues("Basic c3RhZ2luZw==", sent.Header.Get("Authorization"))
+	})
+
+	t.Run("Forbidden", func(t *testing.T) {
+		assert := assert.New(t)
+		_, err := transactor.Transact(newRequest("staging", "x1:webpa:api:device:all"))
+		assert.Equal(ErrClusterForbidden, err)
+		assert.EqualValues(http.StatusForbidden, err.(CodedError).StatusCode())
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := transactor.Transact(newRequest("production", "x1:webpa:api:cluster:all"))
+		assert.Equal(t, ErrUnknownCluster, err)
+	})
+}
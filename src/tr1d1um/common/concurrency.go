@@ -0,0 +1,287 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/justinas/alice"
+)
+
+//Concurrency limiting defaults
+const (
+	DefaultConcurrencyQueueTimeout = 10 * time.Second
+	DefaultConcurrencyQueueFactor  = 10
+)
+
+//TenantWeight is the share of the concurrency a tenant gets while requests queue, relative to the other tenants
+type TenantWeight struct {
+	//Key is the API key or partner ID of the tenant
+	Key string
+
+	//Weight is the number of queued requests of the tenant served in a row. Defaults to 1
+	Weight int
+}
+
+//ConcurrencyOptions bounds the number of requests to the services being served at a time
+type ConcurrencyOptions struct {
+	//MaxConcurrent is the number of requests served at a time. Limiting is disabled if not positive
+	MaxConcurrent int
+
+	//MaxQueued bounds the number of requests waiting for their turn. Defaults to DefaultConcurrencyQueueFactor times MaxConcurrent
+	MaxQueued int
+
+	//QueueTimeout is the longest requests wait for their turn. Defaults to DefaultConcurrencyQueueTimeout
+	QueueTimeout time.Duration
+
+	//Weights are the weights of specific tenants. Tenants without one have a weight of 1
+	Weights []TenantWeight
+}
+
+//Validate returns an error if the concurrency limits are misconfigured
+func (o ConcurrencyOptions) Validate() error {
+	var keys = make(map[string]bool, len(o.Weights))
+
+	for _, weight := range o.Weights {
+		switch {
+		case weight.Key == "":
+			return errors.New("tenant weights must have a key")
+		case weight.Weight < 0:
+			return fmt.Errorf("tenant '%s' has a negative weight", weight.Key)
+		case keys[weight.Key]:
+			return fmt.Errorf("tenant '%s' is weighted more than once", weight.Key)
+		}
+
+		keys[weight.Key] = true
+	}
+
+	return nil
+}
+
+//tenantQueue holds the requests of a tenant waiting for their turn, in order of arrival
+type tenantQueue struct {
+	key     string
+	weight  int
+	waiters []chan struct{}
+}
+
+//ConcurrencyLimiter bounds the number of requests being served at a time. Requests beyond the limit are queued
+//by tenant and tenants are served in weighted round-robin, so that a burst from one tenant doesn't add latency
+//to the requests of the others
+type ConcurrencyLimiter struct {
+	options ConcurrencyOptions
+	weights map[string]int
+
+	lock   sync.Mutex
+	active int
+	queued int
+	queues map[string]*tenantQueue
+
+	//ring holds the tenants with queued requests in the order they take turns. The tenant at cursor is the one
+	//being served and has had served requests handed a slot in a row
+	ring   []*tenantQueue
+	cursor int
+	served int
+}
+
+//NewConcurrencyLimiter builds the limiter out of the given options. It returns nil if limiting is disabled
+func NewConcurrencyLimiter(o ConcurrencyOptions) (*ConcurrencyLimiter, error) {
+	if o.MaxConcurrent < 1 {
+		return nil, nil
+	}
+
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	if o.MaxQueued < 1 {
+		o.MaxQueued = DefaultConcurrencyQueueFactor * o.MaxConcurrent
+	}
+
+	if o.QueueTimeout <= 0 {
+		o.QueueTimeout = DefaultConcurrencyQueueTimeout
+	}
+
+	var weights = make(map[string]int, len(o.Weights))
+	for _, weight := range o.Weights {
+		if weight.Weight > 0 {
+			weights[weight.Key] = weight.Weight
+		}
+	}
+
+	return &ConcurrencyLimiter{
+		options: o,
+		weights: weights,
+		queues:  make(map[string]*tenantQueue),
+	}, nil
+}
+
+//acquire takes a slot for the request of the given tenant right away if one is free and no request is waiting.
+//Otherwise, it returns the channel the slot is handed over through once it is the turn of the request
+//ok is false if the queue is full
+func (c *ConcurrencyLimiter) acquire(tenant string) (turn chan struct{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.active < c.options.MaxConcurrent && c.queued == 0 {
+		c.active++
+		return nil, true
+	}
+
+	if c.queued >= c.options.MaxQueued {
+		return nil, false
+	}
+
+	queue, exists := c.queues[tenant]
+	if !exists {
+		queue = &tenantQueue{key: tenant, weight: 1}
+		if weight, ok := c.weights[tenant]; ok {
+			queue.weight = weight
+		}
+
+		c.queues[tenant] = queue
+	}
+
+	if len(queue.waiters) == 0 {
+		c.ring = append(c.ring, queue)
+	}
+
+	turn = make(chan struct{}, 1)
+	queue.waiters = append(queue.waiters, turn)
+	c.queued++
+	return turn, true
+}
+
+//release hands the slot of a request that is done over to the next queued request, if any
+func (c *ConcurrencyLimiter) release() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.queued == 0 {
+		c.active--
+		return
+	}
+
+	var queue = c.ring[c.cursor]
+	queue.waiters[0] <- struct{}{}
+	queue.waiters = queue.waiters[1:]
+	c.queued--
+	c.served++
+
+	switch {
+	case len(queue.waiters) == 0:
+		c.remove(c.cursor)
+	case c.served >= queue.weight:
+		c.cursor, c.served = (c.cursor+1)%len(c.ring), 0
+	}
+}
+
+//abandon removes the given request from the queue of its tenant once it stopped waiting for its turn
+//It returns false if the request was handed a slot in the meantime
+func (c *ConcurrencyLimiter) abandon(tenant string, turn chan struct{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	queue, ok := c.queues[tenant]
+	if !ok {
+		return false
+	}
+
+	for i, waiter := range queue.waiters {
+		if waiter != turn {
+			continue
+		}
+
+		queue.waiters = append(queue.waiters[:i], queue.waiters[i+1:]...)
+		c.queued--
+
+		if len(queue.waiters) == 0 {
+			for position, q := range c.ring {
+				if q == queue {
+					c.remove(position)
+					break
+				}
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+//remove takes the tenant at the given position out of the ring, along with its queue
+func (c *ConcurrencyLimiter) remove(position int) {
+	delete(c.queues, c.ring[position].key)
+	c.ring = append(c.ring[:position], c.ring[position+1:]...)
+
+	switch {
+	case position < c.cursor:
+		c.cursor--
+	case position == c.cursor:
+		c.served = 0
+	}
+
+	if c.cursor >= len(c.ring) {
+		c.cursor = 0
+	}
+}
+
+//Limiter returns the middleware that makes requests wait for their turn once MaxConcurrent of them are being served
+//Requests are rejected with a 503 if the queue is full or their turn doesn't come within QueueTimeout
+func (c *ConcurrencyLimiter) Limiter() alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if c == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var tenant = apiKey(r)
+
+			turn, ok := c.acquire(tenant)
+			if !ok {
+				c.reject(w, "too many requests are queued", "queue_full")
+				return
+			}
+
+			if turn != nil {
+				var timer = time.NewTimer(c.options.QueueTimeout)
+
+				select {
+				case <-turn:
+					timer.Stop()
+				case <-timer.C:
+					if c.abandon(tenant, turn) {
+						c.reject(w, "request timed out waiting for its turn", "queue_timeout")
+						return
+					}
+				case <-r.Context().Done():
+					timer.Stop()
+					if c.abandon(tenant, turn) {
+						return
+					}
+				}
+			}
+
+			defer c.release()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (c *ConcurrencyLimiter) reject(w http.ResponseWriter, message, code string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(c.options.QueueTimeout.Seconds()))))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": message,
+		"code":    code,
+	})
+}
@@ -0,0 +1,136 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/comcast-bascule/bascule"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewConcurrencyLimiter(t *testing.T) {
+	assert := assert.New(t)
+
+	limiter, err := NewConcurrencyLimiter(ConcurrencyOptions{})
+	assert.Nil(err)
+	assert.Nil(limiter)
+
+	_, err = NewConcurrencyLimiter(ConcurrencyOptions{MaxConcurrent: 1, Weights: []TenantWeight{{Key: "comcast"}, {Key: "comcast"}}})
+	assert.NotNil(err)
+
+	_, err = NewConcurrencyLimiter(ConcurrencyOptions{MaxConcurrent: 1, Weights: []TenantWeight{{Weight: 2}}})
+	assert.NotNil(err)
+}
+
+//awaitRequests waits until the given numbers of requests are being served and waiting for their turn
+func awaitRequests(c *ConcurrencyLimiter, active, queued int) {
+	for {
+		c.lock.Lock()
+		reached := c.active == active && c.queued == queued
+		c.lock.Unlock()
+
+		if reached {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConcurrencyLimiterFairness(t *testing.T) {
+	assert := assert.New(t)
+
+	limiter, err := NewConcurrencyLimiter(ConcurrencyOptions{MaxConcurrent: 1, QueueTimeout: time.Minute})
+	assert.Nil(err)
+
+	var (
+		lock    sync.Mutex
+		served  []string
+		proceed = make(chan struct{})
+		done    sync.WaitGroup
+
+		handler = limiter.Limiter()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			served = append(served, r.Header.Get(HeaderXmidtPartnerID)+r.URL.Path)
+			lock.Unlock()
+			<-proceed
+		}))
+	)
+
+	//a burst from comcast queues ahead of the request of sky, which should still not wait for the whole burst
+	for i, request := range []struct{ partner, path string }{{"comcast", "/1"}, {"comcast", "/2"}, {"comcast", "/3"}, {"sky", "/1"}} {
+		r := httptest.NewRequest(http.MethodGet, request.path, nil)
+		r.Header.Set(HeaderXmidtPartnerID, request.partner)
+		r = r.WithContext(bascule.WithAuthentication(r.Context(), bascule.Authentication{
+			Token: bascule.NewToken("jwt", "client", bascule.Attributes{DefaultPartnerClaim: request.partner}),
+		}))
+
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+		}()
+
+		awaitRequests(limiter, 1, i)
+	}
+
+	for i := 0; i < 4; i++ {
+		proceed <- struct{}{}
+	}
+
+	done.Wait()
+	assert.Equal([]string{"comcast/1", "comcast/2", "sky/1", "comcast/3"}, served)
+	awaitRequests(limiter, 0, 0)
+}
+
+func TestConcurrencyLimiterRejection(t *testing.T) {
+	var (
+		proceed = make(chan struct{})
+		handler = func(limiter *ConcurrencyLimiter) http.Handler {
+			return limiter.Limiter()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-proceed
+			}))
+		}
+	)
+
+	t.Run("QueueFull", func(t *testing.T) {
+		assert := assert.New(t)
+		limiter, _ := NewConcurrencyLimiter(ConcurrencyOptions{MaxConcurrent: 1, MaxQueued: 1, QueueTimeout: time.Minute})
+		h := handler(limiter)
+
+		go h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		awaitRequests(limiter, 1, 0)
+		go h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		awaitRequests(limiter, 1, 1)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(http.StatusServiceUnavailable, w.Code)
+		assert.Contains(w.Body.String(), "queue_full")
+
+		proceed <- struct{}{}
+		proceed <- struct{}{}
+		awaitRequests(limiter, 0, 0)
+	})
+
+	t.Run("QueueTimeout", func(t *testing.T) {
+		assert := assert.New(t)
+		limiter, _ := NewConcurrencyLimiter(ConcurrencyOptions{MaxConcurrent: 1, QueueTimeout: 10 * time.Millisecond})
+		h := handler(limiter)
+
+		go h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		awaitRequests(limiter, 1, 0)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(http.StatusServiceUnavailable, w.Code)
+		assert.Contains(w.Body.String(), "queue_timeout")
+		assert.Equal("1", w.Header().Get("Retry-After"))
+
+		proceed <- struct{}{}
+		awaitRequests(limiter, 0, 0)
+	})
+}
@@ -1,9 +1,110 @@
 package common
 
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Comcast/comcast-bascule/bascule"
+)
+
 type contextKey int
 
 //Keys to important context values on incoming requests to TR1D1UM
+//Prefer the accessors below to reading them directly: they don't panic when a value is missing, i.e. because the
+//middleware which sets it didn't run (yet)
 const (
 	ContextKeyRequestArrivalTime contextKey = iota
 	ContextKeyRequestTID
+
+	//ContextKeyRequestHeaders holds the headers of the incoming request so they can be
+	//selectively forwarded to the XMiDT API
+	ContextKeyRequestHeaders
+
+	//ContextKeyRequestMethod holds the HTTP method of the incoming request
+	ContextKeyRequestMethod
+
+	//ContextKeyRequestDeviceID holds the ID of the device the incoming request targets, if any
+	ContextKeyRequestDeviceID
+
+	//ContextKeyRequestLane holds the lane (see Lanes) of the incoming request
+	ContextKeyRequestLane
+
+	//ContextKeyOutboundCalls holds the record of the calls to the XMiDT API made for the incoming request
+	ContextKeyOutboundCalls
+
+	//ContextKeySettings holds the Settings of the server handling the incoming request
+	ContextKeySettings
 )
+
+//RequestTID returns the transaction ID of the incoming request, empty if none was assigned
+func RequestTID(ctx context.Context) string {
+	tid, _ := ctx.Value(ContextKeyRequestTID).(string)
+	return tid
+}
+
+//RequestArrivalTime returns the time the incoming request arrived at, if it was recorded
+func RequestArrivalTime(ctx context.Context) (time.Time, bool) {
+	arrivalTime, ok := ctx.Value(ContextKeyRequestArrivalTime).(time.Time)
+	return arrivalTime, ok
+}
+
+//RequestHeaders returns the headers of the incoming request, nil if they weren't recorded
+//Reading from a nil http.Header is safe so callers needn't check
+func RequestHeaders(ctx context.Context) http.Header {
+	headers, _ := ctx.Value(ContextKeyRequestHeaders).(http.Header)
+	return headers
+}
+
+//RequestMethod returns the HTTP method of the incoming request, empty if it wasn't recorded
+func RequestMethod(ctx context.Context) string {
+	method, _ := ctx.Value(ContextKeyRequestMethod).(string)
+	return method
+}
+
+//RequestDeviceID returns the ID of the device the incoming request targets, empty if none
+func RequestDeviceID(ctx context.Context) string {
+	deviceID, _ := ctx.Value(ContextKeyRequestDeviceID).(string)
+	return deviceID
+}
+
+//RequestPrincipal returns the identity the incoming request was authenticated as, empty if it wasn't
+func RequestPrincipal(ctx context.Context) string {
+	if auth, ok := bascule.FromContext(ctx); ok && auth.Token != nil {
+		return auth.Token.Principal()
+	}
+
+	return ""
+}
+
+//RequestPartner returns the partner ID the incoming request was made on behalf of, empty if none
+//It is the one granted by the token of the API consumer or, only if trusted (see PartnerOptions), the one of
+//the HeaderXmidtPartnerID header
+func RequestPartner(ctx context.Context) string {
+	if partner := AuthenticatedPartner(ctx); partner != "" {
+		return partner
+	}
+
+	if settings(ctx).partners.TrustHeader {
+		return RequestHeaders(ctx).Get(HeaderXmidtPartnerID)
+	}
+
+	return ""
+}
+
+//RequestFailedCall returns the last call to the XMiDT API made for the incoming request if it failed
+func RequestFailedCall(ctx context.Context) (OutboundCall, bool) {
+	o, ok := ctx.Value(ContextKeyOutboundCalls).(*outboundCalls)
+	if !ok {
+		return OutboundCall{}, false
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if !o.failed {
+		return OutboundCall{}, false
+	}
+
+	return OutboundCall{TID: RequestTID(ctx), Target: o.target, Attempts: o.attempts}, true
+}
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Comcast/comcast-bascule/bascule"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestContextAccessorsMissingValues(t *testing.T) {
+	assert := assert.New(t)
+
+	//none of the accessors should panic when the middleware which sets their values didn't run
+	var ctx = context.Background()
+	assert.Empty(RequestTID(ctx))
+	assert.Empty(RequestMethod(ctx))
+	assert.Empty(RequestDeviceID(ctx))
+	assert.Empty(RequestPrincipal(ctx))
+	assert.Empty(RequestPartner(ctx))
+	assert.Nil(RequestHeaders(ctx))
+
+	_, ok := RequestArrivalTime(ctx)
+	assert.False(ok)
+
+	//values of the wrong type are as good as missing
+	ctx = context.WithValue(ctx, ContextKeyRequestTID, 42)
+	assert.Empty(RequestTID(ctx))
+}
+
+func TestRequestContextAccessors(t *testing.T) {
+	var (
+		assert      = assert.New(t)
+		arrivalTime = time.Now()
+		headers     = http.Header{HeaderXmidtPartnerID: []string{"comcast"}}
+		ctx         = context.Background()
+	)
+
+	ctx = context.WithValue(ctx, ContextKeyRequestTID, "tid")
+	ctx = context.WithValue(ctx, ContextKeyRequestArrivalTime, arrivalTime)
+	ctx = context.WithValue(ctx, ContextKeyRequestHeaders, headers)
+	ctx = context.WithValue(ctx, ContextKeyRequestMethod, http.MethodGet)
+	ctx = context.WithValue(ctx, ContextKeyRequestDeviceID, "mac:112233445566")
+	ctx = bascule.WithAuthentication(ctx, bascule.Authentication{Token: bascule.NewToken("jwt", "principal", bascule.Attributes{DefaultPartnerClaim: "comcast"})})
+
+	assert.Equal("tid", RequestTID(ctx))
+	assert.Equal(headers, RequestHeaders(ctx))
+	assert.Equal(http.MethodGet, RequestMethod(ctx))
+	assert.Equal("mac:112233445566", RequestDeviceID(ctx))
+	assert.Equal("principal", RequestPrincipal(ctx))
+	assert.Equal("comcast", RequestPartner(ctx))
+
+	actualArrivalTime, ok := RequestArrivalTime(ctx)
+	assert.True(ok)
+	assert.Equal(arrivalTime, actualArrivalTime)
+}
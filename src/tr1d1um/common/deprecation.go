@@ -0,0 +1,147 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/gorilla/mux"
+)
+
+//Headers signalling the deprecation of routes to API consumers
+const (
+	//HeaderDeprecation is set to the date at which a route was deprecated, or "true" if there isn't one
+	HeaderDeprecation = "Deprecation"
+
+	//HeaderSunset is the date after which a route is expected to be removed (RFC 8594)
+	HeaderSunset = "Sunset"
+)
+
+//Deprecation describes a route slated for removal
+type Deprecation struct {
+	//Route is the path template of the deprecated route, as registered (i.e. "/api/v2/device/{deviceid}/{service:iot}")
+	Route string
+
+	//Methods restricts the deprecation to some methods of the route. All of them are deprecated if empty
+	Methods []string
+
+	//Date is the RFC 3339 date or time at which the route was deprecated (optional)
+	Date string
+
+	//Sunset is the RFC 3339 date or time after which the route is expected to be removed (optional)
+	Sunset string
+
+	//Link points API consumers to the documentation of the deprecation, i.e. its replacement (optional)
+	Link string
+}
+
+//deprecatedRoute is a Deprecation with its headers ready to be sent
+type deprecatedRoute struct {
+	methods     map[string]bool
+	deprecation string
+	sunset      string
+	link        string
+}
+
+//Deprecations signals to API consumers which routes are deprecated and measures how much they are still used
+type Deprecations struct {
+	routes  map[string][]deprecatedRoute
+	counter metrics.Counter
+}
+
+//NewDeprecations builds the deprecation signalling of the given routes. Requests to them are counted by the
+//given counter (optional) by route and method
+func NewDeprecations(deprecations []Deprecation, counter metrics.Counter) (*Deprecations, error) {
+	d := &Deprecations{
+		routes:  make(map[string][]deprecatedRoute, len(deprecations)),
+		counter: counter,
+	}
+
+	for _, deprecation := range deprecations {
+		if deprecation.Route == "" {
+			return nil, fmt.Errorf("deprecations must have a route")
+		}
+
+		route := deprecatedRoute{
+			methods:     make(map[string]bool, len(deprecation.Methods)),
+			deprecation: "true",
+			link:        deprecation.Link,
+		}
+
+		for _, method := range deprecation.Methods {
+			route.methods[strings.ToUpper(method)] = true
+		}
+
+		if deprecation.Date != "" {
+			date, err := parseDeprecationDate(deprecation.Date)
+			if err != nil {
+				return nil, fmt.Errorf("invalid deprecation date of %s: %s", deprecation.Route, err)
+			}
+			route.deprecation = date
+		}
+
+		if deprecation.Sunset != "" {
+			sunset, err := parseDeprecationDate(deprecation.Sunset)
+			if err != nil {
+				return nil, fmt.Errorf("invalid sunset of %s: %s", deprecation.Route, err)
+			}
+			route.sunset = sunset
+		}
+
+		d.routes[deprecation.Route] = append(d.routes[deprecation.Route], route)
+	}
+
+	return d, nil
+}
+
+//parseDeprecationDate turns the given RFC 3339 date or time into an HTTP date
+func parseDeprecationDate(value string) (string, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		if t, err = time.Parse("2006-01-02", value); err != nil {
+			return "", err
+		}
+	}
+
+	return t.UTC().Format(http.TimeFormat), nil
+}
+
+//Track is a mux middleware which adds the deprecation headers to the responses of deprecated routes and counts
+//their requests
+func (d *Deprecations) Track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d == nil || len(d.routes) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var template string
+		if current := mux.CurrentRoute(r); current != nil {
+			template, _ = current.GetPathTemplate()
+		}
+
+		for _, route := range d.routes[template] {
+			if len(route.methods) > 0 && !route.methods[r.Method] {
+				continue
+			}
+
+			w.Header().Set(HeaderDeprecation, route.deprecation)
+			if route.sunset != "" {
+				w.Header().Set(HeaderSunset, route.sunset)
+			}
+
+			if route.link != "" {
+				w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="deprecation"`, route.link))
+			}
+
+			if d.counter != nil {
+				d.counter.With(RouteLabel, template, MethodLabel, r.Method).Add(1)
+			}
+			break
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
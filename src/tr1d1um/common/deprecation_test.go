@@ -0,0 +1,53 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecations(t *testing.T) {
+	_, err := NewDeprecations([]Deprecation{{Route: "/device/{deviceid}/iot", Sunset: "soon"}}, nil)
+	assert.NotNil(t, err)
+
+	deprecations, err := NewDeprecations([]Deprecation{
+		{
+			Route:   "/device/{deviceid}/{service:iot}",
+			Methods: []string{"post"},
+			Date:    "2019-06-01",
+			Sunset:  "2019-12-31T00:00:00Z",
+			Link:    "https://example.com/deprecations/iot",
+		},
+	}, nil)
+	assert.Nil(t, err)
+
+	router := mux.NewRouter()
+	router.Use(deprecations.Track)
+	router.Handle("/device/{deviceid}/{service:iot}", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	router.Handle("/device/{deviceid}/{service}", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+
+	t.Run("Deprecated", func(t *testing.T) {
+		assert := assert.New(t)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/device/mac:112233445566/iot", nil))
+
+		assert.EqualValues("Sat, 01 Jun 2019 00:00:00 GMT", w.Header().Get(HeaderDeprecation))
+		assert.EqualValues("Tue, 31 Dec 2019 00:00:00 GMT", w.Header().Get(HeaderSunset))
+		assert.EqualValues(`<https://example.com/deprecations/iot>; rel="deprecation"`, w.Header().Get("Link"))
+	})
+
+	t.Run("OtherMethod", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/device/mac:112233445566/iot", nil))
+		assert.Empty(t, w.Header().Get(HeaderDeprecation))
+	})
+
+	t.Run("OtherRoute", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/device/mac:112233445566/config", nil))
+		assert.Empty(t, w.Header().Get(HeaderDeprecation))
+	})
+}
@@ -0,0 +1,192 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+//Address families backend connections may prefer
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+//DialerOptions tune how connections to the XMiDT API are dialed when its hosts resolve to both IPv4 and IPv6 addresses
+type DialerOptions struct {
+	//DualStack enables happy eyeballs (RFC 6555): if the preferred addresses don't connect within FallbackDelay,
+	//the other family is dialed in parallel. Otherwise the addresses are dialed one after the other
+	DualStack bool
+
+	//FallbackDelay is how long the preferred family gets before the other one is dialed. Defaults to 300ms
+	FallbackDelay time.Duration
+
+	//PreferredFamily is dialed first: ipv4 or ipv6. Defaults to the family of the first address the resolver returns
+	PreferredFamily string
+}
+
+//Validate returns an error if the preferred family is unknown
+func (o DialerOptions) Validate() error {
+	switch o.PreferredFamily {
+	case "", FamilyIPv4, FamilyIPv6:
+		return nil
+	}
+
+	return fmt.Errorf("preferred address family '%s' should be %s or %s", o.PreferredFamily, FamilyIPv4, FamilyIPv6)
+}
+
+//DialContext returns the function which dials backend connections, meant for http.Transport
+//Hosts are resolved with the given lookup (i.e. a DNS cache) or the default resolver if nil
+func (o DialerOptions) DialContext(timeout time.Duration, lookup LookupFunc) (func(context.Context, string, string) (net.Conn, error), error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	d := &dialer{
+		o:      o,
+		net:    &net.Dialer{Timeout: timeout, FallbackDelay: o.FallbackDelay},
+		lookup: lookup,
+	}
+
+	if !o.DualStack {
+		d.net.FallbackDelay = -1
+	}
+
+	if d.o.FallbackDelay <= 0 {
+		d.o.FallbackDelay = 300 * time.Millisecond
+	}
+
+	if lookup == nil {
+		if o.PreferredFamily == "" {
+			return d.net.DialContext, nil
+		}
+
+		d.lookup = net.DefaultResolver.LookupIPAddr
+	}
+
+	return d.dial, nil
+}
+
+type dialer struct {
+	o      DialerOptions
+	net    *net.Dialer
+	lookup LookupFunc
+}
+
+type dialResult struct {
+	conn      net.Conn
+	err       error
+	preferred bool
+}
+
+//dial resolves the host of the address itself so that the preferred family is dialed first
+func (d *dialer) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || network != "tcp" || net.ParseIP(host) != nil {
+		return d.net.DialContext(ctx, network, address)
+	}
+
+	ips, err := d.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var family = d.o.PreferredFamily
+	if family == "" && len(ips) > 0 {
+		family = FamilyIPv6
+		if ips[0].IP.To4() != nil {
+			family = FamilyIPv4
+		}
+	}
+
+	var preferred, others []string
+	for _, ip := range ips {
+		if (ip.IP.To4() != nil) == (family == FamilyIPv4) {
+			preferred = append(preferred, net.JoinHostPort(ip.String(), port))
+		} else {
+			others = append(others, net.JoinHostPort(ip.String(), port))
+		}
+	}
+
+	if !d.o.DualStack || len(preferred) == 0 || len(others) == 0 {
+		return d.dialSerial(ctx, append(preferred, others...))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		results  = make(chan dialResult, 2)
+		fallback = time.NewTimer(d.o.FallbackDelay)
+		pending  = 1
+		firstErr error
+	)
+
+	defer fallback.Stop()
+
+	start := func(addresses []string, preferred bool) {
+		go func() {
+			conn, err := d.dialSerial(ctx, addresses)
+			results <- dialResult{conn: conn, err: err, preferred: preferred}
+		}()
+	}
+
+	start(preferred, true)
+
+	for {
+		select {
+		case <-fallback.C:
+			if others != nil {
+				pending++
+				start(others, false)
+				others = nil
+			}
+
+		case result := <-results:
+			pending--
+
+			if result.err == nil {
+				//the connection of the slower family, if any, is closed once it completes
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}
+				}(pending)
+
+				return result.conn, nil
+			}
+
+			if firstErr == nil || result.preferred {
+				firstErr = result.err
+			}
+
+			if others != nil {
+				pending++
+				start(others, false)
+				others = nil
+			} else if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+//dialSerial dials the addresses in order until one connects
+func (d *dialer) dialSerial(ctx context.Context, addresses []string) (net.Conn, error) {
+	var err error
+	for _, address := range addresses {
+		var conn net.Conn
+		if conn, err = d.net.DialContext(ctx, "tcp", address); err == nil {
+			return conn, nil
+		}
+	}
+
+	if err == nil {
+		err = fmt.Errorf("no addresses to dial")
+	}
+
+	return nil, err
+}
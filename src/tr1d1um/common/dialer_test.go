@@ -0,0 +1,80 @@
+package common
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialerOptionsValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(DialerOptions{}.Validate())
+	assert.Nil(DialerOptions{PreferredFamily: FamilyIPv6}.Validate())
+	assert.NotNil(DialerOptions{PreferredFamily: "ipx"}.Validate())
+
+	_, err := DialerOptions{PreferredFamily: "ipx"}.DialContext(time.Second, nil)
+	assert.NotNil(err)
+}
+
+func TestDialerPreferredFamily(t *testing.T) {
+	v4, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("IPv4 loopback is not available")
+	}
+	defer v4.Close()
+
+	//both families listen on the same port so that either may answer for the host
+	_, port, _ := net.SplitHostPort(v4.Addr().String())
+	v6, err := net.Listen("tcp6", net.JoinHostPort("::1", port))
+	if err != nil {
+		t.Skip("IPv6 loopback is not available")
+	}
+	defer v6.Close()
+
+	addresses := []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}, {IP: net.ParseIP("::1")}}
+
+	newDialer := func(o DialerOptions) *dialer {
+		return &dialer{
+			o:   o,
+			net: &net.Dialer{Timeout: time.Second},
+			lookup: func(context.Context, string) ([]net.IPAddr, error) {
+				return addresses, nil
+			},
+		}
+	}
+
+	dialFamily := func(d *dialer) string {
+		conn, err := d.dial(context.Background(), "tcp", net.JoinHostPort("scytale.example.com", port))
+		if !assert.Nil(t, err) {
+			return ""
+		}
+
+		defer conn.Close()
+		if conn.RemoteAddr().(*net.TCPAddr).IP.To4() != nil {
+			return FamilyIPv4
+		}
+		return FamilyIPv6
+	}
+
+	t.Run("IPv6", func(t *testing.T) {
+		assert.EqualValues(t, FamilyIPv6, dialFamily(newDialer(DialerOptions{PreferredFamily: FamilyIPv6, DualStack: true, FallbackDelay: time.Second})))
+	})
+
+	t.Run("IPv4", func(t *testing.T) {
+		assert.EqualValues(t, FamilyIPv4, dialFamily(newDialer(DialerOptions{PreferredFamily: FamilyIPv4})))
+	})
+
+	t.Run("ResolverOrder", func(t *testing.T) {
+		assert.EqualValues(t, FamilyIPv4, dialFamily(newDialer(DialerOptions{DualStack: true, FallbackDelay: time.Second})))
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		v6.Close()
+		assert.EqualValues(t, FamilyIPv4, dialFamily(newDialer(DialerOptions{PreferredFamily: FamilyIPv6, DualStack: true, FallbackDelay: time.Second})))
+		assert.EqualValues(t, FamilyIPv4, dialFamily(newDialer(DialerOptions{PreferredFamily: FamilyIPv6})))
+	})
+}
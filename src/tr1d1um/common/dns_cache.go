@@ -0,0 +1,169 @@
+package common
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"golang.org/x/sync/singleflight"
+)
+
+//Outcomes of the lookups of the DNS cache
+const (
+	DNSHit      = "hit"
+	DNSMiss     = "miss"
+	DNSStale    = "stale"
+	DNSNegative = "negative"
+	DNSError    = "error"
+)
+
+//DefaultDNSTimeout is the time the lookups of the DNS cache may take by default
+const DefaultDNSTimeout = 5 * time.Second
+
+//LookupFunc resolves the IP addresses of a host (i.e. net.Resolver.LookupIPAddr)
+type LookupFunc func(context.Context, string) ([]net.IPAddr, error)
+
+//DNSCacheOptions configure the in-process cache of the addresses of the XMiDT API hosts
+//The standard resolver doesn't expose the TTLs of records so entries live for a fixed TTL
+type DNSCacheOptions struct {
+	//TTL is how long resolved addresses are cached. The cache is disabled if zero
+	TTL time.Duration
+
+	//NegativeTTL is how long failed lookups are cached. They aren't if zero
+	NegativeTTL time.Duration
+
+	//MaxStale is how long after they expire addresses keep being used while the resolver fails
+	MaxStale time.Duration
+
+	//Timeout bounds the time lookups may take. Defaults to DefaultDNSTimeout
+	Timeout time.Duration
+}
+
+type dnsEntry struct {
+	addresses []net.IPAddr
+	err       error
+	expires   time.Time
+}
+
+//DNSCache caches the addresses of hosts so that resolver hiccups don't fail the requests to known hosts
+//Concurrent lookups of a host share a single query to the resolver
+type DNSCache struct {
+	o       DNSCacheOptions
+	lookup  LookupFunc
+	lookups metrics.Counter
+	now     func() time.Time
+
+	group   singleflight.Group
+	lock    sync.RWMutex
+	entries map[string]dnsEntry
+}
+
+//NewDNSCache builds a cache in front of the given lookup (the default resolver if nil). It is nil if disabled
+//Lookups are counted by outcome
+func NewDNSCache(o DNSCacheOptions, lookup LookupFunc, lookups metrics.Counter) *DNSCache {
+	if o.TTL <= 0 {
+		return nil
+	}
+
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupIPAddr
+	}
+
+	if lookups == nil {
+		lookups = discard.NewCounter()
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultDNSTimeout
+	}
+
+	return &DNSCache{
+		o:       o,
+		lookup:  lookup,
+		lookups: lookups,
+		now:     time.Now,
+		entries: make(map[string]dnsEntry),
+	}
+}
+
+//LookupIPAddr returns the cached addresses of the host, resolving them if they expired
+func (c *DNSCache) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	c.lock.RLock()
+	entry, ok := c.entries[host]
+	c.lock.RUnlock()
+
+	if ok && c.now().Before(entry.expires) {
+		if entry.err != nil {
+			c.lookups.With(OutcomeLabel, DNSNegative).Add(1)
+			return nil, entry.err
+		}
+
+		c.lookups.With(OutcomeLabel, DNSHit).Add(1)
+		return entry.addresses, nil
+	}
+
+	//the lookup is shared by the concurrent callers so it doesn't run with the context of any of them, each
+	//caller may still give up on it
+	results := c.group.DoChan(host, func() (interface{}, error) {
+		return c.resolve(host)
+	})
+
+	select {
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+
+		return result.Val.([]net.IPAddr), nil
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+//resolve queries the resolver and updates the entry of the host with the outcome
+func (c *DNSCache) resolve(host string) ([]net.IPAddr, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.o.Timeout)
+	defer cancel()
+
+	addresses, err := c.lookup(ctx, host)
+	now := c.now()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err == nil {
+		c.entries[host] = dnsEntry{addresses: addresses, expires: now.Add(c.o.TTL)}
+		c.lookups.With(OutcomeLabel, DNSMiss).Add(1)
+		return addresses, nil
+	}
+
+	//the last known addresses are better than failing while the resolver recovers
+	if previous, ok := c.entries[host]; ok && previous.err == nil && now.Before(previous.expires.Add(c.o.MaxStale)) {
+		c.lookups.With(OutcomeLabel, DNSStale).Add(1)
+		return previous.addresses, nil
+	}
+
+	//timeouts say nothing of the host so they are not cached
+	if c.o.NegativeTTL > 0 && !isTimeout(err) {
+		c.entries[host] = dnsEntry{err: err, expires: now.Add(c.o.NegativeTTL)}
+	} else {
+		delete(c.entries, host)
+	}
+
+	c.lookups.With(OutcomeLabel, DNSError).Add(1)
+	return nil, err
+}
+
+//isTimeout reports whether err is the expiry or cancellation of the context of a lookup rather than its outcome
+func isTimeout(err error) bool {
+	if err == context.DeadlineExceeded || err == context.Canceled {
+		return true
+	}
+
+	dnsErr, ok := err.(*net.DNSError)
+	return ok && dnsErr.IsTimeout
+}
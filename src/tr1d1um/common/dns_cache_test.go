@@ -0,0 +1,143 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDNSCache(t *testing.T) {
+	assert.Nil(t, NewDNSCache(DNSCacheOptions{}, nil, nil))
+
+	var (
+		now       = time.Unix(1000, 0)
+		addresses = []net.IPAddr{{IP: net.ParseIP("10.0.0.1")}}
+		failure   = errors.New("no such host")
+		lookupErr error
+		lookups   int
+	)
+
+	c := NewDNSCache(DNSCacheOptions{TTL: 30 * time.Second, NegativeTTL: 2 * time.Second, MaxStale: time.Minute},
+		func(context.Context, string) ([]net.IPAddr, error) {
+			lookups++
+			return addresses, lookupErr
+		}, nil)
+
+	c.now = func() time.Time { return now }
+
+	lookup := func() ([]net.IPAddr, error) {
+		return c.LookupIPAddr(context.Background(), "scytale.example.com")
+	}
+
+	t.Run("Hit", func(t *testing.T) {
+		assert := assert.New(t)
+
+		result, err := lookup()
+		assert.Nil(err)
+		assert.EqualValues(addresses, result)
+
+		result, err = lookup()
+		assert.Nil(err)
+		assert.EqualValues(addresses, result)
+		assert.EqualValues(1, lookups)
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		assert := assert.New(t)
+		now, lookupErr = now.Add(40*time.Second), failure
+
+		result, err := lookup()
+		assert.Nil(err)
+		assert.EqualValues(addresses, result)
+		assert.EqualValues(2, lookups)
+	})
+
+	t.Run("Negative", func(t *testing.T) {
+		assert := assert.New(t)
+		now = now.Add(2 * time.Minute)
+
+		_, err := lookup()
+		assert.Equal(failure, err)
+
+		_, err = lookup()
+		assert.Equal(failure, err)
+		assert.EqualValues(3, lookups)
+
+		now, lookupErr = now.Add(3*time.Second), nil
+		result, err := lookup()
+		assert.Nil(err)
+		assert.EqualValues(addresses, result)
+		assert.EqualValues(4, lookups)
+	})
+}
+
+func TestDNSCacheSharedLookup(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		addresses = []net.IPAddr{{IP: net.ParseIP("10.0.0.1")}}
+		release   = make(chan struct{})
+		lookups   int32
+	)
+
+	c := NewDNSCache(DNSCacheOptions{TTL: 30 * time.Second, NegativeTTL: time.Minute},
+		func(ctx context.Context, _ string) ([]net.IPAddr, error) {
+			atomic.AddInt32(&lookups, 1)
+			select {
+			case <-release:
+				return addresses, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}, nil)
+
+	//the first caller gives up on the lookup it started
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := c.LookupIPAddr(ctx, "scytale.example.com")
+		canceled <- err
+	}()
+
+	for atomic.LoadInt32(&lookups) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	assert.Equal(context.Canceled, <-canceled)
+
+	//which keeps going for the others
+	close(release)
+	result, err := c.LookupIPAddr(context.Background(), "scytale.example.com")
+	assert.Nil(err)
+	assert.EqualValues(addresses, result)
+	assert.EqualValues(1, atomic.LoadInt32(&lookups))
+}
+
+func TestDNSCacheTimeout(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		lookups int
+	)
+
+	c := NewDNSCache(DNSCacheOptions{TTL: 30 * time.Second, NegativeTTL: time.Minute, Timeout: time.Millisecond},
+		func(ctx context.Context, _ string) ([]net.IPAddr, error) {
+			lookups++
+			<-ctx.Done()
+			return nil, &net.DNSError{Err: "i/o timeout", Name: "scytale.example.com", IsTimeout: true}
+		}, nil)
+
+	for i := 0; i < 2; i++ {
+		_, err := c.LookupIPAddr(context.Background(), "scytale.example.com")
+		assert.NotNil(err)
+	}
+
+	//timed out lookups are not cached as negative entries
+	assert.Equal(2, lookups)
+	assert.True(isTimeout(context.Canceled))
+	assert.False(isTimeout(errors.New("no such host")))
+}
@@ -0,0 +1,147 @@
+package common
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+//DefaultDrainRetryAfter is the time API consumers are told to wait before retrying requests rejected while the
+//instance drains, by which it is usually out of rotation
+const DefaultDrainRetryAfter = 30 * time.Second
+
+//Drainer takes an instance out of rotation during deploys: once draining, it reports the instance as not ready and,
+//optionally, rejects new requests while the in-flight ones complete
+type Drainer struct {
+	lock       sync.RWMutex
+	draining   bool
+	rejecting  bool
+	inFlight   map[string]int
+	retryAfter time.Duration
+}
+
+//DrainStatus is the state of a Drainer
+type DrainStatus struct {
+	Draining  bool `json:"draining"`
+	Rejecting bool `json:"rejecting"`
+
+	//InFlight is the number of requests being served, by route (method and path template)
+	InFlight map[string]int `json:"inFlight"`
+	Total    int            `json:"total"`
+}
+
+//NewDrainer returns a Drainer of an instance which is not draining. Rejected requests are answered with the given
+//Retry-After, DefaultDrainRetryAfter if not positive
+func NewDrainer(retryAfter time.Duration) *Drainer {
+	if retryAfter <= 0 {
+		retryAfter = DefaultDrainRetryAfter
+	}
+
+	return &Drainer{inFlight: make(map[string]int), retryAfter: retryAfter}
+}
+
+//Drain marks the instance as draining. If reject is true, new requests are rejected with a 503
+func (d *Drainer) Drain(reject bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.draining, d.rejecting = true, reject
+}
+
+//Undrain puts the instance back in rotation
+func (d *Drainer) Undrain() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.draining, d.rejecting = false, false
+}
+
+//Status returns the current state of the drainer
+func (d *Drainer) Status() DrainStatus {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	var status = DrainStatus{Draining: d.draining, Rejecting: d.rejecting, InFlight: make(map[string]int, len(d.inFlight))}
+	for route, count := range d.inFlight {
+		status.InFlight[route] = count
+		status.Total += count
+	}
+
+	return status
+}
+
+//Track is a mux middleware which counts the in-flight requests of the routes of a router and
+//rejects new ones while the instance drains, if asked to
+func (d *Drainer) Track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.Method + " " + r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = r.Method + " " + template
+			}
+		}
+
+		d.lock.Lock()
+		if d.rejecting {
+			d.lock.Unlock()
+
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Header().Set("Connection", "close")
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.retryAfter.Seconds()))))
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"message": "instance is draining",
+				"code":    "instance_draining",
+			})
+			return
+		}
+
+		d.inFlight[route]++
+		d.lock.Unlock()
+
+		defer func() {
+			d.lock.Lock()
+			defer d.lock.Unlock()
+
+			if d.inFlight[route]--; d.inFlight[route] == 0 {
+				delete(d.inFlight, route)
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+//ReadinessHandler answers 200 unless the instance is draining, in which case it answers 503
+//so that load balancers and service catalogs stop sending new requests
+func (d *Drainer) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.Status().Draining {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+//DrainHandler is the admin handler of a Drainer:
+//GET reports the state and in-flight requests, PUT drains (?reject=true also rejects new requests) and DELETE undrains
+func DrainHandler(d *Drainer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		switch r.Method {
+		case http.MethodPut:
+			d.Drain(r.URL.Query().Get("reject") == "true")
+		case http.MethodDelete:
+			d.Undrain()
+		}
+
+		json.NewEncoder(w).Encode(d.Status())
+	})
+}
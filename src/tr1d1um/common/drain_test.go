@@ -0,0 +1,104 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrainerTrack(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		drainer  = NewDrainer(10 * time.Second)
+		router   = mux.NewRouter()
+		inFlight DrainStatus
+	)
+
+	router.Use(drainer.Track)
+	router.HandleFunc("/device/{deviceid}/stat", func(w http.ResponseWriter, r *http.Request) {
+		inFlight = drainer.Status()
+	})
+
+	t.Run("Serving", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/device/mac:112233445566/stat", nil))
+
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.Equal(DrainStatus{InFlight: map[string]int{"GET /device/{deviceid}/stat": 1}, Total: 1}, inFlight)
+		assert.Equal(DrainStatus{InFlight: map[string]int{}}, drainer.Status())
+	})
+
+	t.Run("Draining", func(t *testing.T) {
+		drainer.Drain(false)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/device/mac:112233445566/stat", nil))
+
+		assert.Equal(http.StatusOK, recorder.Code)
+	})
+
+	t.Run("Rejecting", func(t *testing.T) {
+		drainer.Drain(true)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/device/mac:112233445566/stat", nil))
+
+		assert.Equal(http.StatusServiceUnavailable, recorder.Code)
+		assert.Equal("close", recorder.Header().Get("Connection"))
+		assert.Equal("10", recorder.Header().Get("Retry-After"))
+		assert.JSONEq(`{"message":"instance is draining","code":"instance_draining"}`, recorder.Body.String())
+	})
+
+	t.Run("Undrained", func(t *testing.T) {
+		drainer.Undrain()
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/device/mac:112233445566/stat", nil))
+
+		assert.Equal(http.StatusOK, recorder.Code)
+	})
+}
+
+func TestNewDrainerDefaultRetryAfter(t *testing.T) {
+	assert.Equal(t, DefaultDrainRetryAfter, NewDrainer(0).retryAfter)
+}
+
+func TestDrainHandler(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		drainer = NewDrainer(0)
+		handler = DrainHandler(drainer)
+		ready   = drainer.ReadinessHandler()
+	)
+
+	serve := func(h http.Handler, method, target string) (int, DrainStatus) {
+		var (
+			recorder = httptest.NewRecorder()
+			status   DrainStatus
+		)
+
+		h.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+		json.NewDecoder(recorder.Body).Decode(&status)
+		return recorder.Code, status
+	}
+
+	code, _ := serve(ready, http.MethodGet, "/ready")
+	assert.Equal(http.StatusOK, code)
+
+	_, status := serve(handler, http.MethodPut, "/admin/drain?reject=true")
+	assert.Equal(DrainStatus{Draining: true, Rejecting: true, InFlight: map[string]int{}}, status)
+
+	code, _ = serve(ready, http.MethodGet, "/ready")
+	assert.Equal(http.StatusServiceUnavailable, code)
+
+	_, status = serve(handler, http.MethodGet, "/admin/drain")
+	assert.True(status.Draining)
+
+	_, status = serve(handler, http.MethodDelete, "/admin/drain")
+	assert.Equal(DrainStatus{InFlight: map[string]int{}}, status)
+
+	code, _ = serve(ready, http.MethodGet, "/ready")
+	assert.Equal(http.StatusOK, code)
+}
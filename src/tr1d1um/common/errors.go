@@ -2,12 +2,23 @@ package common
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 )
 
 //ErrTr1d1umInternal should be the error shown to external API consumers in Internal Server error cases
 var ErrTr1d1umInternal = errors.New("oops! Something unexpected went wrong in this service")
 
+//ErrClientCanceled is returned when the API consumer disconnects before tr1d1um is done responding
+var ErrClientCanceled = errors.New("client canceled the request")
+
+//ErrMalformedWRPResponse is returned when a successful XMiDT response in JSON isn't a WRP message
+var ErrMalformedWRPResponse = NewCodedError(errors.New("malformed WRP response"), http.StatusBadGateway)
+
+//ErrInvalidRequestTimeout is returned when the timeout requested by the API consumer cannot be parsed
+var ErrInvalidRequestTimeout = NewBadRequestError(fmt.Errorf("%s header should be a positive duration (i.e. 10s) or number of seconds", HeaderWPATimeout))
+
 //CodedError describes the behavior of an error that additionally has an HTTP status code used for TR1D1UM business logic
 type CodedError interface {
 	error
@@ -36,3 +47,36 @@ func NewCodedError(e error, code int) CodedError {
 		statusCode: code,
 	}
 }
+
+//ValidationProblem is one of the problems of a request body
+type ValidationProblem struct {
+	//Field is the path of the offending field (i.e. parameters[1].dataType)
+	Field string `json:"field"`
+
+	Message string `json:"message"`
+}
+
+//ValidationError is the CodedError returned for request bodies with problems. All of them are reported at once
+//so that API consumers can fix their payloads in a single round trip
+type ValidationError struct {
+	Problems []ValidationProblem
+}
+
+func (v *ValidationError) Error() string {
+	var messages = make([]string, len(v.Problems))
+	for i, problem := range v.Problems {
+		messages[i] = problem.Field + ": " + problem.Message
+	}
+
+	return "invalid request body: " + strings.Join(messages, "; ")
+}
+
+//StatusCode returns 400
+func (v *ValidationError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+//ErrorCode returns the code of invalid request bodies
+func (v *ValidationError) ErrorCode() string {
+	return "invalid_request_body"
+}
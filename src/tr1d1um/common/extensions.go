@@ -0,0 +1,29 @@
+package common
+
+import (
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/justinas/alice"
+)
+
+//Extensions are the custom middleware and go-kit server options downstream builds may register for a group of routes
+//so that org-specific concerns (i.e. header normalization, custom authorization) don't require patching the services
+type Extensions struct {
+	//Middleware runs after authentication, in the given order, before the request reaches the go-kit server
+	Middleware []alice.Constructor
+
+	//ServerOptions are applied after the ones of the service so, for instance, their request functions (ServerBefore)
+	//see the context values captured by the service
+	ServerOptions []kithttp.ServerOption
+}
+
+//Handler returns the handler for a group of routes: authentication, then the custom middleware and finally the go-kit
+//server built out of the given parts with the server options of the service followed by the custom ones
+func (e Extensions) Handler(authenticate *alice.Chain, ep endpoint.Endpoint, dec kithttp.DecodeRequestFunc, enc kithttp.EncodeResponseFunc, opts []kithttp.ServerOption) http.Handler {
+	var serverOptions = make([]kithttp.ServerOption, 0, len(opts)+len(e.ServerOptions))
+	serverOptions = append(append(serverOptions, opts...), e.ServerOptions...)
+
+	return authenticate.Append(e.Middleware...).Then(Welcome(kithttp.NewServer(ep, dec, enc, serverOptions...)))
+}
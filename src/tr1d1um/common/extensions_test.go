@@ -0,0 +1,55 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/justinas/alice"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtensionsHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		order []string
+
+		middleware = func(name string) alice.Constructor {
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					order = append(order, name)
+					next.ServeHTTP(w, r)
+				})
+			}
+		}
+
+		before = func(name string) kithttp.ServerOption {
+			return kithttp.ServerBefore(func(ctx context.Context, _ *http.Request) context.Context {
+				order = append(order, name)
+				return ctx
+			})
+		}
+
+		extensions = Extensions{
+			Middleware:    []alice.Constructor{middleware("normalize"), middleware("authorize")},
+			ServerOptions: []kithttp.ServerOption{before("custom")},
+		}
+
+		authenticate = alice.New(middleware("authenticate"))
+		opts         = []kithttp.ServerOption{before("service")}
+	)
+
+	handler := extensions.Handler(&authenticate,
+		func(_ context.Context, request interface{}) (interface{}, error) { return request, nil },
+		func(context.Context, *http.Request) (interface{}, error) { return nil, nil },
+		func(context.Context, http.ResponseWriter, interface{}) error { return nil },
+		opts,
+	)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost", nil))
+	assert.EqualValues([]string{"authenticate", "normalize", "authorize", "service", "custom"}, order)
+	assert.Len(opts, 1)
+}
@@ -0,0 +1,91 @@
+package common
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+//FanOutMode drives how the tasks of a fan out react to failures
+type FanOutMode int
+
+const (
+	//CollectAll runs every task regardless of the failures of the others. Each task reports its own error
+	CollectAll FanOutMode = iota
+
+	//FirstError cancels the remaining tasks as soon as one fails. Tasks which did not get to run report the cancellation
+	FirstError
+)
+
+//FanOut runs a number of similar tasks concurrently (i.e. a WRP message for each member of a device group)
+//so that bulk operations bound their concurrency and timeouts the same way
+type FanOut struct {
+	//Concurrency is the maximum number of tasks running at a time. All tasks run at once if not positive
+	Concurrency int
+
+	//TaskTimeout bounds each task (optional)
+	TaskTimeout time.Duration
+
+	//Mode is either CollectAll (the default) or FirstError
+	Mode FanOutMode
+}
+
+//Run runs task for each index in [0, n) and returns their errors, in the same order
+//In FirstError mode, err is the first error of any task. The context given to tasks is canceled once Run returns
+func (f FanOut) Run(ctx context.Context, n int, task func(ctx context.Context, i int) error) (errs []error, err error) {
+	var (
+		group       = new(errgroup.Group)
+		concurrency = f.Concurrency
+	)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if concurrency < 1 || concurrency > n {
+		concurrency = n
+	}
+
+	errs = make([]error, n)
+	semaphore := make(chan struct{}, concurrency)
+
+	for i := 0; i < n; i++ {
+		if ctx.Err() == nil {
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}
+
+		if ctx.Err() != nil {
+			//tasks which never started report why
+			for j := i; j < n; j++ {
+				errs[j] = ctx.Err()
+			}
+
+			return errs, group.Wait()
+		}
+
+		i := i
+		group.Go(func() error {
+			defer func() { <-semaphore }()
+
+			taskCtx, cancelTask := ctx, context.CancelFunc(func() {})
+			if f.TaskTimeout > 0 {
+				taskCtx, cancelTask = context.WithTimeout(ctx, f.TaskTimeout)
+			}
+			defer cancelTask()
+
+			errs[i] = task(taskCtx, i)
+			if f.Mode == FirstError && errs[i] != nil {
+				//the remaining tasks are canceled before this one frees its slot so none of them starts
+				cancel()
+				return errs[i]
+			}
+
+			return nil
+		})
+	}
+
+	return errs, group.Wait()
+}
@@ -0,0 +1,91 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFanOutCollectAll(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		running, peak int32
+		failure       = errors.New("failure")
+	)
+
+	errs, err := FanOut{Concurrency: 2}.Run(context.Background(), 6, func(_ context.Context, i int) error {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+
+		for {
+			observed := atomic.LoadInt32(&peak)
+			if current <= observed || atomic.CompareAndSwapInt32(&peak, observed, current) {
+				break
+			}
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		if i%2 == 1 {
+			return failure
+		}
+		return nil
+	})
+
+	assert.Nil(err)
+	assert.Equal([]error{nil, failure, nil, failure, nil, failure}, errs)
+	assert.True(peak <= 2)
+}
+
+func TestFanOutFirstError(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		failure = errors.New("failure")
+		started int32
+	)
+
+	errs, err := FanOut{Concurrency: 1, Mode: FirstError}.Run(context.Background(), 4, func(_ context.Context, i int) error {
+		atomic.AddInt32(&started, 1)
+		if i == 1 {
+			return failure
+		}
+		return nil
+	})
+
+	assert.Equal(failure, err)
+	assert.Nil(errs[0])
+	assert.Equal(failure, errs[1])
+	assert.True(started < 4)
+	assert.Equal(context.Canceled, errs[3])
+}
+
+func TestFanOutTaskTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	errs, err := FanOut{TaskTimeout: 10 * time.Millisecond}.Run(context.Background(), 2, func(ctx context.Context, i int) error {
+		if i == 0 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+
+		_, hasDeadline := ctx.Deadline()
+		assert.True(hasDeadline)
+		return nil
+	})
+
+	assert.Nil(err)
+	assert.Equal([]error{context.DeadlineExceeded, nil}, errs)
+}
+
+func TestFanOutCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	errs, _ := FanOut{Concurrency: 1}.Run(ctx, 3, func(context.Context, int) error { return nil })
+	assert.Equal(t, []error{context.Canceled, context.Canceled, context.Canceled}, errs)
+}
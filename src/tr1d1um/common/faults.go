@@ -0,0 +1,270 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/justinas/alice"
+	"github.com/spf13/cast"
+)
+
+//HeaderInjectedFault is the response header through which injected faults are disclosed
+const HeaderInjectedFault = "X-Injected-Fault"
+
+//Kinds of injected faults
+const (
+	FaultLatency  = "latency"
+	FaultError    = "error"
+	FaultTruncate = "truncate"
+)
+
+//Kinds of faults the faulty backend injects into the responses of the XMiDT API
+const (
+	//FaultMalformedWRP replaces the response body with bytes which are not a WRP message
+	FaultMalformedWRP = "malformed-wrp"
+
+	//FaultTruncatedWRP cuts the response body in half
+	FaultTruncatedWRP = "truncated-wrp"
+
+	//FaultMalformedPayload replaces the payload of the WRP message of the response with one which is not a WDMP document
+	FaultMalformedPayload = "malformed-payload"
+
+	//FaultExoticStatus replaces the status code of the response with an unusual one
+	FaultExoticStatus = "exotic-status"
+)
+
+//FaultyBackend is the name of the backend which injects faults into the responses of the XMiDT API, so that
+//the decoding of responses can be tested against backend misbehavior. It is only meant for test environments
+const FaultyBackend = "faulty"
+
+//DefaultExoticStatusCodes are the status codes FaultExoticStatus picks from when none are configured
+var DefaultExoticStatusCodes = []int{http.StatusMultiStatus, http.StatusTeapot, http.StatusTooManyRequests, 520, 599}
+
+//malformedWRP is never valid msgpack (0xc1 is unused by the format) nor JSON
+var malformedWRP = []byte{0xc1, 0xc1, 0xc1, 0xc1}
+
+func init() {
+	RegisterBackend(FaultyBackend, func(o BackendOptions) (Backend, error) {
+		next, err := NewBackend(DefaultBackend, o)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewFaultyBackend(next, o.Config)
+	})
+}
+
+//NewFaultyBackend decorates the given backend so that responses are corrupted at the configured rate.
+//The configuration holds:
+//  rate: the probability (0 to 1) that a response is corrupted
+//  faults: the kinds of faults picked from at random. Defaults to all of them
+//  statusCodes: the status codes of FaultExoticStatus. Defaults to DefaultExoticStatusCodes
+//Injected faults are disclosed through the HeaderInjectedFault header of the responses
+func NewFaultyBackend(next Backend, config map[string]interface{}) (Backend, error) {
+	var f = &faultyBackend{
+		next:        next,
+		faults:      []string{FaultMalformedWRP, FaultTruncatedWRP, FaultMalformedPayload, FaultExoticStatus},
+		statusCodes: DefaultExoticStatusCodes,
+		sample:      rand.Float64,
+		pick:        rand.Intn,
+	}
+
+	for key, value := range config {
+		var err error
+
+		switch strings.ToLower(key) {
+		case "rate":
+			if f.rate, err = cast.ToFloat64E(value); err == nil && (f.rate < 0 || f.rate > 1) {
+				err = fmt.Errorf("should be between 0 and 1 but was %v", f.rate)
+			}
+		case "faults":
+			if f.faults, err = cast.ToStringSliceE(value); err == nil {
+				for _, fault := range f.faults {
+					if fault != FaultMalformedWRP && fault != FaultTruncatedWRP && fault != FaultMalformedPayload && fault != FaultExoticStatus {
+						err = fmt.Errorf("unknown fault '%s'", fault)
+					}
+				}
+			}
+		case "statuscodes":
+			if f.statusCodes, err = cast.ToIntSliceE(value); err == nil {
+				for _, code := range f.statusCodes {
+					if code < 200 || code > 999 {
+						err = fmt.Errorf("invalid status code %d", code)
+					}
+				}
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s of the faulty backend: %s", key, err)
+		}
+	}
+
+	if len(f.faults) == 0 || len(f.statusCodes) == 0 {
+		return nil, errors.New("the faulty backend needs faults and status codes to pick from")
+	}
+
+	return f, nil
+}
+
+type faultyBackend struct {
+	next        Backend
+	rate        float64
+	faults      []string
+	statusCodes []int
+	sample      func() float64
+	pick        func(int) int
+}
+
+func (f *faultyBackend) SendWRP(ctx context.Context, r *WRPRequest) (*XmidtResponse, error) {
+	result, err := f.next.SendWRP(ctx, r)
+	return f.corrupt(result), err
+}
+
+func (f *faultyBackend) RequestStat(ctx context.Context, authValue, deviceID string) (*XmidtResponse, error) {
+	result, err := f.next.RequestStat(ctx, authValue, deviceID)
+	return f.corrupt(result), err
+}
+
+//corrupt injects one of the faults into the given response, at the configured rate
+func (f *faultyBackend) corrupt(result *XmidtResponse) *XmidtResponse {
+	if result == nil || f.sample() >= f.rate {
+		return result
+	}
+
+	var (
+		fault     = f.faults[f.pick(len(f.faults))]
+		corrupted = *result
+	)
+
+	//the body as received no longer matches the corrupted one
+	corrupted.Compressed, corrupted.ContentEncoding = nil, ""
+	corrupted.ForwardedHeaders = make(http.Header, len(result.ForwardedHeaders)+1)
+	for name, values := range result.ForwardedHeaders {
+		corrupted.ForwardedHeaders[name] = values
+	}
+
+	switch fault {
+	case FaultMalformedWRP:
+		corrupted.Code, corrupted.Body = http.StatusOK, malformedWRP
+	case FaultTruncatedWRP:
+		corrupted.Body = result.Body[:len(result.Body)/2]
+	case FaultMalformedPayload:
+		var message wrp.Message
+		if DecodeWRP(result.Body, &message) != nil {
+			return result
+		}
+
+		message.Payload = []byte(`{"statusCode": 200, "parameters": [`)
+		if body, err := EncodeWRP(&message); err == nil {
+			corrupted.Body, corrupted.ContentType = body, wrp.Msgpack.ContentType()
+		}
+	case FaultExoticStatus:
+		corrupted.Code = f.statusCodes[f.pick(len(f.statusCodes))]
+	}
+
+	corrupted.ForwardedHeaders.Add(HeaderInjectedFault, fault)
+	return &corrupted
+}
+
+//FaultRule describes the faults injected into the responses of a group of routes
+//Rates are probabilities between 0 and 1
+type FaultRule struct {
+	//Latency is the artificial delay added to requests at LatencyRate
+	Latency     time.Duration
+	LatencyRate float64
+
+	//ErrorStatus is the status code of the error responses returned instead of the real ones at ErrorRate
+	//Defaults to 503
+	ErrorStatus int
+	ErrorRate   float64
+
+	//TruncateRate is the rate at which response bodies are cut in half
+	TruncateRate float64
+}
+
+//Validate returns an error if the rule is invalid
+func (f FaultRule) Validate() error {
+	for name, rate := range map[string]float64{"latencyRate": f.LatencyRate, "errorRate": f.ErrorRate, "truncateRate": f.TruncateRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s should be between 0 and 1 but was %v", name, rate)
+		}
+	}
+
+	if f.ErrorStatus != 0 && (f.ErrorStatus < 400 || f.ErrorStatus > 599) {
+		return fmt.Errorf("errorStatus should be an HTTP error status code but was %d", f.ErrorStatus)
+	}
+
+	return nil
+}
+
+//FaultInjector returns the middleware that injects the faults described by the given rule so that API consumers can
+//test their retry and timeout handling. It is only meant for test environments
+func FaultInjector(f FaultRule) alice.Constructor {
+	return faultInjector(f, rand.Float64)
+}
+
+//faultInjector is FaultInjector with the source of randomness made explicit
+func faultInjector(f FaultRule, sample func() float64) alice.Constructor {
+	if f.ErrorStatus == 0 {
+		f.ErrorStatus = http.StatusServiceUnavailable
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if sample() < f.LatencyRate {
+				w.Header().Add(HeaderInjectedFault, FaultLatency)
+
+				select {
+				case <-time.After(f.Latency):
+				case <-r.Context().Done():
+					return
+				}
+			}
+
+			if sample() < f.ErrorRate {
+				w.Header().Add(HeaderInjectedFault, FaultError)
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(f.ErrorStatus)
+
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"message": "injected fault",
+				})
+				return
+			}
+
+			if sample() < f.TruncateRate {
+				w.Header().Add(HeaderInjectedFault, FaultTruncate)
+				w = &truncatingWriter{ResponseWriter: w}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+//truncatingWriter cuts the first write of the response body in half and drops any later one
+type truncatingWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (t *truncatingWriter) Write(p []byte) (int, error) {
+	if t.written {
+		return len(p), nil
+	}
+
+	t.written = true
+	if _, err := t.ResponseWriter.Write(p[:len(p)/2]); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,171 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaultRuleValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(FaultRule{LatencyRate: 1, ErrorRate: 0.5, ErrorStatus: http.StatusTooManyRequests}.Validate())
+	assert.NotNil(FaultRule{TruncateRate: 1.5}.Validate())
+	assert.NotNil(FaultRule{ErrorStatus: http.StatusOK}.Validate())
+}
+
+func TestFaultInjector(t *testing.T) {
+	var (
+		ok = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte(`{"parameters":[]}`))
+		})
+
+		//samples returns the given values in order
+		samples = func(values ...float64) func() float64 {
+			return func() (value float64) {
+				value, values = values[0], values[1:]
+				return
+			}
+		}
+
+		rule = FaultRule{Latency: 10 * time.Millisecond, LatencyRate: 0.5, ErrorRate: 0.5, TruncateRate: 0.5}
+	)
+
+	t.Run("None", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		faultInjector(rule, samples(0.5, 0.5, 0.5))(ok).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.EqualValues(`{"parameters":[]}`, recorder.Body.String())
+		assert.Empty(recorder.Header().Get(HeaderInjectedFault))
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder, start := httptest.NewRecorder(), time.Now()
+
+		faultInjector(rule, samples(0.1, 0.5, 0.5))(ok).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.True(time.Since(start) >= rule.Latency)
+		assert.EqualValues(FaultLatency, recorder.Header().Get(HeaderInjectedFault))
+		assert.EqualValues(`{"parameters":[]}`, recorder.Body.String())
+	})
+
+	t.Run("LatencyCanceled", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		faultInjector(FaultRule{Latency: time.Hour, LatencyRate: 1}, samples(0.1))(ok).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+		assert.Empty(recorder.Body.String())
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		faultInjector(rule, samples(0.5, 0.1))(ok).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.EqualValues(http.StatusServiceUnavailable, recorder.Code)
+		assert.EqualValues(FaultError, recorder.Header().Get(HeaderInjectedFault))
+		assert.JSONEq(`{"message": "injected fault"}`, recorder.Body.String())
+	})
+
+	t.Run("Truncate", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		faultInjector(rule, samples(0.5, 0.5, 0.1))(ok).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.EqualValues(FaultTruncate, recorder.Header().Get(HeaderInjectedFault))
+		assert.EqualValues(`{"parame`, recorder.Body.String())
+	})
+}
+
+func TestNewFaultyBackend(t *testing.T) {
+	assert := assert.New(t)
+
+	backend, err := NewBackend(FaultyBackend, BackendOptions{
+		TargetURL: "http://localhost",
+		Config:    map[string]interface{}{"rate": "0.5", "faults": []interface{}{FaultExoticStatus}, "statuscodes": []interface{}{418}},
+	})
+
+	assert.Nil(err)
+	assert.Equal(0.5, backend.(*faultyBackend).rate)
+	assert.Equal([]string{FaultExoticStatus}, backend.(*faultyBackend).faults)
+	assert.Equal([]int{http.StatusTeapot}, backend.(*faultyBackend).statusCodes)
+
+	for _, config := range []map[string]interface{}{
+		{"rate": 2},
+		{"faults": []string{"explode"}},
+		{"faults": []string{}},
+		{"statusCodes": []int{100}},
+	} {
+		_, err = NewFaultyBackend(new(MockBackend), config)
+		assert.NotNil(err, "%v", config)
+	}
+}
+
+func TestFaultyBackend(t *testing.T) {
+	var (
+		body = wrp.MustEncode(&wrp.Message{Type: wrp.SimpleRequestResponseMessageType, Payload: []byte(`{"statusCode": 200}`)}, wrp.Msgpack)
+
+		respond = func() *XmidtResponse {
+			return &XmidtResponse{Code: http.StatusOK, Body: body, ForwardedHeaders: http.Header{"X-Xmidt-Message": []string{"ok"}}}
+		}
+	)
+
+	t.Run("NotSampled", func(t *testing.T) {
+		assert := assert.New(t)
+		next := new(MockBackend)
+		next.On("RequestStat", context.TODO(), "auth", "mac:112233445566").Return(respond(), nil)
+
+		f, _ := NewFaultyBackend(next, map[string]interface{}{"rate": 0.5})
+		f.(*faultyBackend).sample = func() float64 { return 0.5 }
+
+		result, err := f.RequestStat(context.TODO(), "auth", "mac:112233445566")
+		assert.Nil(err)
+		assert.Equal(respond(), result)
+	})
+
+	for i, fault := range []string{FaultMalformedWRP, FaultTruncatedWRP, FaultMalformedPayload, FaultExoticStatus} {
+		i, fault := i, fault
+
+		t.Run(fault, func(t *testing.T) {
+			assert := assert.New(t)
+			next := new(MockBackend)
+			next.On("SendWRP", context.TODO(), new(WRPRequest)).Return(respond(), nil)
+
+			f, _ := NewFaultyBackend(next, map[string]interface{}{"rate": 0.5, "statusCodes": []int{520}})
+			f.(*faultyBackend).sample = func() float64 { return 0.1 }
+			f.(*faultyBackend).pick = func(n int) int {
+				if n == 4 {
+					return i
+				}
+				return 0
+			}
+
+			result, err := f.SendWRP(context.TODO(), new(WRPRequest))
+			assert.Nil(err)
+			assert.Equal(fault, result.ForwardedHeaders.Get(HeaderInjectedFault))
+			assert.Equal("ok", result.ForwardedHeaders.Get("X-Xmidt-Message"))
+
+			var message wrp.Message
+			switch fault {
+			case FaultMalformedWRP, FaultTruncatedWRP:
+				assert.NotNil(DecodeWRP(result.Body, &message))
+			case FaultMalformedPayload:
+				assert.Nil(DecodeWRP(result.Body, &message))
+				assert.False(json.Valid(message.Payload))
+			case FaultExoticStatus:
+				assert.Equal(520, result.Code)
+				assert.Equal(body, result.Body)
+			}
+		})
+	}
+}
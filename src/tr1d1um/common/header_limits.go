@@ -0,0 +1,82 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+//HeaderLimits bounds the headers of requests so that oversized or countless headers (header bombs) are turned away
+//rather than processed and forwarded to the XMiDT API. Zero values mean no limit
+type HeaderLimits struct {
+	//MaxCount is the maximum number of header fields. A header with several values counts once per value
+	MaxCount int
+
+	//MaxSize is the maximum total size in bytes of the header names and values
+	MaxSize int
+}
+
+//HeaderLimitError is the CodedError returned when headers exceed their limits
+type HeaderLimitError struct {
+	Message string
+}
+
+func (h *HeaderLimitError) Error() string {
+	return h.Message
+}
+
+//StatusCode is 431 Request Header Fields Too Large
+func (h *HeaderLimitError) StatusCode() int {
+	return http.StatusRequestHeaderFieldsTooLarge
+}
+
+//ErrorCode lets API consumers tell header limit violations apart from other client errors
+func (h *HeaderLimitError) ErrorCode() string {
+	return "headers_too_large"
+}
+
+//Check returns a HeaderLimitError if the given headers exceed the limits, nil otherwise
+//The kind of headers (i.e. request or forwarded) is used to describe the violation
+func (l HeaderLimits) Check(kind string, header http.Header) error {
+	if l.MaxCount <= 0 && l.MaxSize <= 0 {
+		return nil
+	}
+
+	var count, size int
+	for name, values := range header {
+		for _, value := range values {
+			count++
+			size += len(name) + len(value)
+		}
+	}
+
+	if l.MaxCount > 0 && count > l.MaxCount {
+		return &HeaderLimitError{Message: fmt.Sprintf("%s headers exceed the limit of %d fields", kind, l.MaxCount)}
+	}
+
+	if l.MaxSize > 0 && size > l.MaxSize {
+		return &HeaderLimitError{Message: fmt.Sprintf("%s headers exceed the limit of %d bytes", kind, l.MaxSize)}
+	}
+
+	return nil
+}
+
+//Enforce is a mux middleware which rejects the requests whose headers exceed the limits with a 431
+func (l HeaderLimits) Enforce(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := l.Check("request", r.Header); err != nil {
+			h := err.(*HeaderLimitError)
+
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(h.StatusCode())
+
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"message": h.Message,
+				"code":    h.ErrorCode(),
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
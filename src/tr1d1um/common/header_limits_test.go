@@ -0,0 +1,46 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderLimits(t *testing.T) {
+	header := http.Header{
+		"X-A": []string{"a", "b"},
+		"X-B": []string{strings.Repeat("c", 100)},
+	}
+
+	t.Run("Check", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.Nil(HeaderLimits{}.Check("request", header))
+		assert.Nil(HeaderLimits{MaxCount: 3, MaxSize: 111}.Check("request", header))
+		assert.EqualError(HeaderLimits{MaxCount: 2}.Check("request", header), "request headers exceed the limit of 2 fields")
+		assert.EqualError(HeaderLimits{MaxSize: 110}.Check("forwarded", header), "forwarded headers exceed the limit of 110 bytes")
+	})
+
+	t.Run("Enforce", func(t *testing.T) {
+		assert := assert.New(t)
+		handler := HeaderLimits{MaxCount: 2}.Enforce(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header = header
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.EqualValues(http.StatusRequestHeaderFieldsTooLarge, w.Code)
+		assert.JSONEq(`{"message":"request headers exceed the limit of 2 fields","code":"headers_too_large"}`, w.Body.String())
+
+		r.Header = http.Header{"X-A": []string{"a"}}
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.EqualValues(http.StatusOK, w.Code)
+	})
+}
@@ -0,0 +1,124 @@
+package common
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+//hopByHopHeaders are meaningful only for a single transport-level connection and
+//are therefore never forwarded regardless of the configured rules
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+//HeaderForwardingRules describes which headers tr1d1um is allowed to forward
+//Header names and prefixes are matched case-insensitively
+type HeaderForwardingRules struct {
+	//Allowed contains header names that should be forwarded
+	Allowed []string
+
+	//Prefixes contains header name prefixes such that any header having one of them should be forwarded
+	Prefixes []string
+
+	//Denied contains header names that should never be forwarded. It takes precedence over Allowed and Prefixes
+	//Hop-by-hop headers are always denied
+	Denied []string
+}
+
+//HeaderFilter decides which headers should be forwarded based on some HeaderForwardingRules
+type HeaderFilter struct {
+	allowed  map[string]bool
+	denied   map[string]bool
+	prefixes []string
+}
+
+//NewHeaderFilter builds a HeaderFilter out of the given rules
+func NewHeaderFilter(rules HeaderForwardingRules) *HeaderFilter {
+	f := &HeaderFilter{
+		allowed: make(map[string]bool),
+		denied:  make(map[string]bool),
+	}
+
+	for _, name := range rules.Allowed {
+		f.allowed[strings.ToLower(name)] = true
+	}
+
+	for _, name := range append(rules.Denied, hopByHopHeaders...) {
+		f.denied[strings.ToLower(name)] = true
+	}
+
+	for _, prefix := range rules.Prefixes {
+		f.prefixes = append(f.prefixes, strings.ToLower(prefix))
+	}
+
+	return f
+}
+
+//NewResponseHeaderFilter builds the filter of the response headers of backends forwarded to API consumers out of the
+//given rules, DefaultResponseHeaderRules if nil. Age, which tr1d1um sets on the responses it serves from cache, is
+//allowed unless denied
+func NewResponseHeaderFilter(rules *HeaderForwardingRules) *HeaderFilter {
+	var withAge = DefaultResponseHeaderRules
+	if rules != nil {
+		withAge = *rules
+	}
+
+	withAge.Allowed = append([]string{"Age"}, withAge.Allowed...)
+	return NewHeaderFilter(withAge)
+}
+
+//Allows returns true if the header with the given name should be forwarded
+func (f *HeaderFilter) Allows(name string) bool {
+	if f == nil {
+		return false
+	}
+
+	name = strings.ToLower(name)
+
+	if f.denied[name] {
+		return false
+	}
+
+	if f.allowed[name] {
+		return true
+	}
+
+	for _, prefix := range f.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Forward copies the headers this filter allows from 'from' into 'to'
+//Headers already present in 'to' are left untouched
+//The names of the headers which were forwarded and of the ones which were not are returned, sorted, for auditing
+func (f *HeaderFilter) Forward(from http.Header, to http.Header) (forwarded, skipped []string) {
+	for headerKey, headerValues := range from {
+		if !f.Allows(headerKey) || len(to[http.CanonicalHeaderKey(headerKey)]) > 0 {
+			skipped = append(skipped, http.CanonicalHeaderKey(headerKey))
+			continue
+		}
+
+		for _, headerValue := range headerValues {
+			to.Add(headerKey, headerValue)
+		}
+
+		forwarded = append(forwarded, http.CanonicalHeaderKey(headerKey))
+	}
+
+	sort.Strings(forwarded)
+	sort.Strings(skipped)
+	return
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderFilterAllows(t *testing.T) {
+	f := NewHeaderFilter(HeaderForwardingRules{
+		Allowed:  []string{"Accept"},
+		Prefixes: []string{"x-"},
+		Denied:   []string{"X-Internal"},
+	})
+
+	tests := []struct {
+		name     string
+		header   string
+		expected bool
+	}{
+		{"Allowed", "Accept", true},
+		{"AllowedCaseInsensitive", "aCCEPT", true},
+		{"Prefix", "X-Test", true},
+		{"PrefixCaseInsensitive", "x-test", true},
+		{"Denied", "x-internal", false},
+		{"HopByHop", "Connection", false},
+		{"NoMatch", "Authorization", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, f.Allows(test.header))
+		})
+	}
+}
+
+func TestHeaderFilterNil(t *testing.T) {
+	var f *HeaderFilter
+	assert.False(t, f.Allows("X-Test"))
+}
+
+func TestHeaderFilterForward(t *testing.T) {
+	t.Run("Filtered", func(t *testing.T) {
+		assert := assert.New(t)
+		f := NewHeaderFilter(HeaderForwardingRules{Prefixes: []string{"X"}})
+
+		from := http.Header{
+			"X-A":               []string{"a", "b"},
+			"Y-A":               []string{"c"},
+			"Transfer-Encoding": []string{"chunked"},
+		}
+		to := make(http.Header)
+
+		forwarded, skipped := f.Forward(from, to)
+		assert.Equal(http.Header{"X-A": []string{"a", "b"}}, to)
+		assert.Equal([]string{"X-A"}, forwarded)
+		assert.Equal([]string{"Transfer-Encoding", "Y-A"}, skipped)
+	})
+
+	t.Run("ExistingKept", func(t *testing.T) {
+		assert := assert.New(t)
+		f := NewHeaderFilter(HeaderForwardingRules{Allowed: []string{"Authorization"}})
+
+		from := http.Header{"Authorization": []string{"inbound"}}
+		to := http.Header{"Authorization": []string{"outbound"}}
+
+		forwarded, skipped := f.Forward(from, to)
+		assert.Equal([]string{"outbound"}, to["Authorization"])
+		assert.Empty(forwarded)
+		assert.Equal([]string{"Authorization"}, skipped)
+	})
+}
@@ -0,0 +1,15 @@
+package common
+
+import "context"
+
+//DeviceHints are descriptive details of a device which help diagnose device-specific failures
+//(i.e. a parameter that is not supported on some firmware)
+type DeviceHints struct {
+	Firmware string `json:"firmware,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
+
+//DeviceHinter looks up the hints of a device
+type DeviceHinter interface {
+	DeviceHints(ctx context.Context, authHeaderValue, deviceID string) (*DeviceHints, error)
+}
@@ -0,0 +1,147 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+)
+
+//HeaderWPALane lets API consumers pick the lane of their requests
+const HeaderWPALane = "X-Webpa-Lane"
+
+//Lanes requests are classified into
+const (
+	//InteractiveLane is the lane of the requests someone is waiting on, i.e. troubleshooting a device
+	InteractiveLane = "interactive"
+
+	//BatchLane is the lane of bulk traffic, i.e. provisioning, which can afford to wait
+	BatchLane = "batch"
+)
+
+//LaneOptions classifies requests into the interactive and batch lanes, each with its own concurrency pool and
+//client of the XMiDT API, so that bulk traffic can't push interactive requests into timeouts
+type LaneOptions struct {
+	//Enabled turns the lanes on
+	Enabled bool
+
+	//Header is the header through which API consumers pick the lane of their requests. Defaults to HeaderWPALane
+	Header string
+
+	//BatchRoutes are the path templates (i.e. /api/v2/group/{group}/{service}) of the routes whose requests are
+	//in the batch lane unless they pick one through Header. Requests to other routes are interactive by default
+	BatchRoutes []string
+
+	//Interactive and Batch are the concurrency pools of the lanes. A lane is unlimited if its MaxConcurrent isn't positive
+	Interactive ConcurrencyOptions
+	Batch       ConcurrencyOptions
+}
+
+//Lanes classifies requests into lanes and bounds the number of requests of each lane served at a time
+type Lanes struct {
+	header      string
+	batchRoutes map[string]bool
+	limiters    map[string]*ConcurrencyLimiter
+}
+
+//NewLanes builds the lanes out of the given options. It returns nil if they are disabled
+func NewLanes(o LaneOptions) (*Lanes, error) {
+	if !o.Enabled {
+		return nil, nil
+	}
+
+	var l = &Lanes{
+		header:      o.Header,
+		batchRoutes: make(map[string]bool, len(o.BatchRoutes)),
+		limiters:    make(map[string]*ConcurrencyLimiter, 2),
+	}
+
+	if l.header == "" {
+		l.header = HeaderWPALane
+	}
+
+	for _, route := range o.BatchRoutes {
+		if !strings.HasPrefix(route, "/") {
+			return nil, fmt.Errorf("batch route '%s' should be an absolute path template", route)
+		}
+
+		l.batchRoutes[route] = true
+	}
+
+	for lane, options := range map[string]ConcurrencyOptions{InteractiveLane: o.Interactive, BatchLane: o.Batch} {
+		limiter, err := NewConcurrencyLimiter(options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid concurrency limits of the %s lane: %s", lane, err)
+		}
+
+		l.limiters[lane] = limiter
+	}
+
+	return l, nil
+}
+
+//Classify returns the lane of the given request: the one picked through the lane header, if valid, else the batch
+//lane for requests to batch routes and the interactive one for the others
+func (l *Lanes) Classify(r *http.Request) string {
+	switch lane := strings.ToLower(r.Header.Get(l.header)); lane {
+	case InteractiveLane, BatchLane:
+		return lane
+	}
+
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil && l.batchRoutes[template] {
+			return BatchLane
+		}
+	}
+
+	return InteractiveLane
+}
+
+//Limiter returns the middleware that classifies requests, records their lane in their context (see Lane) and makes
+//them wait for their turn in the concurrency pool of their lane
+func (l *Lanes) Limiter() alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+
+		var limited = make(map[string]http.Handler, len(l.limiters))
+		for lane, limiter := range l.limiters {
+			limited[lane] = limiter.Limiter()(next)
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var lane = l.Classify(r)
+			limited[lane].ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ContextKeyRequestLane, lane)))
+		})
+	}
+}
+
+//Lane returns the lane of the request of the given context, InteractiveLane if it wasn't classified
+func Lane(ctx context.Context) string {
+	if lane, ok := ctx.Value(ContextKeyRequestLane).(string); ok {
+		return lane
+	}
+
+	return InteractiveLane
+}
+
+//LaneDo returns the function which sends the requests of the batch lane through batch and the others through
+//interactive, so that the lanes don't compete for the connections to the XMiDT API. batch may be nil in which
+//case all requests go through interactive
+func LaneDo(interactive, batch func(*http.Request) (*http.Response, error)) func(*http.Request) (*http.Response, error) {
+	if batch == nil {
+		return interactive
+	}
+
+	return func(req *http.Request) (*http.Response, error) {
+		if Lane(req.Context()) == BatchLane {
+			return batch(req)
+		}
+
+		return interactive(req)
+	}
+}
@@ -0,0 +1,129 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLanes(t *testing.T) {
+	assert := assert.New(t)
+
+	lanes, err := NewLanes(LaneOptions{BatchRoutes: []string{"/api/v2/group/{group}/{service}"}})
+	assert.Nil(err)
+	assert.Nil(lanes)
+
+	_, err = NewLanes(LaneOptions{Enabled: true, BatchRoutes: []string{"group/{group}/{service}"}})
+	assert.NotNil(err)
+
+	_, err = NewLanes(LaneOptions{Enabled: true, Batch: ConcurrencyOptions{MaxConcurrent: 1, Weights: []TenantWeight{{Weight: 2}}}})
+	assert.NotNil(err)
+}
+
+func TestLanesClassify(t *testing.T) {
+	var (
+		lanes, _ = NewLanes(LaneOptions{Enabled: true, BatchRoutes: []string{"/api/v2/group/{group}/{service}"}})
+		router   = mux.NewRouter()
+		lane     string
+	)
+
+	router.Handle("/api/v2/group/{group}/{service}", lanes.Limiter()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lane = Lane(r.Context())
+	})))
+
+	router.Handle("/api/v2/{path:.*}", lanes.Limiter()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lane = Lane(r.Context())
+	})))
+
+	for _, testCase := range []struct {
+		path, header, expected string
+	}{
+		{path: "/api/v2/device/mac:112233445566/config", expected: InteractiveLane},
+		{path: "/api/v2/device/mac:112233445566/config", header: "Batch", expected: BatchLane},
+		{path: "/api/v2/group/gateways/config", expected: BatchLane},
+		{path: "/api/v2/group/gateways/config", header: InteractiveLane, expected: InteractiveLane},
+		{path: "/api/v2/group/gateways/config", header: "urgent", expected: BatchLane},
+	} {
+		request := httptest.NewRequest(http.MethodGet, testCase.path, nil)
+		request.Header.Set(HeaderWPALane, testCase.header)
+
+		router.ServeHTTP(httptest.NewRecorder(), request)
+		assert.Equal(t, testCase.expected, lane, "%v", testCase)
+	}
+
+	assert.Equal(t, InteractiveLane, Lane(context.Background()))
+}
+
+func TestLanesIsolation(t *testing.T) {
+	assert := assert.New(t)
+
+	lanes, err := NewLanes(LaneOptions{Enabled: true, Batch: ConcurrencyOptions{MaxConcurrent: 1, QueueTimeout: time.Minute}})
+	assert.Nil(err)
+
+	var (
+		proceed = make(chan struct{})
+		done    sync.WaitGroup
+
+		handler = lanes.Limiter()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if Lane(r.Context()) == BatchLane {
+				<-proceed
+			}
+		}))
+
+		batch = func() {
+			defer done.Done()
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set(HeaderWPALane, BatchLane)
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+		}
+	)
+
+	done.Add(2)
+	go batch()
+	awaitRequests(lanes.limiters[BatchLane], 1, 0)
+	go batch()
+	awaitRequests(lanes.limiters[BatchLane], 1, 1)
+
+	//the batch lane being saturated doesn't hold interactive requests back
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(http.StatusOK, recorder.Code)
+
+	close(proceed)
+	done.Wait()
+}
+
+func TestLaneDo(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		used string
+
+		interactive = func(*http.Request) (*http.Response, error) {
+			used = InteractiveLane
+			return nil, nil
+		}
+
+		batch = func(*http.Request) (*http.Response, error) {
+			used = BatchLane
+			return nil, nil
+		}
+
+		request = httptest.NewRequest(http.MethodGet, "/", nil)
+	)
+
+	LaneDo(interactive, nil)(request.WithContext(context.WithValue(request.Context(), ContextKeyRequestLane, BatchLane)))
+	assert.Equal(InteractiveLane, used)
+
+	LaneDo(interactive, batch)(request.WithContext(context.WithValue(request.Context(), ContextKeyRequestLane, BatchLane)))
+	assert.Equal(BatchLane, used)
+
+	LaneDo(interactive, batch)(request)
+	assert.Equal(InteractiveLane, used)
+}
@@ -0,0 +1,129 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Comcast/webpa-common/device"
+	"github.com/go-kit/kit/metrics"
+	"github.com/gorilla/mux"
+)
+
+//LegacyAPIBase is the path prefix of the version 1 of the API, which older clients still use
+const LegacyAPIBase = "api/v1"
+
+//LegacyOptions configures the compatibility router which serves the requests of clients of the version 1 of the API
+//so that they can migrate without a proxy in front of tr1d1um
+type LegacyOptions struct {
+	//Enabled turns the compatibility router on
+	Enabled bool
+
+	//Sunset is the RFC 3339 date or time after which version 1 paths are expected to stop being served (optional)
+	Sunset string
+
+	//Link points API consumers to the documentation of the migration (optional)
+	Link string
+}
+
+//legacyHandler translates the requests of version 1 clients into version 2 ones served by the main router
+type legacyHandler struct {
+	router  *mux.Router
+	base    string
+	sunset  string
+	link    string
+	counter metrics.Counter
+}
+
+//NewLegacyHandler returns the handler of the version 1 paths. Their requests are translated into requests to the
+//routes of base (i.e. "api/v2") served by router, their responses carry the deprecation headers and they are counted
+//by the given counter (optional) by version 1 route and method
+func NewLegacyHandler(o LegacyOptions, base string, router *mux.Router, counter metrics.Counter) (http.Handler, error) {
+	l := &legacyHandler{router: router, base: base, link: o.Link, counter: counter}
+
+	if o.Sunset != "" {
+		sunset, err := parseDeprecationDate(o.Sunset)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sunset of the legacy API: %s", err)
+		}
+		l.sunset = sunset
+	}
+
+	return l, nil
+}
+
+func (l *legacyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		translated = r.WithContext(r.Context())
+		url        = *r.URL
+	)
+
+	url.Path, url.RawPath = l.translatePath(r.URL.Path), ""
+	translated.URL, translated.RequestURI = &url, url.RequestURI()
+
+	w.Header().Set(HeaderDeprecation, "true")
+	if l.sunset != "" {
+		w.Header().Set(HeaderSunset, l.sunset)
+	}
+
+	if l.link != "" {
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="deprecation"`, l.link))
+	}
+
+	if l.counter != nil {
+		l.counter.With(RouteLabel, l.route(translated), MethodLabel, r.Method).Add(1)
+	}
+
+	l.router.ServeHTTP(w, translated)
+}
+
+//translatePath turns a version 1 path into its version 2 counterpart. Version 1 clients may address devices by
+//their bare MAC address (i.e. 112233445566 or 11:22:33:44:55:66), which is turned into a mac: device ID
+func (l *legacyHandler) translatePath(path string) string {
+	var segments = strings.Split(strings.TrimPrefix(path, "/"+LegacyAPIBase+"/"), "/")
+
+	for i := 1; i < len(segments); i++ {
+		if segments[i-1] != "device" {
+			continue
+		}
+
+		if _, err := device.ParseID(segments[i]); err != nil {
+			if mac := legacyMAC(segments[i]); mac != "" {
+				segments[i] = "mac:" + mac
+			}
+		}
+	}
+
+	return "/" + l.base + "/" + strings.Join(segments, "/")
+}
+
+//legacyMAC returns the given MAC address without separators, or an empty string if it isn't one
+func legacyMAC(value string) string {
+	var mac = strings.NewReplacer(":", "", "-", "", ".", "").Replace(strings.ToLower(value))
+	if len(mac) != 12 {
+		return ""
+	}
+
+	for _, c := range mac {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return ""
+		}
+	}
+
+	return mac
+}
+
+//route returns the version 1 counterpart of the path template of the route the translated request matches
+func (l *legacyHandler) route(translated *http.Request) string {
+	var match mux.RouteMatch
+	if !l.router.Match(translated, &match) || match.Route == nil {
+		return "/" + LegacyAPIBase + "/"
+	}
+
+	template, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return "/" + LegacyAPIBase + "/"
+	}
+
+	return strings.Replace(template, "/"+l.base+"/", "/"+LegacyAPIBase+"/", 1)
+}
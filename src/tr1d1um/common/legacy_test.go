@@ -0,0 +1,65 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLegacyMAC(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.EqualValues("112233aabbcc", legacyMAC("112233AABBCC"))
+	assert.EqualValues("112233aabbcc", legacyMAC("11:22:33:aa:bb:cc"))
+	assert.EqualValues("112233aabbcc", legacyMAC("11-22-33-AA-BB-CC"))
+	assert.Empty(legacyMAC("112233"))
+	assert.Empty(legacyMAC("11223344556g"))
+}
+
+func TestLegacyHandler(t *testing.T) {
+	_, err := NewLegacyHandler(LegacyOptions{Enabled: true, Sunset: "soon"}, "api/v2", mux.NewRouter(), nil)
+	assert.NotNil(t, err)
+
+	var (
+		router  = mux.NewRouter()
+		counter = &splitCounter{counts: make(map[string]float64)}
+		served  string
+	)
+
+	router.Handle("/api/v2/device/{deviceid}/{service}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = mux.Vars(r)["deviceid"] + " " + r.URL.RawQuery
+	})).Methods(http.MethodGet)
+
+	handler, err := NewLegacyHandler(LegacyOptions{Enabled: true, Sunset: "2020-06-30", Link: "https://example.com/migration"}, "api/v2", router, counter)
+	assert.Nil(t, err)
+	router.PathPrefix("/api/v1/").Handler(handler)
+
+	t.Run("Translated", func(t *testing.T) {
+		assert := assert.New(t)
+
+		for _, deviceID := range []string{"mac:112233445566", "112233445566", "11:22:33:44:55:66"} {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/device/"+deviceID+"/config?names=Device.DeviceInfo.UpTime", nil))
+
+			assert.Equal(http.StatusOK, w.Code)
+			assert.EqualValues("mac:112233445566 names=Device.DeviceInfo.UpTime", served)
+			assert.EqualValues("true", w.Header().Get(HeaderDeprecation))
+			assert.EqualValues("Tue, 30 Jun 2020 00:00:00 GMT", w.Header().Get(HeaderSunset))
+			assert.EqualValues(`<https://example.com/migration>; rel="deprecation"`, w.Header().Get("Link"))
+		}
+
+		assert.EqualValues(3, counter.counts["/api/v1/device/{deviceid}/{service}/GET"])
+	})
+
+	t.Run("Unmatched", func(t *testing.T) {
+		assert := assert.New(t)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/hooks", nil))
+
+		assert.Equal(http.StatusNotFound, w.Code)
+		assert.EqualValues(1, counter.counts["/api/v1//GET"])
+	})
+}
@@ -0,0 +1,139 @@
+package common
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//MessageCatalog holds the translations of error messages: language tag (i.e. es or fr-CA) to English message
+//to translated message. Messages without a translation are sent in English
+//The catalog of a server is part of its Settings
+type MessageCatalog map[string]map[string]string
+
+//MessageTranslation is the translation of an error message in a language
+type MessageTranslation struct {
+	//Language is the language tag (i.e. es or fr-CA) of the translation
+	Language string
+
+	//Message is the English message, as sent when there is no translation
+	Message string
+
+	Translation string
+}
+
+//builtinMessages are the translations of the most common error messages shipped with tr1d1um
+var builtinMessages = MessageCatalog{
+	"es": {
+		"oops! Something unexpected went wrong in this service":       "¡Vaya! Algo inesperado salió mal en este servicio",
+		"timed out connecting to the XMiDT API":                       "se agotó el tiempo de conexión con la API de XMiDT",
+		"timed out waiting for a response from the XMiDT API":         "se agotó el tiempo de espera de una respuesta de la API de XMiDT",
+		"attempt timed out waiting for a response from the XMiDT API": "se agotó el tiempo de espera de un intento de respuesta de la API de XMiDT",
+		"transaction with the XMiDT API exceeded the allowed time":    "la transacción con la API de XMiDT superó el tiempo permitido",
+		"unsupported Service":         "servicio no admitido",
+		"names parameter is required": "el parámetro names es obligatorio",
+		"invalid XPC SET message":     "mensaje SET de XPC no válido",
+		"malformed upstream response": "respuesta del servidor de origen mal formada",
+	},
+	"fr": {
+		"oops! Something unexpected went wrong in this service":       "oups ! Une erreur inattendue s'est produite dans ce service",
+		"timed out connecting to the XMiDT API":                       "délai dépassé lors de la connexion à l'API XMiDT",
+		"timed out waiting for a response from the XMiDT API":         "délai dépassé en attendant une réponse de l'API XMiDT",
+		"attempt timed out waiting for a response from the XMiDT API": "délai dépassé pour une tentative en attendant une réponse de l'API XMiDT",
+		"transaction with the XMiDT API exceeded the allowed time":    "la transaction avec l'API XMiDT a dépassé le temps imparti",
+		"unsupported Service":         "service non pris en charge",
+		"names parameter is required": "le paramètre names est obligatoire",
+		"invalid XPC SET message":     "message SET XPC invalide",
+		"malformed upstream response": "réponse du serveur en amont mal formée",
+	},
+}
+
+//newMessageCatalog adds the given translations to the built-in ones, which they take precedence over
+//Language tags are case insensitive
+func newMessageCatalog(translations []MessageTranslation) MessageCatalog {
+	var merged = make(MessageCatalog, len(builtinMessages))
+	for language, messages := range builtinMessages {
+		merged[language] = make(map[string]string, len(messages))
+		for message, translation := range messages {
+			merged[language][message] = translation
+		}
+	}
+
+	for _, t := range translations {
+		var language = strings.ToLower(t.Language)
+		if merged[language] == nil {
+			merged[language] = make(map[string]string)
+		}
+
+		merged[language][t.Message] = t.Translation
+	}
+
+	return merged
+}
+
+//LocalizeMessage returns the translation of the given error message in the language the API consumer prefers,
+//as per the Accept-Language header of the request of the given context, along with that language
+//The message is returned untouched, with no language, if there is no translation for any of the accepted languages
+func LocalizeMessage(ctx context.Context, message string) (string, string) {
+	inboundHeaders := RequestHeaders(ctx)
+	if inboundHeaders == nil {
+		return message, ""
+	}
+
+	var catalog = settings(ctx).messages
+	for _, language := range acceptedLanguages(inboundHeaders.Get("Accept-Language")) {
+		if strings.HasPrefix(language, "en") {
+			return message, ""
+		}
+
+		for _, candidate := range []string{language, strings.SplitN(language, "-", 2)[0]} {
+			if translation, ok := catalog[candidate][message]; ok {
+				return translation, candidate
+			}
+		}
+	}
+
+	return message, ""
+}
+
+//acceptedLanguages returns the lowercase language tags of the given Accept-Language header by decreasing preference
+//Languages with a zero weight and wildcards are left out
+func acceptedLanguages(header string) []string {
+	type weighted struct {
+		language string
+		q        float64
+	}
+
+	var languages []weighted
+	for _, part := range strings.Split(header, ",") {
+		var (
+			fields   = strings.Split(part, ";")
+			language = strings.ToLower(strings.TrimSpace(fields[0]))
+			q        = 1.0
+		)
+
+		for _, param := range fields[1:] {
+			if param = strings.TrimSpace(param); strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		if language != "" && language != "*" && q > 0 {
+			languages = append(languages, weighted{language, q})
+		}
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].q > languages[j].q
+	})
+
+	var tags = make([]string, len(languages))
+	for i, l := range languages {
+		tags[i] = l.language
+	}
+
+	return tags
+}
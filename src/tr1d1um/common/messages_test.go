@@ -0,0 +1,58 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptedLanguages(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(acceptedLanguages(""))
+	assert.EqualValues([]string{"fr-ca", "es", "en"}, acceptedLanguages("en;q=0.5, fr-CA, *;q=0.1, es;q=0.8, de;q=0"))
+}
+
+func TestLocalizeMessage(t *testing.T) {
+	settings, err := NewSettings(PartnerOptions{}, TIDOptions{}, []MessageTranslation{
+		{Language: "ES", Message: "names parameter is required", Translation: "faltan los nombres"},
+		{Language: "de", Message: ErrTr1d1umInternal.Error(), Translation: "Hoppla! Etwas Unerwartetes ist schiefgelaufen"},
+	})
+	assert.Nil(t, err)
+
+	localize := func(acceptLanguage, message string) (string, string) {
+		ctx := context.WithValue(context.Background(), ContextKeyRequestHeaders, http.Header{"Accept-Language": []string{acceptLanguage}})
+		return LocalizeMessage(WithSettings(ctx, settings), message)
+	}
+
+	testCases := []struct {
+		name           string
+		acceptLanguage string
+		message        string
+		expected       string
+		language       string
+	}{
+		{"NoHeader", "", ErrTr1d1umInternal.Error(), ErrTr1d1umInternal.Error(), ""},
+		{"English", "en-US, fr;q=0.5", ErrTr1d1umInternal.Error(), ErrTr1d1umInternal.Error(), ""},
+		{"Builtin", "fr-CA", ErrTr1d1umInternal.Error(), "oups ! Une erreur inattendue s'est produite dans ce service", "fr"},
+		{"Configured", "de", ErrTr1d1umInternal.Error(), "Hoppla! Etwas Unerwartetes ist schiefgelaufen", "de"},
+		{"Override", "es", "names parameter is required", "faltan los nombres", "es"},
+		{"Preference", "it, es;q=0.9", "invalid XPC SET message", "mensaje SET de XPC no válido", "es"},
+		{"Untranslated", "es", "some dynamic message", "some dynamic message", ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			message, language := localize(testCase.acceptLanguage, testCase.message)
+			assert.EqualValues(testCase.expected, message)
+			assert.EqualValues(testCase.language, language)
+		})
+	}
+
+	message, _ := LocalizeMessage(context.Background(), ErrTr1d1umInternal.Error())
+	assert.EqualValues(t, ErrTr1d1umInternal.Error(), message)
+}
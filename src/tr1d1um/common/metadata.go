@@ -0,0 +1,167 @@
+package common
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//Device metadata defaults
+const (
+	DefaultMetadataTTL        = 24 * time.Hour
+	DefaultMetadataMaxEntries = 100000
+)
+
+//DeviceMetadata is what tr1d1um last learned about a device from its statistics and the WRP messages it sent back
+type DeviceMetadata struct {
+	DeviceID string `json:"deviceId"`
+
+	//LastSeen is the last time the device was found connected
+	LastSeen time.Time `json:"lastSeen"`
+
+	Firmware string `json:"firmware,omitempty"`
+	Model    string `json:"model,omitempty"`
+
+	//Partner is the partner the device belongs to
+	Partner string `json:"partner,omitempty"`
+}
+
+//merge sets the non-empty fields of the given metadata over the ones of m
+func (m *DeviceMetadata) merge(update DeviceMetadata) {
+	if update.LastSeen.After(m.LastSeen) {
+		m.LastSeen = update.LastSeen
+	}
+
+	for _, field := range []struct{ value, update *string }{
+		{&m.Firmware, &update.Firmware},
+		{&m.Model, &update.Model},
+		{&m.Partner, &update.Partner},
+	} {
+		if *field.update != "" {
+			*field.value = *field.update
+		}
+	}
+}
+
+//DeviceMetadataStore keeps the metadata of devices
+//Implementations backed by shared storage (i.e. redis) let tr1d1um instances share what they learn about devices
+type DeviceMetadataStore interface {
+	//Merge records the non-empty fields of the given metadata over the ones known of its device
+	Merge(m DeviceMetadata) error
+
+	//Get returns the metadata of the given device or nil if none is known
+	Get(deviceID string) (*DeviceMetadata, error)
+}
+
+//MetadataOptions configures the in-memory store of device metadata
+type MetadataOptions struct {
+	//TTL is the time the metadata of a device is kept for after it was last updated. Defaults to DefaultMetadataTTL
+	TTL time.Duration
+
+	//MaxEntries bounds the number of devices whose metadata is kept. Defaults to DefaultMetadataMaxEntries
+	MaxEntries int
+}
+
+type metadataEntry struct {
+	metadata  DeviceMetadata
+	updatedAt time.Time
+}
+
+//NewMemoryMetadataStore returns a DeviceMetadataStore which keeps the metadata learned by this tr1d1um instance only
+func NewMemoryMetadataStore(o MetadataOptions) DeviceMetadataStore {
+	if o.TTL <= 0 {
+		o.TTL = DefaultMetadataTTL
+	}
+
+	if o.MaxEntries < 1 {
+		o.MaxEntries = DefaultMetadataMaxEntries
+	}
+
+	return &memoryMetadataStore{
+		options: o,
+		entries: make(map[string]*metadataEntry),
+		now:     time.Now,
+	}
+}
+
+type memoryMetadataStore struct {
+	options MetadataOptions
+	lock    sync.Mutex
+	entries map[string]*metadataEntry
+	now     func() time.Time
+}
+
+func (m *memoryMetadataStore) Merge(update DeviceMetadata) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var now = m.now()
+
+	entry, ok := m.entries[update.DeviceID]
+	if !ok || now.Sub(entry.updatedAt) >= m.options.TTL {
+		if len(m.entries) >= m.options.MaxEntries {
+			m.evict(now)
+		}
+
+		if len(m.entries) >= m.options.MaxEntries {
+			return nil
+		}
+
+		entry = &metadataEntry{metadata: DeviceMetadata{DeviceID: update.DeviceID}}
+		m.entries[update.DeviceID] = entry
+	}
+
+	entry.metadata.merge(update)
+	entry.updatedAt = now
+	return nil
+}
+
+//evict forgets the devices whose metadata expired
+func (m *memoryMetadataStore) evict(now time.Time) {
+	for deviceID, entry := range m.entries {
+		if now.Sub(entry.updatedAt) >= m.options.TTL {
+			delete(m.entries, deviceID)
+		}
+	}
+}
+
+func (m *memoryMetadataStore) Get(deviceID string) (*DeviceMetadata, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	entry, ok := m.entries[deviceID]
+	if !ok || m.now().Sub(entry.updatedAt) >= m.options.TTL {
+		return nil, nil
+	}
+
+	var metadata = entry.metadata
+	return &metadata, nil
+}
+
+//NewMetadataHinter decorates the given hinter (optional) so that the hints of devices whose firmware and model are
+//known from their metadata don't need to be looked up. Hints looked up are recorded into the metadata
+func NewMetadataHinter(next DeviceHinter, store DeviceMetadataStore) DeviceHinter {
+	return &metadataHinter{next: next, store: store}
+}
+
+type metadataHinter struct {
+	next  DeviceHinter
+	store DeviceMetadataStore
+}
+
+func (h *metadataHinter) DeviceHints(ctx context.Context, authHeaderValue, deviceID string) (*DeviceHints, error) {
+	if metadata, err := h.store.Get(deviceID); err == nil && metadata != nil && (metadata.Firmware != "" || metadata.Model != "") {
+		return &DeviceHints{Firmware: metadata.Firmware, Model: metadata.Model}, nil
+	}
+
+	if h.next == nil {
+		return nil, nil
+	}
+
+	hints, err := h.next.DeviceHints(ctx, authHeaderValue, deviceID)
+	if err == nil && hints != nil {
+		h.store.Merge(DeviceMetadata{DeviceID: deviceID, Firmware: hints.Firmware, Model: hints.Model})
+	}
+
+	return hints, err
+}
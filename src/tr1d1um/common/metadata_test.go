@@ -0,0 +1,98 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryMetadataStore(t *testing.T) {
+	var (
+		now   = time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+		store = NewMemoryMetadataStore(MetadataOptions{TTL: time.Hour, MaxEntries: 1}).(*memoryMetadataStore)
+	)
+
+	store.now = func() time.Time { return now }
+
+	t.Run("Merge", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.Nil(store.Merge(DeviceMetadata{DeviceID: "mac:112233445566", LastSeen: now, Firmware: "fw-1.2", Partner: "comcast"}))
+		assert.Nil(store.Merge(DeviceMetadata{DeviceID: "mac:112233445566", Model: "XB6", LastSeen: now.Add(-time.Minute)}))
+
+		metadata, err := store.Get("mac:112233445566")
+		assert.Nil(err)
+		assert.EqualValues(&DeviceMetadata{DeviceID: "mac:112233445566", LastSeen: now, Firmware: "fw-1.2", Model: "XB6", Partner: "comcast"}, metadata)
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.Nil(store.Merge(DeviceMetadata{DeviceID: "mac:665544332211", Firmware: "fw-2"}))
+		metadata, _ := store.Get("mac:665544332211")
+		assert.Nil(metadata)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		assert := assert.New(t)
+		now = now.Add(time.Hour)
+
+		metadata, _ := store.Get("mac:112233445566")
+		assert.Nil(metadata)
+
+		assert.Nil(store.Merge(DeviceMetadata{DeviceID: "mac:665544332211", Firmware: "fw-2"}))
+		metadata, _ = store.Get("mac:665544332211")
+		assert.EqualValues("fw-2", metadata.Firmware)
+	})
+}
+
+//hinterFunc allows plain functions to act as a DeviceHinter
+type hinterFunc func(context.Context, string, string) (*DeviceHints, error)
+
+func (f hinterFunc) DeviceHints(ctx context.Context, authHeaderValue, deviceID string) (*DeviceHints, error) {
+	return f(ctx, authHeaderValue, deviceID)
+}
+
+func TestMetadataHinter(t *testing.T) {
+	var (
+		store   = NewMemoryMetadataStore(MetadataOptions{})
+		lookups int
+		next    = hinterFunc(func(context.Context, string, string) (*DeviceHints, error) {
+			lookups++
+			if lookups > 1 {
+				return nil, errors.New("statistics unavailable")
+			}
+
+			return &DeviceHints{Firmware: "fw-1.2", Model: "XB6"}, nil
+		})
+	)
+
+	t.Run("LookedUp", func(t *testing.T) {
+		assert := assert.New(t)
+		hinter := NewMetadataHinter(next, store)
+
+		for i := 0; i < 2; i++ {
+			hints, err := hinter.DeviceHints(context.Background(), "", "mac:112233445566")
+			assert.Nil(err)
+			assert.EqualValues(&DeviceHints{Firmware: "fw-1.2", Model: "XB6"}, hints)
+		}
+
+		assert.Equal(1, lookups)
+	})
+
+	t.Run("MetadataOnly", func(t *testing.T) {
+		assert := assert.New(t)
+		hinter := NewMetadataHinter(nil, store)
+
+		hints, err := hinter.DeviceHints(context.Background(), "", "mac:112233445566")
+		assert.Nil(err)
+		assert.EqualValues("XB6", hints.Model)
+
+		hints, err = hinter.DeviceHints(context.Background(), "", "mac:665544332211")
+		assert.Nil(err)
+		assert.Nil(hints)
+	})
+}
@@ -0,0 +1,254 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+)
+
+//Names for the metrics tr1d1um reports
+const (
+	ClientCanceledCounter    = "client_canceled"
+	TimeoutsCounter          = "xmidt_timeouts"
+	DeviceErrorsCounter      = "device_errors"
+	MirroredCounter          = "mirrored_requests"
+	CanaryRequestsCounter    = "canary_requests"
+	RawServiceQueriesCounter = "raw_service_queries"
+	DNSLookupsCounter        = "dns_cache_lookups"
+	DeprecatedCounter        = "deprecated_requests"
+	PassthroughCounter       = "passthrough_responses"
+
+	XmidtLatencyHistogram      = "xmidt_request_duration_seconds"
+	XmidtResponseSizeHistogram = "xmidt_response_size_bytes"
+)
+
+//Label names for the metrics tr1d1um reports
+const (
+	TimeoutStageLabel = "stage"
+	StatusLabel       = "status"
+	FirmwareLabel     = "firmware"
+	ModelLabel        = "model"
+	OutcomeLabel      = "outcome"
+	TargetLabel       = "target"
+	ServiceLabel      = "service"
+	RouteLabel        = "route"
+	MethodLabel       = "method"
+)
+
+//Outcomes of mirrored requests
+const (
+	MirrorMatch    = "match"
+	MirrorMismatch = "mismatch"
+	MirrorError    = "error"
+)
+
+//Metrics returns the metrics tr1d1um reports. It is meant to be passed to the server initialization
+func Metrics() []xmetrics.Metric {
+	return []xmetrics.Metric{
+		{
+			Name: ClientCanceledCounter,
+			Type: "counter",
+			Help: "Count of requests whose client disconnected before tr1d1um could respond",
+		},
+		{
+			Name:       TimeoutsCounter,
+			Type:       "counter",
+			Help:       "Count of timed out transactions with the XMiDT API by the stage at which they timed out",
+			LabelNames: []string{TimeoutStageLabel},
+		},
+		{
+			Name:       DeviceErrorsCounter,
+			Type:       "counter",
+			Help:       "Count of failures reported by devices by response status code and device firmware and model (if known)",
+			LabelNames: []string{StatusLabel, FirmwareLabel, ModelLabel},
+		},
+		{
+			Name:       MirroredCounter,
+			Type:       "counter",
+			Help:       "Count of requests mirrored to the secondary backend by whether its response matched the primary one",
+			LabelNames: []string{OutcomeLabel},
+		},
+		{
+			Name:       CanaryRequestsCounter,
+			Type:       "counter",
+			Help:       "Count of requests split between the primary and canary backends by backend and outcome",
+			LabelNames: []string{TargetLabel, OutcomeLabel},
+		},
+		{
+			Name:       RawServiceQueriesCounter,
+			Type:       "counter",
+			Help:       "Count of requests to raw services (i.e. iot) rejected for passing data through query parameters rather than the body",
+			LabelNames: []string{ServiceLabel},
+		},
+		{
+			Name:       DNSLookupsCounter,
+			Type:       "counter",
+			Help:       "Count of the lookups of XMiDT API hosts through the DNS cache by outcome (hit, miss, stale, negative or error)",
+			LabelNames: []string{OutcomeLabel},
+		},
+		{
+			Name:       DeprecatedCounter,
+			Type:       "counter",
+			Help:       "Count of requests to deprecated routes by route and method",
+			LabelNames: []string{RouteLabel, MethodLabel},
+		},
+		{
+			Name: PassthroughCounter,
+			Type: "counter",
+			Help: "Count of XMiDT responses passed through to API consumers as is, without being decoded and re-encoded",
+		},
+		{
+			Name:    XmidtLatencyHistogram,
+			Type:    "histogram",
+			Help:    "A histogram of the latencies of the transactions with the XMiDT API, which include the time devices take to respond",
+			Buckets: []float64{0.25, 0.5, 1, 2.5, 5, 10, 20, 40, 80},
+		},
+		{
+			Name:    XmidtResponseSizeHistogram,
+			Type:    "histogram",
+			Help:    "A histogram of the sizes of the responses of the XMiDT API",
+			Buckets: []float64{256, 1024, 4096, 16384, 65536, 262144, 1048576},
+		},
+	}
+}
+
+//HistogramBuckets are the bucket boundaries of a histogram, which override the ones it is defined with
+type HistogramBuckets struct {
+	//Name is the name of the histogram without namespace nor subsystem (i.e. xmidt_request_duration_seconds)
+	Name string
+
+	//Buckets are the upper bounds of the buckets, in increasing order
+	Buckets []float64
+}
+
+//Validate returns an error if the buckets are not in increasing order
+func (b HistogramBuckets) Validate() error {
+	if len(b.Buckets) == 0 {
+		return fmt.Errorf("histogram '%s' has no buckets", b.Name)
+	}
+
+	for i := 1; i < len(b.Buckets); i++ {
+		if b.Buckets[i] <= b.Buckets[i-1] {
+			return fmt.Errorf("buckets of histogram '%s' should be in increasing order", b.Name)
+		}
+	}
+
+	return nil
+}
+
+//WithHistogramBuckets decorates the given metric modules so that their histograms have the buckets returned by
+//buckets, if any. buckets is only called as metrics are registered, by which time the configuration has been read
+//Invalid buckets are ignored (see ValidateHistogramBuckets) as they would fail the registration of all metrics
+func WithHistogramBuckets(buckets func() []HistogramBuckets, modules ...xmetrics.Module) []xmetrics.Module {
+	var decorated = make([]xmetrics.Module, 0, len(modules))
+
+	for _, module := range modules {
+		module := module
+
+		decorated = append(decorated, func() []xmetrics.Metric {
+			var (
+				metrics   = module()
+				overrides = make(map[string][]float64)
+			)
+
+			for _, b := range buckets() {
+				if b.Validate() == nil {
+					overrides[b.Name] = b.Buckets
+				}
+			}
+
+			for i, metric := range metrics {
+				if override, ok := overrides[metric.Name]; ok && metric.Type == "histogram" {
+					metrics[i].Buckets = override
+				}
+			}
+
+			return metrics
+		})
+	}
+
+	return decorated
+}
+
+//ValidateHistogramBuckets returns an error if some of the given buckets are invalid or don't belong to a histogram
+//of the given modules
+func ValidateHistogramBuckets(buckets []HistogramBuckets, modules ...xmetrics.Module) error {
+	var histograms = make(map[string]bool)
+
+	for _, module := range modules {
+		for _, metric := range module() {
+			if metric.Type == "histogram" {
+				histograms[metric.Name] = true
+			}
+		}
+	}
+
+	for _, b := range buckets {
+		if !histograms[b.Name] {
+			return fmt.Errorf("no histogram named '%s'", b.Name)
+		}
+
+		if err := b.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//Measures groups the tr1d1um metric instruments
+type Measures struct {
+	ClientCanceled metrics.Counter
+	Timeouts       metrics.Counter
+	DeviceErrors   metrics.Counter
+	Mirrored       metrics.Counter
+	CanaryRequests metrics.Counter
+
+	RawServiceQueries metrics.Counter
+	DNSLookups        metrics.Counter
+	Deprecated        metrics.Counter
+	Passthrough       metrics.Counter
+
+	XmidtLatency      metrics.Histogram
+	XmidtResponseSize metrics.Histogram
+}
+
+//NewMeasures builds the tr1d1um measures out of the given registry
+//If the registry is nil, all measures discard their observations
+func NewMeasures(r xmetrics.Registry) *Measures {
+	if r == nil {
+		return &Measures{
+			ClientCanceled: discard.NewCounter(),
+			Timeouts:       discard.NewCounter(),
+			DeviceErrors:   discard.NewCounter(),
+			Mirrored:       discard.NewCounter(),
+			CanaryRequests: discard.NewCounter(),
+
+			RawServiceQueries: discard.NewCounter(),
+			DNSLookups:        discard.NewCounter(),
+			Deprecated:        discard.NewCounter(),
+			Passthrough:       discard.NewCounter(),
+
+			XmidtLatency:      discard.NewHistogram(),
+			XmidtResponseSize: discard.NewHistogram(),
+		}
+	}
+
+	return &Measures{
+		ClientCanceled: r.NewCounter(ClientCanceledCounter),
+		Timeouts:       r.NewCounter(TimeoutsCounter),
+		DeviceErrors:   r.NewCounter(DeviceErrorsCounter),
+		Mirrored:       r.NewCounter(MirroredCounter),
+		CanaryRequests: r.NewCounter(CanaryRequestsCounter),
+
+		RawServiceQueries: r.NewCounter(RawServiceQueriesCounter),
+		DNSLookups:        r.NewCounter(DNSLookupsCounter),
+		Deprecated:        r.NewCounter(DeprecatedCounter),
+		Passthrough:       r.NewCounter(PassthroughCounter),
+
+		XmidtLatency:      r.NewHistogram(XmidtLatencyHistogram, 0),
+		XmidtResponseSize: r.NewHistogram(XmidtResponseSizeHistogram, 0),
+	}
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	assert := assert.New(t)
+	m := Metrics()
+
+	assert.NotEmpty(m)
+	for _, metric := range m {
+		assert.NotEmpty(metric.Name)
+		assert.NotEmpty(metric.Type)
+	}
+}
+
+func TestNewMeasuresNilRegistry(t *testing.T) {
+	assert := assert.New(t)
+	m := NewMeasures(nil)
+
+	assert.NotNil(m.ClientCanceled)
+	assert.NotNil(m.Timeouts)
+	assert.NotNil(m.DeviceErrors)
+	assert.NotPanics(func() {
+		m.ClientCanceled.Add(1)
+		m.Timeouts.With(TimeoutStageLabel, TimeoutStageBackend).Add(1)
+		m.DeviceErrors.With(StatusLabel, "404", FirmwareLabel, "unknown", ModelLabel, "unknown").Add(1)
+	})
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		buckets = []HistogramBuckets{
+			{Name: XmidtLatencyHistogram, Buckets: []float64{20, 30, 40, 50, 60}},
+			{Name: DeprecatedCounter, Buckets: []float64{1}},
+			{Name: XmidtResponseSizeHistogram, Buckets: []float64{2, 1}},
+		}
+
+		modules = WithHistogramBuckets(func() []HistogramBuckets { return buckets }, Metrics)
+	)
+
+	for _, metric := range modules[0]() {
+		switch metric.Name {
+		case XmidtLatencyHistogram:
+			assert.Equal([]float64{20, 30, 40, 50, 60}, metric.Buckets)
+		case XmidtResponseSizeHistogram:
+			assert.Equal([]float64{256, 1024, 4096, 16384, 65536, 262144, 1048576}, metric.Buckets)
+		default:
+			assert.Empty(metric.Buckets)
+		}
+	}
+
+	assert.Nil(ValidateHistogramBuckets(buckets[:1], Metrics))
+	assert.NotNil(ValidateHistogramBuckets(buckets[1:2], Metrics))
+	assert.NotNil(ValidateHistogramBuckets(buckets[2:], Metrics))
+	assert.NotNil(ValidateHistogramBuckets([]HistogramBuckets{{Name: XmidtLatencyHistogram}}, Metrics))
+}
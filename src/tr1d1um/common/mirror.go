@@ -0,0 +1,177 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	kitlog "github.com/go-kit/kit/log"
+)
+
+//DefaultMirrorTimeout is the time mirrored requests are given to complete when no timeout is configured
+const DefaultMirrorTimeout = 30 * time.Second
+
+//MirrorOptions configures the mirroring of read-only requests to a secondary backend
+type MirrorOptions struct {
+	//TargetURL is the base URL of the secondary XMiDT API. It has the same format as the default targetURL
+	//Mirroring is disabled if empty
+	TargetURL string
+
+	//Percentage is the share (0 to 100) of read-only requests which are mirrored
+	Percentage float64
+
+	//Timeout is the time mirrored requests are given to complete. Defaults to DefaultMirrorTimeout
+	Timeout time.Duration
+
+	//LogDiffs indicates whether mirrored responses which differ from the primary ones should be logged
+	LogDiffs bool
+}
+
+//Validate returns an error if the mirroring options are invalid
+func (o MirrorOptions) Validate() error {
+	if o.Percentage < 0 || o.Percentage > 100 {
+		return fmt.Errorf("mirror percentage should be between 0 and 100 but was %v", o.Percentage)
+	}
+	return nil
+}
+
+//NewMirrorTransactor decorates the given transactor so that a share of the read-only requests to defaultTarget are also sent
+//through mirror to the secondary backend. Mirrored requests are sent in the background and their responses are discarded
+//once compared to the primary ones. The primary response is never affected by the mirror
+func NewMirrorTransactor(next, mirror Tr1d1umTransactor, o MirrorOptions, defaultTarget string, logger kitlog.Logger, measures *Measures) Tr1d1umTransactor {
+	if o.TargetURL == "" || o.Percentage <= 0 {
+		return next
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultMirrorTimeout
+	}
+
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
+	if measures == nil {
+		measures = NewMeasures(nil)
+	}
+
+	return &mirrorTransactor{
+		next:          next,
+		mirror:        mirror,
+		options:       o,
+		defaultTarget: defaultTarget,
+		logger:        logger,
+		measures:      measures,
+		sample:        rand.Float64,
+	}
+}
+
+type mirrorTransactor struct {
+	next          Tr1d1umTransactor
+	mirror        Tr1d1umTransactor
+	options       MirrorOptions
+	defaultTarget string
+	logger        kitlog.Logger
+	measures      *Measures
+
+	//sample returns a number in [0, 1) used to decide whether a request is mirrored
+	sample func() float64
+}
+
+func (m *mirrorTransactor) Transact(req *http.Request) (*XmidtResponse, error) {
+	mirrored := m.mirrored(req)
+	result, err := m.next.Transact(req)
+
+	if mirrored != nil {
+		go m.compare(mirrored, result, err)
+	}
+
+	return result, err
+}
+
+//mirrored returns a copy of the given request to be sent to the secondary backend or nil if it should not be mirrored
+func (m *mirrorTransactor) mirrored(req *http.Request) *http.Request {
+	if RequestMethod(req.Context()) != http.MethodGet {
+		return nil
+	}
+
+	if !targets(req, m.defaultTarget) || m.sample()*100 >= m.options.Percentage {
+		return nil
+	}
+
+	var body io.ReadCloser
+	if req.GetBody != nil {
+		var err error
+		if body, err = req.GetBody(); err != nil {
+			return nil
+		}
+	} else if req.Body != nil && req.Body != http.NoBody {
+		//the body could not be read twice
+		return nil
+	}
+
+	//the mirrored request should outlive the incoming one
+	var (
+		inbound = req.Context()
+		ctx     = context.WithValue(context.Background(), ContextKeyRequestHeaders, RequestHeaders(inbound))
+	)
+
+	ctx = context.WithValue(ctx, ContextKeyRequestTID, RequestTID(inbound))
+	ctx = WithSettings(ctx, settings(inbound))
+
+	mirrored := req.WithContext(ctx)
+	mirrored.Body, mirrored.Header = body, make(http.Header, len(req.Header))
+
+	for name, values := range req.Header {
+		mirrored.Header[name] = append([]string(nil), values...)
+	}
+
+	if err := retarget(mirrored, m.defaultTarget, m.options.TargetURL); err != nil {
+		return nil
+	}
+
+	return mirrored
+}
+
+//compare sends the mirrored request and compares its response to the primary one
+func (m *mirrorTransactor) compare(mirrored *http.Request, primary *XmidtResponse, primaryErr error) {
+	ctx, cancel := context.WithTimeout(mirrored.Context(), m.options.Timeout)
+	defer cancel()
+
+	result, err := m.mirror.Transact(mirrored.WithContext(ctx))
+
+	var outcome = MirrorMatch
+	switch {
+	case err != nil:
+		outcome = MirrorError
+	case primaryErr != nil || primary.Code != result.Code || !bytes.Equal(primary.Body, result.Body):
+		outcome = MirrorMismatch
+	}
+
+	m.measures.Mirrored.With(OutcomeLabel, outcome).Add(1)
+
+	if !m.options.LogDiffs || outcome == MirrorMatch {
+		return
+	}
+
+	var keyvals = []interface{}{logging.MessageKey(), "mirrored response differs from the primary one", "tid", RequestTID(mirrored.Context()), "outcome", outcome}
+
+	if primaryErr != nil {
+		keyvals = append(keyvals, "primaryError", primaryErr)
+	} else {
+		keyvals = append(keyvals, "primaryCode", primary.Code, "primaryBodyLength", len(primary.Body))
+	}
+
+	if err != nil {
+		keyvals = append(keyvals, "mirrorError", err)
+	} else {
+		keyvals = append(keyvals, "mirrorCode", result.Code, "mirrorBodyLength", len(result.Body))
+	}
+
+	logging.Info(m.logger).Log(keyvals...)
+}
@@ -0,0 +1,126 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMirrorOptionsValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(MirrorOptions{Percentage: 100}.Validate())
+	assert.NotNil(MirrorOptions{Percentage: -1}.Validate())
+	assert.NotNil(MirrorOptions{Percentage: 100.5}.Validate())
+}
+
+// outcomeCounter counts observations by the value of their outcome label
+type outcomeCounter struct {
+	outcomes map[string]float64
+	outcome  string
+}
+
+func (c *outcomeCounter) With(labelValues ...string) metrics.Counter {
+	return &outcomeCounter{outcomes: c.outcomes, outcome: labelValues[1]}
+}
+
+func (c *outcomeCounter) Add(delta float64) {
+	c.outcomes[c.outcome] += delta
+}
+
+func TestMirrorTransactor(t *testing.T) {
+	var (
+		primary = transactFunc(func(r *http.Request) (*XmidtResponse, error) {
+			return &XmidtResponse{Code: http.StatusOK, Body: []byte("primary")}, nil
+		})
+
+		newRequest = func(method string) *http.Request {
+			r, _ := http.NewRequest(http.MethodPost, "scytale:6000/api/v2/device", bytes.NewBufferString("wrp"))
+			r.Header.Set("Authorization", "Bearer consumer")
+
+			ctx := context.WithValue(context.Background(), ContextKeyRequestMethod, method)
+			ctx = context.WithValue(ctx, ContextKeyRequestTID, "tid")
+			return r.WithContext(ctx)
+		}
+	)
+
+	t.Run("Disabled", func(t *testing.T) {
+		_, isMirror := NewMirrorTransactor(primary, nil, MirrorOptions{TargetURL: "http://canary:6000"}, "scytale:6000", nil, nil).(*mirrorTransactor)
+		assert.False(t, isMirror)
+	})
+
+	t.Run("Mirrored", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var (
+			received = make(chan *http.Request, 1)
+			body     = make(chan []byte, 1)
+			mirror   = transactFunc(func(r *http.Request) (*XmidtResponse, error) {
+				payload, _ := ioutil.ReadAll(r.Body)
+				body <- payload
+				received <- r
+				return &XmidtResponse{Code: http.StatusOK, Body: []byte("mirror")}, nil
+			})
+		)
+
+		transactor := NewMirrorTransactor(primary, mirror, MirrorOptions{TargetURL: "http://canary:6000", Percentage: 100, LogDiffs: true},
+			"scytale:6000", nil, nil)
+
+		result, err := transactor.Transact(newRequest(http.MethodGet))
+		assert.Nil(err)
+		assert.EqualValues("primary", result.Body)
+
+		select {
+		case r := <-received:
+			assert.EqualValues("http://canary:6000/api/v2/device", r.URL.String())
+			assert.EqualValues("Bearer consumer", r.Header.Get("Authorization"))
+			assert.EqualValues("tid", r.Context().Value(ContextKeyRequestTID))
+			assert.EqualValues("wrp", <-body)
+		case <-time.After(time.Second):
+			assert.Fail("request was not mirrored")
+		}
+	})
+
+	t.Run("Outcomes", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var (
+			counter    = &outcomeCounter{outcomes: make(map[string]float64)}
+			mirror     = transactFunc(func(r *http.Request) (*XmidtResponse, error) { return primary.Transact(r) })
+			transactor = NewMirrorTransactor(primary, mirror, MirrorOptions{TargetURL: "http://canary:6000", Percentage: 100},
+				"scytale:6000", nil, &Measures{Mirrored: counter}).(*mirrorTransactor)
+		)
+
+		transactor.compare(newRequest(http.MethodGet), &XmidtResponse{Code: http.StatusOK, Body: []byte("primary")}, nil)
+		transactor.compare(newRequest(http.MethodGet), &XmidtResponse{Code: http.StatusNotFound}, nil)
+		transactor.compare(newRequest(http.MethodGet), nil, errors.New("network"))
+
+		transactor.mirror = transactFunc(func(r *http.Request) (*XmidtResponse, error) { return nil, errors.New("network") })
+		transactor.compare(newRequest(http.MethodGet), &XmidtResponse{Code: http.StatusOK}, nil)
+
+		assert.EqualValues(map[string]float64{MirrorMatch: 1, MirrorMismatch: 2, MirrorError: 1}, counter.outcomes)
+	})
+
+	t.Run("NotMirrored", func(t *testing.T) {
+		var mirror = transactFunc(func(r *http.Request) (*XmidtResponse, error) {
+			return nil, errors.New("should not be mirrored")
+		})
+
+		assert := assert.New(t)
+		transactor := NewMirrorTransactor(primary, mirror, MirrorOptions{TargetURL: "http://canary:6000", Percentage: 50}, "scytale:6000", nil, nil).(*mirrorTransactor)
+
+		transactor.sample = func() float64 { return 0.5 }
+		assert.Nil(transactor.mirrored(newRequest(http.MethodGet)))
+
+		transactor.sample = func() float64 { return 0.4 }
+		assert.Nil(transactor.mirrored(newRequest(http.MethodPatch)))
+		assert.NotNil(transactor.mirrored(newRequest(http.MethodGet)))
+	})
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+//OutboundCall identifies the last call to the XMiDT API made on behalf of an incoming request. It is reported to
+//API consumers when the call fails so that their support tickets hold what it takes to look the failure up
+type OutboundCall struct {
+	//TID is the transaction ID the call was made with
+	TID string `json:"tid"`
+
+	//Target is the XMiDT API instance (scheme and host) the last attempt was sent to
+	Target string `json:"target"`
+
+	//Attempts is the number of attempts, the initial one and the retries, the call took
+	Attempts int `json:"attempts"`
+}
+
+//outboundCalls records the attempts of the calls to the XMiDT API for an incoming request. Requests fanned out to
+//several devices make concurrent calls, so the last attempt of any of them is what is recorded
+type outboundCalls struct {
+	lock     sync.Mutex
+	target   string
+	attempts int
+	failed   bool
+}
+
+//withOutboundCalls adds to the given context the record of the calls to the XMiDT API made for the incoming request
+func withOutboundCalls(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ContextKeyOutboundCalls, new(outboundCalls))
+}
+
+//beginOutboundCall resets the attempts recorded in the given context as a new call to the XMiDT API starts
+func beginOutboundCall(ctx context.Context) {
+	if o, ok := ctx.Value(ContextKeyOutboundCalls).(*outboundCalls); ok {
+		o.lock.Lock()
+		o.attempts, o.failed = 0, false
+		o.lock.Unlock()
+	}
+}
+
+//CountAttempts decorates the given HTTP transactor (i.e. http.Client.Do) so that each of its attempts is recorded
+//for the incoming request, along with the XMiDT API instance it was sent to and whether it failed. Attempts whose
+//context holds no record, like the ones of mirrored requests, are not recorded
+func CountAttempts(next func(*http.Request) (*http.Response, error)) func(*http.Request) (*http.Response, error) {
+	return func(req *http.Request) (*http.Response, error) {
+		resp, err := next(req)
+
+		if o, ok := req.Context().Value(ContextKeyOutboundCalls).(*outboundCalls); ok {
+			var target = url.URL{Scheme: req.URL.Scheme, Host: req.URL.Host}
+
+			o.lock.Lock()
+			o.target, o.failed = target.String(), err != nil || resp.StatusCode >= http.StatusInternalServerError
+			o.attempts++
+			o.lock.Unlock()
+		}
+
+		return resp, err
+	}
+}
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountAttempts(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		ctx    = context.WithValue(withOutboundCalls(context.Background()), ContextKeyRequestTID, "test-tid")
+		codes  = []int{http.StatusBadGateway, http.StatusOK}
+
+		do = CountAttempts(func(*http.Request) (*http.Response, error) {
+			if len(codes) == 0 {
+				return nil, errors.New("connection refused")
+			}
+
+			code := codes[0]
+			codes = codes[1:]
+			return &http.Response{StatusCode: code}, nil
+		})
+
+		attempt = func(ctx context.Context, target string) {
+			do(httptest.NewRequest(http.MethodPost, target+"/api/v2/device?secret=s3cr3t", nil).WithContext(ctx))
+		}
+	)
+
+	_, failed := RequestFailedCall(ctx)
+	assert.False(failed)
+
+	//calls which eventually succeed are not reported
+	beginOutboundCall(ctx)
+	attempt(ctx, "http://xmidt-1:6000")
+
+	call, failed := RequestFailedCall(ctx)
+	assert.True(failed)
+	assert.Equal(OutboundCall{TID: "test-tid", Target: "http://xmidt-1:6000", Attempts: 1}, call)
+
+	attempt(ctx, "http://xmidt-1:6000")
+
+	_, failed = RequestFailedCall(ctx)
+	assert.False(failed)
+
+	//the attempts are counted for each call
+	beginOutboundCall(ctx)
+	attempt(ctx, "http://xmidt-1:6000")
+	attempt(ctx, "https://xmidt-2")
+
+	call, failed = RequestFailedCall(ctx)
+	assert.True(failed)
+	assert.Equal(OutboundCall{TID: "test-tid", Target: "https://xmidt-2", Attempts: 2}, call)
+
+	//attempts without a record, like the ones of mirrored requests, are left alone
+	attempt(context.Background(), "http://mirror")
+	_, failed = RequestFailedCall(context.Background())
+	assert.False(failed)
+}
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"context"
+)
+
+//DefaultPartnerClaim is the JWT claim which holds the partner ID(s) of API consumers by default
+const DefaultPartnerClaim = "partner-id"
+
+//PartnerOptions tells where the partner ID on behalf of which a request is made comes from. The partner ID drives
+//trust decisions (tenants, quotas, signing keys) so it is taken from the token of the API consumer
+//They are part of the Settings of a server
+type PartnerOptions struct {
+	//Claim is the JWT claim which holds the partner ID(s) of API consumers. Defaults to DefaultPartnerClaim
+	//If it holds several partner IDs, HeaderXmidtPartnerID may select one of them, else the first one applies
+	Claim string
+
+	//TrustHeader lets HeaderXmidtPartnerID set the partner ID of the requests whose token lacks the claim
+	//Only enable it if all API consumers are trusted, i.e. behind a gateway which sets the header itself
+	TrustHeader bool
+}
+
+//AuthenticatedPartner returns the partner ID the token of the incoming request grants, empty if none
+//The partner ID requested through HeaderXmidtPartnerID is only honored if the token grants it
+func AuthenticatedPartner(ctx context.Context) string {
+	var partners = Claim(ctx, settings(ctx).partners.Claim)
+
+	if len(partners) == 0 {
+		return ""
+	}
+
+	if requested := RequestHeaders(ctx).Get(HeaderXmidtPartnerID); requested != "" {
+		for _, partner := range partners {
+			if partner == requested {
+				return partner
+			}
+		}
+	}
+
+	return partners[0]
+}
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Comcast/comcast-bascule/bascule"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestPartner(t *testing.T) {
+	var (
+		requested = func(partner string) context.Context {
+			return context.WithValue(context.Background(), ContextKeyRequestHeaders, http.Header{HeaderXmidtPartnerID: []string{partner}})
+		}
+
+		granted = func(ctx context.Context, claim string, partners ...interface{}) context.Context {
+			return bascule.WithAuthentication(ctx, bascule.Authentication{Token: bascule.NewToken("jwt", "client", bascule.Attributes{claim: partners})})
+		}
+	)
+
+	testCases := []struct {
+		name     string
+		options  PartnerOptions
+		ctx      context.Context
+		expected string
+	}{
+		{"None", PartnerOptions{}, context.Background(), ""},
+		{"UntrustedHeader", PartnerOptions{}, requested("comcast"), ""},
+		{"TrustedHeader", PartnerOptions{TrustHeader: true}, requested("comcast"), "comcast"},
+		{"Claim", PartnerOptions{}, granted(context.Background(), DefaultPartnerClaim, "comcast"), "comcast"},
+		{"ConfiguredClaim", PartnerOptions{Claim: "partners"}, granted(context.Background(), "partners", "comcast"), "comcast"},
+		{"ClaimOverHeader", PartnerOptions{TrustHeader: true}, granted(requested("sky"), DefaultPartnerClaim, "comcast"), "comcast"},
+		{"HeaderSelectsGranted", PartnerOptions{}, granted(requested("sky"), DefaultPartnerClaim, "comcast", "sky"), "sky"},
+		{"HeaderSelectsNotGranted", PartnerOptions{}, granted(requested("cox"), DefaultPartnerClaim, "comcast", "sky"), "comcast"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			settings, err := NewSettings(testCase.options, TIDOptions{}, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.expected, RequestPartner(WithSettings(testCase.ctx, settings)))
+		})
+	}
+}
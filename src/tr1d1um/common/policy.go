@@ -0,0 +1,203 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//DefaultPolicyTimeout is the time the retrieval of the policy from the config service may take by default
+const DefaultPolicyTimeout = 10 * time.Second
+
+//policyKeys are the settings the fleet-wide policy may hold, along with the check of their values. They are the
+//services API consumers may address, the parameters whose values are only visible with some capabilities and the
+//partners and their tenants
+var policyKeys = map[string]func(interface{}) bool{
+	"supportedServices": isStringList,
+	"redaction":         isObject,
+	"tenants":           isObject,
+}
+
+//PolicyOptions configures the retrieval, at startup, of the fleet-wide policy from a central config service so that
+//policy changes don't require touching the configuration file of every instance
+type PolicyOptions struct {
+	//URL is the URL of the policy, a JSON object of settings among the policyKeys. Disabled if empty
+	URL string
+
+	//Authorization is the value of the Authorization header of the retrieval (optional)
+	Authorization string
+
+	//Timeout bounds the time the retrieval may take. Defaults to DefaultPolicyTimeout
+	Timeout time.Duration
+
+	//Cache is the file the last policy retrieved is kept in, for instances to start with it if the config service is
+	//unavailable (optional)
+	Cache string
+
+	//Required makes startup fail if the policy can neither be retrieved nor read from the cache. Otherwise, the
+	//settings of the configuration file are used
+	Required bool
+}
+
+//Policy is the fleet-wide policy retrieved at startup
+type Policy struct {
+	//Settings override the ones of the configuration file
+	Settings map[string]interface{}
+
+	//Source is the URL or the cache file the settings come from
+	Source string
+
+	//Warnings are the problems which didn't prevent getting the policy, i.e. the failed retrieval when the cache was used
+	Warnings []error
+}
+
+//LoadPolicy retrieves the policy from the config service, falling back to the cache if it fails
+//It returns a nil policy if the retrieval is disabled
+func LoadPolicy(o PolicyOptions) (*Policy, error) {
+	if o.URL == "" {
+		return nil, nil
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultPolicyTimeout
+	}
+
+	data, err := fetchPolicy(o)
+	if err == nil {
+		var settings map[string]interface{}
+		if settings, err = parsePolicy(data); err == nil {
+			policy := &Policy{Settings: settings, Source: o.URL}
+			if o.Cache != "" {
+				if cacheErr := writePolicyCache(o.Cache, data); cacheErr != nil {
+					policy.Warnings = append(policy.Warnings, fmt.Errorf("unable to cache the policy: %s", cacheErr))
+				}
+			}
+
+			return policy, nil
+		}
+	}
+
+	err = fmt.Errorf("unable to retrieve the policy from %s: %s", o.URL, err)
+	if o.Cache == "" {
+		return nil, err
+	}
+
+	data, cacheErr := ioutil.ReadFile(o.Cache)
+	if cacheErr != nil {
+		return nil, fmt.Errorf("%s, nor to read its cache: %s", err, cacheErr)
+	}
+
+	settings, cacheErr := parsePolicy(data)
+	if cacheErr != nil {
+		return nil, fmt.Errorf("%s, and its cache is invalid: %s", err, cacheErr)
+	}
+
+	return &Policy{Settings: settings, Source: o.Cache, Warnings: []error{err}}, nil
+}
+
+func fetchPolicy(o PolicyOptions) ([]byte, error) {
+	request, err := http.NewRequest(http.MethodGet, o.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Accept", "application/json")
+	if o.Authorization != "" {
+		request.Header.Set("Authorization", o.Authorization)
+	}
+
+	response, err := (&http.Client{Timeout: o.Timeout}).Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	return ioutil.ReadAll(response.Body)
+}
+
+//parsePolicy decodes the given policy and checks that it only holds known settings with values of the right type
+//All the problems are reported at once so that the policy can be fixed in a single round trip
+func parsePolicy(data []byte) (map[string]interface{}, error) {
+	var settings map[string]interface{}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("policy is not a JSON object: %s", err)
+	}
+
+	var (
+		keys     = make([]string, 0, len(settings))
+		problems []string
+	)
+
+	for key := range settings {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		if check, ok := policyKeys[key]; !ok {
+			problems = append(problems, fmt.Sprintf("%s is not a policy setting", key))
+		} else if !check(settings[key]) {
+			problems = append(problems, fmt.Sprintf("%s has an invalid value", key))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid policy: %s", strings.Join(problems, "; "))
+	}
+
+	return settings, nil
+}
+
+//writePolicyCache replaces the cache with the given policy. The policy is written to a temporary file first so
+//that an interrupted write doesn't leave a truncated cache behind
+func writePolicyCache(path string, data []byte) error {
+	temporary, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(temporary.Name())
+
+	if _, err = temporary.Write(data); err == nil {
+		err = temporary.Close()
+	} else {
+		temporary.Close()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temporary.Name(), path)
+}
+
+func isStringList(value interface{}) bool {
+	list, ok := value.([]interface{})
+	if !ok {
+		return false
+	}
+
+	for _, item := range list {
+		if _, ok := item.(string); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isObject(value interface{}) bool {
+	_, ok := value.(map[string]interface{})
+	return ok
+}
@@ -0,0 +1,98 @@
+package common
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPolicy = `{"supportedServices": ["config", "stat"], "tenants": {"tenants": []}}`
+
+func TestLoadPolicyDisabled(t *testing.T) {
+	policy, err := LoadPolicy(PolicyOptions{})
+	assert.Nil(t, policy)
+	assert.Nil(t, err)
+}
+
+func TestLoadPolicy(t *testing.T) {
+	var (
+		assert        = assert.New(t)
+		authorization string
+		body          = testPolicy
+		code          = http.StatusOK
+
+		configService = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization = r.Header.Get("Authorization")
+			w.WriteHeader(code)
+			io.WriteString(w, body)
+		}))
+	)
+
+	defer configService.Close()
+
+	dir, err := ioutil.TempDir("", "policy")
+	if !assert.Nil(err) {
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	var o = PolicyOptions{URL: configService.URL, Authorization: "Basic abc", Cache: filepath.Join(dir, "policy.json")}
+
+	policy, err := LoadPolicy(o)
+	if assert.Nil(err) && assert.NotNil(policy) {
+		assert.Equal(configService.URL, policy.Source)
+		assert.Equal([]interface{}{"config", "stat"}, policy.Settings["supportedServices"])
+		assert.Empty(policy.Warnings)
+		assert.Equal("Basic abc", authorization)
+	}
+
+	cached, _ := ioutil.ReadFile(o.Cache)
+	assert.Equal(testPolicy, string(cached))
+
+	//the last policy retrieved is used while the config service is unavailable or answers invalid policies
+	code = http.StatusServiceUnavailable
+
+	policy, err = LoadPolicy(o)
+	if assert.Nil(err) && assert.NotNil(policy) {
+		assert.Equal(o.Cache, policy.Source)
+		assert.Equal([]interface{}{"config", "stat"}, policy.Settings["supportedServices"])
+		assert.Len(policy.Warnings, 1)
+	}
+
+	code, body = http.StatusOK, `{"supportedServices": "config"}`
+
+	policy, err = LoadPolicy(o)
+	if assert.Nil(err) && assert.NotNil(policy) {
+		assert.Equal(o.Cache, policy.Source)
+		assert.Contains(policy.Warnings[0].Error(), "supportedServices has an invalid value")
+	}
+
+	os.Remove(o.Cache)
+
+	policy, err = LoadPolicy(o)
+	assert.Nil(policy)
+	assert.NotNil(err)
+}
+
+func TestParsePolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	settings, err := parsePolicy([]byte(testPolicy))
+	assert.Nil(err)
+	assert.Len(settings, 2)
+
+	_, err = parsePolicy([]byte(`[]`))
+	assert.NotNil(err)
+
+	_, err = parsePolicy([]byte(`{"targetURL": "http://xmidt", "supportedServices": [1], "redaction": []}`))
+	if assert.NotNil(err) {
+		assert.Equal("invalid policy: redaction has an invalid value; supportedServices has an invalid value; targetURL is not a policy setting", err.Error())
+	}
+}
@@ -0,0 +1,133 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//DefaultPresenceMaxEntries is the maximum number of devices whose presence is cached when none is configured
+const DefaultPresenceMaxEntries = 100000
+
+//PresenceOptions configures the caching of whether devices are connected, as learned from their statistics
+type PresenceOptions struct {
+	//TTL is the time the presence of a device is trusted for. Presence is not cached if not positive
+	TTL time.Duration
+
+	//MaxEntries bounds the number of devices whose presence is cached. Defaults to DefaultPresenceMaxEntries
+	MaxEntries int
+}
+
+type presenceEntry struct {
+	online    bool
+	checkedAt time.Time
+	lastSeen  time.Time
+}
+
+//PresenceCache remembers which devices were recently found connected or not so that requests for devices known
+//to be offline can fail right away rather than waiting for the XMiDT API to time out
+//A nil PresenceCache knows of no device
+type PresenceCache struct {
+	options PresenceOptions
+	lock    sync.Mutex
+	entries map[string]*presenceEntry
+	now     func() time.Time
+}
+
+//NewPresenceCache builds a PresenceCache out of the given options. It returns nil if the TTL is not positive
+func NewPresenceCache(o PresenceOptions) *PresenceCache {
+	if o.TTL <= 0 {
+		return nil
+	}
+
+	if o.MaxEntries < 1 {
+		o.MaxEntries = DefaultPresenceMaxEntries
+	}
+
+	return &PresenceCache{
+		options: o,
+		entries: make(map[string]*presenceEntry),
+		now:     time.Now,
+	}
+}
+
+//Observe records whether the given device is connected
+func (p *PresenceCache) Observe(deviceID string, online bool) {
+	if p == nil {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var now = p.now()
+
+	entry, ok := p.entries[deviceID]
+	if !ok {
+		if len(p.entries) >= p.options.MaxEntries {
+			p.evict(now)
+		}
+
+		if len(p.entries) >= p.options.MaxEntries {
+			return
+		}
+
+		entry = new(presenceEntry)
+		p.entries[deviceID] = entry
+	}
+
+	entry.online, entry.checkedAt = online, now
+	if online {
+		entry.lastSeen = now
+	}
+}
+
+//evict forgets the devices whose presence can't be trusted anymore
+func (p *PresenceCache) evict(now time.Time) {
+	for deviceID, entry := range p.entries {
+		if now.Sub(entry.checkedAt) >= p.options.TTL {
+			delete(p.entries, deviceID)
+		}
+	}
+}
+
+//Offline reports whether the given device was recently found offline, along with the last time it was seen
+//connected, if known
+func (p *PresenceCache) Offline(deviceID string) (lastSeen time.Time, offline bool) {
+	if p == nil {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	entry, ok := p.entries[deviceID]
+	if !ok || entry.online || p.now().Sub(entry.checkedAt) >= p.options.TTL {
+		return
+	}
+
+	return entry.lastSeen, true
+}
+
+//DeviceOfflineError is the CodedError returned for the requests of devices known to be offline
+type DeviceOfflineError struct {
+	DeviceID string
+
+	//LastSeen is the last time the device was seen connected. It is zero if unknown
+	LastSeen time.Time
+}
+
+func (d *DeviceOfflineError) Error() string {
+	return fmt.Sprintf("device %s is not connected", d.DeviceID)
+}
+
+//StatusCode is 404, as reported by the XMiDT API for devices which are not connected
+func (d *DeviceOfflineError) StatusCode() int {
+	return http.StatusNotFound
+}
+
+//ErrorCode lets API consumers tell offline devices apart from unknown routes
+func (d *DeviceOfflineError) ErrorCode() string {
+	return "device-not-connected"
+}
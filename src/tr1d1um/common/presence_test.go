@@ -0,0 +1,63 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresenceCache(t *testing.T) {
+	var nilCache *PresenceCache
+	nilCache.Observe("mac:112233445566", false)
+	_, offline := nilCache.Offline("mac:112233445566")
+	assert.False(t, offline)
+	assert.Nil(t, NewPresenceCache(PresenceOptions{}))
+
+	var (
+		now = time.Unix(1000, 0)
+		p   = NewPresenceCache(PresenceOptions{TTL: time.Minute, MaxEntries: 1})
+	)
+
+	p.now = func() time.Time { return now }
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, offline := p.Offline("mac:112233445566")
+		assert.False(t, offline)
+	})
+
+	t.Run("Offline", func(t *testing.T) {
+		assert := assert.New(t)
+		p.Observe("mac:112233445566", true)
+
+		now = now.Add(30 * time.Second)
+		p.Observe("mac:112233445566", false)
+
+		lastSeen, offline := p.Offline("mac:112233445566")
+		assert.True(offline)
+		assert.Equal(time.Unix(1000, 0), lastSeen)
+
+		//the cache is full of trusted entries
+		p.Observe("mac:665544332211", false)
+		_, offline = p.Offline("mac:665544332211")
+		assert.False(offline)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		assert := assert.New(t)
+		now = now.Add(time.Minute)
+
+		_, offline := p.Offline("mac:112233445566")
+		assert.False(offline)
+
+		p.Observe("mac:665544332211", false)
+		_, offline = p.Offline("mac:665544332211")
+		assert.True(offline)
+	})
+
+	t.Run("BackOnline", func(t *testing.T) {
+		p.Observe("mac:665544332211", true)
+		_, offline := p.Offline("mac:665544332211")
+		assert.False(t, offline)
+	})
+}
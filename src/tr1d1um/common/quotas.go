@@ -0,0 +1,337 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/justinas/alice"
+)
+
+//Quota periods. Both reset at midnight UTC (the monthly one on the first day of the month)
+const (
+	DailyQuotaPeriod   = "daily"
+	MonthlyQuotaPeriod = "monthly"
+)
+
+//Headers through which API consumers learn about the quota of the period closest to exhaustion
+const (
+	HeaderRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderRateLimitReset     = "X-RateLimit-Reset"
+)
+
+//ErrQuotaUnidentified is returned by the quota endpoint when the quota key of the API consumer cannot be found
+var ErrQuotaUnidentified = errors.New("requests carry no API key or partner ID to which a quota applies")
+
+//Quota is the maximum number of requests an API key may issue per period. Zero means unlimited
+type Quota struct {
+	//Key is the API key or partner ID the quota applies to
+	Key string
+
+	Daily   int64
+	Monthly int64
+}
+
+//QuotaConfig describes how requests are metered
+//The quota key of a request is its partner ID (see RequestPartner), else the principal of its token
+type QuotaConfig struct {
+	//Default applies to the keys without a quota of their own
+	Default Quota
+
+	//Quotas are the quotas of specific API keys or partners
+	Quotas []Quota
+}
+
+//Validate returns an error if the quotas are misconfigured
+func (c QuotaConfig) Validate() error {
+	var seen = make(map[string]bool)
+
+	if c.Default.Daily < 0 || c.Default.Monthly < 0 {
+		return errors.New("default quota cannot be negative")
+	}
+
+	for _, quota := range c.Quotas {
+		if quota.Daily < 0 || quota.Monthly < 0 {
+			return fmt.Errorf("quota of '%s' cannot be negative", quota.Key)
+		}
+
+		if quota.Key == "" {
+			return errors.New("quotas must have a key")
+		}
+
+		if seen[quota.Key] {
+			return fmt.Errorf("key '%s' has more than one quota", quota.Key)
+		}
+		seen[quota.Key] = true
+	}
+
+	return nil
+}
+
+//QuotaStore keeps the usage counters of quotas
+//Implementations backed by shared storage (i.e. redis) let tr1d1um instances enforce quotas together
+type QuotaStore interface {
+	//Increment adds one to the given counter, creating it if needed, and returns its updated value
+	//The counter may be forgotten after expires
+	Increment(counter string, expires time.Time) (int64, error)
+
+	//Get returns the current value of the given counter
+	Get(counter string) (int64, error)
+}
+
+//NewMemoryQuotaStore returns a QuotaStore which keeps the counters of this tr1d1um instance only
+func NewMemoryQuotaStore() QuotaStore {
+	return &memoryQuotaStore{counters: make(map[string]*quotaCounter), now: time.Now}
+}
+
+type quotaCounter struct {
+	value   int64
+	expires time.Time
+}
+
+type memoryQuotaStore struct {
+	lock     sync.Mutex
+	counters map[string]*quotaCounter
+	now      func() time.Time
+}
+
+func (m *memoryQuotaStore) Increment(counter string, expires time.Time) (int64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	c, ok := m.counters[counter]
+	if !ok {
+		//new counters usually mean a new period began so it's a good time to forget the expired ones
+		var now = m.now()
+		for name, existing := range m.counters {
+			if now.After(existing.expires) {
+				delete(m.counters, name)
+			}
+		}
+
+		c = &quotaCounter{expires: expires}
+		m.counters[counter] = c
+	}
+
+	c.value++
+	return c.value, nil
+}
+
+func (m *memoryQuotaStore) Get(counter string) (int64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if c, ok := m.counters[counter]; ok {
+		return c.value, nil
+	}
+
+	return 0, nil
+}
+
+//QuotaUsage describes the quota of a key for a period
+type QuotaUsage struct {
+	Limit     int64     `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	Resets    time.Time `json:"resets"`
+}
+
+//Quotas meters requests against the quotas of their API keys
+type Quotas struct {
+	byKey map[string]Quota
+	def   Quota
+	store QuotaStore
+	now   func() time.Time
+}
+
+//NewQuotas builds the quotas out of the given configuration. If store is nil, a memory store is used
+func NewQuotas(c QuotaConfig, store QuotaStore) (*Quotas, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	if store == nil {
+		store = NewMemoryQuotaStore()
+	}
+
+	var byKey = make(map[string]Quota, len(c.Quotas))
+	for _, quota := range c.Quotas {
+		byKey[quota.Key] = quota
+	}
+
+	return &Quotas{byKey: byKey, def: c.Default, store: store, now: time.Now}, nil
+}
+
+//key returns the API key the given request is metered by
+func (q *Quotas) key(r *http.Request) string {
+	return apiKey(r)
+}
+
+//apiKey returns the API key of the given request: its partner ID (see RequestPartner), else the principal of its token
+func apiKey(r *http.Request) string {
+	var ctx = r.Context()
+	if RequestHeaders(ctx) == nil {
+		//requests are metered before their headers are captured
+		ctx = context.WithValue(ctx, ContextKeyRequestHeaders, r.Header)
+	}
+
+	if partner := RequestPartner(ctx); partner != "" {
+		return partner
+	}
+
+	return RequestPrincipal(ctx)
+}
+
+func (q *Quotas) quota(key string) Quota {
+	if quota, ok := q.byKey[key]; ok {
+		return quota
+	}
+
+	return q.def
+}
+
+type quotaPeriod struct {
+	name  string
+	limit int64
+	start time.Time
+	reset time.Time
+}
+
+//periods returns the metered periods of the given quota which include now
+func (q *Quotas) periods(quota Quota, now time.Time) []quotaPeriod {
+	var (
+		periods []quotaPeriod
+		y, m, d = now.UTC().Date()
+	)
+
+	if quota.Daily > 0 {
+		start := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		periods = append(periods, quotaPeriod{name: DailyQuotaPeriod, limit: quota.Daily, start: start, reset: start.AddDate(0, 0, 1)})
+	}
+
+	if quota.Monthly > 0 {
+		start := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+		periods = append(periods, quotaPeriod{name: MonthlyQuotaPeriod, limit: quota.Monthly, start: start, reset: start.AddDate(0, 1, 0)})
+	}
+
+	return periods
+}
+
+func (p quotaPeriod) counter(key string) string {
+	return fmt.Sprintf("%s/%s/%s", key, p.name, p.start.Format("2006-01-02"))
+}
+
+func (p quotaPeriod) usage(used int64) QuotaUsage {
+	var remaining = p.limit - used
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return QuotaUsage{Limit: p.limit, Used: used, Remaining: remaining, Resets: p.reset}
+}
+
+//Usage returns the quota usage of the given key by period. Unlimited periods are left out
+func (q *Quotas) Usage(key string) (map[string]QuotaUsage, error) {
+	var usage = make(map[string]QuotaUsage)
+
+	for _, period := range q.periods(q.quota(key), q.now()) {
+		used, err := q.store.Get(period.counter(key))
+		if err != nil {
+			return nil, err
+		}
+
+		usage[period.name] = period.usage(used)
+	}
+
+	return usage, nil
+}
+
+//Enforcer returns the middleware that counts requests against the quotas of their API keys
+//Requests are rejected with a 429 once a quota is exhausted. Requests without a key and failures of the store are let through
+func (q *Quotas) Enforcer() alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var key = q.key(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var (
+				now      = q.now()
+				tightest *QuotaUsage
+			)
+
+			for _, period := range q.periods(q.quota(key), now) {
+				used, err := q.store.Increment(period.counter(key), period.reset)
+				if err != nil {
+					logging.Error(logging.GetLogger(r.Context())).Log(logging.MessageKey(), "Quota usage could not be counted",
+						logging.ErrorKey(), err, "period", period.name)
+					continue
+				}
+
+				usage := period.usage(used)
+				if used > period.limit {
+					rateLimitHeaders(w.Header(), usage)
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(period.reset.Sub(now).Seconds()))))
+					w.Header().Set("Content-Type", "application/json; charset=utf-8")
+					w.WriteHeader(http.StatusTooManyRequests)
+
+					json.NewEncoder(w).Encode(map[string]interface{}{
+						"message": fmt.Sprintf("%s quota of %d requests is exhausted", period.name, period.limit),
+						"code":    period.name + "_quota_exhausted",
+					})
+					return
+				}
+
+				if tightest == nil || usage.Remaining < tightest.Remaining {
+					tightest = &usage
+				}
+			}
+
+			if tightest != nil {
+				rateLimitHeaders(w.Header(), *tightest)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func rateLimitHeaders(h http.Header, usage QuotaUsage) {
+	h.Set(HeaderRateLimitLimit, strconv.FormatInt(usage.Limit, 10))
+	h.Set(HeaderRateLimitRemaining, strconv.FormatInt(usage.Remaining, 10))
+	h.Set(HeaderRateLimitReset, strconv.FormatInt(usage.Resets.Unix(), 10))
+}
+
+//QuotaHandler lets API consumers query the remaining quota of their API key
+func QuotaHandler(q *Quotas) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		var key = q.key(r)
+		if key == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"message": ErrQuotaUnidentified.Error()})
+			return
+		}
+
+		usage, err := q.Usage(key)
+		if err != nil {
+			logging.Error(logging.GetLogger(r.Context())).Log(logging.MessageKey(), "Quota usage could not be read", logging.ErrorKey(), err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]interface{}{"message": "quota usage is unavailable"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"key": key, "quotas": usage})
+	})
+}
@@ -0,0 +1,218 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Comcast/comcast-bascule/bascule"
+	"github.com/stretchr/testify/assert"
+)
+
+//failingQuotaStore is a QuotaStore whose backend is unavailable
+type failingQuotaStore struct{}
+
+func (failingQuotaStore) Increment(string, time.Time) (int64, error) {
+	return 0, errors.New("unavailable")
+}
+
+func (failingQuotaStore) Get(string) (int64, error) {
+	return 0, errors.New("unavailable")
+}
+
+func quotaRequest(partner string, attributes bascule.Attributes) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/v2/device/mac:112233445566/config?names=p", nil)
+	if partner != "" {
+		r.Header.Set(HeaderXmidtPartnerID, partner)
+	}
+
+	if attributes != nil {
+		r = r.WithContext(bascule.WithAuthentication(r.Context(), bascule.Authentication{Token: bascule.NewToken("jwt", "principal", attributes)}))
+	}
+
+	return r
+}
+
+//partnerRequest returns a request made on behalf of the given partner, unidentified if it is empty
+func partnerRequest(partner string) *http.Request {
+	if partner == "" {
+		return quotaRequest("", nil)
+	}
+
+	return quotaRequest("", bascule.Attributes{DefaultPartnerClaim: partner})
+}
+
+func TestQuotaConfigValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(QuotaConfig{Default: Quota{Daily: 10}, Quotas: []Quota{{Key: "a", Monthly: 100}}}.Validate())
+	assert.NotNil(QuotaConfig{Default: Quota{Daily: -1}}.Validate())
+	assert.NotNil(QuotaConfig{Quotas: []Quota{{Key: "a", Daily: -1}}}.Validate())
+	assert.NotNil(QuotaConfig{Quotas: []Quota{{Daily: 1}}}.Validate())
+	assert.NotNil(QuotaConfig{Quotas: []Quota{{Key: "a"}, {Key: "a"}}}.Validate())
+
+	_, err := NewQuotas(QuotaConfig{Quotas: []Quota{{}}}, nil)
+	assert.NotNil(err)
+}
+
+func TestQuotasKey(t *testing.T) {
+	q, _ := NewQuotas(QuotaConfig{}, nil)
+
+	testCases := []struct {
+		name     string
+		request  *http.Request
+		expected string
+	}{
+		{"Claim", quotaRequest("", bascule.Attributes{"partner-id": []interface{}{"claim", "other"}}), "claim"},
+		{"HeaderSelectsGranted", quotaRequest("other", bascule.Attributes{"partner-id": []interface{}{"claim", "other"}}), "other"},
+		{"UntrustedHeader", quotaRequest("header", bascule.Attributes{}), "principal"},
+		{"Principal", quotaRequest("", bascule.Attributes{}), "principal"},
+		{"Unidentified", quotaRequest("header", nil), ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, q.key(testCase.request))
+		})
+	}
+
+	t.Run("TrustedHeader", func(t *testing.T) {
+		assert := assert.New(t)
+		trusting, _ := NewSettings(PartnerOptions{TrustHeader: true}, TIDOptions{}, nil)
+		trusted := func(r *http.Request) *http.Request {
+			return r.WithContext(WithSettings(r.Context(), trusting))
+		}
+
+		assert.Equal("claim", q.key(trusted(quotaRequest("header", bascule.Attributes{"partner-id": "claim"}))))
+		assert.Equal("header", q.key(trusted(quotaRequest("header", bascule.Attributes{}))))
+		assert.Equal("principal", q.key(trusted(quotaRequest("", bascule.Attributes{}))))
+	})
+}
+
+func TestQuotasEnforcer(t *testing.T) {
+	var (
+		now   = time.Date(2019, time.March, 31, 23, 59, 30, 0, time.UTC)
+		next  = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+		store = NewMemoryQuotaStore().(*memoryQuotaStore)
+
+		q, _ = NewQuotas(QuotaConfig{
+			Default: Quota{Daily: 2},
+			Quotas:  []Quota{{Key: "big", Daily: 100, Monthly: 1}},
+		}, store)
+
+		handler = q.Enforcer()(next)
+	)
+
+	q.now = func() time.Time { return now }
+	store.now = q.now
+
+	t.Run("WithinQuota", func(t *testing.T) {
+		assert := assert.New(t)
+
+		for remaining := 1; remaining >= 0; remaining-- {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, partnerRequest("small"))
+			assert.EqualValues(http.StatusOK, w.Code)
+			assert.EqualValues("2", w.Header().Get(HeaderRateLimitLimit))
+			assert.EqualValues(remaining, w.Header().Get(HeaderRateLimitRemaining)[0]-'0')
+		}
+	})
+
+	t.Run("Exhausted", func(t *testing.T) {
+		assert := assert.New(t)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, partnerRequest("small"))
+		assert.EqualValues(http.StatusTooManyRequests, w.Code)
+		assert.EqualValues("30", w.Header().Get("Retry-After"))
+		assert.EqualValues("0", w.Header().Get(HeaderRateLimitRemaining))
+		assert.Contains(w.Body.String(), "daily_quota_exhausted")
+	})
+
+	t.Run("TightestPeriod", func(t *testing.T) {
+		assert := assert.New(t)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, partnerRequest("big"))
+		assert.EqualValues(http.StatusOK, w.Code)
+		assert.EqualValues("1", w.Header().Get(HeaderRateLimitLimit))
+		assert.EqualValues("0", w.Header().Get(HeaderRateLimitRemaining))
+	})
+
+	t.Run("NewPeriod", func(t *testing.T) {
+		assert := assert.New(t)
+		now = now.Add(time.Minute)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, partnerRequest("small"))
+		assert.EqualValues(http.StatusOK, w.Code)
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, partnerRequest("big"))
+		assert.EqualValues(http.StatusOK, w.Code)
+		assert.Len(store.counters, 3)
+	})
+
+	t.Run("Unidentified", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, quotaRequest("", nil))
+		assert.EqualValues(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(HeaderRateLimitLimit))
+	})
+
+	t.Run("StoreFailure", func(t *testing.T) {
+		failing, _ := NewQuotas(QuotaConfig{Default: Quota{Daily: 1}}, failingQuotaStore{})
+		w := httptest.NewRecorder()
+
+		failing.Enforcer()(next).ServeHTTP(w, partnerRequest("small"))
+		assert.EqualValues(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestQuotaHandler(t *testing.T) {
+	var (
+		now   = time.Date(2019, time.March, 15, 12, 0, 0, 0, time.UTC)
+		store = NewMemoryQuotaStore().(*memoryQuotaStore)
+		q, _  = NewQuotas(QuotaConfig{Default: Quota{Daily: 10, Monthly: 100}}, store)
+	)
+
+	q.now = func() time.Time { return now }
+	store.now = q.now
+	q.Enforcer()(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), partnerRequest("partner"))
+
+	t.Run("Usage", func(t *testing.T) {
+		assert := assert.New(t)
+		w := httptest.NewRecorder()
+
+		QuotaHandler(q).ServeHTTP(w, partnerRequest("partner"))
+		assert.EqualValues(http.StatusOK, w.Code)
+
+		var body struct {
+			Key    string
+			Quotas map[string]QuotaUsage
+		}
+
+		assert.Nil(json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal("partner", body.Key)
+		assert.EqualValues(QuotaUsage{Limit: 10, Used: 1, Remaining: 9, Resets: time.Date(2019, time.March, 16, 0, 0, 0, 0, time.UTC)}, body.Quotas[DailyQuotaPeriod])
+		assert.EqualValues(99, body.Quotas[MonthlyQuotaPeriod].Remaining)
+		assert.Equal(time.Date(2019, time.April, 1, 0, 0, 0, 0, time.UTC), body.Quotas[MonthlyQuotaPeriod].Resets)
+	})
+
+	t.Run("Unidentified", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		QuotaHandler(q).ServeHTTP(w, quotaRequest("", nil))
+		assert.EqualValues(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("StoreFailure", func(t *testing.T) {
+		failing, _ := NewQuotas(QuotaConfig{Default: Quota{Daily: 1}}, failingQuotaStore{})
+		w := httptest.NewRecorder()
+
+		QuotaHandler(failing).ServeHTTP(w, partnerRequest("partner"))
+		assert.EqualValues(t, http.StatusServiceUnavailable, w.Code)
+	})
+}
@@ -0,0 +1,328 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Service catalogs instances may register into
+const (
+	ConsulProvider = "consul"
+	EtcdProvider   = "etcd"
+)
+
+//RegistrationOptions configure the registration of a tr1d1um instance into a service catalog so that gateways
+//can discover it without static configuration
+type RegistrationOptions struct {
+	//Provider is the service catalog: ConsulProvider or EtcdProvider. Registration is disabled if empty
+	Provider string
+
+	//Endpoint is the base URL of the Consul agent (i.e. http://localhost:8500) or of the etcd v3 API (i.e. http://localhost:2379)
+	Endpoint string
+
+	//Token is the Consul ACL token (optional)
+	Token string
+
+	//Name is the service name the instance is registered under. Defaults to tr1d1um
+	Name string
+
+	//ID identifies the instance in the catalog. Defaults to the name followed by the address
+	ID string
+
+	//Address is the host:port gateways reach the instance at
+	Address string
+
+	//HealthCheckURL is the URL the catalog checks the health of the instance at (optional)
+	HealthCheckURL string
+
+	//CheckInterval is how often Consul checks the health of the instance. Defaults to 10s
+	CheckInterval time.Duration
+
+	//TTL is the lifetime of the etcd registration which is renewed while the instance runs. Defaults to 30s
+	TTL time.Duration
+
+	//Prefix is the etcd key prefix registrations are stored under. Defaults to /services/
+	Prefix string
+
+	//Tags and Meta describe the instance (i.e. its version and region)
+	Tags []string
+	Meta map[string]string
+}
+
+//Validate returns an error if registration is enabled but can't be performed
+func (o RegistrationOptions) Validate() error {
+	switch strings.ToLower(o.Provider) {
+	case "":
+		return nil
+	case ConsulProvider, EtcdProvider:
+	default:
+		return fmt.Errorf("unknown service catalog '%s'", o.Provider)
+	}
+
+	if o.Endpoint == "" {
+		return fmt.Errorf("the endpoint of the %s catalog is required", o.Provider)
+	}
+
+	if _, _, err := splitAddress(o.Address); err != nil {
+		return fmt.Errorf("invalid registration address '%s': %s", o.Address, err)
+	}
+
+	return nil
+}
+
+func (o RegistrationOptions) withDefaults() RegistrationOptions {
+	if o.Name == "" {
+		o.Name = "tr1d1um"
+	}
+
+	if o.ID == "" {
+		o.ID = o.Name + "-" + o.Address
+	}
+
+	if o.CheckInterval <= 0 {
+		o.CheckInterval = 10 * time.Second
+	}
+
+	if o.TTL <= 0 {
+		o.TTL = 30 * time.Second
+	}
+
+	if o.Prefix == "" {
+		o.Prefix = "/services/"
+	}
+
+	o.Endpoint = strings.TrimSuffix(o.Endpoint, "/")
+	return o
+}
+
+//Registrar adds an instance to a service catalog on startup and removes it on shutdown
+type Registrar interface {
+	Register(context.Context) error
+	Deregister(context.Context) error
+}
+
+//NewRegistrar returns the registrar of the configured service catalog. It is nil if registration is disabled
+func NewRegistrar(o RegistrationOptions, client *http.Client) (Registrar, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	o = o.withDefaults()
+
+	switch strings.ToLower(o.Provider) {
+	case ConsulProvider:
+		return &consulRegistrar{o: o, client: client}, nil
+	case EtcdProvider:
+		return &etcdRegistrar{o: o, client: client}, nil
+	}
+
+	return nil, nil
+}
+
+func splitAddress(address string) (host string, port int, err error) {
+	var p string
+	if host, p, err = net.SplitHostPort(address); err == nil {
+		port, err = strconv.Atoi(p)
+	}
+	return
+}
+
+//call sends a catalog API request and fails on any non 2xx response
+func call(ctx context.Context, client *http.Client, method, url string, headers http.Header, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return err
+	}
+
+	for name := range headers {
+		request.Header.Set(name, headers.Get(name))
+	}
+
+	response, err := client.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+	data, _ := ioutil.ReadAll(response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s %s: %d %s", method, url, response.StatusCode, bytes.TrimSpace(data))
+	}
+
+	if result != nil {
+		return json.Unmarshal(data, result)
+	}
+
+	return nil
+}
+
+//consulRegistrar registers instances through the service API of the local Consul agent
+type consulRegistrar struct {
+	o      RegistrationOptions
+	client *http.Client
+}
+
+type consulCheck struct {
+	HTTP                           string
+	Interval                       string
+	DeregisterCriticalServiceAfter string
+}
+
+type consulService struct {
+	ID      string
+	Name    string
+	Address string
+	Port    int
+	Tags    []string          `json:",omitempty"`
+	Meta    map[string]string `json:",omitempty"`
+	Check   *consulCheck      `json:",omitempty"`
+}
+
+func (c *consulRegistrar) headers() http.Header {
+	var headers = http.Header{}
+	if c.o.Token != "" {
+		headers.Set("X-Consul-Token", c.o.Token)
+	}
+	return headers
+}
+
+func (c *consulRegistrar) Register(ctx context.Context) error {
+	host, port, _ := splitAddress(c.o.Address)
+
+	service := consulService{ID: c.o.ID, Name: c.o.Name, Address: host, Port: port, Tags: c.o.Tags, Meta: c.o.Meta}
+	if c.o.HealthCheckURL != "" {
+		service.Check = &consulCheck{
+			HTTP:     c.o.HealthCheckURL,
+			Interval: c.o.CheckInterval.String(),
+
+			//instances which stop without deregistering are eventually removed
+			DeregisterCriticalServiceAfter: (10 * c.o.CheckInterval).String(),
+		}
+	}
+
+	return call(ctx, c.client, http.MethodPut, c.o.Endpoint+"/v1/agent/service/register", c.headers(), service, nil)
+}
+
+func (c *consulRegistrar) Deregister(ctx context.Context) error {
+	return call(ctx, c.client, http.MethodPut, c.o.Endpoint+"/v1/agent/service/deregister/"+c.o.ID, c.headers(), nil, nil)
+}
+
+//etcdRegistrar stores the instance under a key attached to a lease which is kept alive until deregistration,
+//so that instances which stop abruptly disappear once their lease expires
+type etcdRegistrar struct {
+	o      RegistrationOptions
+	client *http.Client
+
+	lock  sync.Mutex
+	lease json.Number
+	stop  chan struct{}
+}
+
+//etcdInstance is the value of the key of an instance
+type etcdInstance struct {
+	ID             string            `json:"id"`
+	Name           string            `json:"name"`
+	Address        string            `json:"address"`
+	HealthCheckURL string            `json:"healthCheckURL,omitempty"`
+	Tags           []string          `json:"tags,omitempty"`
+	Meta           map[string]string `json:"meta,omitempty"`
+}
+
+func (e *etcdRegistrar) Register(ctx context.Context) error {
+	var lease struct {
+		ID json.Number
+	}
+
+	seconds := int64(e.o.TTL / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	if err := call(ctx, e.client, http.MethodPost, e.o.Endpoint+"/v3/lease/grant", nil, map[string]int64{"TTL": seconds}, &lease); err != nil {
+		return err
+	}
+
+	value, _ := json.Marshal(etcdInstance{
+		ID:             e.o.ID,
+		Name:           e.o.Name,
+		Address:        e.o.Address,
+		HealthCheckURL: e.o.HealthCheckURL,
+		Tags:           e.o.Tags,
+		Meta:           e.o.Meta,
+	})
+
+	//the keys and values of the etcd JSON API are base64 encoded, as []byte are
+	put := struct {
+		Key   []byte      `json:"key"`
+		Value []byte      `json:"value"`
+		Lease json.Number `json:"lease"`
+	}{[]byte(e.o.Prefix + e.o.Name + "/" + e.o.ID), value, lease.ID}
+
+	if err := call(ctx, e.client, http.MethodPost, e.o.Endpoint+"/v3/kv/put", nil, put, nil); err != nil {
+		return err
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.lease, e.stop = lease.ID, make(chan struct{})
+	go e.keepAlive(lease.ID, e.stop)
+	return nil
+}
+
+//keepAlive renews the lease a few times per TTL so that a missed renewal doesn't expire the registration
+func (e *etcdRegistrar) keepAlive(lease json.Number, stop <-chan struct{}) {
+	ticker := time.NewTicker(e.o.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), e.o.TTL/3)
+			call(ctx, e.client, http.MethodPost, e.o.Endpoint+"/v3/lease/keepalive", nil, map[string]json.Number{"ID": lease}, nil)
+			cancel()
+		}
+	}
+}
+
+func (e *etcdRegistrar) Deregister(ctx context.Context) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.stop == nil {
+		return nil
+	}
+
+	close(e.stop)
+	e.stop = nil
+
+	//revoking the lease deletes the key
+	return call(ctx, e.client, http.MethodPost, e.o.Endpoint+"/v3/lease/revoke", nil, map[string]json.Number{"ID": e.lease}, nil)
+}
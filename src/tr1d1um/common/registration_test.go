@@ -0,0 +1,144 @@
+package common
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrationOptionsValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(RegistrationOptions{}.Validate())
+	assert.Nil(RegistrationOptions{Provider: "Consul", Endpoint: "http://localhost:8500", Address: "tr1d1um:6100"}.Validate())
+	assert.NotNil(RegistrationOptions{Provider: "zookeeper", Endpoint: "http://localhost:2181", Address: "tr1d1um:6100"}.Validate())
+	assert.NotNil(RegistrationOptions{Provider: "etcd", Address: "tr1d1um:6100"}.Validate())
+	assert.NotNil(RegistrationOptions{Provider: "etcd", Endpoint: "http://localhost:2379", Address: "tr1d1um"}.Validate())
+}
+
+func TestNewRegistrarDisabled(t *testing.T) {
+	registrar, err := NewRegistrar(RegistrationOptions{}, nil)
+	assert.Nil(t, registrar)
+	assert.Nil(t, err)
+}
+
+func TestConsulRegistrar(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		requests []string
+		service  map[string]interface{}
+	)
+
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		assert.Equal("secret", r.Header.Get("X-Consul-Token"))
+
+		if r.URL.Path == "/v1/agent/service/register" {
+			assert.Nil(json.NewDecoder(r.Body).Decode(&service))
+		}
+	}))
+	defer consul.Close()
+
+	registrar, err := NewRegistrar(RegistrationOptions{
+		Provider:       ConsulProvider,
+		Endpoint:       consul.URL + "/",
+		Token:          "secret",
+		Address:        "tr1d1um:6100",
+		HealthCheckURL: "http://tr1d1um:6101/health",
+		Tags:           []string{"us-east"},
+		Meta:           map[string]string{"version": "0.1.2"},
+	}, nil)
+
+	assert.Nil(err)
+	assert.Nil(registrar.Register(context.Background()))
+	assert.Nil(registrar.Deregister(context.Background()))
+
+	assert.Equal([]string{"PUT /v1/agent/service/register", "PUT /v1/agent/service/deregister/tr1d1um-tr1d1um:6100"}, requests)
+	assert.Equal("tr1d1um-tr1d1um:6100", service["ID"])
+	assert.Equal("tr1d1um", service["Name"])
+	assert.Equal("tr1d1um", service["Address"])
+	assert.EqualValues(6100, service["Port"])
+	assert.Equal([]interface{}{"us-east"}, service["Tags"])
+	assert.Equal(map[string]interface{}{"version": "0.1.2"}, service["Meta"])
+	assert.Equal(map[string]interface{}{"HTTP": "http://tr1d1um:6101/health", "Interval": "10s", "DeregisterCriticalServiceAfter": "1m40s"}, service["Check"])
+}
+
+func TestConsulRegistrarFailure(t *testing.T) {
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "ACL not found", http.StatusForbidden)
+	}))
+	defer consul.Close()
+
+	registrar, _ := NewRegistrar(RegistrationOptions{Provider: ConsulProvider, Endpoint: consul.URL, Address: "tr1d1um:6100"}, nil)
+	assert.NotNil(t, registrar.Register(context.Background()))
+}
+
+func TestEtcdRegistrar(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		lock       sync.Mutex
+		keepAlives int
+		put        map[string]interface{}
+		revoked    map[string]interface{}
+	)
+
+	decode := func(r *http.Request, v interface{}) {
+		decoder := json.NewDecoder(r.Body)
+		decoder.UseNumber()
+		assert.Nil(decoder.Decode(v))
+	}
+
+	etcd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		switch r.URL.Path {
+		case "/v3/lease/grant":
+			var grant map[string]interface{}
+			decode(r, &grant)
+			assert.Equal(json.Number("1"), grant["TTL"])
+
+			//int64 values are strings in the responses of the etcd JSON API
+			w.Write([]byte(`{"ID":"7587848943269532406","TTL":"1"}`))
+		case "/v3/kv/put":
+			decode(r, &put)
+		case "/v3/lease/keepalive":
+			keepAlives++
+		case "/v3/lease/revoke":
+			decode(r, &revoked)
+		}
+	}))
+	defer etcd.Close()
+
+	registrar, err := NewRegistrar(RegistrationOptions{
+		Provider: EtcdProvider,
+		Endpoint: etcd.URL,
+		Address:  "tr1d1um:6100",
+		TTL:      time.Second,
+	}, nil)
+
+	assert.Nil(err)
+	assert.Nil(registrar.Register(context.Background()))
+	time.Sleep(500 * time.Millisecond)
+	assert.Nil(registrar.Deregister(context.Background()))
+	assert.Nil(registrar.Deregister(context.Background()))
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	key, _ := base64.StdEncoding.DecodeString(put["key"].(string))
+	value, _ := base64.StdEncoding.DecodeString(put["value"].(string))
+
+	assert.Equal("/services/tr1d1um/tr1d1um-tr1d1um:6100", string(key))
+	assert.JSONEq(`{"id":"tr1d1um-tr1d1um:6100","name":"tr1d1um","address":"tr1d1um:6100"}`, string(value))
+	assert.Equal(json.Number("7587848943269532406"), put["lease"])
+	assert.True(keepAlives > 0)
+	assert.Equal(json.Number("7587848943269532406"), revoked["ID"])
+}
@@ -0,0 +1,250 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-kit/kit/log"
+)
+
+//Reloadable is key material (certificates, JWKS, etc.) which can be reloaded at runtime so that its rotation
+//doesn't require a restart
+type Reloadable interface {
+	Reload() error
+}
+
+//ReloadFunc adapts a function to Reloadable
+type ReloadFunc func() error
+
+//Reload calls f
+func (f ReloadFunc) Reload() error {
+	return f()
+}
+
+//Certificate is a certificate and private key loaded from PEM files which are reloaded as they are rotated
+//Handshakes use the certificate loaded last so connections established before a reload keep theirs
+type Certificate struct {
+	certificateFile string
+	keyFile         string
+
+	lock    sync.RWMutex
+	current *tls.Certificate
+}
+
+//NewCertificate loads the certificate and private key from the given PEM files
+func NewCertificate(certificateFile, keyFile string) (*Certificate, error) {
+	var c = &Certificate{certificateFile: certificateFile, keyFile: keyFile}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+//Reload reads the certificate and private key again. The current ones are kept if they can't be loaded, i.e. while
+//their files are halfway through being replaced
+func (c *Certificate) Reload() error {
+	loaded, err := tls.LoadX509KeyPair(c.certificateFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	c.current = &loaded
+	c.lock.Unlock()
+	return nil
+}
+
+//Files returns the files the certificate and private key are loaded from
+func (c *Certificate) Files() []string {
+	return []string{c.certificateFile, c.keyFile}
+}
+
+//GetCertificate returns the current certificate, meant for the tls.Config of servers
+func (c *Certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.current, nil
+}
+
+//GetClientCertificate returns the current certificate, meant for the tls.Config of clients
+func (c *Certificate) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.current, nil
+}
+
+//ClientTLSOptions configures the TLS of the connections to the XMiDT API
+type ClientTLSOptions struct {
+	//CertificateFile and KeyFile are the PEM files of the client certificate tr1d1um authenticates with (optional)
+	CertificateFile string
+	KeyFile         string
+
+	//CAFile is the PEM file of the certificate authorities the XMiDT API certificates are verified against
+	//Defaults to the ones of the system
+	CAFile string
+}
+
+//Config returns the TLS configuration of the connections to the XMiDT API, along with the client certificate so
+//that it can be reloaded. Both are nil if nothing is configured
+func (o ClientTLSOptions) Config() (*tls.Config, *Certificate, error) {
+	if o.CertificateFile == "" && o.KeyFile == "" && o.CAFile == "" {
+		return nil, nil, nil
+	}
+
+	var (
+		config      = new(tls.Config)
+		certificate *Certificate
+	)
+
+	if o.CAFile != "" {
+		data, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, nil, fmt.Errorf("no certificate found in '%s'", o.CAFile)
+		}
+	}
+
+	if o.CertificateFile != "" || o.KeyFile != "" {
+		var err error
+		if certificate, err = NewCertificate(o.CertificateFile, o.KeyFile); err != nil {
+			return nil, nil, err
+		}
+
+		config.GetClientCertificate = certificate.GetClientCertificate
+	}
+
+	return config, certificate, nil
+}
+
+//rotated is key material known to a Rotator
+type rotated struct {
+	name       string
+	reloadable Reloadable
+	files      []string
+}
+
+//Rotator reloads key material as it is rotated: on demand (i.e. on SIGHUP) or as the files it is loaded from change
+type Rotator struct {
+	logger log.Logger
+
+	lock    sync.Mutex
+	rotated []rotated
+}
+
+//NewRotator returns a Rotator which logs the outcome of reloads to the given logger
+func NewRotator(logger log.Logger) *Rotator {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+
+	return &Rotator{logger: logger}
+}
+
+//Add makes the given key material reloaded by the rotator. files are the ones it is loaded from, if any, whose
+//changes trigger a reload once the rotator watches them
+func (r *Rotator) Add(name string, reloadable Reloadable, files ...string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.rotated = append(r.rotated, rotated{name: name, reloadable: reloadable, files: files})
+}
+
+//Rotate reloads all the key material. Failures are logged and leave the material being used as is
+func (r *Rotator) Rotate() {
+	r.lock.Lock()
+	var all = append([]rotated(nil), r.rotated...)
+	r.lock.Unlock()
+
+	for _, material := range all {
+		r.reload(material)
+	}
+}
+
+func (r *Rotator) reload(material rotated) {
+	if err := material.reloadable.Reload(); err != nil {
+		logging.Error(r.logger).Log(logging.MessageKey(), "Key material could not be reloaded", "name", material.name, logging.ErrorKey(), err)
+		return
+	}
+
+	logging.Info(r.logger).Log(logging.MessageKey(), "Key material reloaded", "name", material.name)
+}
+
+//Watch reloads key material whenever the files it is loaded from change, until shutdown is closed
+//The directories of the files are watched rather than the files themselves as rotation usually replaces them
+//(i.e. Kubernetes swaps the symbolic link of the directory of mounted secrets)
+func (r *Rotator) Watch(shutdown <-chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	var (
+		all         = append([]rotated(nil), r.rotated...)
+		directories = make(map[string]bool)
+	)
+	r.lock.Unlock()
+
+	for _, material := range all {
+		for _, file := range material.files {
+			directories[filepath.Dir(file)] = true
+		}
+	}
+
+	if len(directories) == 0 {
+		watcher.Close()
+		return errors.New("no key material is loaded from files")
+	}
+
+	for directory := range directories {
+		if err = watcher.Add(directory); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-shutdown:
+				return
+			case event := <-watcher.Events:
+				for _, material := range all {
+					if watches(material, event.Name) {
+						r.reload(material)
+					}
+				}
+			case err := <-watcher.Errors:
+				logging.Error(r.logger).Log(logging.MessageKey(), "Key material files could not be watched", logging.ErrorKey(), err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+//watches tells whether a change to the given file may be a rotation of the given key material, which is the case of
+//changes in the directories of its files as rotation often replaces the links they resolve through
+func watches(material rotated, changed string) bool {
+	for _, file := range material.files {
+		if filepath.Dir(file) == filepath.Dir(changed) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,153 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//writeCertificate writes a self-signed certificate for the given common name and its key into dir
+func writeCertificate(t *testing.T, dir, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "tls.key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "tls.crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+}
+
+//commonName returns the common name of the current certificate
+func commonName(c *Certificate) string {
+	current, _ := c.GetCertificate(nil)
+	parsed, _ := x509.ParseCertificate(current.Certificate[0])
+	return parsed.Subject.CommonName
+}
+
+func TestCertificateRotation(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, _ := ioutil.TempDir("", "tr1d1um")
+	defer os.RemoveAll(dir)
+
+	_, err := NewCertificate(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"))
+	assert.NotNil(err)
+
+	writeCertificate(t, dir, "first")
+	certificate, err := NewCertificate(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"))
+	assert.Nil(err)
+	assert.Equal("first", commonName(certificate))
+
+	var (
+		rotator = NewRotator(nil)
+		jwks    int
+	)
+
+	rotator.Add("certificate", certificate, certificate.Files()...)
+	rotator.Add("JWKS", ReloadFunc(func() error {
+		jwks++
+		return errors.New("unreachable")
+	}))
+
+	writeCertificate(t, dir, "second")
+	rotator.Rotate()
+	assert.Equal("second", commonName(certificate))
+	assert.Equal(1, jwks)
+
+	//a broken rotation keeps the current certificate
+	ioutil.WriteFile(filepath.Join(dir, "tls.key"), []byte("garbage"), 0600)
+	rotator.Rotate()
+	assert.Equal("second", commonName(certificate))
+
+	client, _ := certificate.GetClientCertificate(nil)
+	server, _ := certificate.GetCertificate(nil)
+	assert.Equal(server, client)
+}
+
+func TestRotatorWatch(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, _ := ioutil.TempDir("", "tr1d1um")
+	defer os.RemoveAll(dir)
+
+	var (
+		shutdown = make(chan struct{})
+		rotator  = NewRotator(nil)
+	)
+
+	defer close(shutdown)
+	assert.NotNil(rotator.Watch(shutdown))
+
+	writeCertificate(t, dir, "first")
+	certificate, err := NewCertificate(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"))
+	assert.Nil(err)
+
+	rotator.Add("certificate", certificate, certificate.Files()...)
+	assert.Nil(rotator.Watch(shutdown))
+
+	writeCertificate(t, dir, "second")
+
+	for deadline := time.Now().Add(5 * time.Second); commonName(certificate) != "second" && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Equal("second", commonName(certificate))
+}
+
+func TestClientTLSOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, _ := ioutil.TempDir("", "tr1d1um")
+	defer os.RemoveAll(dir)
+
+	config, certificate, err := ClientTLSOptions{}.Config()
+	assert.Nil(err)
+	assert.Nil(config)
+	assert.Nil(certificate)
+
+	_, _, err = ClientTLSOptions{CAFile: filepath.Join(dir, "ca.crt")}.Config()
+	assert.NotNil(err)
+
+	writeCertificate(t, dir, "tr1d1um")
+	config, certificate, err = ClientTLSOptions{
+		CertificateFile: filepath.Join(dir, "tls.crt"),
+		KeyFile:         filepath.Join(dir, "tls.key"),
+		CAFile:          filepath.Join(dir, "tls.crt"),
+	}.Config()
+
+	assert.Nil(err)
+	assert.NotNil(config.RootCAs)
+	assert.Equal("tr1d1um", commonName(certificate))
+
+	_, _, err = ClientTLSOptions{CAFile: filepath.Join(dir, "tls.key")}.Config()
+	assert.NotNil(err)
+}
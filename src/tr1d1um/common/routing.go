@@ -0,0 +1,53 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+//routedMethods are the HTTP methods tr1d1um routes may be registered for
+var routedMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+//MethodNotAllowedHandler returns the handler for requests to known routes with methods the routes don't support
+//OPTIONS requests are answered with the methods the route supports. Any other method gets a 405
+//Both include an accurate Allow header. The handler is meant to be set as the router's MethodNotAllowedHandler
+func MethodNotAllowedHandler(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+
+		for _, method := range routedMethods {
+			var (
+				match     mux.RouteMatch
+				candidate = r.WithContext(r.Context())
+			)
+
+			candidate.Method = method
+			if router.Match(candidate, &match) && match.MatchErr == nil {
+				allowed = append(allowed, method)
+			}
+		}
+
+		w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "method not allowed",
+		})
+	})
+}
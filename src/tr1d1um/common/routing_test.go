@@ -0,0 +1,48 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMethodNotAllowedHandler(t *testing.T) {
+	var (
+		r   = mux.NewRouter()
+		api = r.PathPrefix("/api/v2/").Subrouter()
+		ok  = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {})
+	)
+
+	api.Handle("/device/{deviceid}/{service:iot}", ok).Methods(http.MethodPost)
+	api.Handle("/device/{deviceid}/{service}", ok).Methods(http.MethodGet, http.MethodPatch)
+	r.MethodNotAllowedHandler = MethodNotAllowedHandler(r)
+
+	t.Run("NotAllowed", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		r.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/api/v2/device/mac:112233445566/config", nil))
+		assert.EqualValues(http.StatusMethodNotAllowed, recorder.Code)
+		assert.EqualValues("GET, PATCH, OPTIONS", recorder.Header().Get("Allow"))
+		assert.JSONEq(`{"message": "method not allowed"}`, recorder.Body.String())
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		r.ServeHTTP(recorder, httptest.NewRequest(http.MethodOptions, "/api/v2/device/mac:112233445566/iot", nil))
+		assert.EqualValues(http.StatusNoContent, recorder.Code)
+		assert.EqualValues("GET, POST, PATCH, OPTIONS", recorder.Header().Get("Allow"))
+	})
+
+	t.Run("UnknownRoute", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+
+		r.ServeHTTP(recorder, httptest.NewRequest(http.MethodOptions, "/api/v2/unknown", nil))
+		assert.EqualValues(t, http.StatusNotFound, recorder.Code)
+	})
+}
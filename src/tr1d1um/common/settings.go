@@ -0,0 +1,53 @@
+package common
+
+import (
+	"context"
+	"net/http"
+)
+
+//Settings are the server-wide settings tr1d1um services and transactors read from the context of requests: where
+//partner IDs come from, how transaction IDs are carried and generated and how error messages are translated
+//They belong to a server rather than the process so that servers of different configurations may run side by side
+type Settings struct {
+	partners PartnerOptions
+	tid      *tidSettings
+	messages MessageCatalog
+}
+
+//defaultSettings apply to requests that didn't go through the middleware of any Settings
+var defaultSettings, _ = NewSettings(PartnerOptions{}, TIDOptions{}, nil)
+
+//NewSettings builds the settings of a server. It returns an error if the transaction ID options are invalid
+func NewSettings(partners PartnerOptions, tid TIDOptions, translations []MessageTranslation) (*Settings, error) {
+	if partners.Claim == "" {
+		partners.Claim = DefaultPartnerClaim
+	}
+
+	settings, err := newTIDSettings(tid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Settings{partners: partners, tid: settings, messages: newMessageCatalog(translations)}, nil
+}
+
+//Apply is a middleware which makes the settings available to the handling of requests and their calls to the XMiDT API
+func (s *Settings) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(WithSettings(r.Context(), s)))
+	})
+}
+
+//WithSettings returns a copy of the given context which carries the given settings
+func WithSettings(ctx context.Context, s *Settings) context.Context {
+	return context.WithValue(ctx, ContextKeySettings, s)
+}
+
+//settings returns the settings of the request of the given context, the default ones if none were applied
+func settings(ctx context.Context) *Settings {
+	if s, ok := ctx.Value(ContextKeySettings).(*Settings); ok {
+		return s
+	}
+
+	return defaultSettings
+}
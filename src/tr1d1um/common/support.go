@@ -0,0 +1,239 @@
+package common
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Support bundle defaults
+const (
+	DefaultSupportTransactions   = 100
+	DefaultSupportMetricsTimeout = 5 * time.Second
+)
+
+//SupportRedactionMask replaces the values of secret settings in support bundles
+const SupportRedactionMask = "<redacted>"
+
+//sensitiveSettings are the words which, found in the name of a setting, make its value a secret
+var sensitiveSettings = []string{"secret", "password", "passphrase", "token", "accesskey", "authheader", "authorization", "credential"}
+
+//SupportOptions configures the support bundle: a tarball of what the platform team needs to look into an escalation
+type SupportOptions struct {
+	//Enabled turns on the admin endpoint of the support bundle
+	Enabled bool
+
+	//Transactions is the number of the most recent transactions kept for the bundle. Defaults to DefaultSupportTransactions
+	Transactions int
+
+	//MetricsURL is the URL of the metrics (in the Prometheus text format) the bundle takes a snapshot of (optional)
+	MetricsURL string
+
+	//MetricsTimeout bounds the time the snapshot of the metrics may take. Defaults to DefaultSupportMetricsTimeout
+	MetricsTimeout time.Duration
+}
+
+//TransactionRecord summarizes a transaction served by tr1d1um
+type TransactionRecord struct {
+	TID     string        `json:"tid,omitempty"`
+	Time    time.Time     `json:"time"`
+	Method  string        `json:"method"`
+	Path    string        `json:"path"`
+	Code    int           `json:"code"`
+	Latency time.Duration `json:"latency"`
+}
+
+//TransactionRecorder keeps the most recent transactions
+type TransactionRecorder struct {
+	lock    sync.Mutex
+	records []TransactionRecord
+	next    int
+	full    bool
+}
+
+//NewTransactionRecorder returns a recorder which keeps the given number of transactions, DefaultSupportTransactions
+//if not positive
+func NewTransactionRecorder(capacity int) *TransactionRecorder {
+	if capacity < 1 {
+		capacity = DefaultSupportTransactions
+	}
+
+	return &TransactionRecorder{records: make([]TransactionRecord, capacity)}
+}
+
+//Record is a mux middleware which records the transactions of the routes of a router
+//Their query is left out as it may hold the names and values of device parameters
+func (t *TransactionRecorder) Record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			start    = time.Now()
+			recorder = &accessLogWriter{ResponseWriter: w, code: http.StatusOK}
+		)
+
+		next.ServeHTTP(recorder, r)
+
+		t.add(TransactionRecord{
+			TID:     w.Header().Get(TIDHeader(r.Context())),
+			Time:    start,
+			Method:  r.Method,
+			Path:    r.URL.Path,
+			Code:    recorder.code,
+			Latency: time.Since(start),
+		})
+	})
+}
+
+func (t *TransactionRecorder) add(record TransactionRecord) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.records[t.next] = record
+	t.next = (t.next + 1) % len(t.records)
+	t.full = t.full || t.next == 0
+}
+
+//Transactions returns the recorded transactions, oldest first
+func (t *TransactionRecorder) Transactions() []TransactionRecord {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.full {
+		return append([]TransactionRecord{}, t.records[:t.next]...)
+	}
+
+	return append(append([]TransactionRecord{}, t.records[t.next:]...), t.records[:t.next]...)
+}
+
+//RedactSettings returns a copy of the given settings (i.e. the ones of viper) whose secrets are masked: the values of
+//the settings named after secrets and the passwords of URLs
+func RedactSettings(settings map[string]interface{}) map[string]interface{} {
+	var redacted = make(map[string]interface{}, len(settings))
+	for name, value := range settings {
+		redacted[name] = redactSetting(name, value)
+	}
+
+	return redacted
+}
+
+func redactSetting(name string, value interface{}) interface{} {
+	var lowered = strings.ToLower(name)
+	for _, word := range sensitiveSettings {
+		if strings.Contains(lowered, word) {
+			return SupportRedactionMask
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return RedactSettings(v)
+	case map[interface{}]interface{}:
+		var redacted = make(map[string]interface{}, len(v))
+		for key, child := range v {
+			redacted[fmt.Sprint(key)] = redactSetting(fmt.Sprint(key), child)
+		}
+		return redacted
+	case []interface{}:
+		var redacted = make([]interface{}, len(v))
+		for i, child := range v {
+			redacted[i] = redactSetting("", child)
+		}
+		return redacted
+	case string:
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			if _, hasPassword := u.User.Password(); hasPassword {
+				u.User = url.UserPassword(u.User.Username(), SupportRedactionMask)
+				return u.String()
+			}
+		}
+	}
+
+	return value
+}
+
+//SupportBundleHandler is the admin handler of the support bundle, a gzipped tarball of:
+//config.json (the settings, whose secrets are redacted), transactions.json (the most recent transactions),
+//goroutines.txt (a dump of the goroutines) and metrics.txt (a snapshot of the metrics, if available)
+func SupportBundleHandler(o SupportOptions, settings func() map[string]interface{}, transactions *TransactionRecorder) http.Handler {
+	if o.MetricsTimeout <= 0 {
+		o.MetricsTimeout = DefaultSupportMetricsTimeout
+	}
+
+	var client = &http.Client{Timeout: o.MetricsTimeout}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			buffer  bytes.Buffer
+			gzipped = gzip.NewWriter(&buffer)
+			tarball = tar.NewWriter(gzipped)
+			now     = time.Now()
+		)
+
+		for _, file := range []struct {
+			name    string
+			content func() ([]byte, error)
+		}{
+			{"config.json", func() ([]byte, error) { return json.MarshalIndent(RedactSettings(settings()), "", "  ") }},
+			{"transactions.json", func() ([]byte, error) { return json.MarshalIndent(transactions.Transactions(), "", "  ") }},
+			{"goroutines.txt", goroutineDump},
+			{"metrics.txt", func() ([]byte, error) { return metricsSnapshot(client, o.MetricsURL), nil }},
+		} {
+			content, err := file.content()
+			if err != nil {
+				content = []byte(fmt.Sprintf("%s could not be produced: %s\n", file.name, err))
+			}
+
+			tarball.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(content)), ModTime: now})
+			tarball.Write(content)
+		}
+
+		if err := tarball.Close(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := gzipped.Close(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"tr1d1um-support-%s.tar.gz\"", now.UTC().Format("20060102T150405Z")))
+		w.Write(buffer.Bytes())
+	})
+}
+
+//goroutineDump returns the stacks of all the goroutines, in the format of unrecovered panics
+func goroutineDump() ([]byte, error) {
+	var buffer bytes.Buffer
+	err := pprof.Lookup("goroutine").WriteTo(&buffer, 2)
+	return buffer.Bytes(), err
+}
+
+//metricsSnapshot returns the metrics served at the given URL, or why they couldn't be fetched
+func metricsSnapshot(client *http.Client, metricsURL string) []byte {
+	if metricsURL == "" {
+		return []byte("no metrics URL is configured\n")
+	}
+
+	response, err := client.Get(metricsURL)
+	if err != nil {
+		return []byte(fmt.Sprintf("metrics could not be fetched: %s\n", err))
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil || response.StatusCode != http.StatusOK {
+		return []byte(fmt.Sprintf("metrics could not be fetched: status %d, error %v\n", response.StatusCode, err))
+	}
+
+	return body
+}
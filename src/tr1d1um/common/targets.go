@@ -0,0 +1,182 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Comcast/webpa-common/device"
+)
+
+//TargetPool holds the XMiDT API instances requests are spread across
+//Each device is routed to the same instance through rendezvous hashing so that membership changes only move
+//the devices of the instances which were added or removed
+type TargetPool struct {
+	lock    sync.RWMutex
+	targets []string
+
+	//pick returns a number in [0, n) used to pick the target of requests for no particular device
+	pick func(n int) int
+}
+
+//validateTargets returns an error if the given targets can't make up a pool
+func validateTargets(targets []string) error {
+	var seen = make(map[string]bool, len(targets))
+	for _, target := range targets {
+		if strings.TrimSpace(target) == "" {
+			return errors.New("targets can't be empty")
+		}
+
+		if seen[target] {
+			return fmt.Errorf("target '%s' is listed more than once", target)
+		}
+
+		seen[target] = true
+	}
+
+	return nil
+}
+
+//NewTargetPool builds a pool of the given base URLs of XMiDT API instances. An empty pool disables sticky routing
+func NewTargetPool(targets []string) (*TargetPool, error) {
+	if err := validateTargets(targets); err != nil {
+		return nil, err
+	}
+
+	return &TargetPool{targets: append([]string(nil), targets...), pick: rand.Intn}, nil
+}
+
+//Targets returns the current members of the pool
+func (p *TargetPool) Targets() []string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return append([]string(nil), p.targets...)
+}
+
+//Update replaces the members of the pool. Invalid members are rejected and leave the current ones untouched
+func (p *TargetPool) Update(targets []string) error {
+	if err := validateTargets(targets); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	p.targets = append([]string(nil), targets...)
+	p.lock.Unlock()
+	return nil
+}
+
+//Target returns the member of the pool requests for the given device (which may be empty) are sent to
+//It is empty if the pool has no members
+func (p *TargetPool) Target(deviceID string) string {
+	var targets = p.Targets()
+
+	if len(targets) == 0 {
+		return ""
+	}
+
+	if deviceID == "" {
+		return targets[p.pick(len(targets))]
+	}
+
+	if canonicalID, err := device.ParseID(deviceID); err == nil {
+		deviceID = string(canonicalID)
+	}
+
+	var (
+		target string
+		top    uint64
+	)
+
+	for i, candidate := range targets {
+		h := fnv.New64a()
+		h.Write([]byte(candidate))
+		h.Write([]byte{0})
+		h.Write([]byte(deviceID))
+
+		if weight := mix(h.Sum64()); i == 0 || weight > top {
+			target, top = candidate, weight
+		}
+	}
+
+	return target
+}
+
+//mix spreads the bits of an FNV hash, whose high bits barely change between similar inputs such as device IDs
+//(this is the finalizer of MurmurHash3)
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+//NewStickyTransactor decorates the given transactor so that requests to defaultTarget are sent to the member of
+//the pool their device hashes to instead. Requests to other targets (i.e. tenants or canaries) are left alone
+func NewStickyTransactor(next Tr1d1umTransactor, pool *TargetPool, defaultTarget string) Tr1d1umTransactor {
+	if pool == nil {
+		return next
+	}
+
+	return &stickyTransactor{next: next, pool: pool, defaultTarget: defaultTarget}
+}
+
+type stickyTransactor struct {
+	next          Tr1d1umTransactor
+	pool          *TargetPool
+	defaultTarget string
+}
+
+func (s *stickyTransactor) Transact(req *http.Request) (*XmidtResponse, error) {
+	if !targets(req, s.defaultTarget) {
+		return s.next.Transact(req)
+	}
+
+	deviceID := RequestDeviceID(req.Context())
+	if target := s.pool.Target(deviceID); target != "" {
+		if err := retarget(req, s.defaultTarget, target); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.next.Transact(req)
+}
+
+//TargetsHandler returns the handler through which the members of the given pool are read (GET) and replaced (PUT)
+//GET with a device query parameter also reports the member the requests for that device are sent to
+func TargetsHandler(pool *TargetPool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		if r.Method == http.MethodPut {
+			var update struct {
+				Targets []string `json:"targets"`
+			}
+
+			if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{"message": "invalid targets: " + err.Error()})
+				return
+			}
+
+			if err := pool.Update(update.Targets); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{"message": err.Error()})
+				return
+			}
+		}
+
+		var body = map[string]interface{}{"targets": pool.Targets()}
+		if deviceID := r.URL.Query().Get("device"); deviceID != "" {
+			body["target"] = pool.Target(deviceID)
+		}
+
+		json.NewEncoder(w).Encode(body)
+	})
+}
@@ -0,0 +1,120 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTargetPool(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewTargetPool([]string{"http://scytale-1:6000", ""})
+	assert.NotNil(err)
+
+	_, err = NewTargetPool([]string{"http://scytale-1:6000", "http://scytale-1:6000"})
+	assert.NotNil(err)
+
+	pool, err := NewTargetPool(nil)
+	assert.Nil(err)
+	assert.Empty(pool.Target("mac:112233445566"))
+}
+
+func TestTargetPoolTarget(t *testing.T) {
+	assert := assert.New(t)
+
+	pool, _ := NewTargetPool([]string{"http://scytale-1:6000", "http://scytale-2:6000", "http://scytale-3:6000"})
+
+	var (
+		assigned = make(map[string]string)
+		counts   = make(map[string]int)
+	)
+
+	for i := 0; i < 3000; i++ {
+		deviceID := fmt.Sprintf("mac:%012x", i)
+		assigned[deviceID] = pool.Target(deviceID)
+		counts[assigned[deviceID]]++
+
+		assert.Equal(assigned[deviceID], pool.Target(deviceID))
+	}
+
+	for _, count := range counts {
+		assert.InDelta(1000, count, 150)
+	}
+
+	//sticky regardless of the form of the ID
+	assert.Equal(pool.Target("mac:112233445566"), pool.Target("MAC:11:22:33:44:55:66"))
+
+	//only the devices of the removed target move
+	assert.Nil(pool.Update([]string{"http://scytale-1:6000", "http://scytale-3:6000"}))
+	for deviceID, target := range assigned {
+		if target != "http://scytale-2:6000" {
+			assert.Equal(target, pool.Target(deviceID))
+		}
+	}
+
+	pool.pick = func(n int) int { return n - 1 }
+	assert.Equal("http://scytale-3:6000", pool.Target(""))
+}
+
+func TestStickyTransactor(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		sent *http.Request
+		next = transactFunc(func(r *http.Request) (*XmidtResponse, error) {
+			sent = r
+			return &XmidtResponse{Code: http.StatusOK}, nil
+		})
+	)
+
+	pool, _ := NewTargetPool([]string{"http://scytale-1:6000"})
+	transactor := NewStickyTransactor(next, pool, "scytale:6000")
+
+	r, _ := http.NewRequest(http.MethodGet, "scytale:6000/api/v2/device/mac:112233445566/stat", nil)
+	_, err := transactor.Transact(r.WithContext(context.WithValue(context.Background(), ContextKeyRequestDeviceID, "mac:112233445566")))
+	assert.Nil(err)
+	assert.EqualValues("http://scytale-1:6000/api/v2/device/mac:112233445566/stat", sent.URL.String())
+
+	//requests to other targets are left alone
+	r, _ = http.NewRequest(http.MethodGet, "http://canary:6000/api/v2/device/mac:112233445566/stat", nil)
+	_, err = transactor.Transact(r)
+	assert.Nil(err)
+	assert.EqualValues("http://canary:6000/api/v2/device/mac:112233445566/stat", sent.URL.String())
+
+	assert.IsType(next, NewStickyTransactor(next, nil, "scytale:6000"))
+}
+
+func TestTargetsHandler(t *testing.T) {
+	pool, _ := NewTargetPool([]string{"http://scytale-1:6000"})
+	handler := TargetsHandler(pool)
+
+	t.Run("Get", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin/targets?device=mac:112233445566", nil))
+		assert.JSONEq(t, `{"targets": ["http://scytale-1:6000"], "target": "http://scytale-1:6000"}`, recorder.Body.String())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/admin/targets", bytes.NewBufferString(`{"targets": [""]}`)))
+		assert.EqualValues(http.StatusBadRequest, recorder.Code)
+		assert.Equal([]string{"http://scytale-1:6000"}, pool.Targets())
+	})
+
+	t.Run("Put", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/admin/targets", bytes.NewBufferString(`{"targets": ["http://scytale-2:6000"]}`)))
+		assert.EqualValues(http.StatusOK, recorder.Code)
+		assert.Equal([]string{"http://scytale-2:6000"}, pool.Targets())
+	})
+}
@@ -0,0 +1,181 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/Comcast/comcast-bascule/bascule"
+)
+
+//Tenant is an isolated XMiDT environment fronted by tr1d1um on behalf of some partners
+type Tenant struct {
+	//Name identifies the tenant in logs and configuration errors
+	Name string
+
+	//Partners are the partner IDs whose requests are routed to this tenant
+	Partners []string
+
+	//TargetURL is the base URL of the XMiDT API of this tenant. It has the same format as the default targetURL
+	TargetURL string
+
+	//Authorization is the Authorization header value used against the XMiDT API of this tenant (optional)
+	//If empty, the one of the API consumer is forwarded
+	Authorization string
+}
+
+//TenantConfig describes how requests are routed to tenants
+//Requests that do not belong to any tenant are sent to the default target
+//Tenants may come with their own credentials, so requests are routed by their partner ID (see RequestPartner)
+type TenantConfig struct {
+	//Tenants are the configured tenants
+	Tenants []Tenant
+}
+
+//Validate returns an error if the tenants are misconfigured
+func (c TenantConfig) Validate() error {
+	var owners = make(map[string]string)
+
+	for _, tenant := range c.Tenants {
+		if tenant.Name == "" {
+			return errors.New("tenants must have a name")
+		}
+
+		if _, err := url.Parse(tenant.TargetURL); err != nil || tenant.TargetURL == "" {
+			return fmt.Errorf("tenant '%s' has an invalid targetURL '%s'", tenant.Name, tenant.TargetURL)
+		}
+
+		if len(tenant.Partners) == 0 {
+			return fmt.Errorf("tenant '%s' has no partners", tenant.Name)
+		}
+
+		for _, partner := range tenant.Partners {
+			if owner, taken := owners[partner]; taken {
+				return fmt.Errorf("partner '%s' belongs to both tenants '%s' and '%s'", partner, owner, tenant.Name)
+			}
+			owners[partner] = tenant.Name
+		}
+	}
+
+	return nil
+}
+
+//TenantRouter finds the tenant requests belong to
+//Its configuration may be updated at any time
+type TenantRouter struct {
+	lock      sync.RWMutex
+	byPartner map[string]*Tenant
+}
+
+//NewTenantRouter builds a TenantRouter out of the given configuration
+func NewTenantRouter(c TenantConfig) (*TenantRouter, error) {
+	var r = new(TenantRouter)
+	return r, r.Update(c)
+}
+
+//Update replaces the configuration of the router. An invalid configuration is rejected and leaves the router untouched
+func (r *TenantRouter) Update(c TenantConfig) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	var byPartner = make(map[string]*Tenant)
+	for i := range c.Tenants {
+		for _, partner := range c.Tenants[i].Partners {
+			byPartner[partner] = &c.Tenants[i]
+		}
+	}
+
+	r.lock.Lock()
+	r.byPartner = byPartner
+	r.lock.Unlock()
+
+	return nil
+}
+
+//Tenant returns the tenant the given outgoing request belongs to, if any
+func (r *TenantRouter) Tenant(req *http.Request) *Tenant {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.byPartner[RequestPartner(req.Context())]
+}
+
+//Claim returns the string values of the given claim (i.e. capabilities) of the JWT the request of the given context
+//was authenticated with, if any
+func Claim(ctx context.Context, name string) []string {
+	if auth, ok := bascule.FromContext(ctx); ok && auth.Token != nil {
+		return claimValues(auth.Token.Attributes()[name])
+	}
+	return nil
+}
+
+//claimValues returns the string values of a JWT claim which may either be a string or a list of them
+func claimValues(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []string:
+		return value
+	case []interface{}:
+		var values []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+//NewTenantTransactor decorates the given transactor so that requests to the default target are sent to the XMiDT API
+//of the tenant they belong to instead. defaultTarget is the base URL outgoing requests are built with
+func NewTenantTransactor(next Tr1d1umTransactor, router *TenantRouter, defaultTarget string) Tr1d1umTransactor {
+	return &tenantTransactor{next: next, router: router, defaultTarget: defaultTarget}
+}
+
+type tenantTransactor struct {
+	next          Tr1d1umTransactor
+	router        *TenantRouter
+	defaultTarget string
+}
+
+func (t *tenantTransactor) Transact(req *http.Request) (*XmidtResponse, error) {
+	var tenant = t.router.Tenant(req)
+
+	if tenant == nil || !targets(req, t.defaultTarget) {
+		return t.next.Transact(req)
+	}
+
+	if err := retarget(req, t.defaultTarget, tenant.TargetURL); err != nil {
+		return nil, err
+	}
+
+	if tenant.Authorization != "" {
+		req.Header.Set("Authorization", tenant.Authorization)
+	}
+
+	return t.next.Transact(req)
+}
+
+//targets returns true if the given outgoing request is sent to the given base URL
+func targets(req *http.Request, baseURL string) bool {
+	return strings.HasPrefix(req.URL.String(), baseURL)
+}
+
+//retarget sends the given outgoing request to the same path under another base URL
+func retarget(req *http.Request, from, to string) error {
+	targetURL, err := url.Parse(to + strings.TrimPrefix(req.URL.String(), from))
+	if err != nil {
+		return err
+	}
+
+	req.URL, req.Host = targetURL, targetURL.Host
+	return nil
+}
@@ -0,0 +1,146 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Comcast/comcast-bascule/bascule"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTenantConfig = TenantConfig{
+	Tenants: []Tenant{
+		{Name: "staging", Partners: []string{"comcast-staging"}, TargetURL: "http://staging:6000", Authorization: "Basic c3RhZ2luZw=="},
+		{Name: "lab", Partners: []string{"lab"}, TargetURL: "http://lab:6000"},
+	},
+}
+
+type transactFunc func(*http.Request) (*XmidtResponse, error)
+
+func (f transactFunc) Transact(r *http.Request) (*XmidtResponse, error) {
+	return f(r)
+}
+
+func TestTenantConfigValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(testTenantConfig.Validate())
+	assert.NotNil(TenantConfig{Tenants: []Tenant{{Partners: []string{"a"}, TargetURL: "http://a"}}}.Validate())
+	assert.NotNil(TenantConfig{Tenants: []Tenant{{Name: "a", Partners: []string{"a"}}}}.Validate())
+	assert.NotNil(TenantConfig{Tenants: []Tenant{{Name: "a", TargetURL: "http://a"}}}.Validate())
+	assert.NotNil(TenantConfig{Tenants: []Tenant{
+		{Name: "a", Partners: []string{"p"}, TargetURL: "http://a"},
+		{Name: "b", Partners: []string{"p"}, TargetURL: "http://b"},
+	}}.Validate())
+}
+
+func TestTenantRouter(t *testing.T) {
+	router, err := NewTenantRouter(testTenantConfig)
+	assert.Nil(t, err)
+
+	newRequest := func(partnerHeader string, claim interface{}) *http.Request {
+		ctx := context.WithValue(context.Background(), ContextKeyRequestHeaders, http.Header{HeaderXmidtPartnerID: []string{partnerHeader}})
+		if claim != nil {
+			ctx = bascule.WithAuthentication(ctx, bascule.Authentication{Token: bascule.NewToken("jwt", "client", bascule.Attributes{"partner-id": claim})})
+		}
+
+		r, _ := http.NewRequest(http.MethodGet, "http://localhost:6000", nil)
+		return r.WithContext(ctx)
+	}
+
+	t.Run("UntrustedHeader", func(t *testing.T) {
+		//a token whose claim matches no tenant doesn't fall back to the header
+		assert.Nil(t, router.Tenant(newRequest("lab", nil)))
+		assert.Nil(t, router.Tenant(newRequest("lab", "other")))
+	})
+
+	t.Run("TrustedHeader", func(t *testing.T) {
+		trusting, _ := NewSettings(PartnerOptions{TrustHeader: true}, TIDOptions{}, nil)
+		trusted := func(r *http.Request) *http.Request {
+			return r.WithContext(WithSettings(r.Context(), trusting))
+		}
+
+		assert.EqualValues(t, "lab", router.Tenant(trusted(newRequest("lab", nil))).Name)
+		assert.Nil(t, router.Tenant(trusted(newRequest("lab", "other"))))
+	})
+
+	t.Run("RequestPartner", func(t *testing.T) {
+		assert := assert.New(t)
+		router, _ := NewTenantRouter(TenantConfig{Tenants: testTenantConfig.Tenants})
+
+		assert.EqualValues("lab", router.Tenant(newRequest("", "lab")).Name)
+		assert.Nil(router.Tenant(newRequest("lab", nil)))
+	})
+
+	t.Run("Claim", func(t *testing.T) {
+		assert := assert.New(t)
+
+		//the header selects one of the partners the token grants, else the first one applies
+		assert.EqualValues("staging", router.Tenant(newRequest("comcast-staging", []interface{}{"other", "comcast-staging"})).Name)
+		assert.Nil(router.Tenant(newRequest("lab", []interface{}{"other", "comcast-staging"})))
+	})
+
+	t.Run("None", func(t *testing.T) {
+		assert.Nil(t, router.Tenant(newRequest("other", "other")))
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		assert := assert.New(t)
+		router, _ := NewTenantRouter(testTenantConfig)
+
+		assert.NotNil(router.Update(TenantConfig{Tenants: []Tenant{{Name: "broken"}}}))
+		assert.NotNil(router.Tenant(newRequest("", "lab")))
+
+		assert.Nil(router.Update(TenantConfig{}))
+		assert.Nil(router.Tenant(newRequest("", "lab")))
+	})
+}
+
+func TestTenantTransactor(t *testing.T) {
+	router, _ := NewTenantRouter(testTenantConfig)
+
+	var sent *http.Request
+	transactor := NewTenantTransactor(transactFunc(func(r *http.Request) (*XmidtResponse, error) {
+		sent = r
+		return &XmidtResponse{}, nil
+	}), router, "scytale:6000")
+
+	newRequest := func(partner string) *http.Request {
+		r, _ := http.NewRequest(http.MethodPost, "scytale:6000/api/v2/device", nil)
+		r.Header.Set("Authorization", "Bearer consumer")
+		return r.WithContext(bascule.WithAuthentication(context.Background(), bascule.Authentication{
+			Token: bascule.NewToken("jwt", "client", bascule.Attributes{"partner-id": partner}),
+		}))
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		assert := assert.New(t)
+		transactor.Transact(newRequest("other"))
+		assert.EqualValues("scytale:6000/api/v2/device", sent.URL.String())
+		assert.EqualValues("Bearer consumer", sent.Header.Get("Authorization"))
+	})
+
+	t.Run("UntrustedHeader", func(t *testing.T) {
+		assert := assert.New(t)
+		r := newRequest("other")
+		transactor.Transact(r.WithContext(context.WithValue(r.Context(), ContextKeyRequestHeaders, http.Header{HeaderXmidtPartnerID: []string{"comcast-staging"}})))
+		assert.EqualValues("scytale:6000/api/v2/device", sent.URL.String())
+		assert.EqualValues("Bearer consumer", sent.Header.Get("Authorization"))
+	})
+
+	t.Run("TenantCredentials", func(t *testing.T) {
+		assert := assert.New(t)
+		transactor.Transact(newRequest("comcast-staging"))
+		assert.EqualValues("http://staging:6000/api/v2/device", sent.URL.String())
+		assert.EqualValues("staging:6000", sent.Host)
+		assert.EqualValues("Basic c3RhZ2luZw==", sent.Header.Get("Authorization"))
+	})
+
+	t.Run("ConsumerCredentials", func(t *testing.T) {
+		assert := assert.New(t)
+		transactor.Transact(newRequest("lab"))
+		assert.EqualValues("http://lab:6000/api/v2/device", sent.URL.String())
+		assert.EqualValues("Bearer consumer", sent.Header.Get("Authorization"))
+	})
+}
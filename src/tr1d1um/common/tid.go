@@ -0,0 +1,197 @@
+package common
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Formats of the transaction IDs tr1d1um generates for requests which don't come with one
+const (
+	//TIDBase64 is 16 random bytes, base64 (URL) encoded. It is the default
+	TIDBase64 = "base64"
+
+	//TIDUUID is a random (version 4) UUID
+	TIDUUID = "uuid"
+
+	//TIDULID is a ULID: a timestamp followed by random bits, in Crockford's base32, which sorts by creation time
+	//The ULIDs of an instance are monotonic: those created within the same millisecond still sort in creation order
+	TIDULID = "ulid"
+)
+
+//TIDGenerator generates transaction IDs
+type TIDGenerator func() (string, error)
+
+//TIDOptions configure the transaction IDs tr1d1um correlates requests with. They are part of the Settings of a server
+type TIDOptions struct {
+	//Header is the name of the header transaction IDs are read from and written to. Defaults to HeaderWPATID
+	Header string
+
+	//Format is the format of generated transaction IDs: TIDBase64 (the default), TIDUUID, TIDULID
+	//or any format added through RegisterTIDGenerator
+	Format string
+
+	//InstanceID prefixes generated transaction IDs so they can be traced back to the instance that handled the request (optional)
+	InstanceID string
+}
+
+//Validate returns an error if the format is unknown or the header name is not a valid one
+func (o TIDOptions) Validate() error {
+	if tidGenerator(o.Format) == nil {
+		return fmt.Errorf("unknown transaction ID format '%s'", o.Format)
+	}
+
+	if strings.ContainsAny(o.Header, " :\t\r\n") {
+		return fmt.Errorf("invalid transaction ID header '%s'", o.Header)
+	}
+
+	return nil
+}
+
+type tidSettings struct {
+	header   string
+	generate TIDGenerator
+	prefix   string
+}
+
+var (
+	tidGeneratorsLock sync.RWMutex
+	tidGenerators     = map[string]TIDGenerator{
+		"":        base64TID,
+		TIDBase64: base64TID,
+		TIDUUID:   uuidTID,
+		TIDULID:   ulidTID,
+	}
+)
+
+//RegisterTIDGenerator makes a transaction ID format available to TIDOptions, replacing any format of the same name
+//Formats are meant to be registered at startup, before the Settings which use them are built
+func RegisterTIDGenerator(format string, generate TIDGenerator) {
+	tidGeneratorsLock.Lock()
+	defer tidGeneratorsLock.Unlock()
+	tidGenerators[strings.ToLower(format)] = generate
+}
+
+//tidGenerator returns the generator of the given format. It is nil if the format is unknown
+func tidGenerator(format string) TIDGenerator {
+	tidGeneratorsLock.RLock()
+	defer tidGeneratorsLock.RUnlock()
+	return tidGenerators[strings.ToLower(format)]
+}
+
+//newTIDSettings returns how transaction IDs are read, written and generated as per the given options
+func newTIDSettings(o TIDOptions) (*tidSettings, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	var settings = &tidSettings{
+		header:   http.CanonicalHeaderKey(o.Header),
+		generate: tidGenerator(o.Format),
+	}
+
+	if settings.header == "" {
+		settings.header = HeaderWPATID
+	}
+
+	if o.InstanceID != "" {
+		settings.prefix = o.InstanceID + "-"
+	}
+
+	return settings, nil
+}
+
+//TIDHeader returns the name of the header which carries the transaction IDs of the request of the given context
+func TIDHeader(ctx context.Context) string {
+	return settings(ctx).tid.header
+}
+
+func base64TID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func uuidTID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	buf[6] = buf[6]&0x0f | 0x40 //version 4
+	buf[8] = buf[8]&0x3f | 0x80 //RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:]), nil
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+//ulidState is the last ULID generated, so that the next ones are greater even within the same millisecond
+var ulidState struct {
+	sync.Mutex
+	ms      uint64
+	entropy [10]byte
+}
+
+//ulidEntropy returns the timestamp and random bits of the next ULID
+//Within the same millisecond (or if the clock goes back) the random bits of the last ULID are incremented instead
+func ulidEntropy(ms uint64) (uint64, [10]byte, error) {
+	ulidState.Lock()
+	defer ulidState.Unlock()
+
+	if ms > ulidState.ms {
+		if _, err := rand.Read(ulidState.entropy[:]); err != nil {
+			return 0, ulidState.entropy, err
+		}
+
+		ulidState.ms = ms
+		return ulidState.ms, ulidState.entropy, nil
+	}
+
+	for i := len(ulidState.entropy) - 1; i >= 0; i-- {
+		if ulidState.entropy[i]++; ulidState.entropy[i] != 0 {
+			return ulidState.ms, ulidState.entropy, nil
+		}
+	}
+
+	//the 80 bits overflowed, which takes more IDs than can be generated in a millisecond
+	ulidState.ms++
+	return ulidState.ms, ulidState.entropy, nil
+}
+
+func ulidTID() (string, error) {
+	var buf [16]byte
+
+	//48 bits of milliseconds since the epoch followed by 80 random bits
+	ms, entropy, err := ulidEntropy(uint64(time.Now().UnixNano() / int64(time.Millisecond)))
+	if err != nil {
+		return "", err
+	}
+
+	binary.BigEndian.PutUint64(buf[:8], ms<<16)
+	copy(buf[6:], entropy[:])
+
+	//the 128 bits are encoded 5 at a time, the first character only carrying 3 of them
+	var (
+		hi  = binary.BigEndian.Uint64(buf[:8])
+		lo  = binary.BigEndian.Uint64(buf[8:])
+		out [26]byte
+	)
+
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(out[:]), nil
+}
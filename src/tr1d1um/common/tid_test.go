@@ -0,0 +1,102 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTIDOptionsValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(TIDOptions{}.Validate())
+	assert.Nil(TIDOptions{Header: "X-Correlation-Id", Format: "ULID"}.Validate())
+	assert.NotNil(TIDOptions{Format: "snowflake"}.Validate())
+	assert.NotNil(TIDOptions{Header: "X-Correlation Id"}.Validate())
+}
+
+//withTIDOptions returns a context carrying settings made of the given transaction ID options
+func withTIDOptions(t *testing.T, o TIDOptions) context.Context {
+	settings, err := NewSettings(PartnerOptions{}, o, nil)
+	assert.Nil(t, err)
+	return WithSettings(context.TODO(), settings)
+}
+
+func TestTIDSettings(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options TIDOptions
+		pattern string
+	}{
+		{"Default", TIDOptions{}, `^[A-Za-z0-9_-]{22}$`},
+		{"UUID", TIDOptions{Format: TIDUUID}, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{"ULID", TIDOptions{Format: TIDULID}, `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`},
+		{"InstanceID", TIDOptions{Format: TIDUUID, InstanceID: "tr1d1um-3"}, `^tr1d1um-3-[0-9a-f]{8}-`},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Regexp(t, regexp.MustCompile(testCase.pattern), genTID(withTIDOptions(t, testCase.options)))
+		})
+	}
+
+	t.Run("Header", func(t *testing.T) {
+		assert := assert.New(t)
+		ctx := withTIDOptions(t, TIDOptions{Header: "x-correlation-id"})
+		assert.Equal("X-Correlation-Id", TIDHeader(ctx))
+
+		r := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+		r.Header.Set("X-Correlation-Id", "tid01")
+		r.Header.Set(HeaderWPATID, "ignored")
+
+		assert.Equal("tid01", Capture(ctx, r).Value(ContextKeyRequestTID))
+
+		//requests handled with other settings are unaffected
+		assert.Equal(HeaderWPATID, TIDHeader(context.TODO()))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		settings, err := NewSettings(PartnerOptions{}, TIDOptions{Format: "snowflake"}, nil)
+		assert.Nil(t, settings)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestULIDOrder(t *testing.T) {
+	first, _ := ulidTID()
+	time.Sleep(2 * time.Millisecond)
+	second, _ := ulidTID()
+
+	assert.True(t, strings.Compare(first, second) < 0)
+
+	//within the same millisecond too
+	var previous = second
+	for i := 0; i < 1000; i++ {
+		next, err := ulidTID()
+		assert.Nil(t, err)
+		assert.True(t, strings.Compare(previous, next) < 0)
+		previous = next
+	}
+}
+
+func TestULIDEntropyOverflow(t *testing.T) {
+	ulidState.Lock()
+	ulidState.ms, ulidState.entropy = 1000, [10]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	ulidState.Unlock()
+
+	ms, entropy, err := ulidEntropy(1000)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1001, ms)
+	assert.Equal(t, [10]byte{}, entropy)
+}
+
+func TestRegisterTIDGenerator(t *testing.T) {
+	RegisterTIDGenerator("Sequence", func() (string, error) { return "42", nil })
+	assert.Equal(t, "tr1d1um-3-42", genTID(withTIDOptions(t, TIDOptions{Format: "sequence", InstanceID: "tr1d1um-3"})))
+}
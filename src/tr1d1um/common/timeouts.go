@@ -0,0 +1,195 @@
+package common
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+//Stages of a transaction with the XMiDT API at which a timeout may occur
+const (
+	//TimeoutStageConnect is the stage at which a connection to the XMiDT API is being established
+	TimeoutStageConnect = "connect"
+
+	//TimeoutStageBackend is the stage at which tr1d1um waits for the XMiDT API (and ultimately the device) to respond
+	TimeoutStageBackend = "backend"
+
+	//TimeoutStageAttempt is the stage at which tr1d1um waits for the XMiDT API to respond to one of the attempts
+	//(the initial one or a retry) of a transaction
+	TimeoutStageAttempt = "attempt"
+
+	//TimeoutStageTotal covers the entire HTTP transaction as bounded by the HTTP client timeout
+	TimeoutStageTotal = "total"
+
+	//TimeoutStageWriteOrder is the stage at which a write waits for the previous writes to the same device, before
+	//its transaction with the XMiDT API starts
+	TimeoutStageWriteOrder = "write_order"
+)
+
+var timeoutMessages = map[string]string{
+	TimeoutStageConnect:    "timed out connecting to the XMiDT API",
+	TimeoutStageBackend:    "timed out waiting for a response from the XMiDT API",
+	TimeoutStageAttempt:    "attempt timed out waiting for a response from the XMiDT API",
+	TimeoutStageTotal:      "transaction with the XMiDT API exceeded the allowed time",
+	TimeoutStageWriteOrder: "timed out waiting for previous writes to the device",
+}
+
+//ErrorCoder describes errors that carry a stable, machine-readable code for API consumers
+type ErrorCoder interface {
+	ErrorCode() string
+}
+
+//TimeoutError is the CodedError returned when a transaction with the XMiDT API times out
+type TimeoutError struct {
+	//Stage is the part of the transaction that timed out
+	Stage string
+
+	//Err is the underlying error
+	Err error
+}
+
+func (t *TimeoutError) Error() string {
+	return timeoutMessages[t.Stage]
+}
+
+//StatusCode returns 504 as tr1d1um acts as a gateway to the XMiDT API
+func (t *TimeoutError) StatusCode() int {
+	return http.StatusGatewayTimeout
+}
+
+//ErrorCode returns a code that identifies the stage that timed out (i.e. "backend_timeout")
+func (t *TimeoutError) ErrorCode() string {
+	return t.Stage + "_timeout"
+}
+
+//timeoutStage returns the stage at which the given transaction error occurred if it was a timeout
+//ctx should be the context bounding the transaction
+func timeoutStage(ctx context.Context, err error) (stage string, isTimeout bool) {
+	var (
+		urlErr, _  = err.(*url.Error)
+		timeout, _ = err.(interface{ Timeout() bool })
+	)
+
+	if err != context.DeadlineExceeded && (timeout == nil || !timeout.Timeout()) {
+		return
+	}
+
+	if urlErr != nil {
+		if opErr, ok := urlErr.Err.(*net.OpError); ok && opErr.Op == "dial" {
+			return TimeoutStageConnect, true
+		}
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return TimeoutStageBackend, true
+	}
+
+	if err == errAttemptTimeout || urlErr != nil && urlErr.Err == errAttemptTimeout {
+		return TimeoutStageAttempt, true
+	}
+
+	return TimeoutStageTotal, true
+}
+
+//attemptTimeoutError is the error of the attempts of a transaction which time out. Like deadline errors,
+//it is temporary so that the attempt is retried within the time left for the transaction
+type attemptTimeoutError struct{}
+
+func (attemptTimeoutError) Error() string   { return "attempt timed out" }
+func (attemptTimeoutError) Timeout() bool   { return true }
+func (attemptTimeoutError) Temporary() bool { return true }
+
+var errAttemptTimeout error = attemptTimeoutError{}
+
+//AttemptTimeout decorates the given HTTP transactor (i.e. http.Client.Do) so that each attempt (the initial one or
+//a retry) is bounded by the given timeout, including the reading of the response body. The transaction as a whole
+//remains bounded by the deadline of the context of its request. A zero timeout leaves attempts unbounded
+func AttemptTimeout(timeout time.Duration, next func(*http.Request) (*http.Response, error)) func(*http.Request) (*http.Response, error) {
+	if timeout <= 0 {
+		return next
+	}
+
+	return func(req *http.Request) (*http.Response, error) {
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+
+		resp, err := next(req.WithContext(ctx))
+		if err != nil {
+			if urlErr, ok := err.(*url.Error); ok && ctx.Err() == context.DeadlineExceeded && req.Context().Err() == nil {
+				if opErr, ok := urlErr.Err.(*net.OpError); !ok || opErr.Op != "dial" {
+					err = &url.Error{Op: urlErr.Op, URL: urlErr.URL, Err: errAttemptTimeout}
+				}
+			}
+
+			cancel()
+			return nil, err
+		}
+
+		resp.Body = &cancelOnClose{ReadCloser: resp.Body, attempt: ctx, transaction: req.Context(), cancel: cancel}
+		return resp, nil
+	}
+}
+
+//cancelOnClose releases the context of an attempt once its response body is closed
+//Reading the body past the timeout of the attempt, but not of the transaction, fails with errAttemptTimeout
+type cancelOnClose struct {
+	io.ReadCloser
+	attempt, transaction context.Context
+	cancel               context.CancelFunc
+}
+
+func (c *cancelOnClose) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && c.attempt.Err() == context.DeadlineExceeded && c.transaction.Err() == nil {
+		err = errAttemptTimeout
+	}
+
+	return n, err
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+//TimeoutBounds limits the request timeouts API consumers may ask for through the HeaderWPATimeout header
+//Requested timeouts are clamped to [Min, Max]. The header is ignored unless Max is set
+type TimeoutBounds struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+//requestTimeout returns the timeout for a request given the value of its HeaderWPATimeout header
+//fallback is used when the header is absent or the bounds don't allow clients to choose
+func (b TimeoutBounds) requestTimeout(requested string, fallback time.Duration) (time.Duration, error) {
+	if requested == "" || b.Max <= 0 {
+		return fallback, nil
+	}
+
+	timeout, err := time.ParseDuration(requested)
+	if err != nil {
+		var seconds float64
+		if seconds, err = strconv.ParseFloat(requested, 64); err != nil {
+			return 0, ErrInvalidRequestTimeout
+		}
+
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+
+	if timeout <= 0 {
+		return 0, ErrInvalidRequestTimeout
+	}
+
+	if timeout < b.Min {
+		timeout = b.Min
+	}
+
+	if timeout > b.Max {
+		timeout = b.Max
+	}
+
+	return timeout, nil
+}
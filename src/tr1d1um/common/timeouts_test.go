@@ -0,0 +1,193 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//netTimeoutError is a net.Error which reports a timeout
+type netTimeoutError struct{}
+
+func (netTimeoutError) Error() string   { return "i/o timeout" }
+func (netTimeoutError) Timeout() bool   { return true }
+func (netTimeoutError) Temporary() bool { return true }
+
+//clientTimeoutError mimics the error returned by an http.Client when its timeout is exceeded
+type clientTimeoutError struct{}
+
+func (clientTimeoutError) Error() string { return "Client.Timeout exceeded while awaiting headers" }
+func (clientTimeoutError) Timeout() bool { return true }
+
+func TestTimeoutStage(t *testing.T) {
+	expiredCtx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		err           error
+		expectedStage string
+		isTimeout     bool
+	}{
+		{
+			name: "Connect",
+			ctx:  expiredCtx,
+			err: &url.Error{Op: "Post", URL: "http://xmidt", Err: &net.OpError{
+				Op: "dial", Net: "tcp", Err: netTimeoutError{},
+			}},
+			expectedStage: TimeoutStageConnect,
+			isTimeout:     true,
+		},
+		{
+			name:          "Backend",
+			ctx:           expiredCtx,
+			err:           &url.Error{Op: "Post", URL: "http://xmidt", Err: context.DeadlineExceeded},
+			expectedStage: TimeoutStageBackend,
+			isTimeout:     true,
+		},
+		{
+			name:          "Attempt",
+			ctx:           context.Background(),
+			err:           &url.Error{Op: "Post", URL: "http://xmidt", Err: errAttemptTimeout},
+			expectedStage: TimeoutStageAttempt,
+			isTimeout:     true,
+		},
+		{
+			name:          "Total",
+			ctx:           context.Background(),
+			err:           &url.Error{Op: "Post", URL: "http://xmidt", Err: clientTimeoutError{}},
+			expectedStage: TimeoutStageTotal,
+			isTimeout:     true,
+		},
+		{
+			name: "NotTimeout",
+			ctx:  context.Background(),
+			err:  &url.Error{Op: "Post", URL: "http://xmidt", Err: errors.New("connection refused")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			stage, isTimeout := timeoutStage(test.ctx, test.err)
+			assert.Equal(test.expectedStage, stage)
+			assert.Equal(test.isTimeout, isTimeout)
+		})
+	}
+}
+
+func TestTimeoutError(t *testing.T) {
+	assert := assert.New(t)
+	var e error = &TimeoutError{Stage: TimeoutStageBackend, Err: context.DeadlineExceeded}
+
+	ce, ok := e.(CodedError)
+	assert.True(ok)
+	assert.EqualValues(http.StatusGatewayTimeout, ce.StatusCode())
+	assert.EqualValues("backend_timeout", e.(ErrorCoder).ErrorCode())
+	assert.NotEmpty(e.Error())
+}
+
+func TestRequestTimeout(t *testing.T) {
+	var (
+		fallback = 40 * time.Second
+		bounds   = TimeoutBounds{Min: time.Second, Max: time.Minute}
+	)
+
+	tests := []struct {
+		name      string
+		bounds    TimeoutBounds
+		requested string
+		expected  time.Duration
+		err       error
+	}{
+		{"NotRequested", bounds, "", fallback, nil},
+		{"Disabled", TimeoutBounds{}, "5s", fallback, nil},
+		{"Duration", bounds, "5s", 5 * time.Second, nil},
+		{"Seconds", bounds, "2.5", 2500 * time.Millisecond, nil},
+		{"BelowMin", bounds, "10ms", time.Second, nil},
+		{"AboveMax", bounds, "1h", time.Minute, nil},
+		{"Malformed", bounds, "soon", 0, ErrInvalidRequestTimeout},
+		{"NotPositive", bounds, "-3s", 0, ErrInvalidRequestTimeout},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			actual, err := test.bounds.requestTimeout(test.requested, fallback)
+
+			assert.EqualValues(test.expected, actual)
+			assert.EqualValues(test.err, err)
+		})
+	}
+}
+
+func TestAttemptTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var delay time.Duration
+		if r.URL.Path == "/slow" {
+			delay = time.Second
+		}
+
+		select {
+		case <-time.After(delay):
+			w.Write([]byte("ok"))
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	t.Run("Unbounded", func(t *testing.T) {
+		assert := assert.New(t)
+		assert.NotNil(AttemptTimeout(0, server.Client().Do))
+	})
+
+	do := AttemptTimeout(50*time.Millisecond, server.Client().Do)
+
+	t.Run("Responded", func(t *testing.T) {
+		assert := assert.New(t)
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := do(req)
+		assert.Nil(err)
+
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.Nil(err)
+		assert.EqualValues("ok", body)
+		assert.Nil(resp.Body.Close())
+	})
+
+	t.Run("AttemptTimedOut", func(t *testing.T) {
+		assert := assert.New(t)
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/slow", nil)
+		_, err := do(req)
+
+		netErr, ok := err.(net.Error)
+		assert.True(ok)
+		assert.True(netErr.Temporary())
+
+		stage, isTimeout := timeoutStage(req.Context(), err)
+		assert.True(isTimeout)
+		assert.Equal(TimeoutStageAttempt, stage)
+	})
+
+	t.Run("TotalTimedOut", func(t *testing.T) {
+		assert := assert.New(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/slow", nil)
+		_, err := do(req.WithContext(ctx))
+
+		stage, isTimeout := timeoutStage(ctx, err)
+		assert.True(isTimeout)
+		assert.Equal(TimeoutStageBackend, stage)
+	})
+}
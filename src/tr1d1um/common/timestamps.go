@@ -0,0 +1,46 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+//Headers of the times of the stages of a transaction, which let API consumers detect clock skew and stale responses
+//Times are in RFC 3339 format, in UTC, as measured by tr1d1um
+const (
+	//HeaderWPAReceivedAt is the time tr1d1um received the request of the API consumer
+	HeaderWPAReceivedAt = "X-Webpa-Received-At"
+
+	//HeaderWPABackendSentAt is the time tr1d1um sent the request to the XMiDT API
+	HeaderWPABackendSentAt = "X-Webpa-Backend-Sent-At"
+
+	//HeaderWPABackendRespondedAt is the time the XMiDT API responded. It is older than the response for responses
+	//served from cache
+	HeaderWPABackendRespondedAt = "X-Webpa-Backend-Responded-At"
+)
+
+//FormatTimestamp formats the given time the way the timestamps of transactions are reported
+func FormatTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+//SetTimestamps sets the headers of the times of the transaction of the given context, whose XMiDT response is
+//resp (optional). Times which weren't recorded are left out
+func SetTimestamps(ctx context.Context, h http.Header, resp *XmidtResponse) {
+	if receivedAt, ok := RequestArrivalTime(ctx); ok {
+		h.Set(HeaderWPAReceivedAt, FormatTimestamp(receivedAt))
+	}
+
+	if resp == nil {
+		return
+	}
+
+	if !resp.SentAt.IsZero() {
+		h.Set(HeaderWPABackendSentAt, FormatTimestamp(resp.SentAt))
+	}
+
+	if !resp.RespondedAt.IsZero() {
+		h.Set(HeaderWPABackendRespondedAt, FormatTimestamp(resp.RespondedAt))
+	}
+}
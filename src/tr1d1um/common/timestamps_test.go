@@ -0,0 +1,30 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetTimestamps(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		arrival  = time.Date(2020, time.March, 1, 12, 0, 0, 0, time.FixedZone("EST", -5*3600))
+		response = &XmidtResponse{SentAt: arrival.Add(time.Millisecond), RespondedAt: arrival.Add(time.Second)}
+	)
+
+	headers := make(http.Header)
+	SetTimestamps(context.Background(), headers, nil)
+	assert.Empty(headers)
+
+	SetTimestamps(context.Background(), headers, new(XmidtResponse))
+	assert.Empty(headers)
+
+	SetTimestamps(context.WithValue(context.Background(), ContextKeyRequestArrivalTime, arrival), headers, response)
+	assert.Equal("2020-03-01T17:00:00Z", headers.Get(HeaderWPAReceivedAt))
+	assert.Equal("2020-03-01T17:00:00.001Z", headers.Get(HeaderWPABackendSentAt))
+	assert.Equal("2020-03-01T17:00:01Z", headers.Get(HeaderWPABackendRespondedAt))
+}
@@ -1,10 +1,16 @@
 package common
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
+
+	"github.com/Comcast/webpa-common/logging"
 )
 
 //XmidtResponse represents the data that a tr1d1um transactor keeps from an HTTP request to
@@ -19,6 +25,22 @@ type XmidtResponse struct {
 
 	//Body represents the full data off the XMiDT http.Response body
 	Body []byte
+
+	//ContentType is the media type of Body
+	ContentType string
+
+	//Compressed is the body as received, if it was compressed with ContentEncoding (gzip), so that it can be passed
+	//through to API consumers as is. Body holds it decompressed. The XMiDT API only compresses responses if the
+	//requests it receives ask for it through Accept-Encoding, as HTTP clients otherwise decompress them themselves
+	Compressed      []byte
+	ContentEncoding string
+
+	//Latency is the time the XMiDT API took to respond, including reading the full body
+	Latency time.Duration
+
+	//SentAt and RespondedAt are the times the request was sent to the XMiDT API and its response fully read
+	SentAt      time.Time
+	RespondedAt time.Time
 }
 
 //Tr1d1umTransactor performs a typical HTTP request but
@@ -34,43 +56,176 @@ type Tr1d1umTransactorOptions struct {
 	//RequestTimeout is the deadline duration for the HTTP transaction to be completed
 	RequestTimeout time.Duration
 
+	//RequestTimeoutBounds limits the timeouts API consumers may request through the HeaderWPATimeout header
+	//If not specified, the header is ignored and RequestTimeout always applies
+	RequestTimeoutBounds TimeoutBounds
+
 	//Do is the core responsible to perform the actual HTTP request
 	Do func(*http.Request) (*http.Response, error)
+
+	//RequestHeaders are the rules for which headers from the incoming request are forwarded to the XMiDT API
+	//If not specified, no incoming headers are forwarded
+	RequestHeaders HeaderForwardingRules
+
+	//ResponseHeaders are the rules for which headers from the XMiDT API response are forwarded back
+	//If not specified, DefaultResponseHeaderRules is used
+	ResponseHeaders *HeaderForwardingRules
+
+	//ForwardedHeaderLimits bounds the incoming headers forwarded to the XMiDT API. Transactions exceeding them fail
+	//with a HeaderLimitError rather than being sent
+	ForwardedHeaderLimits HeaderLimits
+
+	//UserAgent identifies tr1d1um to the XMiDT API so that its operators can attribute traffic per deployment
+	//It replaces any forwarded User-Agent. If empty, the one of the HTTP client is sent
+	UserAgent string
+
+	//Measures are the metric instruments the transactor reports to (optional)
+	Measures *Measures
+}
+
+//DefaultResponseHeaderRules forwards only the XMiDT API response headers prefixed with "X"
+var DefaultResponseHeaderRules = HeaderForwardingRules{
+	Prefixes: []string{"X"},
 }
 
 func NewTr1d1umTransactor(o *Tr1d1umTransactorOptions) Tr1d1umTransactor {
+	measures := o.Measures
+	if measures == nil {
+		measures = NewMeasures(nil)
+	}
+
 	return &tr1d1umTransactor{
-		Do:             o.Do,
-		RequestTimeout: o.RequestTimeout,
+		Measures:             measures,
+		Do:                   o.Do,
+		RequestTimeout:       o.RequestTimeout,
+		RequestTimeoutBounds: o.RequestTimeoutBounds,
+		RequestHeaders:       NewHeaderFilter(o.RequestHeaders),
+		ResponseHeaders:      NewResponseHeaderFilter(o.ResponseHeaders),
+		HeaderLimits:         o.ForwardedHeaderLimits,
+		UserAgent:            o.UserAgent,
 	}
 }
 
 type tr1d1umTransactor struct {
-	RequestTimeout time.Duration
-	Do             func(*http.Request) (*http.Response, error)
+	RequestTimeout       time.Duration
+	RequestTimeoutBounds TimeoutBounds
+	Do                   func(*http.Request) (*http.Response, error)
+	RequestHeaders       *HeaderFilter
+	ResponseHeaders      *HeaderFilter
+	HeaderLimits         HeaderLimits
+	UserAgent            string
+	Measures             *Measures
+}
+
+//decompress decompresses the body of the given response, compressed with the given content encoding if not empty
+func decompress(result *XmidtResponse, contentEncoding string) error {
+	switch strings.ToLower(contentEncoding) {
+	case "", "identity":
+		return nil
+	case "gzip":
+		reader, err := gzip.NewReader(bytes.NewReader(result.Body))
+		if err != nil {
+			return NewCodedError(err, http.StatusBadGateway)
+		}
+
+		defer reader.Close()
+
+		decompressed, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return NewCodedError(err, http.StatusBadGateway)
+		}
+
+		result.Compressed, result.ContentEncoding, result.Body = result.Body, contentEncoding, decompressed
+		return nil
+	}
+
+	return NewCodedError(fmt.Errorf("unsupported content encoding '%s'", contentEncoding), http.StatusBadGateway)
 }
 
 func (t *tr1d1umTransactor) Transact(req *http.Request) (result *XmidtResponse, err error) {
-	ctx, cancel := context.WithTimeout(req.Context(), t.RequestTimeout)
+	inboundHeaders := RequestHeaders(req.Context())
+
+	var timeout time.Duration
+	if timeout, err = t.RequestTimeoutBounds.requestTimeout(inboundHeaders.Get(HeaderWPATimeout), t.RequestTimeout); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
 	defer cancel()
 
-	var resp *http.Response
+	//header forwarding decisions are logged for each transaction so the rules can be audited
+	var (
+		audit                            = logging.Debug(logging.GetLogger(req.Context()))
+		requestForwarded, requestSkipped = t.RequestHeaders.Forward(inboundHeaders, req.Header)
+
+		resp  *http.Response
+		start = time.Now()
+	)
+
+	var forwarded = make(http.Header, len(requestForwarded))
+	for _, name := range requestForwarded {
+		forwarded[name] = req.Header[name]
+	}
+
+	if err = t.HeaderLimits.Check("forwarded", forwarded); err != nil {
+		return
+	}
+
+	if t.UserAgent != "" {
+		req.Header.Set("User-Agent", t.UserAgent)
+	}
+
+	beginOutboundCall(ctx)
 	if resp, err = t.Do(req.WithContext(ctx)); err == nil {
 		result = &XmidtResponse{
 			ForwardedHeaders: make(http.Header),
 			Body:             []byte{},
 		}
 
-		ForwardHeadersByPrefix("X", resp.Header, result.ForwardedHeaders)
-		result.Code = resp.StatusCode
+		responseForwarded, responseSkipped := t.ResponseHeaders.Forward(resp.Header, result.ForwardedHeaders)
+		result.Code, result.ContentType = resp.StatusCode, resp.Header.Get("Content-Type")
+
+		audit.Log(logging.MessageKey(), "header forwarding", "tid", RequestTID(req.Context()), "url", req.URL.String(),
+			"requestHeadersForwarded", requestForwarded, "requestHeadersSkipped", requestSkipped,
+			"responseHeadersForwarded", responseForwarded, "responseHeadersSkipped", responseSkipped)
 
 		defer resp.Body.Close()
 
 		result.Body, err = ioutil.ReadAll(resp.Body)
+		result.SentAt, result.RespondedAt = start, time.Now()
+		result.Latency = result.RespondedAt.Sub(start)
+
+		//deadlines may as well expire while the body is being read
+		if err != nil {
+			return nil, t.transactionError(ctx, req, err)
+		}
+
+		err = decompress(result, resp.Header.Get("Content-Encoding"))
+
+		t.Measures.XmidtLatency.Observe(result.Latency.Seconds())
+		t.Measures.XmidtResponseSize.Observe(float64(len(result.Body)))
 		return
 	}
 
-	//Timeout, network errors, etc.
-	err = NewCodedError(err, http.StatusServiceUnavailable)
+	audit.Log(logging.MessageKey(), "header forwarding", "tid", RequestTID(req.Context()), "url", req.URL.String(),
+		"requestHeadersForwarded", requestForwarded, "requestHeadersSkipped", requestSkipped)
+
+	err = t.transactionError(ctx, req, err)
 	return
 }
+
+//transactionError returns the error reported for the given failure of the transaction of req, bounded by ctx
+func (t *tr1d1umTransactor) transactionError(ctx context.Context, req *http.Request, err error) error {
+	//the API consumer went away so there is no one to report the failure to
+	if req.Context().Err() == context.Canceled {
+		return ErrClientCanceled
+	}
+
+	if stage, isTimeout := timeoutStage(ctx, err); isTimeout {
+		t.Measures.Timeouts.With(TimeoutStageLabel, stage).Add(1)
+		return &TimeoutError{Stage: stage, Err: err}
+	}
+
+	//network errors, etc.
+	return NewCodedError(err, http.StatusServiceUnavailable)
+}
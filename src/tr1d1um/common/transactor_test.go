@@ -2,11 +2,14 @@ package common
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -29,6 +32,110 @@ func TestTransactError(t *testing.T) {
 	assert.EqualValues(expectedErr, e)
 }
 
+func TestTransactTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	transactor := NewTr1d1umTransactor(&Tr1d1umTransactorOptions{
+		RequestTimeout: time.Nanosecond,
+		Do: func(r *http.Request) (*http.Response, error) {
+			<-r.Context().Done()
+			return nil, r.Context().Err()
+		},
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "localhost:6003/test", nil)
+	_, e := transactor.Transact(r)
+
+	assert.IsType(&TimeoutError{}, e)
+	assert.EqualValues(TimeoutStageBackend, e.(*TimeoutError).Stage)
+	assert.EqualValues(http.StatusGatewayTimeout, e.(CodedError).StatusCode())
+}
+
+//slowBody is a response body which never ends before the context of its request is done
+type slowBody struct {
+	ctx context.Context
+}
+
+func (s slowBody) Read([]byte) (int, error) {
+	<-s.ctx.Done()
+	return 0, s.ctx.Err()
+}
+
+func (s slowBody) Close() error {
+	return nil
+}
+
+func TestTransactBodyTimeout(t *testing.T) {
+	t.Run("Backend", func(t *testing.T) {
+		assert := assert.New(t)
+
+		transactor := NewTr1d1umTransactor(&Tr1d1umTransactorOptions{
+			RequestTimeout: 10 * time.Millisecond,
+			Do: func(r *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: slowBody{r.Context()}}, nil
+			},
+		})
+
+		result, e := transactor.Transact(httptest.NewRequest(http.MethodGet, "localhost:6003/test", nil))
+
+		assert.Nil(result)
+		if assert.IsType(&TimeoutError{}, e) {
+			assert.EqualValues(TimeoutStageBackend, e.(*TimeoutError).Stage)
+			assert.EqualValues(http.StatusGatewayTimeout, e.(CodedError).StatusCode())
+		}
+	})
+
+	t.Run("Attempt", func(t *testing.T) {
+		assert := assert.New(t)
+
+		done := make(chan struct{})
+		defer close(done)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+
+			select {
+			case <-done:
+			case <-r.Context().Done():
+			}
+		}))
+
+		defer server.Close()
+
+		transactor := NewTr1d1umTransactor(&Tr1d1umTransactorOptions{
+			RequestTimeout: time.Minute,
+			Do:             AttemptTimeout(10*time.Millisecond, server.Client().Do),
+		})
+
+		request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		_, e := transactor.Transact(request)
+
+		if assert.IsType(&TimeoutError{}, e) {
+			assert.EqualValues(TimeoutStageAttempt, e.(*TimeoutError).Stage)
+		}
+	})
+}
+
+func TestTransactClientCanceled(t *testing.T) {
+	assert := assert.New(t)
+
+	transactor := NewTr1d1umTransactor(&Tr1d1umTransactorOptions{
+		Do: func(_ *http.Request) (*http.Response, error) {
+			return nil, context.Canceled
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := httptest.NewRequest(http.MethodGet, "localhost:6003/test", nil)
+	_, e := transactor.Transact(r.WithContext(ctx))
+
+	assert.EqualValues(ErrClientCanceled, e)
+}
+
 func TestTransactIdeal(t *testing.T) {
 	assert := assert.New(t)
 
@@ -56,5 +163,177 @@ func TestTransactIdeal(t *testing.T) {
 	r := httptest.NewRequest(http.MethodGet, "localhost:6003/test", nil)
 	actual, e := transactor.Transact(r)
 	assert.Nil(e)
+	assert.True(actual.Latency > 0)
+	assert.Equal(actual.Latency, actual.RespondedAt.Sub(actual.SentAt))
+
+	actual.Latency, actual.SentAt, actual.RespondedAt = 0, time.Time{}, time.Time{}
 	assert.EqualValues(expected, actual)
 }
+
+func TestTransactHeaderForwarding(t *testing.T) {
+	assert := assert.New(t)
+
+	transactor := NewTr1d1umTransactor(&Tr1d1umTransactorOptions{
+		RequestHeaders: HeaderForwardingRules{
+			Allowed: []string{"x-partner-id"},
+		},
+		ResponseHeaders: &HeaderForwardingRules{
+			Prefixes: []string{"x-"},
+			Denied:   []string{"X-Backend-Instance"},
+		},
+		Do: func(r *http.Request) (*http.Response, error) {
+			assert.EqualValues("comcast", r.Header.Get("X-Partner-Id"))
+			assert.Empty(r.Header.Get("X-Other"))
+
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+				Header: http.Header{
+					"X-A":                []string{"a"},
+					"X-Backend-Instance": []string{"talaria-1"},
+				},
+			}, nil
+		},
+	})
+
+	inbound := http.Header{
+		"X-Partner-Id": []string{"comcast"},
+		"X-Other":      []string{"other"},
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "localhost:6003/test", nil)
+	r = r.WithContext(context.WithValue(r.Context(), ContextKeyRequestHeaders, inbound))
+
+	actual, e := transactor.Transact(r)
+	assert.Nil(e)
+	assert.EqualValues(http.Header{"X-A": []string{"a"}}, actual.ForwardedHeaders)
+}
+
+func TestTransactForwardedHeaderLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	transactor := NewTr1d1umTransactor(&Tr1d1umTransactorOptions{
+		RequestHeaders:        HeaderForwardingRules{Prefixes: []string{"x-"}},
+		ForwardedHeaderLimits: HeaderLimits{MaxCount: 2},
+		Do: func(r *http.Request) (*http.Response, error) {
+			assert.Fail("request should not have been sent")
+			return nil, nil
+		},
+	})
+
+	inbound := http.Header{
+		"X-A": []string{"a", "b"},
+		"X-B": []string{"c"},
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "localhost:6003/test", nil)
+	r = r.WithContext(context.WithValue(r.Context(), ContextKeyRequestHeaders, inbound))
+
+	_, e := transactor.Transact(r)
+	assert.IsType(&HeaderLimitError{}, e)
+	assert.EqualValues(http.StatusRequestHeaderFieldsTooLarge, e.(CodedError).StatusCode())
+}
+
+func TestTransactUserAgent(t *testing.T) {
+	assert := assert.New(t)
+
+	transactor := NewTr1d1umTransactor(&Tr1d1umTransactorOptions{
+		RequestHeaders: HeaderForwardingRules{Allowed: []string{"User-Agent"}},
+		UserAgent:      "tr1d1um/0.1.2 (tr1d1um-1)",
+		Do: func(r *http.Request) (*http.Response, error) {
+			assert.EqualValues("tr1d1um/0.1.2 (tr1d1um-1)", r.Header.Get("User-Agent"))
+			return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(""))}, nil
+		},
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "localhost:6003/test", nil)
+	r = r.WithContext(context.WithValue(r.Context(), ContextKeyRequestHeaders, http.Header{"User-Agent": []string{"curl/7.64.0"}}))
+
+	_, e := transactor.Transact(r)
+	assert.Nil(e)
+}
+
+func TestTransactRequestedTimeout(t *testing.T) {
+	t.Run("Honored", func(t *testing.T) {
+		assert := assert.New(t)
+
+		transactor := NewTr1d1umTransactor(&Tr1d1umTransactorOptions{
+			RequestTimeout:       time.Minute,
+			RequestTimeoutBounds: TimeoutBounds{Min: time.Second, Max: time.Minute},
+			Do: func(r *http.Request) (*http.Response, error) {
+				deadline, ok := r.Context().Deadline()
+				assert.True(ok)
+				assert.True(time.Until(deadline) <= 2*time.Second)
+
+				return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(""))}, nil
+			},
+		})
+
+		r := httptest.NewRequest(http.MethodGet, "localhost:6003/test", nil)
+		r = r.WithContext(context.WithValue(r.Context(), ContextKeyRequestHeaders, http.Header{HeaderWPATimeout: []string{"2s"}}))
+
+		_, e := transactor.Transact(r)
+		assert.Nil(e)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		assert := assert.New(t)
+
+		transactor := NewTr1d1umTransactor(&Tr1d1umTransactorOptions{
+			RequestTimeoutBounds: TimeoutBounds{Max: time.Minute},
+			Do: func(r *http.Request) (*http.Response, error) {
+				assert.Fail("request should not have been sent")
+				return nil, nil
+			},
+		})
+
+		r := httptest.NewRequest(http.MethodGet, "localhost:6003/test", nil)
+		r = r.WithContext(context.WithValue(r.Context(), ContextKeyRequestHeaders, http.Header{HeaderWPATimeout: []string{"never"}}))
+
+		_, e := transactor.Transact(r)
+		assert.EqualValues(ErrInvalidRequestTimeout, e)
+	})
+}
+
+func TestTransactCompressed(t *testing.T) {
+	var (
+		assert     = assert.New(t)
+		compressed bytes.Buffer
+		writer     = gzip.NewWriter(&compressed)
+	)
+
+	writer.Write([]byte("wrp message"))
+	writer.Close()
+
+	transact := func(contentEncoding string, body []byte) (*XmidtResponse, error) {
+		transactor := NewTr1d1umTransactor(&Tr1d1umTransactorOptions{
+			Do: func(_ *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Encoding": []string{contentEncoding}},
+					Body:       ioutil.NopCloser(bytes.NewReader(body)),
+				}, nil
+			},
+		})
+
+		return transactor.Transact(httptest.NewRequest(http.MethodGet, "localhost:6003/test", nil))
+	}
+
+	//the body is decompressed for the services while the compressed one is kept for passthrough
+	result, err := transact("gzip", compressed.Bytes())
+	if assert.Nil(err) {
+		assert.Equal("wrp message", string(result.Body))
+		assert.Equal(compressed.Bytes(), result.Compressed)
+		assert.Equal("gzip", result.ContentEncoding)
+	}
+
+	_, err = transact("gzip", []byte("not gzipped"))
+	if assert.NotNil(err) {
+		assert.Equal(http.StatusBadGateway, err.(CodedError).StatusCode())
+	}
+
+	_, err = transact("br", []byte("brotli"))
+	if assert.NotNil(err) {
+		assert.Equal(http.StatusBadGateway, err.(CodedError).StatusCode())
+	}
+}
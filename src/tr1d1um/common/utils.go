@@ -2,8 +2,6 @@ package common
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
 	"net/http"
 	"strings"
 	"time"
@@ -13,11 +11,18 @@ import (
 	"github.com/Comcast/webpa-common/logging"
 	kitlog "github.com/go-kit/kit/log"
 	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
 )
 
-//HeaderWPATID is the header key for the WebPA transaction UUID
+//HeaderWPATID is the default header key for the WebPA transaction UUID (see TIDOptions)
 const HeaderWPATID = "X-WebPA-Transaction-Id"
 
+//HeaderWPATimeout is the header key through which API consumers may ask for a specific timeout for their request
+const HeaderWPATimeout = "X-Webpa-Timeout"
+
+//HeaderXmidtPartnerID is the header key for the partner ID on behalf of which an API consumer makes a request
+const HeaderXmidtPartnerID = "X-Xmidt-Partner-Id"
+
 //TransactionLogging is used by the different Tr1d1um services to
 //keep track of incoming requests and their corresponding responses
 func TransactionLogging(logger kitlog.Logger) kithttp.ServerFinalizerFunc {
@@ -40,7 +45,7 @@ func TransactionLogging(logger kitlog.Logger) kithttp.ServerFinalizerFunc {
 			"requestMethod", r.Method,
 			"responseCode", code,
 			"responseHeaders", ctx.Value(kithttp.ContextKeyResponseHeaders),
-			"tid", ctx.Value(ContextKeyRequestTID),
+			"tid", RequestTID(ctx),
 			"satClientID", satClientID,
 		)
 
@@ -49,10 +54,10 @@ func TransactionLogging(logger kitlog.Logger) kithttp.ServerFinalizerFunc {
 		//B: as soon as Tr1d1um is done sending the response for R
 		var latency time.Duration
 
-		if requestArrivalTime, ok := rCtx.Value(ContextKeyRequestArrivalTime).(time.Time); ok {
+		if requestArrivalTime, ok := RequestArrivalTime(rCtx); ok {
 			latency = time.Since(requestArrivalTime)
 		} else {
-			logging.Error(logger).Log("tid", ctx.Value(ContextKeyRequestTID), logging.MessageKey(), "latency value could not be derived")
+			logging.Error(logger).Log("tid", RequestTID(ctx), logging.MessageKey(), "latency value could not be derived")
 		}
 
 		transactionLogger.Log("latency", latency)
@@ -60,9 +65,11 @@ func TransactionLogging(logger kitlog.Logger) kithttp.ServerFinalizerFunc {
 }
 
 //ForwardHeadersByPrefix copies headers h where the source and target are 'from' and 'to' respectively such that key(h) has p as prefix
+//The prefix match is case-insensitive
 func ForwardHeadersByPrefix(p string, from http.Header, to http.Header) {
+	p = strings.ToLower(p)
 	for headerKey, headerValues := range from {
-		if strings.HasPrefix(headerKey, p) {
+		if strings.HasPrefix(strings.ToLower(headerKey), p) {
 			for _, headerValue := range headerValues {
 				to.Add(headerKey, headerValue)
 			}
@@ -72,14 +79,39 @@ func ForwardHeadersByPrefix(p string, from http.Header, to http.Header) {
 
 //ErrorLogEncoder decorates the errorEncoder in such a way that
 //errors are logged with their corresponding unique request identifier
+//Requests canceled by the API consumer are not errors of tr1d1um or the XMiDT API so they are logged at debug level
 func ErrorLogEncoder(logger kitlog.Logger, ee kithttp.ErrorEncoder) kithttp.ErrorEncoder {
-	var errorLogger = logging.Error(logger)
+	var errorLogger, debugLogger = logging.Error(logger), logging.Debug(logger)
+	return func(ctx context.Context, e error, w http.ResponseWriter) {
+		if IsClientCanceled(ctx, e) {
+			debugLogger.Log(logging.MessageKey(), e.Error(), "tid", RequestTID(ctx))
+		} else {
+			errorLogger.Log(logging.ErrorKey(), e.Error(), "tid", RequestTID(ctx))
+		}
+		ee(ctx, e, w)
+	}
+}
+
+//ClientCanceledEncoder decorates the errorEncoder in such a way that
+//requests canceled by the API consumer are counted and no response is attempted for them
+func ClientCanceledEncoder(m *Measures, ee kithttp.ErrorEncoder) kithttp.ErrorEncoder {
 	return func(ctx context.Context, e error, w http.ResponseWriter) {
-		errorLogger.Log(logging.ErrorKey(), e.Error(), "tid", ctx.Value(ContextKeyRequestTID).(string))
+		if IsClientCanceled(ctx, e) {
+			if m != nil {
+				m.ClientCanceled.Add(1)
+			}
+			return
+		}
 		ee(ctx, e, w)
 	}
 }
 
+//IsClientCanceled returns true if the given error or request context show that the API consumer
+//is no longer waiting for a response
+func IsClientCanceled(ctx context.Context, e error) bool {
+	return e == ErrClientCanceled || ctx.Err() == context.Canceled
+}
+
 //Welcome is an Alice-style constructor that defines necessary request
 //context values assumed to exist by the delegate. These values should
 //be those expected to be used both in and outside the gokit server flow
@@ -97,20 +129,24 @@ func Welcome(delegate http.Handler) http.Handler {
 //intended to be used only throughout the gokit server flow: (request decoding, business logic,  response encoding)
 func Capture(ctx context.Context, r *http.Request) context.Context {
 	var tid string
-	if tid = r.Header.Get(HeaderWPATID); tid == "" {
-		tid = genTID()
+	if tid = r.Header.Get(TIDHeader(ctx)); tid == "" {
+		tid = genTID(ctx)
 	}
 
+	ctx = context.WithValue(ctx, ContextKeyRequestHeaders, r.Header)
+	ctx = context.WithValue(ctx, ContextKeyRequestMethod, r.Method)
+	ctx = context.WithValue(ctx, ContextKeyRequestDeviceID, mux.Vars(r)["deviceid"])
+	ctx = withOutboundCalls(ctx)
 	return context.WithValue(ctx, ContextKeyRequestTID, tid)
 }
 
-//genTID generates a 16-byte long string
+//genTID generates a transaction ID in the format of the settings of the given context (see TIDOptions)
 //it returns "N/A" in the extreme case the random string could not be generated
-func genTID() (tid string) {
-	buf := make([]byte, 16)
-	tid = "N/A"
-	if _, err := rand.Read(buf); err == nil {
-		tid = base64.RawURLEncoding.EncodeToString(buf)
+func genTID(ctx context.Context) string {
+	tid := settings(ctx).tid
+	if id, err := tid.generate(); err == nil {
+		return tid.prefix + id
 	}
-	return
+
+	return "N/A"
 }
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/metrics/generic"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -73,6 +74,37 @@ func TestErrorLogEncoder(t *testing.T) {
 	})
 }
 
+func TestClientCanceledEncoder(t *testing.T) {
+	t.Run("Canceled", func(t *testing.T) {
+		assert := assert.New(t)
+		counter := generic.NewCounter("test")
+		m := &Measures{ClientCanceled: counter}
+
+		ee := ClientCanceledEncoder(m, func(_ context.Context, _ error, _ http.ResponseWriter) {
+			assert.Fail("client canceled requests should not be encoded")
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		ee(ctx, errors.New("network error"), httptest.NewRecorder())
+		ee(context.Background(), ErrClientCanceled, httptest.NewRecorder())
+		assert.EqualValues(2, counter.Value())
+	})
+
+	t.Run("OtherErrors", func(t *testing.T) {
+		assert := assert.New(t)
+		var called bool
+
+		ee := ClientCanceledEncoder(nil, func(_ context.Context, _ error, _ http.ResponseWriter) {
+			called = true
+		})
+
+		ee(context.Background(), errors.New("test"), httptest.NewRecorder())
+		assert.True(called)
+	})
+}
+
 func TestWelcome(t *testing.T) {
 	assert := assert.New(t)
 	var handler = http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
@@ -91,6 +123,7 @@ func TestCapture(t *testing.T) {
 		r.Header.Set(HeaderWPATID, "tid01")
 		ctx := Capture(context.TODO(), r)
 		assert.EqualValues("tid01", ctx.Value(ContextKeyRequestTID).(string))
+		assert.EqualValues(r.Header, ctx.Value(ContextKeyRequestHeaders))
 	})
 
 	t.Run("GeneratedTID", func(t *testing.T) {
@@ -103,6 +136,6 @@ func TestCapture(t *testing.T) {
 
 func TestGenTID(t *testing.T) {
 	assert := assert.New(t)
-	tid := genTID()
+	tid := genTID(context.TODO())
 	assert.NotEmpty(tid)
 }
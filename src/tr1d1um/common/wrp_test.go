@@ -0,0 +1,140 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+)
+
+var benchmarkMessage = &wrp.Message{
+	Type:            wrp.SimpleRequestResponseMessageType,
+	Source:          "dns:tr1d1um.xmidt.comcast.net/api/v2",
+	Destination:     "mac:112233445566/config",
+	TransactionUUID: "5f1fb9cd-1cc4-4a52-9a69-1a2b3c4d5e6f",
+	Payload:         []byte(`{"statusCode":200,"parameters":[{"name":"Device.DeviceInfo.X_CISCO_COM_FirmwareName","value":"TG1682_3.8p1s1_PROD_sey","dataType":0,"parameterCount":1,"message":"Success"}]}`),
+}
+
+func TestWRPCoding(t *testing.T) {
+	assert := assert.New(t)
+
+	data, err := EncodeWRP(benchmarkMessage)
+	assert.Nil(err)
+	assert.Equal(wrp.MustEncode(benchmarkMessage, wrp.Msgpack), data)
+
+	for i := 0; i < 3; i++ {
+		var decoded wrp.Message
+		assert.Nil(DecodeWRP(data, &decoded))
+		assert.Equal(*benchmarkMessage, decoded)
+	}
+
+	var decoded wrp.Message
+	assert.NotNil(DecodeWRP([]byte("not msgpack"), &decoded))
+
+	//a failure must not leave a broken decoder in the pool
+	assert.Nil(DecodeWRP(data, &decoded))
+	assert.Equal(benchmarkMessage.Payload, decoded.Payload)
+}
+
+func TestWRPFormatCoding(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, format := range []wrp.Format{wrp.Msgpack, wrp.JSON} {
+		data, err := EncodeWRPFormat(benchmarkMessage, format)
+		assert.Nil(err)
+
+		var decoded wrp.Message
+		assert.Nil(DecodeWRPFormat(data, format, &decoded))
+		assert.Equal(*benchmarkMessage, decoded)
+	}
+
+	data, err := EncodeWRPFormat(benchmarkMessage, wrp.Msgpack)
+	assert.Nil(err)
+	assert.Equal(wrp.MustEncode(benchmarkMessage, wrp.Msgpack), data)
+
+	//JSON payloads are base64 encoded
+	data, err = EncodeWRPFormat(&wrp.Message{Source: "local", Payload: []byte("{}")}, wrp.JSON)
+	assert.Nil(err)
+	assert.JSONEq(`{"msg_type":0,"source":"local","payload":"e30="}`, string(data))
+}
+
+func TestWRPMessageFields(t *testing.T) {
+	var (
+		messageType = reflect.TypeOf(wrp.Message{})
+		mirrorType  = reflect.TypeOf(wrpMessage{})
+	)
+
+	//wrpMessage must carry every field of wrp.Message along with qos
+	assert.Equal(t, messageType.NumField()+1, mirrorType.NumField())
+	for i := 0; i < messageType.NumField(); i++ {
+		field, ok := mirrorType.FieldByName(messageType.Field(i).Name)
+		if assert.True(t, ok, messageType.Field(i).Name) {
+			assert.Equal(t, messageType.Field(i).Type, field.Type)
+			assert.Equal(t, messageType.Field(i).Tag, field.Tag)
+		}
+	}
+
+	message := &wrp.Message{Source: "local", Payload: []byte("{}")}
+	assert.Equal(t, &wrpMessage{Source: "local", Payload: []byte("{}"), QualityOfService: 50}, newWRPMessage(message, 50))
+	assert.Equal(t, message, newWRPMessage(message, 50).message())
+}
+
+func TestParseWRPEncoding(t *testing.T) {
+	assert := assert.New(t)
+
+	for encoding, expected := range map[string]wrp.Format{"": wrp.Msgpack, "msgpack": wrp.Msgpack, "JSON": wrp.JSON} {
+		format, err := ParseWRPEncoding(encoding)
+		assert.Nil(err)
+		assert.Equal(expected, format)
+	}
+
+	_, err := ParseWRPEncoding("xml")
+	assert.NotNil(err)
+}
+
+//The benchmarks compare pooled coders against one per message, as under load (go test -bench WRP -benchmem)
+func BenchmarkDecodeWRP(b *testing.B) {
+	data := wrp.MustEncode(benchmarkMessage, wrp.Msgpack)
+
+	b.Run("New", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				var m wrp.Message
+				wrp.NewDecoderBytes(data, wrp.Msgpack).Decode(&m)
+			}
+		})
+	})
+
+	b.Run("Pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				var m wrp.Message
+				DecodeWRP(data, &m)
+			}
+		})
+	})
+}
+
+func BenchmarkEncodeWRP(b *testing.B) {
+	b.Run("New", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				var data []byte
+				wrp.NewEncoderBytes(&data, wrp.Msgpack).Encode(benchmarkMessage)
+			}
+		})
+	})
+
+	b.Run("Pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				EncodeWRP(benchmarkMessage)
+			}
+		})
+	})
+}
@@ -14,6 +14,6 @@ type statRequest struct {
 func makeStatEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, r interface{}) (interface{}, error) {
 		statReq := (r).(*statRequest)
-		return s.RequestStat(statReq.AuthHeaderValue, statReq.DeviceID)
+		return s.RequestStat(ctx, statReq.AuthHeaderValue, statReq.DeviceID)
 	}
 }
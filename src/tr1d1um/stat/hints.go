@@ -0,0 +1,90 @@
+package stat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+)
+
+//Default locations of the device hints in the XMiDT stat response
+const (
+	DefaultFirmwareField = "convey.fw-name"
+	DefaultModelField    = "convey.hw-model"
+)
+
+//HintFields locates the device hints in the XMiDT stat response
+//Fields are dot-separated paths into the JSON response (i.e. convey.fw-name)
+type HintFields struct {
+	Firmware string
+	Model    string
+}
+
+//NewDeviceHinter builds a DeviceHinter which takes device hints from the device statistics
+func NewDeviceHinter(s Service, fields HintFields) common.DeviceHinter {
+	if fields.Firmware == "" {
+		fields.Firmware = DefaultFirmwareField
+	}
+
+	if fields.Model == "" {
+		fields.Model = DefaultModelField
+	}
+
+	return &deviceHinter{
+		s:      s,
+		fields: fields,
+	}
+}
+
+type deviceHinter struct {
+	s      Service
+	fields HintFields
+}
+
+//DeviceHints requests the statistics of the given device and extracts its hints out of them
+func (h *deviceHinter) DeviceHints(ctx context.Context, authHeaderValue, deviceID string) (*common.DeviceHints, error) {
+	resp, err := h.s.RequestStat(ctx, authHeaderValue, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Code != http.StatusOK {
+		return nil, fmt.Errorf("device statistics unavailable. Status code: %d", resp.Code)
+	}
+
+	var stat interface{}
+	if err = json.Unmarshal(resp.Body, &stat); err != nil {
+		return nil, err
+	}
+
+	return &common.DeviceHints{
+		Firmware: lookupField(stat, h.fields.Firmware),
+		Model:    lookupField(stat, h.fields.Model),
+	}, nil
+}
+
+//lookupField returns the value at the given dot-separated path of a JSON document or an empty string if there is none
+func lookupField(document interface{}, path string) string {
+	for _, key := range strings.Split(path, ".") {
+		object, ok := document.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+
+		if document, ok = object[key]; !ok {
+			return ""
+		}
+	}
+
+	switch value := document.(type) {
+	case string:
+		return value
+	case nil, map[string]interface{}, []interface{}:
+		return ""
+	default:
+		return fmt.Sprint(value)
+	}
+}
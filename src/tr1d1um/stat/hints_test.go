@@ -0,0 +1,86 @@
+package stat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//statServiceFunc allows plain functions to act as a stat Service
+type statServiceFunc func(context.Context, string, string) (*common.XmidtResponse, error)
+
+func (f statServiceFunc) RequestStat(ctx context.Context, authHeaderValue, deviceID string) (*common.XmidtResponse, error) {
+	return f(ctx, authHeaderValue, deviceID)
+}
+
+func TestDeviceHints(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		assert := assert.New(t)
+
+		hinter := NewDeviceHinter(statServiceFunc(func(_ context.Context, auth, deviceID string) (*common.XmidtResponse, error) {
+			assert.EqualValues("Basic xyz", auth)
+			assert.EqualValues("mac:112233445566", deviceID)
+
+			return &common.XmidtResponse{
+				Code: http.StatusOK,
+				Body: []byte(`{"id": "mac:112233445566", "convey": {"fw-name": "fw-1.2", "hw-model": "XB6"}}`),
+			}, nil
+		}), HintFields{})
+
+		hints, err := hinter.DeviceHints(context.Background(), "Basic xyz", "mac:112233445566")
+		assert.Nil(err)
+		assert.EqualValues(&common.DeviceHints{Firmware: "fw-1.2", Model: "XB6"}, hints)
+	})
+
+	t.Run("CustomFields", func(t *testing.T) {
+		assert := assert.New(t)
+
+		hinter := NewDeviceHinter(statServiceFunc(func(context.Context, string, string) (*common.XmidtResponse, error) {
+			return &common.XmidtResponse{
+				Code: http.StatusOK,
+				Body: []byte(`{"metadata": {"firmware": "fw-2", "model": 7}}`),
+			}, nil
+		}), HintFields{Firmware: "metadata.firmware", Model: "metadata.model"})
+
+		hints, err := hinter.DeviceHints(context.Background(), "", "mac:112233445566")
+		assert.Nil(err)
+		assert.EqualValues(&common.DeviceHints{Firmware: "fw-2", Model: "7"}, hints)
+	})
+
+	t.Run("StatUnavailable", func(t *testing.T) {
+		hinter := NewDeviceHinter(statServiceFunc(func(context.Context, string, string) (*common.XmidtResponse, error) {
+			return &common.XmidtResponse{Code: http.StatusNotFound}, nil
+		}), HintFields{})
+
+		hints, err := hinter.DeviceHints(context.Background(), "", "mac:112233445566")
+		assert.Nil(t, hints)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("StatError", func(t *testing.T) {
+		hinter := NewDeviceHinter(statServiceFunc(func(context.Context, string, string) (*common.XmidtResponse, error) {
+			return nil, errors.New("network")
+		}), HintFields{})
+
+		_, err := hinter.DeviceHints(context.Background(), "", "mac:112233445566")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestLookupField(t *testing.T) {
+	var document = map[string]interface{}{
+		"a": map[string]interface{}{"b": "c", "n": float64(2), "o": map[string]interface{}{}},
+	}
+
+	assert := assert.New(t)
+	assert.EqualValues("c", lookupField(document, "a.b"))
+	assert.EqualValues("2", lookupField(document, "a.n"))
+	assert.EqualValues("", lookupField(document, "a.o"))
+	assert.EqualValues("", lookupField(document, "a.b.c"))
+	assert.EqualValues("", lookupField(document, "x"))
+}
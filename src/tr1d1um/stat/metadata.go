@@ -0,0 +1,91 @@
+package stat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+//ErrUnknownMetadata is returned when nothing is known of the requested device
+var ErrUnknownMetadata = common.NewCodedError(errors.New("no metadata known for the device"), http.StatusNotFound)
+
+//NewMetadataService decorates the given service so that what the statistics of devices tell about them (that they
+//are connected, their firmware and model) is recorded into the given store
+func NewMetadataService(s Service, store common.DeviceMetadataStore, fields HintFields) Service {
+	if store == nil {
+		return s
+	}
+
+	if fields.Firmware == "" {
+		fields.Firmware = DefaultFirmwareField
+	}
+
+	if fields.Model == "" {
+		fields.Model = DefaultModelField
+	}
+
+	return &metadataService{
+		Service: s,
+		store:   store,
+		fields:  fields,
+		now:     time.Now,
+	}
+}
+
+type metadataService struct {
+	Service
+
+	store  common.DeviceMetadataStore
+	fields HintFields
+	now    func() time.Time
+}
+
+func (m *metadataService) RequestStat(ctx context.Context, authHeaderValue, deviceID string) (*common.XmidtResponse, error) {
+	result, err := m.Service.RequestStat(ctx, authHeaderValue, deviceID)
+	if err != nil || result.Code != http.StatusOK {
+		return result, err
+	}
+
+	var stat interface{}
+	if json.Unmarshal(result.Body, &stat) == nil {
+		//the metadata is best effort so failures of the store don't fail the request
+		m.store.Merge(common.DeviceMetadata{
+			DeviceID: deviceID,
+			LastSeen: m.now(),
+			Firmware: lookupField(stat, m.fields.Firmware),
+			Model:    lookupField(stat, m.fields.Model),
+		})
+	}
+
+	return result, err
+}
+
+//makeMetadataEndpoint returns the endpoint which looks up the metadata of devices
+func makeMetadataEndpoint(store common.DeviceMetadataStore) endpoint.Endpoint {
+	return func(_ context.Context, r interface{}) (interface{}, error) {
+		metadata, err := store.Get(r.(*statRequest).DeviceID)
+		if err != nil {
+			return nil, common.NewCodedError(err, http.StatusServiceUnavailable)
+		}
+
+		if metadata == nil {
+			return nil, ErrUnknownMetadata
+		}
+
+		return metadata, nil
+	}
+}
+
+//encodeMetadataResponse writes the metadata of the device
+func encodeMetadataResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set(common.TIDHeader(ctx), common.RequestTID(ctx))
+
+	return json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,54 @@
+package stat
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadataService(t *testing.T) {
+	var (
+		now   = time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+		store = common.NewMemoryMetadataStore(common.MetadataOptions{})
+		code  = http.StatusOK
+		s     = statServiceFunc(func(context.Context, string, string) (*common.XmidtResponse, error) {
+			return &common.XmidtResponse{
+				Code: code,
+				Body: []byte(`{"id": "mac:112233445566", "convey": {"fw-name": "fw-1.2", "hw-model": "XB6"}}`),
+			}, nil
+		})
+	)
+
+	assert.IsType(t, s, NewMetadataService(s, nil, HintFields{}))
+
+	decorated := NewMetadataService(s, store, HintFields{})
+	decorated.(*metadataService).now = func() time.Time { return now }
+
+	t.Run("NotFound", func(t *testing.T) {
+		assert := assert.New(t)
+		code = http.StatusNotFound
+
+		_, err := decorated.RequestStat(context.Background(), "", "mac:112233445566")
+		assert.Nil(err)
+
+		_, err = makeMetadataEndpoint(store)(context.Background(), &statRequest{DeviceID: "mac:112233445566"})
+		assert.Equal(ErrUnknownMetadata, err)
+	})
+
+	t.Run("Recorded", func(t *testing.T) {
+		assert := assert.New(t)
+		code = http.StatusOK
+
+		_, err := decorated.RequestStat(context.Background(), "", "mac:112233445566")
+		assert.Nil(err)
+
+		metadata, err := makeMetadataEndpoint(store)(context.Background(), &statRequest{DeviceID: "mac:112233445566"})
+		assert.Nil(err)
+		assert.EqualValues(&common.DeviceMetadata{DeviceID: "mac:112233445566", LastSeen: now, Firmware: "fw-1.2", Model: "XB6"}, metadata)
+	})
+}
@@ -0,0 +1,43 @@
+package stat
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+)
+
+//NewPresenceService decorates the given service so that whether devices are connected, as found out from their
+//statistics, is recorded into the given cache
+func NewPresenceService(s Service, presence *common.PresenceCache) Service {
+	if presence == nil {
+		return s
+	}
+
+	return &presenceService{
+		Service:  s,
+		presence: presence,
+	}
+}
+
+type presenceService struct {
+	Service
+
+	presence *common.PresenceCache
+}
+
+func (p *presenceService) RequestStat(ctx context.Context, authHeaderValue, deviceID string) (*common.XmidtResponse, error) {
+	result, err := p.Service.RequestStat(ctx, authHeaderValue, deviceID)
+	if err != nil {
+		return result, err
+	}
+
+	switch result.Code {
+	case http.StatusOK:
+		p.presence.Observe(deviceID, true)
+	case http.StatusNotFound:
+		p.presence.Observe(deviceID, false)
+	}
+
+	return result, err
+}
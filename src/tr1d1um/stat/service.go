@@ -1,51 +1,50 @@
 package stat
 
 import (
-	"net/http"
-	"strings"
+	"context"
 
 	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/Comcast/webpa-common/wrp"
 )
 
 //Service defines the behavior of the device statistics Tr1d1um Service
 type Service interface {
-	RequestStat(authHeaderValue, deviceID string) (*common.XmidtResponse, error)
+	RequestStat(ctx context.Context, authHeaderValue, deviceID string) (*common.XmidtResponse, error)
 }
 
 //NewService constructs a new stat service instance given some options
 func NewService(o *ServiceOptions) Service {
+	backend := o.Backend
+	if backend == nil {
+		backend = common.NewXmidtBackend("", o.XmidtStatURL, wrp.Msgpack, o.Tr1d1umTransactor)
+	}
+
 	return &service{
-		Tr1d1umTransactor: o.Tr1d1umTransactor,
-		XmidtStatURL:      o.XmidtStatURL,
+		Backend: backend,
 	}
 }
 
 //ServiceOptions defines the options needed to build a new stat service
 type ServiceOptions struct {
 	//Base Endpoint URL for device stats from XMiDT API
+	//Only used when no Backend is given
 	XmidtStatURL string
 
 	//Tr1d1umTransactor is the component that's responsible to make the HTTP
 	//request to the XMiDT API and return only data we care about
+	//Only used when no Backend is given
 	common.Tr1d1umTransactor
+
+	//Backend looks up the device statistics
+	//If nil, they are requested from the XMiDT API at XmidtStatURL through the Tr1d1umTransactor
+	Backend common.Backend
 }
 
 type service struct {
-	//Tr1d1umTransactor is the component that's responsible to make the HTTP
-	//request to the XMiDT API and return only data we care about
-	common.Tr1d1umTransactor
-
-	XmidtStatURL string
+	common.Backend
 }
 
-//RequestStat contacts the XMiDT cluster for device statistics
-func (s *service) RequestStat(authHeaderValue, deviceID string) (result *common.XmidtResponse, err error) {
-	var r *http.Request
-
-	if r, err = http.NewRequest(http.MethodGet, strings.Replace(s.XmidtStatURL, "${device}", deviceID, 1), nil); err == nil {
-		r.Header.Add("Authorization", authHeaderValue)
-
-		result, err = s.Tr1d1umTransactor.Transact(r)
-	}
-	return
+//RequestStat contacts the backend for device statistics
+func (s *service) RequestStat(ctx context.Context, authHeaderValue, deviceID string) (*common.XmidtResponse, error) {
+	return s.Backend.RequestStat(ctx, authHeaderValue, deviceID)
 }
@@ -1,6 +1,7 @@
 package stat
 
 import (
+	"context"
 	"net/http"
 	"testing"
 
@@ -24,5 +25,5 @@ func TestRequestStat(t *testing.T) {
 
 	m.On("Transact", mock.MatchedBy(requestMatcher)).Return(&common.XmidtResponse{}, nil)
 
-	s.RequestStat("token", "mac:1122334455")
+	s.RequestStat(context.TODO(), "token", "mac:1122334455")
 }
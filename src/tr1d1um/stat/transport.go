@@ -23,6 +23,22 @@ type Options struct {
 	APIRouter    *mux.Router
 	Authenticate *alice.Chain
 	Log          kitlog.Logger
+
+	//Measures are the metric instruments tr1d1um reports to
+	Measures *common.Measures
+
+	//Extensions are the custom middleware and server options of the stat routes (optional)
+	Extensions common.Extensions
+
+	//CacheHeaders are the caching headers of the successful responses of the stat routes, under the stat key (optional)
+	CacheHeaders common.CachePolicy
+
+	//Metadata is the store of device metadata served by the metadata route (optional)
+	Metadata common.DeviceMetadataStore
+
+	//ResponseHeaders are the response headers of the backend forwarded to API consumers
+	//Defaults to common.DefaultResponseHeaderRules
+	ResponseHeaders *common.HeaderForwardingRules
 }
 
 //ConfigHandler sets up the server that powers the stat service
@@ -30,19 +46,31 @@ type Options struct {
 func ConfigHandler(c *Options) {
 	opts := []kithttp.ServerOption{
 		kithttp.ServerBefore(common.Capture),
-		kithttp.ServerErrorEncoder(common.ErrorLogEncoder(c.Log, encodeError)),
+		kithttp.ServerErrorEncoder(common.ErrorLogEncoder(c.Log, common.ClientCanceledEncoder(c.Measures, encodeError))),
 		kithttp.ServerFinalizer(common.TransactionLogging(c.Log)),
 	}
 
-	statHandler := kithttp.NewServer(
+	statHandler := c.Extensions.Handler(c.Authenticate,
 		makeStatEndpoint(c.S),
 		decodeRequest,
-		encodeResponse,
-		opts...,
+		common.CacheHeadersEncoder(c.CacheHeaders, "stat", encodeResponse(common.NewResponseHeaderFilter(c.ResponseHeaders))),
+		opts,
 	)
 
-	c.APIRouter.Handle("/device/{deviceid}/stat", c.Authenticate.Then(common.Welcome(statHandler))).
+	c.APIRouter.Handle("/device/{deviceid}/stat", statHandler).
 		Methods(http.MethodGet)
+
+	if c.Metadata != nil {
+		metadataHandler := c.Extensions.Handler(c.Authenticate,
+			makeMetadataEndpoint(c.Metadata),
+			decodeRequest,
+			encodeMetadataResponse,
+			opts,
+		)
+
+		c.APIRouter.Handle("/device/{deviceid}/metadata", metadataHandler).
+			Methods(http.MethodGet)
+	}
 }
 
 func decodeRequest(_ context.Context, r *http.Request) (req interface{}, err error) {
@@ -61,32 +89,59 @@ func decodeRequest(_ context.Context, r *http.Request) (req interface{}, err err
 
 func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Header().Set(common.HeaderWPATID, ctx.Value(common.ContextKeyRequestTID).(string))
+	w.Header().Set(common.TIDHeader(ctx), common.RequestTID(ctx))
 
+	var status = http.StatusInternalServerError
 	if ce, ok := err.(common.CodedError); ok {
-		w.WriteHeader(ce.StatusCode())
+		status = ce.StatusCode()
 	} else {
-		w.WriteHeader(http.StatusInternalServerError)
 		err = common.ErrTr1d1umInternal
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": err.Error(),
-	})
+	message, language := common.LocalizeMessage(ctx, err.Error())
+	if language != "" {
+		w.Header().Set("Content-Language", language)
+	}
+
+	w.WriteHeader(status)
+
+	body := map[string]interface{}{
+		"message": message,
+	}
+
+	if ec, ok := err.(common.ErrorCoder); ok {
+		body["code"] = ec.ErrorCode()
+	}
+
+	if call, ok := common.RequestFailedCall(ctx); ok && status >= http.StatusInternalServerError {
+		body["outbound"] = call
+	}
+
+	json.NewEncoder(w).Encode(body)
 }
 
-//encodeResponse simply forwards the response Tr1d1um got from the XMiDT API
+//encodeResponse returns the function which simply forwards the response Tr1d1um got from the XMiDT API, along with
+//the response headers the given filter allows
 //TODO: What about if XMiDT cluster reports 500. There would be ambiguity
 //about which machine is actually having the error (Tr1d1um or the Xmidt API)
 //do we care to make that distinction?
-func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) (err error) {
-	resp := response.(*common.XmidtResponse)
+func encodeResponse(responseHeaders *common.HeaderFilter) kithttp.EncodeResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) (err error) {
+		resp := response.(*common.XmidtResponse)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set(common.HeaderWPATID, ctx.Value(common.ContextKeyRequestTID).(string))
-	common.ForwardHeadersByPrefix("", resp.ForwardedHeaders, w.Header())
+		if ctx.Err() == context.Canceled {
+			return common.ErrClientCanceled
+		}
 
-	w.WriteHeader(resp.Code)
-	_, err = w.Write(resp.Body)
-	return
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(common.TIDHeader(ctx), common.RequestTID(ctx))
+		common.SetTimestamps(ctx, w.Header(), resp)
+		responseHeaders.Forward(resp.ForwardedHeaders, w.Header())
+
+		w.WriteHeader(resp.Code)
+		if _, err = w.Write(resp.Body); err != nil && ctx.Err() == context.Canceled {
+			err = common.ErrClientCanceled
+		}
+		return
+	}
 }
@@ -62,6 +62,25 @@ func TestEncodeError(t *testing.T) {
 		})
 	})
 
+	t.Run("GatewayTimeout", func(t *testing.T) {
+		assert := assert.New(t)
+		e := &common.TimeoutError{Stage: common.TimeoutStageBackend}
+
+		expected := bytes.NewBufferString("")
+		json.NewEncoder(expected).Encode(
+			map[string]string{
+				"code":    "backend_timeout",
+				"message": e.Error(),
+			},
+		)
+
+		w := httptest.NewRecorder()
+		encodeError(ctxTID, e, w)
+
+		assert.EqualValues(http.StatusGatewayTimeout, w.Code)
+		assert.EqualValues(expected.String(), w.Body.String())
+	})
+
 	t.Run("BadRequest", func(t *testing.T) {
 		testErrorEncode(t, http.StatusBadRequest, []error{
 			common.NewBadRequestError(device.ErrorInvalidDeviceName),
@@ -114,16 +133,37 @@ func TestEncodeResponse(t *testing.T) {
 
 		resp = &common.XmidtResponse{
 			Code:             http.StatusOK,
-			ForwardedHeaders: http.Header{},
+			ForwardedHeaders: http.Header{"X-Xmidt-Message": {"ok"}, "X-Scytale-Instance": {"scytale-3"}, "Server": {"scytale"}},
 			Body:             p,
 		}
+
+		responseHeaders = common.NewResponseHeaderFilter(&common.HeaderForwardingRules{Prefixes: []string{"X"}, Denied: []string{"X-Scytale-Instance"}})
 	)
 
-	//Tr1d1um just forwards the response
-	var e = encodeResponse(ctxTID, w, resp)
+	//Tr1d1um just forwards the response, with the headers it may
+	var e = encodeResponse(responseHeaders)(ctxTID, w, resp)
 
 	assert.Nil(e)
 	assert.EqualValues("application/json", w.Header().Get("Content-Type"))
+	assert.EqualValues("ok", w.Header().Get("X-Xmidt-Message"))
+	assert.Empty(w.Header().Get("X-Scytale-Instance"))
+	assert.Empty(w.Header().Get("Server"))
 	assert.EqualValues(p, w.Body.String())
 	assert.EqualValues(resp.Code, w.Code)
 }
+
+func TestEncodeResponseClientCanceled(t *testing.T) {
+	var assert = assert.New(t)
+
+	ctx, cancel := context.WithCancel(ctxTID)
+	cancel()
+
+	w := httptest.NewRecorder()
+	e := encodeResponse(common.NewResponseHeaderFilter(nil))(ctx, w, &common.XmidtResponse{
+		Code: http.StatusOK,
+		Body: []byte(`{"dBytesSent": "1024"}`),
+	})
+
+	assert.EqualValues(common.ErrClientCanceled, e)
+	assert.Empty(w.Body.String())
+}
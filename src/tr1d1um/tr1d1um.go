@@ -27,6 +27,8 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Comcast/comcast-bascule/bascule"
@@ -36,11 +38,12 @@ import (
 	"github.com/goph/emperror"
 
 	"github.com/Comcast/webpa-common/basculechecks"
-	"github.com/Comcast/webpa-common/xhttp"
 
 	"github.com/Comcast/tr1d1um/src/tr1d1um/hooks"
 	"github.com/Comcast/tr1d1um/src/tr1d1um/stat"
+	app "github.com/Comcast/tr1d1um/src/tr1d1um/tr1d1um"
 	"github.com/Comcast/tr1d1um/src/tr1d1um/translation"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
 
 	"github.com/Comcast/webpa-common/concurrent"
 	"github.com/Comcast/webpa-common/logging"
@@ -49,36 +52,110 @@ import (
 	"github.com/Comcast/webpa-common/webhook"
 	"github.com/Comcast/webpa-common/webhook/aws"
 	"github.com/SermoDigital/jose/jwt"
+	"github.com/fsnotify/fsnotify"
 
 	"github.com/Comcast/webpa-common/xmetrics"
 	"github.com/go-kit/kit/log"
-	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
-//convenient global values
+// convenient global values
 const (
-	DefaultKeyID             = "current"
-	applicationName, apiBase = "tr1d1um", "api/v2"
+	DefaultKeyID    = "current"
+	applicationName = "tr1d1um"
 
 	translationServicesKey = "supportedServices"
+	rawServicesKey         = "rawServices"
 	targetURLKey           = "targetURL"
+	targetsKey             = "targets"
+	backendKey             = "backend.type"
+	backendConfigKey       = "backend.config"
+	wrpEncodingKey         = "wrpEncoding"
+	maxWRPSizeKey          = "maxWRPSize"
+	transactionIDsKey      = "transactionIDs"
+	partnersKey            = "partners"
+	registrationKey        = "registration"
+	accessLogKey           = "accessLog"
+	adminKey               = "admin"
+	supportBundleKey       = "supportBundle"
+	policyKey              = "policy"
 	netDialerTimeoutKey    = "netDialerTimeout"
+	proxyKey               = "proxy"
+	clientTLSKey           = "clientTLS"
+	watchKeyMaterialKey    = "keyRotation.watch"
+	dialerKey              = "dialer"
+	dialerDualStackKey     = "dialer.dualStack"
+	dnsCacheKey            = "dnsCache"
 	clientTimeoutKey       = "clientTimeout"
 	reqTimeoutKey          = "respWaitTimeout"
+	reqTimeoutMinKey       = "requestTimeoutBounds.min"
+	reqTimeoutMaxKey       = "requestTimeoutBounds.max"
+	connectTimeoutKey      = "timeouts.connect"
+	attemptTimeoutKey      = "timeouts.attempt"
+	totalTimeoutKey        = "timeouts.total"
 	reqRetryIntervalKey    = "requestRetryInterval"
 	reqMaxRetriesKey       = "requestMaxRetries"
 	WRPSourcekey           = "WRPSource"
+	WRPAddressingKey       = "WRPAddressing"
+	acceptMsgpackKey       = "acceptMsgpack"
+	canonicalJSONKey       = "canonicalJSON"
+	passthroughKey         = "passthrough"
+	setLimitsKey           = "setLimits"
+	statusMappingKey       = "statusMapping"
+	groupsKey              = "deviceGroups.groups"
+	groupConcurrencyKey    = "deviceGroups.concurrency"
+	profilesKey            = "parameterProfiles"
+	aliasesKey             = "parameterAliases"
+	dataModelKey           = "dataModel"
+	deviceHintsEnabledKey  = "deviceHints.enabled"
+	deviceHintsFieldsKey   = "deviceHints.fields"
+	devicePresenceKey      = "devicePresence"
+	deviceMetadataKey      = "deviceMetadata"
+	tenantsKey             = "tenants"
+	clustersKey            = "clusters"
+	mirrorKey              = "mirror"
+	canaryKey              = "canary"
+	alertsKey              = "alerts"
+	deprecationsKey        = "deprecations"
+	legacyAPIKey           = "legacyAPI"
+	responseCacheKey       = "responseCache"
+	backpressureKey        = "backpressure"
+	writeOrderingKey       = "writeOrdering"
+	quotasKey              = "quotas"
+	concurrencyLimitsKey   = "concurrencyLimits"
+	lanesKey               = "lanes"
+	histogramBucketsKey    = "histogramBuckets"
+	batchClientTimeoutKey  = "lanes.batchClientTimeout"
+	signingKey             = "signing"
+	qosRulesKey            = "qosRules"
+	transformsKey          = "transforms"
+	cacheHeadersKey        = "cacheHeaders"
+	diagnosticsKey         = "diagnostics"
+	firmwareKey            = "firmware"
+	redactionKey           = "redaction"
+	confirmationsKey       = "confirmations.enabled"
+	confirmationSecretKey  = "confirmations.secret"
+	confirmationTTLKey     = "confirmations.ttl"
+	errorMessagesKey       = "errorMessages"
+	faultInjectionKey      = "faultInjection.enabled"
+	faultRulesKey          = "faultInjection.routes"
 	hooksSchemeKey         = "hooksScheme"
+	requestHeadersKey      = "headerForwarding.request"
+	responseHeadersKey     = "headerForwarding.response"
+	inboundLimitsKey       = "headerLimits.inbound"
+	forwardedLimitsKey     = "headerLimits.forwarded"
+	userAgentKey           = "userAgent"
 	applicationVersion     = "0.1.2"
 )
 
 var defaults = map[string]interface{}{
 	translationServicesKey: []string{}, // no services allowed by the default
+	rawServicesKey:         translation.DefaultRawServices,
 	targetURLKey:           "localhost:6000",
 	netDialerTimeoutKey:    "5s",
+	dialerDualStackKey:     true,
 	clientTimeoutKey:       "50s",
 	reqTimeoutKey:          "40s",
 	reqRetryIntervalKey:    "2s",
@@ -90,8 +167,16 @@ var defaults = map[string]interface{}{
 func tr1d1um(arguments []string) (exitCode int) {
 
 	var (
-		f, v                                = pflag.NewFlagSet(applicationName, pflag.ContinueOnError), viper.New()
-		logger, metricsRegistry, webPA, err = server.Initialize(applicationName, arguments, f, v, webhook.Metrics, aws.Metrics, basculechecks.Metrics)
+		f, v    = pflag.NewFlagSet(applicationName, pflag.ContinueOnError), viper.New()
+		modules = []xmetrics.Module{webhook.Metrics, aws.Metrics, basculechecks.Metrics, common.Metrics}
+
+		//the buckets of histograms are only known once the configuration is read, which happens as metrics are registered
+		histogramBuckets = func() (buckets []common.HistogramBuckets) {
+			v.UnmarshalKey(histogramBucketsKey, &buckets)
+			return
+		}
+
+		logger, metricsRegistry, webPA, err = server.Initialize(applicationName, arguments, f, v, common.WithHistogramBuckets(histogramBuckets, modules...)...)
 	)
 
 	if err != nil {
@@ -99,9 +184,17 @@ func tr1d1um(arguments []string) (exitCode int) {
 		return 1
 	}
 
+	if err = common.ValidateHistogramBuckets(histogramBuckets(), modules...); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid histogram buckets: %s\n", err.Error())
+		return 1
+	}
+
 	var (
 		infoLogger, errorLogger = logging.Info(logger), logging.Error(logger)
 		authenticate            *alice.Chain
+
+		//key material is reloaded on SIGHUP, and as its files change if configured, so that rotation doesn't require restarts
+		rotator = common.NewRotator(logger)
 	)
 
 	// This allows us to communicate the version of the binary upon request.
@@ -118,15 +211,30 @@ func tr1d1um(arguments []string) (exitCode int) {
 
 	infoLogger.Log("configurationFile", v.ConfigFileUsed())
 
-	r := mux.NewRouter()
+	//the fleet-wide policy, if any, overrides the corresponding settings of the configuration file
+	var policyOptions common.PolicyOptions
+	v.UnmarshalKey(policyKey, &policyOptions)
 
-	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
-		w.WriteHeader(http.StatusBadRequest)
-	})
+	policy, err := common.LoadPolicy(policyOptions)
+	switch {
+	case err != nil && policyOptions.Required:
+		fmt.Fprintf(os.Stderr, "Unable to load the policy: %s\n", err.Error())
+		return 1
+	case err != nil:
+		errorLogger.Log(logging.MessageKey(), "Unable to load the policy, using the configuration file", logging.ErrorKey(), err)
+	case policy != nil:
+		for _, warning := range policy.Warnings {
+			errorLogger.Log(logging.MessageKey(), "Policy loaded with a problem", logging.ErrorKey(), warning)
+		}
 
-	APIRouter := r.PathPrefix(fmt.Sprintf("/%s/", apiBase)).Subrouter()
+		for key, value := range policy.Settings {
+			v.Set(key, value)
+		}
+
+		infoLogger.Log(logging.MessageKey(), "Policy loaded", "source", policy.Source)
+	}
 
-	authenticate, err = authenticationHandler(v, logger, metricsRegistry)
+	authenticate, err = authenticationHandler(v, logger, metricsRegistry, rotator)
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Unable to build authentication handler: %s\n", err.Error())
@@ -140,6 +248,180 @@ func tr1d1um(arguments []string) (exitCode int) {
 		return 1
 	}
 
+	requestHeaders, responseHeaders := newHeaderForwardingRules(v)
+
+	var inboundLimits, forwardedLimits common.HeaderLimits
+	v.UnmarshalKey(inboundLimitsKey, &inboundLimits)
+	v.UnmarshalKey(forwardedLimitsKey, &forwardedLimits)
+
+	var tidOptions common.TIDOptions
+	v.UnmarshalKey(transactionIDsKey, &tidOptions)
+
+	if err = tidOptions.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid transaction ID configuration: %s\n", err.Error())
+		return 1
+	}
+
+	var partnerOptions common.PartnerOptions
+	v.UnmarshalKey(partnersKey, &partnerOptions)
+
+	//translations of error messages, in addition to the built-in ones
+	var errorMessages []common.MessageTranslation
+	v.UnmarshalKey(errorMessagesKey, &errorMessages)
+
+	var clusterOptions common.ClusterOptions
+	v.UnmarshalKey(clustersKey, &clusterOptions)
+
+	var tenantConfig common.TenantConfig
+	v.UnmarshalKey(tenantsKey, &tenantConfig)
+
+	tenantRouter, err := common.NewTenantRouter(tenantConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid tenants: %s\n", err.Error())
+		return 1
+	}
+
+	var targetPool *common.TargetPool
+	if targets := v.GetStringSlice(targetsKey); len(targets) > 0 {
+		if targetPool, err = common.NewTargetPool(targets); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid targets: %s\n", err.Error())
+			return 1
+		}
+	}
+
+	//tenants and targets are reloaded whenever the configuration file changes. Invalid changes are ignored
+	v.OnConfigChange(func(fsnotify.Event) {
+		if targetPool != nil {
+			if err := targetPool.Update(v.GetStringSlice(targetsKey)); err != nil {
+				errorLogger.Log(logging.MessageKey(), "Target configuration change was rejected", logging.ErrorKey(), err)
+			} else {
+				infoLogger.Log(logging.MessageKey(), "Target configuration reloaded", "targets", len(targetPool.Targets()))
+			}
+		}
+
+		var updated common.TenantConfig
+		v.UnmarshalKey(tenantsKey, &updated)
+
+		if err := tenantRouter.Update(updated); err != nil {
+			errorLogger.Log(logging.MessageKey(), "Tenant configuration change was rejected", logging.ErrorKey(), err)
+			return
+		}
+
+		infoLogger.Log(logging.MessageKey(), "Tenant configuration reloaded", "tenants", len(updated.Tenants))
+	})
+	v.WatchConfig()
+
+	var mirrorOptions common.MirrorOptions
+	v.UnmarshalKey(mirrorKey, &mirrorOptions)
+
+	var canaryOptions common.CanaryOptions
+	v.UnmarshalKey(canaryKey, &canaryOptions)
+
+	var alertOptions common.AlertOptions
+	v.UnmarshalKey(alertsKey, &alertOptions)
+
+	var deprecations []common.Deprecation
+	v.UnmarshalKey(deprecationsKey, &deprecations)
+
+	var legacyAPI common.LegacyOptions
+	v.UnmarshalKey(legacyAPIKey, &legacyAPI)
+
+	var adminOptions common.AdminOptions
+	v.UnmarshalKey(adminKey, &adminOptions)
+
+	accessLog, err := common.OpenAccessLog(v.GetString(accessLogKey))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to open the access log: %s\n", err.Error())
+		return 1
+	}
+
+	client, err := newClient(v, tConfigs, common.NewMeasures(metricsRegistry), rotator)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid client configuration: %s\n", err.Error())
+		return 1
+	}
+
+	wrpFormat, err := common.ParseWRPEncoding(v.GetString(wrpEncodingKey))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid WRP encoding: %s\n", err.Error())
+		return 1
+	}
+
+	options := []app.Option{
+		app.WithLogger(logger),
+		app.WithAccessLog(accessLog),
+		app.WithMetrics(metricsRegistry),
+		app.WithAuth(authenticate),
+		app.WithTargetURL(v.GetString(targetURLKey)),
+		app.WithBackend(v.GetString(backendKey), v.GetStringMap(backendConfigKey)),
+		app.WithWRPEncoding(wrpFormat),
+		app.WithMaxWRPSize(v.GetInt(maxWRPSizeKey)),
+		app.WithWRPSource(v.GetString(WRPSourcekey)),
+		app.WithServices(v.GetStringSlice(translationServicesKey)...),
+		app.WithRawServices(v.GetStringSlice(rawServicesKey)),
+		app.WithHTTPClient(client),
+		app.WithRetries(v.GetInt(reqMaxRetriesKey), v.GetDuration(reqRetryIntervalKey)),
+		app.WithRequestTimeout(tConfigs.rTimeout, tConfigs.rTimeoutBounds),
+		app.WithAttemptTimeout(tConfigs.aTimeout),
+		app.WithHeaderForwarding(requestHeaders, responseHeaders),
+		app.WithHeaderLimits(inboundLimits, forwardedLimits),
+		app.WithUserAgent(userAgent(v)),
+		app.WithPartners(partnerOptions),
+		app.WithTransactionIDs(tidOptions),
+		app.WithErrorMessages(errorMessages),
+		app.WithTenants(tenantRouter),
+		app.WithClusters(clusterOptions),
+		app.WithTargets(targetPool),
+		app.WithMirror(mirrorOptions),
+		app.WithCanary(canaryOptions),
+		app.WithAlerts(alertOptions),
+		app.WithDeprecations(deprecations),
+		app.WithLegacyAPI(legacyAPI),
+		app.WithAdmin(adminOptions),
+	}
+
+	//requests to the services are metered against the quotas of API keys, if configured
+	if v.IsSet(quotasKey) {
+		var quotaConfig common.QuotaConfig
+		v.UnmarshalKey(quotasKey, &quotaConfig)
+
+		options = append(options, app.WithQuotas(quotaConfig, common.NewMemoryQuotaStore()))
+	}
+
+	var concurrencyLimits common.ConcurrencyOptions
+	v.UnmarshalKey(concurrencyLimitsKey, &concurrencyLimits)
+	options = append(options, app.WithConcurrencyLimits(concurrencyLimits))
+
+	//the batch lane gets its own client so that bulk traffic doesn't hold the connections interactive requests need
+	var lanes common.LaneOptions
+	v.UnmarshalKey(lanesKey, &lanes)
+
+	if lanes.Enabled {
+		batchClient, errClient := newClient(v, tConfigs, common.NewMeasures(metricsRegistry), rotator)
+		if errClient != nil {
+			fmt.Fprintf(os.Stderr, "Invalid client configuration: %s\n", errClient.Error())
+			return 1
+		}
+
+		if timeout := v.GetDuration(batchClientTimeoutKey); timeout > 0 {
+			batchClient.Timeout = timeout
+		}
+
+		options = append(options, app.WithLanes(lanes, batchClient))
+	}
+
+	//the support bundle takes its snapshot of the metrics from the metric server unless told otherwise
+	if v.GetBool(supportBundleKey + ".enabled") {
+		var supportOptions common.SupportOptions
+		v.UnmarshalKey(supportBundleKey, &supportOptions)
+
+		if supportOptions.MetricsURL == "" && v.GetString("metric.address") != "" {
+			supportOptions.MetricsURL = metricsURL(v.GetString("metric.address"))
+		}
+
+		options = append(options, app.WithSupportBundle(supportOptions, v.AllSettings))
+	}
+
 	//
 	// Webhooks (if not configured, handler for webhooks is not set up)
 	//
@@ -152,80 +434,169 @@ func tr1d1um(arguments []string) (exitCode int) {
 			fmt.Fprintf(os.Stderr, "Error creating new webHook factory: %s\n", err.Error())
 			return 1
 		}
-	}
 
-	if snsFactory != nil {
-		hooks.ConfigHandler(&hooks.Options{
-			APIRouter:    APIRouter,
-			RootRouter:   r,
+		options = append(options, app.WithWebhooks(hooks.Options{
 			SoAProvider:  v.GetString("soa.provider"),
-			Authenticate: authenticate,
-			M:            metricsRegistry,
 			Host:         v.GetString("fqdn") + v.GetString("primary.address"),
 			HooksFactory: snsFactory,
-			Log:          logger,
 			Scheme:       v.GetString(hooksSchemeKey),
-		})
+		}))
 	}
 
-	//
-	// Stat Service
-	//
-	ss := stat.NewService(&stat.ServiceOptions{
-		Tr1d1umTransactor: common.NewTr1d1umTransactor(
-			&common.Tr1d1umTransactorOptions{
-				Do: xhttp.RetryTransactor(
-					xhttp.RetryOptions{
-						Logger:   logger,
-						Retries:  v.GetInt(reqMaxRetriesKey),
-						Interval: v.GetDuration(reqRetryIntervalKey),
-					},
-					newClient(v, tConfigs).Do),
-				RequestTimeout: tConfigs.rTimeout,
-			}),
-		XmidtStatURL: fmt.Sprintf("%s/%s/device/${device}/stat", v.GetString(targetURLKey), apiBase),
-	})
+	//caching headers of the successful responses by route group
+	if v.IsSet(cacheHeadersKey) {
+		var cacheHeaders common.CachePolicy
+		v.UnmarshalKey(cacheHeadersKey, &cacheHeaders)
 
-	//Must be called before translation.ConfigHandler due to mux path specificity (https://github.com/gorilla/mux#matching-routes)
-	stat.ConfigHandler(&stat.Options{
-		S:            ss,
-		APIRouter:    APIRouter,
-		Authenticate: authenticate,
-		Log:          logger,
-	})
+		options = append(options, app.WithCacheHeaders(cacheHeaders))
+	}
 
-	//
-	// WRP Service
-	//
+	//faults are injected into the responses of the configured route groups of test environments
+	if v.GetBool(faultInjectionKey) {
+		var faultRules map[string]common.FaultRule
+		v.UnmarshalKey(faultRulesKey, &faultRules)
 
-	ts := translation.NewService(&translation.ServiceOptions{
-		XmidtWrpURL: fmt.Sprintf("%s/%s/device", v.GetString(targetURLKey), apiBase),
+		for group, rule := range faultRules {
+			if err = rule.Validate(); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid fault injection rule for %s: %s\n", group, err.Error())
+				return 1
+			}
 
-		WRPSource: v.GetString(WRPSourcekey),
+			options = append(options, app.WithExtensions(group, common.Extensions{Middleware: []alice.Constructor{common.FaultInjector(rule)}}))
+		}
 
-		Tr1d1umTransactor: common.NewTr1d1umTransactor(
-			&common.Tr1d1umTransactorOptions{
-				RequestTimeout: tConfigs.rTimeout,
-				Do: xhttp.RetryTransactor(
-					xhttp.RetryOptions{
-						Logger:   logger,
-						Retries:  v.GetInt(reqMaxRetriesKey),
-						Interval: v.GetDuration(reqRetryIntervalKey),
-					},
-					newClient(v, tConfigs).Do),
-			}),
-	})
+		infoLogger.Log(logging.MessageKey(), "Fault injection is enabled", "routes", len(faultRules))
+	}
 
-	translation.ConfigHandler(&translation.Options{
-		S:             ts,
-		APIRouter:     APIRouter,
-		Authenticate:  authenticate,
-		Log:           logger,
-		ValidServices: v.GetStringSlice(translationServicesKey),
-	})
+	//device hints come from the device statistics
+	if v.GetBool(deviceHintsEnabledKey) {
+		var hintFields stat.HintFields
+		v.UnmarshalKey(deviceHintsFieldsKey, &hintFields)
+
+		options = append(options, app.WithDeviceHints(hintFields))
+	}
+
+	var presenceOptions common.PresenceOptions
+	v.UnmarshalKey(devicePresenceKey, &presenceOptions)
+	options = append(options, app.WithDevicePresence(presenceOptions))
+
+	if v.GetBool(deviceMetadataKey + ".enabled") {
+		var metadataOptions common.MetadataOptions
+		v.UnmarshalKey(deviceMetadataKey, &metadataOptions)
+		options = append(options, app.WithDeviceMetadata(common.NewMemoryMetadataStore(metadataOptions)))
+	}
+
+	var wrpAddressing = new(translation.WRPAddressing)
+	v.UnmarshalKey(WRPAddressingKey, wrpAddressing)
+
+	var statusMapping = new(translation.StatusMapping)
+	v.UnmarshalKey(statusMappingKey, statusMapping)
+
+	var groups translation.Groups
+	v.UnmarshalKey(groupsKey, &groups)
+
+	var parameterAliases []translation.ParameterAlias
+	v.UnmarshalKey(aliasesKey, &parameterAliases)
+
+	var dataModel []translation.ParameterType
+	v.UnmarshalKey(dataModelKey, &dataModel)
+
+	var profiles translation.Profiles
+	v.UnmarshalKey(profilesKey, &profiles)
+
+	//transform hooks are Go plugins configured by route group
+	var transformPlugins map[string]string
+	v.UnmarshalKey(transformsKey, &transformPlugins)
+
+	for group, path := range transformPlugins {
+		transform, errTransform := translation.LoadTransform(path)
+		if errTransform != nil {
+			fmt.Fprintf(os.Stderr, "Unable to load the %s transform: %s\n", group, errTransform.Error())
+			return 1
+		}
+
+		options = append(options, app.WithTransform(group, transform))
+	}
+
+	var diagnostics = new(translation.Diagnostics)
+	v.UnmarshalKey(diagnosticsKey, diagnostics)
+
+	var firmware = new(translation.Firmware)
+	v.UnmarshalKey(firmwareKey, firmware)
+
+	var redaction = new(translation.Redaction)
+	v.UnmarshalKey(redactionKey, redaction)
+
+	var setLimits *wdmp.SetLimits
+	if v.IsSet(setLimitsKey) {
+		setLimits = new(wdmp.SetLimits)
+		v.UnmarshalKey(setLimitsKey, setLimits)
+	}
+
+	var confirmations *translation.Confirmations
+	if v.GetBool(confirmationsKey) {
+		confirmations = &translation.Confirmations{
+			Secret: v.GetString(confirmationSecretKey),
+			TTL:    v.GetDuration(confirmationTTLKey),
+		}
+
+		if confirmations.Secret == "" {
+			fmt.Fprintf(os.Stderr, "%s is required when confirmations are enabled\n", confirmationSecretKey)
+			return 1
+		}
+	}
+
+	var qosRules translation.QoSRules
+	v.UnmarshalKey(qosRulesKey, &qosRules)
+
+	var signingOptions translation.SigningOptions
+	v.UnmarshalKey(signingKey, &signingOptions)
+
+	var cacheOptions translation.CacheOptions
+	v.UnmarshalKey(responseCacheKey, &cacheOptions)
+
+	var backpressureOptions translation.BackpressureOptions
+	v.UnmarshalKey(backpressureKey, &backpressureOptions)
+
+	var orderingOptions translation.OrderingOptions
+	v.UnmarshalKey(writeOrderingKey, &orderingOptions)
+
+	options = append(options,
+		app.WithWRPAddressing(wrpAddressing),
+		app.WithAcceptMsgpack(v.GetBool(acceptMsgpackKey)),
+		app.WithCanonicalJSON(v.GetBool(canonicalJSONKey)),
+		app.WithPassthrough(v.GetBool(passthroughKey)),
+		app.WithSetLimits(setLimits),
+		app.WithStatusMapping(statusMapping),
+		app.WithGroups(groups, v.GetInt(groupConcurrencyKey)),
+		app.WithAliases(parameterAliases),
+		app.WithDataModel(dataModel),
+		app.WithProfiles(profiles),
+		app.WithDiagnostics(diagnostics),
+		app.WithFirmware(firmware),
+		app.WithRedaction(redaction),
+		app.WithConfirmations(confirmations),
+		app.WithQoSRules(qosRules),
+		app.WithSigning(signingOptions),
+		app.WithResponseCache(cacheOptions),
+		app.WithBackpressure(backpressureOptions),
+		app.WithWriteOrdering(orderingOptions),
+	)
+
+	t, err := app.New(options...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to build tr1d1um: %s\n", err.Error())
+		return 1
+	}
+
+	registrar, err := newRegistrar(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid service registration: %s\n", err.Error())
+		return 1
+	}
 
 	var (
-		_, tr1d1umServer, _ = webPA.Prepare(logger, nil, metricsRegistry, r)
+		_, tr1d1umServer, _ = webPA.Prepare(logger, nil, metricsRegistry, t.Handler())
 		signals             = make(chan os.Signal, 1)
 	)
 
@@ -239,6 +610,15 @@ func tr1d1um(arguments []string) (exitCode int) {
 		return 4
 	}
 
+	//gateways discover the instance once it serves requests
+	if registrar != nil {
+		if err = registrar.Register(context.Background()); err == nil {
+			infoLogger.Log(logging.MessageKey(), "registered into the service catalog")
+		} else {
+			errorLogger.Log(logging.MessageKey(), "Unable to register into the service catalog", logging.ErrorKey(), err)
+		}
+	}
+
 	if snsFactory != nil {
 		// wait for DNS to propagate before subscribing to SNS
 		if err = snsFactory.DnsReady(); err == nil {
@@ -250,23 +630,52 @@ func tr1d1um(arguments []string) (exitCode int) {
 		}
 	}
 
+	if v.GetBool(watchKeyMaterialKey) {
+		if err = rotator.Watch(shutdown); err != nil {
+			errorLogger.Log(logging.MessageKey(), "Unable to watch key material files", logging.ErrorKey(), err)
+		}
+	}
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	go func() {
+		for range hangups {
+			rotator.Rotate()
+		}
+	}()
+
 	signal.Notify(signals)
 	s := server.SignalWait(infoLogger, signals, os.Kill, os.Interrupt)
 	errorLogger.Log(logging.MessageKey(), "exiting due to signal", "signal", s)
+
+	//gateways should stop sending requests before the servers shut down
+	if registrar != nil {
+		if err = registrar.Deregister(context.Background()); err != nil {
+			errorLogger.Log(logging.MessageKey(), "Unable to deregister from the service catalog", logging.ErrorKey(), err)
+		}
+	}
+
 	close(shutdown)
 	waitGroup.Wait()
 
 	return 0
 }
 
-//timeoutConfigs holds parsable config values for HTTP transactions
+// timeoutConfigs holds parsable config values for HTTP transactions
 type timeoutConfigs struct {
 	//HTTP client timeout
 	cTimeout time.Duration
 
-	//HTTP request timeout
+	//HTTP request timeout, which covers all the attempts of a transaction
 	rTimeout time.Duration
 
+	//timeout of each of the attempts of a transaction
+	aTimeout time.Duration
+
+	//bounds for the HTTP request timeouts API consumers may ask for
+	rTimeoutBounds common.TimeoutBounds
+
 	//net dialer timeout
 	dTimeout time.Duration
 }
@@ -280,6 +689,26 @@ func newTimeoutConfigs(v *viper.Viper) (t *timeoutConfigs, err error) {
 					cTimeout: c,
 					rTimeout: r,
 					dTimeout: d,
+					aTimeout: v.GetDuration(attemptTimeoutKey),
+					rTimeoutBounds: common.TimeoutBounds{
+						Min: v.GetDuration(reqTimeoutMinKey),
+						Max: v.GetDuration(reqTimeoutMaxKey),
+					},
+				}
+
+				//the timeouts section takes precedence over the legacy keys
+				if v.IsSet(connectTimeoutKey) {
+					t.dTimeout = v.GetDuration(connectTimeoutKey)
+				}
+
+				if v.IsSet(totalTimeoutKey) {
+					t.rTimeout = v.GetDuration(totalTimeoutKey)
+				}
+
+				if t.aTimeout < 0 || t.dTimeout <= 0 || t.rTimeout <= 0 {
+					t, err = nil, fmt.Errorf("timeouts must be positive")
+				} else if t.aTimeout > t.rTimeout {
+					t, err = nil, fmt.Errorf("the attempt timeout can't exceed the total timeout")
 				}
 			}
 		}
@@ -287,14 +716,115 @@ func newTimeoutConfigs(v *viper.Viper) (t *timeoutConfigs, err error) {
 	return
 }
 
-func newClient(v *viper.Viper, t *timeoutConfigs) *http.Client {
+// newHeaderForwardingRules reads the header forwarding rules for both directions of the XMiDT API transactions
+// Response rules are left nil when not configured so that the transactor defaults apply
+func newHeaderForwardingRules(v *viper.Viper) (request common.HeaderForwardingRules, response *common.HeaderForwardingRules) {
+	v.UnmarshalKey(requestHeadersKey, &request)
+
+	if v.IsSet(responseHeadersKey) {
+		response = new(common.HeaderForwardingRules)
+		v.UnmarshalKey(responseHeadersKey, response)
+	}
+	return
+}
+
+// userAgent returns the configured User-Agent of the requests to the XMiDT API or, if none is, one made of
+// the service name, its version and the instance (its fqdn or else its hostname)
+func userAgent(v *viper.Viper) string {
+	if configured := v.GetString(userAgentKey); configured != "" {
+		return configured
+	}
+
+	instance := v.GetString("fqdn")
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+
+	if instance == "" {
+		return fmt.Sprintf("%s/%s", applicationName, applicationVersion)
+	}
+
+	return fmt.Sprintf("%s/%s (%s)", applicationName, applicationVersion, instance)
+}
+
+// metricsURL returns the URL of the metrics served by the metric server listening at the given address
+func metricsURL(address string) string {
+	if strings.HasPrefix(address, ":") {
+		address = "localhost" + address
+	}
+
+	return fmt.Sprintf("http://%s/metrics", address)
+}
+
+// newRegistrar returns the registrar of the instance into the configured service catalog, if any
+// The instance is advertised at the fqdn and the port of the primary server unless an address is configured
+func newRegistrar(v *viper.Viper) (common.Registrar, error) {
+	var o common.RegistrationOptions
+	v.UnmarshalKey(registrationKey, &o)
+
+	if o.Address == "" {
+		if _, port, err := net.SplitHostPort(v.GetString("primary.address")); err == nil {
+			o.Address = net.JoinHostPort(v.GetString("fqdn"), port)
+		}
+	}
+
+	if o.Meta == nil {
+		o.Meta = make(map[string]string)
+	}
+
+	o.Meta["version"] = applicationVersion
+	return common.NewRegistrar(o, nil)
+}
+
+// newClient returns the client of the XMiDT API transactions, which goes through the egress proxy and
+// resolves hosts through the DNS cache, if configured. Its client certificate, if any, is reloaded by the rotator
+func newClient(v *viper.Viper, t *timeoutConfigs, measures *common.Measures, rotator *common.Rotator) (*http.Client, error) {
+	var proxyOptions common.ProxyOptions
+	v.UnmarshalKey(proxyKey, &proxyOptions)
+
+	proxy, err := proxyOptions.Proxy()
+	if err != nil {
+		return nil, err
+	}
+
+	var dialerOptions common.DialerOptions
+	v.UnmarshalKey(dialerKey, &dialerOptions)
+
+	var (
+		dnsCacheOptions common.DNSCacheOptions
+		lookup          common.LookupFunc
+	)
+
+	v.UnmarshalKey(dnsCacheKey, &dnsCacheOptions)
+	if dnsCache := common.NewDNSCache(dnsCacheOptions, nil, measures.DNSLookups); dnsCache != nil {
+		lookup = dnsCache.LookupIPAddr
+	}
+
+	dial, err := dialerOptions.DialContext(t.dTimeout, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	var clientTLS common.ClientTLSOptions
+	v.UnmarshalKey(clientTLSKey, &clientTLS)
+
+	tlsConfig, certificate, err := clientTLS.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if certificate != nil {
+		rotator.Add("client certificate", certificate, certificate.Files()...)
+	}
+
 	return &http.Client{
 		Timeout: t.cTimeout,
 		Transport: &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout: t.dTimeout,
-			}).Dial},
-	}
+			Proxy:           proxy,
+			DialContext:     dial,
+			TLSClientConfig: tlsConfig,
+		},
+	}, nil
 }
 
 func SetLogger(logger log.Logger) func(delegate http.Handler) http.Handler {
@@ -313,7 +843,7 @@ func GetLogger(ctx context.Context) bascule.Logger {
 	return logger
 }
 
-//JWTValidator provides a convenient way to define jwt validator through config files
+// JWTValidator provides a convenient way to define jwt validator through config files
 type JWTValidator struct {
 	// JWTKeys is used to create the key.Resolver for JWT verification keys
 	Keys key.ResolverFactory `json:"keys"`
@@ -323,8 +853,14 @@ type JWTValidator struct {
 	Custom secure.JWTValidatorFactory `json:"custom"`
 }
 
-//authenticationHandler configures the authorization requirements for requests to reach the main handler
-func authenticationHandler(v *viper.Viper, logger log.Logger, registry xmetrics.Registry) (*alice.Chain, error) {
+// keyCache is implemented by the JWKS resolvers which cache keys, which can be refreshed as keys are rotated
+type keyCache interface {
+	UpdateKeys(ctx context.Context) (int, []error)
+}
+
+// authenticationHandler configures the authorization requirements for requests to reach the main handler
+// JWKS material is refreshed by the rotator
+func authenticationHandler(v *viper.Viper, logger log.Logger, registry xmetrics.Registry, rotator *common.Rotator) (*alice.Chain, error) {
 
 	var (
 		m *basculechecks.JWTValidationMeasures
@@ -364,6 +900,16 @@ func authenticationHandler(v *viper.Viper, logger log.Logger, registry xmetrics.
 			return &alice.Chain{}, emperror.With(err, "failed to create resolver")
 		}
 
+		if cache, ok := resolver.(keyCache); ok {
+			rotator.Add("JWKS", common.ReloadFunc(func() error {
+				if _, errs := cache.UpdateKeys(context.Background()); len(errs) > 0 {
+					return errs[0]
+				}
+
+				return nil
+			}))
+		}
+
 		options = append(options, basculehttp.WithTokenFactory("Bearer", basculehttp.BearerTokenFactory{
 			DefaultKeyId:  DefaultKeyID,
 			Resolver:      resolver,
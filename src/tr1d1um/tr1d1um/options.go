@@ -0,0 +1,479 @@
+package tr1d1um
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/hooks"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/stat"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/translation"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/go-kit/kit/log"
+	"github.com/justinas/alice"
+)
+
+//Option configures a Server built by New
+type Option func(*Server)
+
+//WithTargetURL sets the base URL of the XMiDT API requests are sent to (i.e. http://localhost:6000)
+func WithTargetURL(targetURL string) Option {
+	return func(s *Server) {
+		s.targetURL = targetURL
+	}
+}
+
+//WithBackend sets the backend requests are delivered to devices through (see common.RegisterBackend)
+//By default, requests are sent to the XMiDT API at the target URL
+func WithBackend(name string, config map[string]interface{}) Option {
+	return func(s *Server) {
+		s.backend, s.backendConfig = name, config
+	}
+}
+
+//WithWRPEncoding sets the encoding of the WRP messages sent to the XMiDT API. Defaults to msgpack
+//JSON is meant for debugging: responses are decoded according to their Content-Type either way
+func WithWRPEncoding(format wrp.Format) Option {
+	return func(s *Server) {
+		s.wrpFormat = format
+	}
+}
+
+//WithAccessLog sets the writer of the access log, in the Apache Combined Log Format (see common.OpenAccessLog)
+//There is no access log by default
+func WithAccessLog(out io.Writer) Option {
+	return func(s *Server) {
+		s.accessLog = out
+	}
+}
+
+//WithTLS makes Start serve requests over TLS with the given certificate, which may be reloaded as it is rotated
+//without affecting established connections
+func WithTLS(certificate *common.Certificate) Option {
+	return func(s *Server) {
+		s.certificate = certificate
+	}
+}
+
+//WithAuth sets the authentication chain requests go through before reaching the services
+//By default, requests are not authenticated
+func WithAuth(authenticate *alice.Chain) Option {
+	return func(s *Server) {
+		s.authenticate = authenticate
+	}
+}
+
+//WithLogger sets the logger of the server. Logs are discarded by default
+func WithLogger(logger log.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+//WithMetrics sets the registry the server reports its metrics to. Metrics are discarded by default
+func WithMetrics(registry xmetrics.Registry) Option {
+	return func(s *Server) {
+		s.registry = registry
+	}
+}
+
+//WithServices sets the names of the device services the translation API accepts requests for
+func WithServices(services ...string) Option {
+	return func(s *Server) {
+		s.services = services
+	}
+}
+
+//WithRawServices sets the services whose POST bodies are passed through to devices untouched rather than as WDMP
+//A nil list means translation.DefaultRawServices
+func WithRawServices(services []string) Option {
+	return func(s *Server) {
+		s.translation.RawServices = services
+	}
+}
+
+//WithWRPSource sets the source of outgoing WRP messages
+func WithWRPSource(source string) Option {
+	return func(s *Server) {
+		s.wrpSource = source
+	}
+}
+
+//WithMaxWRPSize sets the size in bytes of the largest WRP message sent to devices. Larger ones are rejected with a 413
+//There is no limit if not positive
+func WithMaxWRPSize(size int) Option {
+	return func(s *Server) {
+		s.maxWRPSize = size
+	}
+}
+
+//WithHTTPClient sets the client used to send requests to the XMiDT API
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Server) {
+		s.client = client
+	}
+}
+
+//WithRetries sets how many times and how often requests failing to reach the XMiDT API are retried
+func WithRetries(retries int, interval time.Duration) Option {
+	return func(s *Server) {
+		s.retries, s.retryInterval = retries, interval
+	}
+}
+
+//WithRequestTimeout sets the timeout of the XMiDT API requests and the bounds API consumers may override it within
+func WithRequestTimeout(timeout time.Duration, bounds common.TimeoutBounds) Option {
+	return func(s *Server) {
+		s.requestTimeout, s.timeoutBounds = timeout, bounds
+	}
+}
+
+//WithAttemptTimeout sets the timeout of each attempt (the initial one and the retries) of the XMiDT API requests
+//Attempts are only bounded by the request timeout if zero
+func WithAttemptTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.attemptTimeout = timeout
+	}
+}
+
+//WithHeaderForwarding sets the rules of the headers forwarded to and from the XMiDT API
+//A nil response means the transactor defaults apply
+func WithHeaderForwarding(request common.HeaderForwardingRules, response *common.HeaderForwardingRules) Option {
+	return func(s *Server) {
+		s.requestHeaders, s.responseHeaders = request, response
+	}
+}
+
+//WithUserAgent sets the User-Agent of the requests to the XMiDT API
+func WithUserAgent(userAgent string) Option {
+	return func(s *Server) {
+		s.userAgent = userAgent
+	}
+}
+
+//WithPartners sets where the partner ID on behalf of which a request is made comes from
+//By default, it is the one the partner-id claim of the token of the API consumer grants
+func WithPartners(o common.PartnerOptions) Option {
+	return func(s *Server) {
+		s.partners = o
+	}
+}
+
+//WithTransactionIDs sets the header which carries transaction IDs and the format of the generated ones
+func WithTransactionIDs(o common.TIDOptions) Option {
+	return func(s *Server) {
+		s.transactionIDs = o
+	}
+}
+
+//WithErrorMessages adds translations of error messages to the built-in ones, which they take precedence over
+func WithErrorMessages(translations []common.MessageTranslation) Option {
+	return func(s *Server) {
+		s.errorMessages = translations
+	}
+}
+
+//WithHeaderLimits sets the limits of the headers of inbound requests, which are rejected with a 431 if exceeded,
+//and of the ones forwarded to the XMiDT API
+func WithHeaderLimits(inbound, forwarded common.HeaderLimits) Option {
+	return func(s *Server) {
+		s.inboundLimits, s.forwardedLimits = inbound, forwarded
+	}
+}
+
+//WithTenants sets the router of the requests of partners with their own XMiDT cluster
+//The router is kept so that its configuration may be updated while the server runs
+func WithTenants(tenants *common.TenantRouter) Option {
+	return func(s *Server) {
+		s.tenants = tenants
+	}
+}
+
+//WithClusters sets the alternate XMiDT clusters API consumers holding the configured capability may send their
+//requests to through the X-Xmidt-Cluster header
+func WithClusters(o common.ClusterOptions) Option {
+	return func(s *Server) {
+		s.clusters = o
+	}
+}
+
+//WithTargets sets the pool of XMiDT API instances the requests to the target URL are spread across by device
+func WithTargets(targets *common.TargetPool) Option {
+	return func(s *Server) {
+		s.targets = targets
+	}
+}
+
+//WithMirror sets the mirroring of read-only requests to a secondary backend
+func WithMirror(o common.MirrorOptions) Option {
+	return func(s *Server) {
+		s.mirror = o
+	}
+}
+
+//WithAlerts sets the error rates of routes and backends above which alert events are emitted
+//There are no alerts by default
+func WithAlerts(o common.AlertOptions) Option {
+	return func(s *Server) {
+		s.alerts = o
+	}
+}
+
+//WithDeprecations sets the routes slated for removal. Their responses carry the Deprecation and Sunset headers
+//and their requests are counted so that their remaining usage can be measured
+func WithDeprecations(deprecations []common.Deprecation) Option {
+	return func(s *Server) {
+		s.deprecated = deprecations
+	}
+}
+
+//WithLegacyAPI enables serving the requests of the clients of the version 1 of the API as version 2 ones
+func WithLegacyAPI(o common.LegacyOptions) Option {
+	return func(s *Server) {
+		s.legacy = o
+	}
+}
+
+//WithCanary sets the split of the traffic between the XMiDT API and a canary backend
+func WithCanary(o common.CanaryOptions) Option {
+	return func(s *Server) {
+		s.canary = o
+	}
+}
+
+//WithQuotas enables the metering of the requests to the services against the quotas of API keys
+func WithQuotas(c common.QuotaConfig, store common.QuotaStore) Option {
+	return func(s *Server) {
+		s.quotas, s.quotaStore = &c, store
+	}
+}
+
+//WithConcurrencyLimits bounds the number of requests to the services served at a time. Requests beyond the limit
+//wait for their turn, which tenants take in weighted round-robin
+func WithConcurrencyLimits(o common.ConcurrencyOptions) Option {
+	return func(s *Server) {
+		s.concurrency = o
+	}
+}
+
+//WithLanes classifies requests into the interactive and batch lanes, each with its own concurrency pool. Requests of
+//the batch lane are sent to the XMiDT API through batchClient, if not nil, rather than the client of the server
+func WithLanes(o common.LaneOptions, batchClient *http.Client) Option {
+	return func(s *Server) {
+		s.lanes, s.batchClient = o, batchClient
+	}
+}
+
+//WithAdmin sets who may use the admin routes and how requests are rejected while the instance drains
+//By default, the admin routes require the common.DefaultAdminCapability capability
+func WithAdmin(o common.AdminOptions) Option {
+	return func(s *Server) {
+		s.admin = o
+	}
+}
+
+//WithSupportBundle enables the admin endpoint of the support bundle. settings returns the configuration of the
+//server (i.e. viper's AllSettings) which is added to the bundle with its secrets redacted
+func WithSupportBundle(o common.SupportOptions, settings func() map[string]interface{}) Option {
+	return func(s *Server) {
+		s.support, s.settings = o, settings
+	}
+}
+
+//WithWebhooks enables the webhook endpoints. The routers, authentication, logger and metrics of the options
+//are the ones of the server
+func WithWebhooks(o hooks.Options) Option {
+	return func(s *Server) {
+		s.hooks = &o
+	}
+}
+
+//WithCacheHeaders sets the caching headers of the successful responses of the route groups of the policy
+//By default, responses have no caching headers
+func WithCacheHeaders(policy common.CachePolicy) Option {
+	return func(s *Server) {
+		s.cacheHeaders = policy
+	}
+}
+
+//WithExtensions registers custom middleware and server options for a group of routes
+//Groups are the route groups of the translation service and the stat routes (StatRoutes)
+func WithExtensions(group string, e common.Extensions) Option {
+	return func(s *Server) {
+		s.extensions[group] = e
+	}
+}
+
+//WithDeviceHints enables looking up the given device statistics to help diagnose the failures devices report
+func WithDeviceHints(fields stat.HintFields) Option {
+	return func(s *Server) {
+		s.hintFields = &fields
+	}
+}
+
+//WithDevicePresence sets the caching of whether devices are connected, as found out from their statistics, so that
+//requests for devices known to be offline fail right away. It is disabled by default
+func WithDevicePresence(o common.PresenceOptions) Option {
+	return func(s *Server) {
+		s.presence = o
+	}
+}
+
+//WithDeviceMetadata sets the store of what tr1d1um learns about devices from their statistics and responses. It is
+//served by the metadata route and spares looking up device hints. It is disabled by default
+func WithDeviceMetadata(store common.DeviceMetadataStore) Option {
+	return func(s *Server) {
+		s.metadata = store
+	}
+}
+
+//WithWRPAddressing sets the source and destination of outgoing WRP messages
+func WithWRPAddressing(addressing *translation.WRPAddressing) Option {
+	return func(s *Server) {
+		s.translation.WRPAddressing = addressing
+	}
+}
+
+//WithAcceptMsgpack sets whether msgpack-encoded request bodies are accepted in addition to JSON
+func WithAcceptMsgpack(accept bool) Option {
+	return func(s *Server) {
+		s.translation.AcceptMsgpack = accept
+	}
+}
+
+//WithCanonicalJSON sets whether device payloads are re-marshaled with sorted keys and stable formatting
+func WithCanonicalJSON(canonical bool) Option {
+	return func(s *Server) {
+		s.translation.CanonicalJSON = canonical
+	}
+}
+
+//WithPassthrough sets whether API consumers may get the successful XMiDT responses as is (see translation.Options)
+func WithPassthrough(passthrough bool) Option {
+	return func(s *Server) {
+		s.translation.Passthrough = passthrough
+	}
+}
+
+//WithSetLimits sets the limits of the bodies of SET requests and of the string values they hold (optional)
+func WithSetLimits(limits *wdmp.SetLimits) Option {
+	return func(s *Server) {
+		s.translation.SetLimits = limits
+	}
+}
+
+//WithStatusMapping sets the translation of the RDK status codes of device responses into HTTP status codes
+func WithStatusMapping(mapping *translation.StatusMapping) Option {
+	return func(s *Server) {
+		s.translation.StatusMapping = mapping
+	}
+}
+
+//WithGroups sets the device groups SET requests can be fanned out to
+func WithGroups(groups translation.Groups, concurrency int) Option {
+	return func(s *Server) {
+		s.translation.Groups, s.translation.GroupConcurrency = groups, concurrency
+	}
+}
+
+//WithDataModel sets the TR-181 data types GET responses are annotated with when API consumers ask for them
+func WithDataModel(dataModel []translation.ParameterType) Option {
+	return func(s *Server) {
+		s.dataModel = dataModel
+	}
+}
+
+//WithProfiles sets the named parameter bundles that can be applied to devices
+func WithProfiles(profiles translation.Profiles) Option {
+	return func(s *Server) {
+		s.translation.Profiles = profiles
+	}
+}
+
+//WithAliases sets the friendly names API consumers may use in place of TR-181 parameter names
+func WithAliases(aliases []translation.ParameterAlias) Option {
+	return func(s *Server) {
+		s.aliases = aliases
+	}
+}
+
+//WithTransform registers the hook applied to the WDMP documents of a group of routes
+func WithTransform(group string, transform *translation.Transform) Option {
+	return func(s *Server) {
+		s.translation.Transforms[group] = transform
+	}
+}
+
+//WithDiagnostics sets the device service and parameters behind the reboot and ping routes
+func WithDiagnostics(diagnostics *translation.Diagnostics) Option {
+	return func(s *Server) {
+		s.translation.Diagnostics = diagnostics
+	}
+}
+
+//WithFirmware sets the allowed firmware URLs and the parameters of the firmware download route
+func WithFirmware(firmware *translation.Firmware) Option {
+	return func(s *Server) {
+		s.translation.Firmware = firmware
+	}
+}
+
+//WithRedaction sets the sensitive parameters whose values are masked in device responses
+func WithRedaction(redaction *translation.Redaction) Option {
+	return func(s *Server) {
+		s.translation.Redaction = redaction
+	}
+}
+
+//WithConfirmations requires API consumers to confirm their DELETE_ROW and REPLACE_ROWS requests (optional)
+func WithConfirmations(confirmations *translation.Confirmations) Option {
+	return func(s *Server) {
+		s.translation.Confirmations = confirmations
+	}
+}
+
+//WithCommands sets the custom WDMP commands exposed in addition to the built-in ones
+func WithCommands(commands translation.Commands) Option {
+	return func(s *Server) {
+		s.translation.Commands = commands
+	}
+}
+
+//WithQoSRules sets the rules inferring the QoS of outgoing WRP messages from their operation
+func WithQoSRules(rules translation.QoSRules) Option {
+	return func(s *Server) {
+		s.qosRules = rules
+	}
+}
+
+//WithSigning sets the keys outgoing WRP payloads are signed with
+func WithSigning(o translation.SigningOptions) Option {
+	return func(s *Server) {
+		s.signing = o
+	}
+}
+
+//WithResponseCache sets the caching of device responses
+func WithResponseCache(o translation.CacheOptions) Option {
+	return func(s *Server) {
+		s.cache = o
+	}
+}
+
+//WithBackpressure sets the shedding of requests to devices which keep timing out
+func WithBackpressure(o translation.BackpressureOptions) Option {
+	return func(s *Server) {
+		s.backpressure = o
+	}
+}
+
+//WithWriteOrdering sets the serialization of the writes to each device, which are sent in the order they were received
+//By default, concurrent writes to a device may reach it in any order
+func WithWriteOrdering(o translation.OrderingOptions) Option {
+	return func(s *Server) {
+		s.ordering = o
+	}
+}
@@ -0,0 +1,488 @@
+//Package tr1d1um builds the tr1d1um server (its routers, services and middleware) so that it can be
+//embedded in test harnesses and composite services as well as run by the tr1d1um binary
+package tr1d1um
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/hooks"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/stat"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/translation"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/xhttp"
+	"github.com/Comcast/webpa-common/xmetrics"
+	"github.com/go-kit/kit/log"
+	"github.com/goph/emperror"
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+)
+
+//APIBase is the path prefix of the tr1d1um API
+const APIBase = "api/v2"
+
+//StatRoutes is the route group of the stat service extensions may be registered for
+const StatRoutes = "stat"
+
+//Server is a tr1d1um server. Its Handler may be served by any HTTP server or it can start its own
+type Server struct {
+	logger       log.Logger
+	registry     xmetrics.Registry
+	authenticate *alice.Chain
+
+	targetURL     string
+	backend       string
+	backendConfig map[string]interface{}
+	wrpFormat     wrp.Format
+	maxWRPSize    int
+	wrpSource     string
+	services      []string
+
+	client          *http.Client
+	batchClient     *http.Client
+	retries         int
+	retryInterval   time.Duration
+	requestTimeout  time.Duration
+	attemptTimeout  time.Duration
+	timeoutBounds   common.TimeoutBounds
+	requestHeaders  common.HeaderForwardingRules
+	inboundLimits   common.HeaderLimits
+	forwardedLimits common.HeaderLimits
+	responseHeaders *common.HeaderForwardingRules
+	userAgent       string
+
+	partners       common.PartnerOptions
+	transactionIDs common.TIDOptions
+	errorMessages  []common.MessageTranslation
+
+	tenants     *common.TenantRouter
+	clusters    common.ClusterOptions
+	targets     *common.TargetPool
+	mirror      common.MirrorOptions
+	alerts      common.AlertOptions
+	deprecated  []common.Deprecation
+	legacy      common.LegacyOptions
+	canary      common.CanaryOptions
+	quotas      *common.QuotaConfig
+	quotaStore  common.QuotaStore
+	concurrency common.ConcurrencyOptions
+	lanes       common.LaneOptions
+
+	hooks        *hooks.Options
+	extensions   map[string]common.Extensions
+	cacheHeaders common.CachePolicy
+	hintFields   *stat.HintFields
+	presence     common.PresenceOptions
+	metadata     common.DeviceMetadataStore
+
+	translation  translation.Options
+	aliases      []translation.ParameterAlias
+	dataModel    []translation.ParameterType
+	qosRules     translation.QoSRules
+	signing      translation.SigningOptions
+	cache        translation.CacheOptions
+	backpressure translation.BackpressureOptions
+	ordering     translation.OrderingOptions
+
+	accessLog   io.Writer
+	certificate *common.Certificate
+	admin       common.AdminOptions
+	support     common.SupportOptions
+	settings    func() map[string]interface{}
+
+	router     *mux.Router
+	handler    http.Handler
+	drainer    *common.Drainer
+	httpServer *http.Server
+}
+
+//New builds a tr1d1um server out of the given options. The defaults are the ones of the tr1d1um configuration
+func New(options ...Option) (*Server, error) {
+	var s = &Server{
+		logger:         log.NewNopLogger(),
+		targetURL:      "localhost:6000",
+		wrpSource:      "dns:localhost",
+		client:         &http.Client{Timeout: 50 * time.Second},
+		retries:        2,
+		retryInterval:  2 * time.Second,
+		requestTimeout: 40 * time.Second,
+		extensions:     make(map[string]common.Extensions),
+		translation:    translation.Options{Transforms: make(map[string]*translation.Transform)},
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	if s.authenticate == nil {
+		authenticate := alice.New()
+		s.authenticate = &authenticate
+	}
+
+	settings, err := common.NewSettings(s.partners, s.transactionIDs, s.errorMessages)
+	if err != nil {
+		return nil, emperror.Wrap(err, "invalid transaction ID configuration")
+	}
+
+	if err := s.configure(); err != nil {
+		return nil, err
+	}
+
+	s.handler = settings.Apply(common.AccessLog(s.accessLog)(s.router))
+
+	return s, nil
+}
+
+//configure builds the services of the server and registers their routes
+func (s *Server) configure() (err error) {
+	var (
+		measures = common.NewMeasures(s.registry)
+		canary   *common.Canary
+	)
+
+	s.router = mux.NewRouter()
+
+	s.router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+
+	s.router.MethodNotAllowedHandler = common.MethodNotAllowedHandler(s.router)
+
+	//admin routes are matched first so that they keep working while the instance drains
+	adminRouter := s.router.PathPrefix(fmt.Sprintf("/%s/admin/", APIBase)).Subrouter()
+	APIRouter := s.router.PathPrefix(fmt.Sprintf("/%s/", APIBase)).Subrouter()
+
+	s.drainer = common.NewDrainer(s.admin.DrainRetryAfter)
+	APIRouter.Use(s.drainer.Track)
+
+	//the most recent transactions, including the rejected ones, are kept for the support bundle
+	var transactions *common.TransactionRecorder
+	if s.support.Enabled {
+		transactions = common.NewTransactionRecorder(s.support.Transactions)
+		APIRouter.Use(transactions.Record)
+	}
+
+	monitor, err := common.NewErrorRateMonitor(s.alerts, s.logger, nil)
+	if err != nil {
+		return emperror.Wrap(err, "invalid alerts configuration")
+	}
+
+	APIRouter.Use(monitor.Track)
+
+	deprecations, err := common.NewDeprecations(s.deprecated, measures.Deprecated)
+	if err != nil {
+		return emperror.Wrap(err, "invalid deprecations configuration")
+	}
+
+	APIRouter.Use(deprecations.Track)
+
+	//header bombs are turned away before any other work is done for the request
+	APIRouter.Use(s.inboundLimits.Enforce)
+
+	s.router.Handle("/ready", s.drainer.ReadinessHandler()).Methods(http.MethodGet)
+
+	//older clients keep working against the version 1 paths while they migrate
+	if s.legacy.Enabled {
+		legacy, errLegacy := common.NewLegacyHandler(s.legacy, APIBase, s.router, measures.Deprecated)
+		if errLegacy != nil {
+			return emperror.Wrap(errLegacy, "invalid legacy API configuration")
+		}
+
+		s.router.PathPrefix(fmt.Sprintf("/%s/", common.LegacyAPIBase)).Handler(legacy)
+	}
+	//admin routes affect every API consumer so they are restricted to administrators
+	var admin = s.authenticate.Append(common.RequireAdmin(s.admin))
+	adminRouter.Handle("/drain", admin.Then(common.Welcome(common.DrainHandler(s.drainer)))).
+		Methods(http.MethodGet, http.MethodPut, http.MethodDelete)
+
+	//the support bundle gathers what escalations to the platform team need
+	if s.support.Enabled {
+		settings := s.settings
+		if settings == nil {
+			settings = func() map[string]interface{} { return nil }
+		}
+
+		adminRouter.Handle("/support", admin.Then(common.Welcome(common.SupportBundleHandler(s.support, settings, transactions)))).
+			Methods(http.MethodGet)
+	}
+
+	if err = s.clusters.Validate(); err != nil {
+		return emperror.Wrap(err, "invalid clusters configuration")
+	}
+
+	if s.tenants == nil {
+		s.tenants, _ = common.NewTenantRouter(common.TenantConfig{})
+	}
+
+	if err = s.mirror.Validate(); err != nil {
+		return emperror.Wrap(err, "invalid mirror configuration")
+	}
+
+	if canary, err = common.NewCanary(s.canary); err != nil {
+		return emperror.Wrap(err, "invalid canary configuration")
+	}
+
+	//the traffic split can be adjusted at runtime
+	if s.canary.TargetURL != "" {
+		adminRouter.Handle("/canary", admin.Then(common.Welcome(common.CanaryHandler(canary)))).
+			Methods(http.MethodGet, http.MethodPut)
+	}
+
+	//the members of the target pool can be changed at runtime
+	if s.targets != nil {
+		adminRouter.Handle("/targets", admin.Then(common.Welcome(common.TargetsHandler(s.targets)))).
+			Methods(http.MethodGet, http.MethodPut)
+	}
+
+	//request bodies are checked against the checksums API consumers send, if any, before they are decoded. This
+	//happens once requests are authenticated as the bodies are read whole
+	var maxBodySize int
+	if s.translation.SetLimits != nil {
+		maxBodySize = s.translation.SetLimits.MaxBodySize
+	}
+
+	var authenticate = s.authenticate.Append(common.VerifyChecksum(maxBodySize))
+
+	//requests to the services are metered against the quotas of API keys, if configured
+	var metered = &authenticate
+	if s.quotas != nil {
+		store := s.quotaStore
+		if store == nil {
+			store = common.NewMemoryQuotaStore()
+		}
+
+		quotas, errQuotas := common.NewQuotas(*s.quotas, store)
+		if errQuotas != nil {
+			return emperror.Wrap(errQuotas, "invalid quotas")
+		}
+
+		meteredChain := authenticate.Append(quotas.Enforcer())
+		metered = &meteredChain
+		APIRouter.Handle("/quota", authenticate.Then(common.Welcome(common.QuotaHandler(quotas)))).Methods(http.MethodGet)
+	}
+
+	//once too many of them are being served, requests to the services take turns across tenants
+	limiter, err := common.NewConcurrencyLimiter(s.concurrency)
+	if err != nil {
+		return emperror.Wrap(err, "invalid concurrency limits")
+	}
+
+	if limiter != nil {
+		limitedChain := metered.Append(limiter.Limiter())
+		metered = &limitedChain
+	}
+
+	//interactive requests don't queue behind bulk ones, nor wait for the connections they hold
+	lanes, err := common.NewLanes(s.lanes)
+	if err != nil {
+		return emperror.Wrap(err, "invalid lanes")
+	}
+
+	if lanes != nil {
+		lanedChain := metered.Append(lanes.Limiter())
+		metered = &lanedChain
+	}
+
+	var batchDo func(*http.Request) (*http.Response, error)
+	if s.batchClient != nil {
+		batchDo = s.batchClient.Do
+	}
+
+	//newTransactor builds the component that sends requests to the XMiDT API on behalf of the tr1d1um services
+	newTransactor := func() common.Tr1d1umTransactor {
+		transactorOptions := common.Tr1d1umTransactorOptions{
+			RequestTimeout:        s.requestTimeout,
+			RequestTimeoutBounds:  s.timeoutBounds,
+			RequestHeaders:        s.requestHeaders,
+			ResponseHeaders:       s.responseHeaders,
+			ForwardedHeaderLimits: s.forwardedLimits,
+			UserAgent:             s.userAgent,
+			Measures:              measures,
+			Do: xhttp.RetryTransactor(
+				xhttp.RetryOptions{
+					Logger:   s.logger,
+					Retries:  s.retries,
+					Interval: s.retryInterval,
+				},
+				common.CountAttempts(common.AttemptTimeout(s.attemptTimeout, common.LaneDo(s.client.Do, batchDo)))),
+		}
+
+		primary := common.NewStickyTransactor(common.NewMonitoredTransactor(common.NewTr1d1umTransactor(&transactorOptions), monitor), s.targets, s.targetURL)
+
+		//failures of the secondary backend should not show in the metrics of the primary one
+		transactorOptions.Measures = nil
+		mirror := common.NewTr1d1umTransactor(&transactorOptions)
+
+		transactor := common.NewMirrorTransactor(primary, mirror, s.mirror, s.targetURL, s.logger, measures)
+		transactor = common.NewCanaryTransactor(transactor, canary, s.targetURL, measures)
+		transactor = common.NewTenantTransactor(transactor, s.tenants, s.targetURL)
+
+		//trusted API consumers may select another cluster than the one of their tenant
+		return common.NewClusterTransactor(transactor, s.clusters, s.targetURL)
+	}
+
+	backend, err := common.NewBackend(s.backend, common.BackendOptions{
+		TargetURL:  s.targetURL,
+		Transactor: newTransactor(),
+		WRPFormat:  s.wrpFormat,
+		Config:     s.backendConfig,
+	})
+
+	if err != nil {
+		return emperror.Wrap(err, "invalid backend")
+	}
+
+	//
+	// Webhooks (if not configured, handler for webhooks is not set up)
+	//
+	if s.hooks != nil {
+		s.hooks.APIRouter, s.hooks.RootRouter = APIRouter, s.router
+		s.hooks.Authenticate, s.hooks.Log, s.hooks.M = &authenticate, s.logger, s.registry
+		hooks.ConfigHandler(s.hooks)
+	}
+
+	//
+	// Stat Service
+	//
+	presence := common.NewPresenceCache(s.presence)
+
+	var hintFields stat.HintFields
+	if s.hintFields != nil {
+		hintFields = *s.hintFields
+	}
+
+	ss := stat.NewMetadataService(stat.NewPresenceService(stat.NewService(&stat.ServiceOptions{
+		Backend: backend,
+	}), presence), s.metadata, hintFields)
+
+	//Must be called before translation.ConfigHandler due to mux path specificity (https://github.com/gorilla/mux#matching-routes)
+	stat.ConfigHandler(&stat.Options{
+		S:            ss,
+		APIRouter:    APIRouter,
+		Authenticate: metered,
+		Log:          s.logger,
+		Measures:     measures,
+		Extensions:   s.extensions[StatRoutes],
+		CacheHeaders: s.cacheHeaders,
+		Metadata:     s.metadata,
+
+		ResponseHeaders: s.responseHeaders,
+	})
+
+	//device hints come from the device statistics, unless they are known from the device metadata
+	if s.hintFields != nil {
+		s.translation.Hinter = stat.NewDeviceHinter(ss, hintFields)
+	}
+
+	if s.metadata != nil {
+		s.translation.Hinter = common.NewMetadataHinter(s.translation.Hinter, s.metadata)
+	}
+
+	//
+	// WRP Service
+	//
+	if err = s.translation.StatusMapping.Validate(); err != nil {
+		return emperror.Wrap(err, "invalid status code mapping")
+	}
+
+	if err = s.translation.Groups.Validate(); err != nil {
+		return emperror.Wrap(err, "invalid device groups")
+	}
+
+	if err = s.translation.Profiles.Validate(); err != nil {
+		return emperror.Wrap(err, "invalid parameter profiles")
+	}
+
+	if err = s.translation.Commands.Validate(); err != nil {
+		return emperror.Wrap(err, "invalid commands")
+	}
+
+	if err = s.translation.Firmware.Validate(); err != nil {
+		return emperror.Wrap(err, "invalid firmware configuration")
+	}
+
+	if s.translation.Aliases, err = translation.NewAliases(s.aliases); err != nil {
+		return emperror.Wrap(err, "invalid parameter aliases")
+	}
+
+	if s.translation.DataModel, err = translation.NewDataModel(s.dataModel); err != nil {
+		return emperror.Wrap(err, "invalid data model")
+	}
+
+	if err = s.qosRules.Validate(); err != nil {
+		return emperror.Wrap(err, "invalid QoS rules")
+	}
+
+	signer, err := translation.NewSigner(s.signing)
+	if err != nil {
+		return emperror.Wrap(err, "invalid WRP signing configuration")
+	}
+
+	ts := translation.NewSigningService(translation.NewService(&translation.ServiceOptions{
+		Backend: backend,
+
+		WRPSource: s.wrpSource,
+
+		QoSRules: s.qosRules,
+
+		MaxMessageSize: s.maxWRPSize,
+	}), signer)
+
+	//writes only queue up for their device once they passed the checks which may reject them right away
+	ordered := translation.NewOrderedService(translation.NewMetadataService(ts, s.metadata), s.ordering)
+	s.translation.S = translation.NewCachingService(translation.NewPresenceService(translation.NewBackpressureService(ordered, s.backpressure), presence), s.cache)
+	s.translation.APIRouter = APIRouter
+	s.translation.Authenticate = metered
+	s.translation.Log = s.logger
+	s.translation.ValidServices = s.services
+	s.translation.Measures = measures
+	s.translation.Extensions = s.extensions
+	s.translation.CacheHeaders = s.cacheHeaders
+	s.translation.ResponseHeaders = s.responseHeaders
+
+	translation.ConfigHandler(&s.translation)
+	return nil
+}
+
+//Handler returns the handler of all the routes of the server
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+//Drainer returns the drainer which takes the server out of rotation
+func (s *Server) Drainer() *common.Drainer {
+	return s.drainer
+}
+
+//Start listens on the given address (i.e. ":6100", or ":0" for any free port) and serves requests in the background
+//until Shutdown is called. The address the server listens on is returned
+func (s *Server) Start(address string) (net.Addr, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.certificate != nil {
+		listener = tls.NewListener(listener, &tls.Config{GetCertificate: s.certificate.GetCertificate})
+	}
+
+	s.httpServer = &http.Server{Handler: s.handler}
+	go s.httpServer.Serve(listener)
+
+	return listener.Addr(), nil
+}
+
+//Shutdown gracefully stops a server started by Start
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.httpServer == nil {
+		return nil
+	}
+
+	return s.httpServer.Shutdown(ctx)
+}
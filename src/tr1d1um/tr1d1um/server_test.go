@@ -0,0 +1,238 @@
+package tr1d1um
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/translation"
+
+	"github.com/Comcast/comcast-bascule/bascule"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/justinas/alice"
+	"github.com/stretchr/testify/assert"
+)
+
+const devicePayload = `{"statusCode":200,"parameters":[{"name":"Device.DeviceInfo.SerialNumber","value":"1234","dataType":0,"parameterCount":1,"message":"Success"}]}`
+
+//newXMiDT returns a fake XMiDT API which answers WRP requests with devicePayload
+func newXMiDT(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request wrp.Message
+		data, _ := ioutil.ReadAll(r.Body)
+		assert.Nil(t, wrp.NewDecoderBytes(data, wrp.Msgpack).Decode(&request))
+		assert.Equal(t, "mac:112233445566/config", request.Destination)
+
+		w.Header().Set("Content-Type", wrp.Msgpack.ContentType())
+		w.Write(wrp.MustEncode(&wrp.Message{Type: wrp.SimpleRequestResponseMessageType, Payload: []byte(devicePayload)}, wrp.Msgpack))
+	}))
+}
+
+//authenticated returns a request authenticated, as the authentication chain would, with a token of the given
+//principal holding the given capabilities
+func authenticated(method, target, principal string, capabilities ...string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	return r.WithContext(bascule.WithAuthentication(r.Context(), bascule.Authentication{
+		Token: bascule.NewToken("jwt", principal, bascule.Attributes{common.CapabilitiesClaim: capabilities}),
+	}))
+}
+
+func TestNew(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		xmidt  = newXMiDT(t)
+	)
+
+	defer xmidt.Close()
+
+	s, err := New(WithTargetURL(xmidt.URL), WithServices("config"), WithRetries(0, 0))
+	assert.Nil(err)
+
+	t.Run("Device", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v2/device/mac:112233445566/config?names=Device.DeviceInfo.SerialNumber", nil))
+
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.JSONEq(devicePayload, recorder.Body.String())
+	})
+
+	t.Run("UnsupportedService", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v2/device/mac:112233445566/iot?names=p", nil))
+
+		assert.Equal(http.StatusBadRequest, recorder.Code)
+	})
+}
+
+func TestNewInvalid(t *testing.T) {
+	s, err := New(WithQoSRules(translation.QoSRules{{QoS: 100}}))
+
+	assert.Nil(t, s)
+	assert.NotNil(t, err)
+}
+
+func TestStart(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		xmidt  = newXMiDT(t)
+	)
+
+	defer xmidt.Close()
+
+	s, err := New(WithTargetURL(xmidt.URL), WithServices("config"))
+	assert.Nil(err)
+
+	address, err := s.Start("127.0.0.1:0")
+	assert.Nil(err)
+
+	response, err := http.Get("http://" + address.String() + "/api/v2/device/mac:112233445566/config?names=Device.DeviceInfo.SerialNumber")
+	if assert.Nil(err) {
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+
+		assert.Equal(http.StatusOK, response.StatusCode)
+		assert.JSONEq(devicePayload, string(body))
+	}
+
+	assert.Nil(s.Shutdown(context.Background()))
+}
+
+func TestDrain(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		xmidt  = newXMiDT(t)
+	)
+
+	defer xmidt.Close()
+
+	s, err := New(WithTargetURL(xmidt.URL), WithServices("config"))
+	assert.Nil(err)
+
+	serve := func(method, target string) int {
+		recorder := httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, authenticated(method, target, "operator", common.DefaultAdminCapability))
+		return recorder.Code
+	}
+
+	assert.Equal(http.StatusOK, serve(http.MethodPut, "/api/v2/admin/drain?reject=true"))
+	assert.Equal(http.StatusServiceUnavailable, serve(http.MethodGet, "/ready"))
+	assert.Equal(http.StatusServiceUnavailable, serve(http.MethodGet, "/api/v2/device/mac:112233445566/config?names=Device.DeviceInfo.SerialNumber"))
+
+	//admin routes keep working while draining
+	assert.Equal(http.StatusOK, serve(http.MethodDelete, "/api/v2/admin/drain"))
+	assert.Equal(http.StatusOK, serve(http.MethodGet, "/ready"))
+	assert.Equal(http.StatusOK, serve(http.MethodGet, "/api/v2/device/mac:112233445566/config?names=Device.DeviceInfo.SerialNumber"))
+}
+
+func TestSupportBundle(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		xmidt  = newXMiDT(t)
+	)
+
+	defer xmidt.Close()
+
+	serve := func(s *Server, target string) int {
+		recorder := httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, authenticated(http.MethodGet, target, "operator", common.DefaultAdminCapability))
+		return recorder.Code
+	}
+
+	disabled, err := New(WithTargetURL(xmidt.URL), WithServices("config"))
+	assert.Nil(err)
+	assert.NotEqual(http.StatusOK, serve(disabled, "/api/v2/admin/support"))
+
+	enabled, err := New(WithTargetURL(xmidt.URL), WithServices("config"), WithSupportBundle(common.SupportOptions{Enabled: true}, nil))
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, serve(enabled, "/api/v2/device/mac:112233445566/config?names=Device.DeviceInfo.SerialNumber"))
+	assert.Equal(http.StatusOK, serve(enabled, "/api/v2/admin/support"))
+}
+
+func TestAdminCapability(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		xmidt  = newXMiDT(t)
+	)
+
+	defer xmidt.Close()
+
+	s, err := New(WithTargetURL(xmidt.URL), WithServices("config"), WithSupportBundle(common.SupportOptions{Enabled: true}, nil),
+		WithAdmin(common.AdminOptions{Capability: "tr1d1um:admin", Principals: []string{"operator"}}))
+	assert.Nil(err)
+
+	serve := func(r *http.Request) int {
+		recorder := httptest.NewRecorder()
+		s.Handler().ServeHTTP(recorder, r)
+		return recorder.Code
+	}
+
+	for _, target := range []string{"/api/v2/admin/drain", "/api/v2/admin/support"} {
+		t.Run(target, func(t *testing.T) {
+			assert.Equal(http.StatusForbidden, serve(httptest.NewRequest(http.MethodGet, target, nil)))
+			assert.Equal(http.StatusForbidden, serve(authenticated(http.MethodGet, target, "partner", "x1:webpa:api:.*:all")))
+			assert.Equal(http.StatusForbidden, serve(authenticated(http.MethodGet, target, "partner", common.DefaultAdminCapability)))
+			assert.Equal(http.StatusOK, serve(authenticated(http.MethodGet, target, "engineer", "tr1d1um:admin")))
+			assert.Equal(http.StatusOK, serve(authenticated(http.MethodGet, target, "operator")))
+		})
+	}
+
+	//an ordinary token can't stop the instance from serving everyone else
+	assert.Equal(http.StatusForbidden, serve(authenticated(http.MethodPut, "/api/v2/admin/drain?reject=true", "partner", "x1:webpa:api:.*:all")))
+	assert.Equal(http.StatusOK, serve(httptest.NewRequest(http.MethodGet, "/ready", nil)))
+}
+
+func TestChecksumAfterAuthentication(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		body   = strings.NewReader(`{"parameters":[]}`)
+
+		unauthorized = alice.New(func(http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+			})
+		})
+	)
+
+	s, err := New(WithServices("config"), WithAuth(&unauthorized))
+	assert.Nil(err)
+
+	//bodies sent along with a checksum are not read for API consumers who aren't authenticated
+	r := httptest.NewRequest(http.MethodPatch, "/api/v2/device/mac:112233445566/config", body)
+	r.Header.Set(common.HeaderContentMD5, "1B2M2Y8AsgTpgAmY7PhCfg==")
+
+	recorder := httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, r)
+	assert.Equal(http.StatusUnauthorized, recorder.Code)
+	assert.Equal(body.Size(), int64(body.Len()))
+}
+
+func TestServerSettings(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		xmidt  = newXMiDT(t)
+	)
+
+	defer xmidt.Close()
+
+	//servers of the same process keep their own settings
+	correlating, err := New(WithTargetURL(xmidt.URL), WithServices("config"), WithTransactionIDs(common.TIDOptions{Header: "X-Correlation-Id"}))
+	assert.Nil(err)
+
+	s, err := New(WithTargetURL(xmidt.URL), WithServices("config"))
+	assert.Nil(err)
+
+	for server, header := range map[*Server]string{correlating: "X-Correlation-Id", s: common.HeaderWPATID} {
+		recorder := httptest.NewRecorder()
+		server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v2/device/mac:112233445566/config?names=Device.DeviceInfo.SerialNumber", nil))
+
+		assert.Equal(http.StatusOK, recorder.Code)
+		assert.NotEmpty(recorder.Header().Get(header))
+	}
+
+	_, err = New(WithTransactionIDs(common.TIDOptions{Format: "snowflake"}))
+	assert.NotNil(err)
+}
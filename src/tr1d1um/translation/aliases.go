@@ -0,0 +1,169 @@
+package translation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+)
+
+//ParameterAlias is a friendly name API consumers may use in place of a full TR-181 parameter name
+type ParameterAlias struct {
+	//Alias is the friendly name (i.e. wifi.ssid)
+	Alias string
+
+	//Name is the TR-181 parameter name the alias stands for (i.e. Device.WiFi.SSID.1.SSID)
+	Name string
+}
+
+//Aliases translates between parameter aliases and TR-181 parameter names
+type Aliases struct {
+	names map[string]string
+}
+
+//NewAliases builds Aliases out of the given list. Aliases must be unique and
+//must not collide with TR-181 names (which start with "Device.")
+func NewAliases(aliases []ParameterAlias) (*Aliases, error) {
+	a := &Aliases{names: make(map[string]string, len(aliases))}
+
+	for _, alias := range aliases {
+		switch {
+		case alias.Alias == "" || alias.Name == "":
+			return nil, fmt.Errorf("parameter aliases require both an alias and a name")
+		case strings.HasPrefix(alias.Alias, "Device."):
+			return nil, fmt.Errorf("parameter alias '%s' looks like a TR-181 name", alias.Alias)
+		case a.names[alias.Alias] != "":
+			return nil, fmt.Errorf("parameter alias '%s' is defined more than once", alias.Alias)
+		}
+
+		a.names[alias.Alias] = alias.Name
+	}
+
+	return a, nil
+}
+
+//name returns the TR-181 name for the given alias or the given name itself if it is not an alias
+func (a *Aliases) name(alias string) (string, bool) {
+	if a != nil {
+		if name, ok := a.names[alias]; ok {
+			return name, true
+		}
+	}
+	return alias, false
+}
+
+//expand replaces the aliases in the names or parameters of a GET or SET WDMP with their TR-181 names
+//The payload is returned untouched if it has no aliases
+func (a *Aliases) expand(payload []byte) []byte {
+	if a == nil || len(a.names) == 0 {
+		return payload
+	}
+
+	var document map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&document); err != nil {
+		return payload
+	}
+
+	var expanded bool
+
+	if names, ok := document["names"].([]interface{}); ok {
+		for i, n := range names {
+			if alias, ok := n.(string); ok {
+				if names[i], ok = a.name(alias); ok {
+					expanded = true
+				}
+			}
+		}
+	}
+
+	if parameters, ok := document["parameters"].([]interface{}); ok {
+		for _, p := range parameters {
+			if parameter, ok := p.(map[string]interface{}); ok {
+				if alias, ok := parameter["name"].(string); ok {
+					if parameter["name"], ok = a.name(alias); ok {
+						expanded = true
+					}
+				}
+			}
+		}
+	}
+
+	if !expanded {
+		return payload
+	}
+
+	if p, err := marshalJSON(document); err == nil {
+		return p
+	}
+
+	return payload
+}
+
+type aliasesContextKey struct{}
+
+//captureAliases returns the function that records the aliases (by TR-181 name) a GET request used
+//so that they can be restored in the response
+func captureAliases(a *Aliases) kithttp.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		if a == nil || r.Method != http.MethodGet {
+			return ctx
+		}
+
+		var used = make(map[string]string)
+		for _, alias := range strings.Split(r.URL.Query().Get("names"), ",") {
+			if name, ok := a.name(alias); ok {
+				used[name] = alias
+			}
+		}
+
+		if len(used) == 0 {
+			return ctx
+		}
+
+		return context.WithValue(ctx, aliasesContextKey{}, used)
+	}
+}
+
+//restoreAliases replaces the TR-181 names in a device payload with the aliases the API consumer requested them by
+func restoreAliases(ctx context.Context, payload []byte) []byte {
+	used, ok := ctx.Value(aliasesContextKey{}).(map[string]string)
+	if !ok {
+		return payload
+	}
+
+	var document map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&document); err != nil {
+		return payload
+	}
+
+	parameters, ok := document["parameters"].([]interface{})
+	if !ok {
+		return payload
+	}
+
+	for _, p := range parameters {
+		if parameter, ok := p.(map[string]interface{}); ok {
+			if name, ok := parameter["name"].(string); ok && used[name] != "" {
+				parameter["name"] = used[name]
+			}
+		}
+	}
+
+	if p, err := marshalJSON(document); err == nil {
+		return p
+	}
+
+	return payload
+}
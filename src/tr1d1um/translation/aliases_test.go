@@ -0,0 +1,101 @@
+package translation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAliases() *Aliases {
+	aliases, err := NewAliases([]ParameterAlias{
+		{Alias: "wifi.ssid", Name: "Device.WiFi.SSID.1.SSID"},
+		{Alias: "uptime", Name: "Device.DeviceInfo.UpTime"},
+	})
+
+	if err != nil {
+		panic(err)
+	}
+
+	return aliases
+}
+
+func TestNewAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		aliases []ParameterAlias
+		valid   bool
+	}{
+		{"Empty", nil, true},
+		{"MissingName", []ParameterAlias{{Alias: "a"}}, false},
+		{"TR181Alias", []ParameterAlias{{Alias: "Device.X", Name: "Device.Y"}}, false},
+		{"Duplicate", []ParameterAlias{{Alias: "a", Name: "Device.X"}, {Alias: "a", Name: "Device.Y"}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewAliases(test.aliases)
+			assert.EqualValues(t, test.valid, err == nil)
+		})
+	}
+}
+
+func TestExpandAliases(t *testing.T) {
+	var aliases = newTestAliases()
+
+	t.Run("Get", func(t *testing.T) {
+		assert.JSONEq(t, `{"command":"GET","names":["Device.WiFi.SSID.1.SSID","Device.X"]}`,
+			string(aliases.expand([]byte(`{"command":"GET","names":["wifi.ssid","Device.X"]}`))))
+	})
+
+	t.Run("Set", func(t *testing.T) {
+		assert.JSONEq(t, `{"command":"SET","parameters":[{"name":"Device.DeviceInfo.UpTime","dataType":2,"value":"1"}]}`,
+			string(aliases.expand([]byte(`{"command":"SET","parameters":[{"name":"uptime","dataType":2,"value":"1"}]}`))))
+	})
+
+	t.Run("NoAliases", func(t *testing.T) {
+		payload := `{"command": "GET", "names": ["Device.X"]}`
+		assert.EqualValues(t, payload, string(aliases.expand([]byte(payload))))
+	})
+
+	t.Run("NilAliases", func(t *testing.T) {
+		var nilAliases *Aliases
+		payload := `{"command": "GET", "names": ["wifi.ssid"]}`
+		assert.EqualValues(t, payload, string(nilAliases.expand([]byte(payload))))
+	})
+}
+
+func TestDecodeRequestAliases(t *testing.T) {
+	assert := assert.New(t)
+
+	r := httptest.NewRequest(http.MethodGet, "http://localhost/api/v2/device?names=wifi.ssid", nil)
+	r = mux.SetURLVars(r, map[string]string{"deviceid": "mac:112233445566", "service": "config"})
+
+	decoded, err := decodeRequest(nil, newTestAliases())(ctxTID, r)
+	assert.Nil(err)
+	assert.JSONEq(`{"command":"GET","names":["Device.WiFi.SSID.1.SSID"]}`, string(decoded.(*wrpRequest).WRPMessage.Payload))
+}
+
+func TestRestoreAliases(t *testing.T) {
+	var aliases = newTestAliases()
+
+	t.Run("Restored", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "http://localhost/api/v2/device?names=wifi.ssid,Device.DeviceInfo.UpTime", nil)
+		ctx := captureAliases(aliases)(context.Background(), r)
+
+		payload := `{"parameters":[{"name":"Device.WiFi.SSID.1.SSID","value":"home"},{"name":"Device.DeviceInfo.UpTime","value":"1"}],"statusCode":200}`
+		assert.JSONEq(t, `{"parameters":[{"name":"wifi.ssid","value":"home"},{"name":"Device.DeviceInfo.UpTime","value":"1"}],"statusCode":200}`,
+			string(restoreAliases(ctx, []byte(payload))))
+	})
+
+	t.Run("NotUsed", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "http://localhost/api/v2/device?names=Device.WiFi.SSID.1.SSID", nil)
+		ctx := captureAliases(aliases)(context.Background(), r)
+
+		payload := `{"parameters":[{"name":"Device.WiFi.SSID.1.SSID","value":"home"}],"statusCode":200}`
+		assert.EqualValues(t, payload, string(restoreAliases(ctx, []byte(payload))))
+	})
+}
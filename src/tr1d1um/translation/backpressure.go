@@ -0,0 +1,236 @@
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/device"
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+//Backpressure defaults
+const (
+	DefaultBackpressureCooldown   = 30 * time.Second
+	DefaultBackpressureMaxDevices = 100000
+)
+
+//DefaultBusyStatusCodes are the RDK status codes with which devices report they timed out or were too busy to respond
+var DefaultBusyStatusCodes = []int{http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+//ErrDeviceBackpressure is the message of the error returned to API consumers while requests to an unhealthy device are rejected
+var ErrDeviceBackpressure = errors.New("device is repeatedly failing to respond; retry later")
+
+//BackpressureOptions configures the rejection of requests to devices that repeatedly fail to respond
+type BackpressureOptions struct {
+	//Threshold is the number of consecutive timeout or busy responses from a device after which new requests
+	//to it are rejected. Backpressure is disabled if not positive
+	Threshold int
+
+	//Cooldown is the time new requests to an unhealthy device are rejected for. Defaults to DefaultBackpressureCooldown
+	Cooldown time.Duration
+
+	//BusyStatusCodes are the RDK status codes counted as failures. Defaults to DefaultBusyStatusCodes
+	BusyStatusCodes []int
+
+	//MaxDevices bounds the number of devices tracked. Defaults to DefaultBackpressureMaxDevices
+	MaxDevices int
+}
+
+//backpressureError is the CodedError returned while requests to a device are rejected
+type backpressureError struct {
+	retryAfter time.Duration
+}
+
+func (b *backpressureError) Error() string {
+	return ErrDeviceBackpressure.Error()
+}
+
+func (b *backpressureError) StatusCode() int {
+	return http.StatusTooManyRequests
+}
+
+func (b *backpressureError) ErrorCode() string {
+	return "device_backpressure"
+}
+
+//Headers lets API consumers know when requests to the device will be accepted again
+func (b *backpressureError) Headers() http.Header {
+	return http.Header{"Retry-After": []string{strconv.Itoa(int(math.Ceil(b.retryAfter.Seconds())))}}
+}
+
+type deviceHealth struct {
+	failures    int
+	lastFailure time.Time
+	until       time.Time
+}
+
+//NewBackpressureService decorates the given service so that, once a device has consecutively timed out or reported
+//being busy Threshold times, new requests to it are rejected with a 429 for Cooldown. This keeps retry storms from
+//making an unhealthy device worse. The first request after the cooldown is let through and any success resets the device
+func NewBackpressureService(s Service, o BackpressureOptions) Service {
+	if o.Threshold < 1 {
+		return s
+	}
+
+	if o.Cooldown <= 0 {
+		o.Cooldown = DefaultBackpressureCooldown
+	}
+
+	if len(o.BusyStatusCodes) == 0 {
+		o.BusyStatusCodes = DefaultBusyStatusCodes
+	}
+
+	if o.MaxDevices < 1 {
+		o.MaxDevices = DefaultBackpressureMaxDevices
+	}
+
+	busy := make(map[int]bool, len(o.BusyStatusCodes))
+	for _, code := range o.BusyStatusCodes {
+		busy[code] = true
+	}
+
+	return &backpressureService{
+		Service: s,
+		options: o,
+		busy:    busy,
+		devices: make(map[string]*deviceHealth),
+		now:     time.Now,
+	}
+}
+
+type backpressureService struct {
+	Service
+
+	options BackpressureOptions
+	busy    map[int]bool
+	lock    sync.Mutex
+	devices map[string]*deviceHealth
+	now     func() time.Time
+}
+
+func (b *backpressureService) SendWRP(ctx context.Context, wrpMsg *wrp.Message, authValue string) (*common.XmidtResponse, error) {
+	var deviceID = wrpMsg.Destination
+	if canonicalID, err := device.ParseID(wrpMsg.Destination); err == nil {
+		deviceID = string(canonicalID)
+	}
+
+	if retryAfter := b.rejectFor(deviceID); retryAfter > 0 {
+		return nil, &backpressureError{retryAfter: retryAfter}
+	}
+
+	result, err := b.Service.SendWRP(ctx, wrpMsg, authValue)
+
+	switch {
+	case b.failed(ctx, result, err):
+		b.recordFailure(deviceID)
+	case err == nil:
+		b.recordSuccess(deviceID)
+	}
+
+	return result, err
+}
+
+//rejectFor returns the time requests to the given device are still rejected for
+func (b *backpressureService) rejectFor(deviceID string) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if health, ok := b.devices[deviceID]; ok {
+		return health.until.Sub(b.now())
+	}
+
+	return 0
+}
+
+//failed returns true if the transaction shows the device timed out or was too busy to respond
+func (b *backpressureService) failed(ctx context.Context, result *common.XmidtResponse, err error) bool {
+	if err != nil {
+		timeoutErr, ok := err.(*common.TimeoutError)
+		return ok && timeoutErr.Stage == common.TimeoutStageBackend
+	}
+
+	if result.Code == http.StatusGatewayTimeout {
+		return true
+	}
+
+	if result.Code != http.StatusOK {
+		return false
+	}
+
+	var (
+		wrpModel            wrp.Message
+		deviceResponseModel struct {
+			StatusCode int `json:"statusCode"`
+		}
+	)
+
+	if common.DecodeWRP(result.Body, &wrpModel) != nil {
+		return false
+	}
+
+	if e, errEncoding := wdmpEncoding(ctx); errEncoding == nil && e != nil {
+		if wrpModel.Payload, errEncoding = e.Decode(wrpModel.Payload); errEncoding != nil {
+			return false
+		}
+	}
+
+	return json.Unmarshal(wrpModel.Payload, &deviceResponseModel) == nil && b.busy[deviceResponseModel.StatusCode]
+}
+
+func (b *backpressureService) recordFailure(deviceID string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var now = b.now()
+
+	health, ok := b.devices[deviceID]
+	if !ok {
+		if len(b.devices) >= b.options.MaxDevices {
+			b.sweep(now)
+		}
+
+		if len(b.devices) >= b.options.MaxDevices {
+			return
+		}
+
+		health = new(deviceHealth)
+		b.devices[deviceID] = health
+	}
+
+	//failures too far apart are not a pattern, but devices that were rejected must succeed to recover
+	if health.failures < b.options.Threshold && now.Sub(health.lastFailure) > b.options.Cooldown {
+		health.failures = 0
+	}
+
+	health.failures++
+	health.lastFailure = now
+
+	if health.failures >= b.options.Threshold {
+		health.until = now.Add(b.options.Cooldown)
+	}
+}
+
+func (b *backpressureService) recordSuccess(deviceID string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.devices, deviceID)
+}
+
+//sweep forgets the devices whose failures are no longer relevant
+//callers must hold the lock
+func (b *backpressureService) sweep(now time.Time) {
+	for deviceID, health := range b.devices {
+		if now.Sub(health.lastFailure) > b.options.Cooldown && now.After(health.until) {
+			delete(b.devices, deviceID)
+		}
+	}
+}
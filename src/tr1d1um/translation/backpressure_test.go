@@ -0,0 +1,126 @@
+package translation
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func deviceResponse(t *testing.T, payload string) *common.XmidtResponse {
+	var body []byte
+	assert.Nil(t, wrp.NewEncoderBytes(&body, wrp.Msgpack).Encode(&wrp.Message{Type: wrp.SimpleRequestResponseMessageType, Payload: []byte(payload)}))
+	return &common.XmidtResponse{Code: http.StatusOK, Body: body}
+}
+
+func TestBackpressureServiceDisabled(t *testing.T) {
+	s := new(MockService)
+	assert.Equal(t, s, NewBackpressureService(s, BackpressureOptions{}))
+}
+
+func TestBackpressureService(t *testing.T) {
+	var (
+		s       = new(MockService)
+		now     = time.Now()
+		unwell  = &wrp.Message{Destination: "mac:112233445566/config"}
+		healthy = &wrp.Message{Destination: "mac:665544332211/config"}
+		busy    = deviceResponse(t, `{"statusCode":503}`)
+		ok      = deviceResponse(t, `{"statusCode":200}`)
+
+		backpressure = NewBackpressureService(s, BackpressureOptions{Threshold: 2, Cooldown: 10 * time.Second}).(*backpressureService)
+	)
+
+	backpressure.now = func() time.Time { return now }
+	s.On("SendWRP", mock.Anything, healthy, "").Return(ok, nil)
+
+	t.Run("BelowThreshold", func(t *testing.T) {
+		assert := assert.New(t)
+		s.On("SendWRP", mock.Anything, unwell, "").Return(busy, nil).Once()
+
+		result, err := backpressure.SendWRP(ctxTID, unwell, "")
+		assert.Nil(err)
+		assert.Equal(busy, result)
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		assert := assert.New(t)
+		timeout := &common.TimeoutError{Stage: common.TimeoutStageBackend}
+		s.On("SendWRP", mock.Anything, unwell, "").Return(nil, timeout).Once()
+
+		_, err := backpressure.SendWRP(ctxTID, unwell, "")
+		assert.Equal(timeout, err)
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		assert := assert.New(t)
+		now = now.Add(2500 * time.Millisecond)
+
+		_, err := backpressure.SendWRP(ctxTID, unwell, "")
+		assert.EqualValues(http.StatusTooManyRequests, err.(common.CodedError).StatusCode())
+		s.AssertNumberOfCalls(t, "SendWRP", 2)
+
+		w := httptest.NewRecorder()
+		encodeError(ctxTID, err, w)
+		assert.EqualValues(http.StatusTooManyRequests, w.Code)
+		assert.EqualValues("8", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("OtherDevices", func(t *testing.T) {
+		_, err := backpressure.SendWRP(ctxTID, healthy, "")
+		assert.Nil(t, err)
+	})
+
+	t.Run("StillFailing", func(t *testing.T) {
+		assert := assert.New(t)
+		now = now.Add(10 * time.Second)
+		s.On("SendWRP", mock.Anything, unwell, "").Return(busy, nil).Once()
+
+		_, err := backpressure.SendWRP(ctxTID, unwell, "")
+		assert.Nil(err)
+
+		_, err = backpressure.SendWRP(ctxTID, unwell, "")
+		assert.IsType(&backpressureError{}, err)
+	})
+
+	t.Run("Recovered", func(t *testing.T) {
+		assert := assert.New(t)
+		now = now.Add(10 * time.Second)
+		s.On("SendWRP", mock.Anything, unwell, "").Return(ok, nil)
+
+		_, err := backpressure.SendWRP(ctxTID, unwell, "")
+		assert.Nil(err)
+		assert.Empty(backpressure.devices)
+	})
+}
+
+func TestBackpressureServiceFailed(t *testing.T) {
+	b := NewBackpressureService(new(MockService), BackpressureOptions{Threshold: 1, BusyStatusCodes: []int{531}}).(*backpressureService)
+
+	testCases := []struct {
+		name     string
+		result   *common.XmidtResponse
+		err      error
+		expected bool
+	}{
+		{"BusyDevice", deviceResponse(t, `{"statusCode":531}`), nil, true},
+		{"DeviceError", deviceResponse(t, `{"statusCode":520}`), nil, false},
+		{"NotWDMP", deviceResponse(t, "raw"), nil, false},
+		{"XmidtTimeout", &common.XmidtResponse{Code: http.StatusGatewayTimeout}, nil, true},
+		{"DeviceOffline", &common.XmidtResponse{Code: http.StatusNotFound}, nil, false},
+		{"ConnectTimeout", nil, &common.TimeoutError{Stage: common.TimeoutStageConnect}, false},
+		{"OtherError", nil, errors.New("internal"), false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, b.failed(ctxTID, testCase.result, testCase.err))
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+//MaxBatchQueries is the maximum number of queries a batch may hold
+const MaxBatchQueries = 20
+
+//Batch decoding errors
+var (
+	ErrInvalidBatch   = common.NewBadRequestError(errors.New("invalid batch request body"))
+	ErrMissingQueries = common.NewBadRequestError(errors.New("at least one query is required"))
+	ErrTooManyQueries = common.NewBadRequestError(fmt.Errorf("batches may hold at most %d queries", MaxBatchQueries))
+)
+
+//BatchQuery is a GET on a service of the device
+type BatchQuery struct {
+	Service    string   `json:"service"`
+	Names      []string `json:"names"`
+	Attributes string   `json:"attributes,omitempty"`
+}
+
+//BatchBody is the request body of batches: the queries to send to a single device at once
+type BatchBody struct {
+	Queries []BatchQuery `json:"queries"`
+}
+
+type batchRequest struct {
+	Queries         []BatchQuery
+	WRPMessages     []*wrp.Message
+	AuthHeaderValue string
+}
+
+//batchOutcome is the result of sending one of the queries of a batch
+type batchOutcome struct {
+	Query BatchQuery
+	wrpOutcome
+}
+
+//batchResult is the part of the aggregated batch response for a single query
+type batchResult struct {
+	Service string   `json:"service"`
+	Names   []string `json:"names"`
+	outcomeResult
+}
+
+//decodeBatchRequest returns the function that decodes a batch of queries to a device into a WRP message for each of them
+func decodeBatchRequest(validServices []string, addressing *WRPAddressing, aliases *Aliases) kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		var body BatchBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return nil, ErrInvalidBatch
+		}
+
+		switch {
+		case len(body.Queries) == 0:
+			return nil, ErrMissingQueries
+		case len(body.Queries) > MaxBatchQueries:
+			return nil, ErrTooManyQueries
+		}
+
+		var (
+			tid      = common.RequestTID(ctx)
+			partner  = common.RequestPartner(ctx)
+			deviceID = mux.Vars(r)["deviceid"]
+			request  = &batchRequest{
+				Queries:         body.Queries,
+				AuthHeaderValue: r.Header.Get(authHeaderKey),
+			}
+		)
+
+		for _, query := range body.Queries {
+			if !contains(query.Service, validServices) {
+				return nil, ErrInvalidService
+			}
+
+			payload, err := wdmp.GetPayload(strings.Join(query.Names, ","), query.Attributes)
+			if err != nil {
+				return nil, err
+			}
+
+			wrpMsg, err := wrap(aliases.expand(payload), tid, map[string]string{"deviceid": deviceID, "service": query.Service}, partner, addressing)
+			if err != nil {
+				return nil, err
+			}
+
+			if err = encodeWDMP(ctx, wrpMsg); err != nil {
+				return nil, err
+			}
+
+			request.WRPMessages = append(request.WRPMessages, wrpMsg)
+		}
+
+		return request, nil
+	}
+}
+
+//makeBatchEndpoint sends all the queries of a batch concurrently
+func makeBatchEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		var (
+			batchReq    = request.(*batchRequest)
+			wrpOutcomes = sendAll(ctx, s, batchReq.WRPMessages, batchReq.AuthHeaderValue, len(batchReq.WRPMessages))
+			outcomes    = make([]batchOutcome, len(batchReq.Queries))
+		)
+
+		if ctx.Err() == context.Canceled {
+			return nil, common.ErrClientCanceled
+		}
+
+		for i, query := range batchReq.Queries {
+			outcomes[i] = batchOutcome{Query: query, wrpOutcome: wrpOutcomes[i]}
+		}
+
+		return outcomes, nil
+	}
+}
+
+//encodeBatchResponse returns the function that encodes the results of a batch, in the order of its queries,
+//as a 207 Multi-Status
+func encodeBatchResponse(o *encodeOptions) kithttp.EncodeResponseFunc {
+	o = o.withDefaults()
+
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		var (
+			outcomes = response.([]batchOutcome)
+			results  = make([]batchResult, 0, len(outcomes))
+		)
+
+		for _, outcome := range outcomes {
+			results = append(results, batchResult{
+				Service:       outcome.Query.Service,
+				Names:         outcome.Query.Names,
+				outcomeResult: o.outcomeResult(ctx, outcome.wrpOutcome),
+			})
+		}
+
+		return writeMultiStatus(ctx, w, map[string]interface{}{"results": results})
+	}
+}
@@ -0,0 +1,127 @@
+package translation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func batchHTTPRequest(body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "http://localhost/api/v2/device/mac:112233445566", strings.NewReader(body))
+	r.Header.Set(authHeaderKey, "Basic xyz")
+	return mux.SetURLVars(r, map[string]string{"deviceid": "mac:112233445566"})
+}
+
+func TestDecodeBatchRequest(t *testing.T) {
+	var (
+		decode    = decodeBatchRequest([]string{"config", "iot"}, nil, nil)
+		tooMany   = strings.TrimSuffix(strings.Repeat(`{"service":"config","names":["p"]},`, MaxBatchQueries+1), ",")
+		testCases = []struct {
+			name string
+			body string
+			err  error
+		}{
+			{"InvalidBody", `{"queries":`, ErrInvalidBatch},
+			{"MissingQueries", `{"queries":[]}`, ErrMissingQueries},
+			{"TooManyQueries", fmt.Sprintf(`{"queries":[%s]}`, tooMany), ErrTooManyQueries},
+			{"InvalidService", `{"queries":[{"service":"stat","names":["p"]}]}`, ErrInvalidService},
+			{"EmptyNames", `{"queries":[{"service":"config","names":[]}]}`, ErrEmptyNames},
+		}
+	)
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := decode(ctxTID, batchHTTPRequest(testCase.body))
+			assert.EqualValues(t, testCase.err, err)
+		})
+	}
+
+	t.Run("Ideal", func(t *testing.T) {
+		assert := assert.New(t)
+
+		decoded, err := decode(ctxTID, batchHTTPRequest(`{"queries":[{"service":"config","names":["p0","p1"]},{"service":"iot","names":["p2"],"attributes":"notify"}]}`))
+		assert.Nil(err)
+
+		request := decoded.(*batchRequest)
+		assert.EqualValues("Basic xyz", request.AuthHeaderValue)
+		assert.Len(request.Queries, 2)
+		assert.Len(request.WRPMessages, 2)
+		assert.EqualValues("mac:112233445566/config", request.WRPMessages[0].Destination)
+		assert.EqualValues(`{"command":"GET","names":["p0","p1"]}`, request.WRPMessages[0].Payload)
+		assert.EqualValues("mac:112233445566/iot", request.WRPMessages[1].Destination)
+		assert.EqualValues(`{"command":"GET_ATTRIBUTES","names":["p2"],"attributes":"notify"}`, request.WRPMessages[1].Payload)
+	})
+}
+
+func TestBatchEndpoint(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		s       = new(MockService)
+		request = &batchRequest{
+			Queries:     []BatchQuery{{Service: "config", Names: []string{"p0"}}, {Service: "config", Names: []string{"p1"}}},
+			WRPMessages: []*wrp.Message{{Destination: "p0"}, {Destination: "p1"}},
+		}
+	)
+
+	s.On("SendWRP", mock.Anything, &wrp.Message{Destination: "p0"}, "").Return(&common.XmidtResponse{Code: http.StatusOK}, nil)
+	s.On("SendWRP", mock.Anything, &wrp.Message{Destination: "p1"}, "").Return(nil, errors.New("network"))
+
+	response, err := makeBatchEndpoint(s)(ctxTID, request)
+	assert.Nil(err)
+
+	outcomes := response.([]batchOutcome)
+	assert.Len(outcomes, 2)
+	assert.EqualValues([]string{"p0"}, outcomes[0].Query.Names)
+	assert.EqualValues(http.StatusOK, outcomes[0].Response.Code)
+	assert.NotNil(outcomes[1].Err)
+}
+
+func TestEncodeBatchResponse(t *testing.T) {
+	assert := assert.New(t)
+	recorder := httptest.NewRecorder()
+
+	outcomes := []batchOutcome{
+		{
+			Query: BatchQuery{Service: "config", Names: []string{"p0"}},
+			wrpOutcome: wrpOutcome{Response: &common.XmidtResponse{
+				Code: http.StatusOK,
+				Body: wrp.MustEncode(&wrp.Message{
+					Type:    wrp.SimpleRequestResponseMessageType,
+					Payload: []byte(`{"statusCode": 200, "message": "Success"}`),
+				}, wrp.Msgpack),
+			}},
+		},
+		{
+			Query:      BatchQuery{Service: "config", Names: []string{"p1"}},
+			wrpOutcome: wrpOutcome{Err: &common.TimeoutError{Stage: common.TimeoutStageBackend}},
+		},
+	}
+
+	assert.Nil(encodeBatchResponse(nil)(ctxTID, recorder, outcomes))
+	assert.EqualValues(http.StatusMultiStatus, recorder.Code)
+
+	var body struct {
+		Results []map[string]interface{}
+	}
+
+	assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Len(body.Results, 2)
+	assert.EqualValues("config", body.Results[0]["service"])
+	assert.EqualValues([]interface{}{"p0"}, body.Results[0]["names"])
+	assert.EqualValues(http.StatusOK, body.Results[0]["statusCode"])
+	assert.EqualValues(map[string]interface{}{"statusCode": float64(200), "message": "Success"}, body.Results[0]["response"])
+	assert.EqualValues([]interface{}{"p1"}, body.Results[1]["names"])
+	assert.EqualValues(http.StatusGatewayTimeout, body.Results[1]["statusCode"])
+}
@@ -0,0 +1,238 @@
+package translation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/device"
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+//DefaultCacheMaxEntries is the maximum number of cached responses when none is configured
+const DefaultCacheMaxEntries = 10000
+
+//CacheOptions configures the caching of GET responses
+type CacheOptions struct {
+	//TTL is the time GET responses are cached for. Caching is disabled if not positive
+	TTL time.Duration
+
+	//MaxEntries bounds the number of cached responses. Defaults to DefaultCacheMaxEntries
+	MaxEntries int
+}
+
+type cacheEntry struct {
+	response *common.XmidtResponse
+	deviceID string
+	storedAt time.Time
+}
+
+//pendingDevice tracks the devices with GET requests in flight. Its generation changes when the device does so that
+//the responses read before the change aren't cached after it
+type pendingDevice struct {
+	requests   int
+	generation uint64
+}
+
+//NewCachingService decorates the given service so that successful GET responses are cached for a short time
+//Any other request for a device (i.e. SET, ADD_ROW, REPLACE_ROWS, DELETE_ROW) invalidates the cached responses of the device
+//along with the responses of the GET requests in flight, which are then not cached
+//API consumers may bypass the cache with the Cache-Control: no-cache request header
+//Responses are only shared between the requests made with the same credentials and routed the same way (tenant and
+//cluster) so that no API consumer gets a response it wouldn't have been able to get from XMiDT itself
+func NewCachingService(s Service, o CacheOptions) Service {
+	if o.TTL <= 0 {
+		return s
+	}
+
+	if o.MaxEntries < 1 {
+		o.MaxEntries = DefaultCacheMaxEntries
+	}
+
+	return &cachingService{
+		Service: s,
+		options: o,
+		entries: make(map[string]*cacheEntry),
+		pending: make(map[string]*pendingDevice),
+		now:     time.Now,
+	}
+}
+
+type cachingService struct {
+	Service
+
+	options CacheOptions
+	lock    sync.Mutex
+	entries map[string]*cacheEntry
+	pending map[string]*pendingDevice
+	now     func() time.Time
+}
+
+func (c *cachingService) SendWRP(ctx context.Context, wrpMsg *wrp.Message, authValue string) (*common.XmidtResponse, error) {
+	var deviceID = wrpMsg.Destination
+	if canonicalID, err := device.ParseID(wrpMsg.Destination); err == nil {
+		deviceID = string(canonicalID)
+	}
+
+	if common.RequestMethod(ctx) != http.MethodGet {
+		result, err := c.Service.SendWRP(ctx, wrpMsg, authValue)
+
+		//the device may have changed even if the request failed on our end
+		c.invalidate(deviceID)
+		return result, err
+	}
+
+	var key = cacheKey(ctx, wrpMsg, authValue)
+
+	if !noCache(ctx) {
+		if cached := c.lookup(key); cached != nil {
+			return cached, nil
+		}
+	}
+
+	var generation = c.begin(deviceID)
+
+	result, err := c.Service.SendWRP(ctx, wrpMsg, authValue)
+	if err == nil && result.Code == http.StatusOK {
+		c.store(key, deviceID, generation, result)
+	} else {
+		c.store(key, deviceID, generation, nil)
+	}
+
+	return result, err
+}
+
+//begin records a GET request in flight for the given device and returns the generation of the device
+func (c *cachingService) begin(deviceID string) uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	pending, ok := c.pending[deviceID]
+	if !ok {
+		pending = new(pendingDevice)
+		c.pending[deviceID] = pending
+	}
+
+	pending.requests++
+	return pending.generation
+}
+
+//lookup returns a copy of the fresh response cached under the given key, if any
+func (c *cachingService) lookup(key string) *common.XmidtResponse {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+
+	age := c.now().Sub(entry.storedAt)
+	if age >= c.options.TTL {
+		delete(c.entries, key)
+		return nil
+	}
+
+	cached := *entry.response
+	cached.Latency = 0
+	cached.ForwardedHeaders = make(http.Header, len(entry.response.ForwardedHeaders)+1)
+
+	for name, values := range entry.response.ForwardedHeaders {
+		cached.ForwardedHeaders[name] = values
+	}
+
+	cached.ForwardedHeaders.Set("Age", strconv.Itoa(int(age.Seconds())))
+	return &cached
+}
+
+//store ends a GET request begun at the given generation of the device and caches its response, unless the device
+//changed while it was in flight. A nil response only ends the request
+func (c *cachingService) store(key, deviceID string, generation uint64, response *common.XmidtResponse) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if pending, ok := c.pending[deviceID]; ok {
+		if pending.requests--; pending.requests <= 0 {
+			delete(c.pending, deviceID)
+		}
+
+		if pending.generation != generation {
+			return
+		}
+	}
+
+	if response == nil {
+		return
+	}
+
+	var now = c.now()
+
+	if len(c.entries) >= c.options.MaxEntries {
+		for k, entry := range c.entries {
+			if now.Sub(entry.storedAt) >= c.options.TTL {
+				delete(c.entries, k)
+			}
+		}
+
+		if len(c.entries) >= c.options.MaxEntries {
+			return
+		}
+	}
+
+	c.entries[key] = &cacheEntry{response: response, deviceID: deviceID, storedAt: now}
+}
+
+//invalidate drops the cached responses of the given device
+func (c *cachingService) invalidate(deviceID string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for k, entry := range c.entries {
+		if entry.deviceID == deviceID {
+			delete(c.entries, k)
+		}
+	}
+
+	if pending, ok := c.pending[deviceID]; ok {
+		pending.generation++
+	}
+}
+
+//cacheKey identifies the GET responses which may be shared. The credentials are hashed so that the tokens of API
+//consumers aren't held in memory longer than their requests
+func cacheKey(ctx context.Context, wrpMsg *wrp.Message, authValue string) string {
+	var (
+		inboundHeaders = common.RequestHeaders(ctx)
+		credentials    = sha256.Sum256([]byte(authValue))
+	)
+
+	return strings.Join([]string{
+		hex.EncodeToString(credentials[:]),
+		inboundHeaders.Get(common.HeaderXmidtPartnerID),
+		inboundHeaders.Get(common.HeaderXmidtCluster),
+		wrpMsg.Destination,
+		strings.Join(wrpMsg.PartnerIDs, ","),
+		wrpMsg.ContentType,
+		string(wrpMsg.Payload),
+	}, "\x00")
+}
+
+//noCache returns true if the API consumer asked for a fresh response
+func noCache(ctx context.Context) bool {
+	inboundHeaders := common.RequestHeaders(ctx)
+
+	for _, directive := range strings.Split(inboundHeaders.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+			return true
+		}
+	}
+
+	return false
+}
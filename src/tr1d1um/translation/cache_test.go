@@ -0,0 +1,161 @@
+package translation
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func cacheContext(method string, headers http.Header) context.Context {
+	ctx := context.WithValue(ctxTID, common.ContextKeyRequestMethod, method)
+	return context.WithValue(ctx, common.ContextKeyRequestHeaders, headers)
+}
+
+func TestCachingServiceDisabled(t *testing.T) {
+	s := new(MockService)
+	assert.Equal(t, s, NewCachingService(s, CacheOptions{}))
+}
+
+func TestCachingService(t *testing.T) {
+	var (
+		s   = new(MockService)
+		now = time.Now()
+		get = &wrp.Message{Destination: "mac:112233445566/config", Payload: []byte(`{"command":"GET","names":["p0"]}`)}
+		set = &wrp.Message{Destination: "mac:112233445566/config", Payload: []byte(`{"command":"SET"}`)}
+		ok  = &common.XmidtResponse{Code: http.StatusOK, Body: []byte("p0"), ForwardedHeaders: http.Header{}, Latency: time.Second}
+
+		caching = NewCachingService(s, CacheOptions{TTL: 10 * time.Second}).(*cachingService)
+	)
+
+	caching.now = func() time.Time { return now }
+	s.On("SendWRP", mock.Anything, get, "").Return(ok, nil)
+	s.On("SendWRP", mock.Anything, set, "").Return(ok, nil)
+
+	t.Run("Miss", func(t *testing.T) {
+		assert := assert.New(t)
+		result, err := caching.SendWRP(cacheContext(http.MethodGet, nil), get, "")
+		assert.Nil(err)
+		assert.Equal(ok, result)
+		s.AssertNumberOfCalls(t, "SendWRP", 1)
+	})
+
+	t.Run("Hit", func(t *testing.T) {
+		assert := assert.New(t)
+		now = now.Add(3 * time.Second)
+
+		result, err := caching.SendWRP(cacheContext(http.MethodGet, nil), get, "")
+		assert.Nil(err)
+		assert.EqualValues("p0", result.Body)
+		assert.EqualValues("3", result.ForwardedHeaders.Get("Age"))
+		assert.Zero(result.Latency)
+		assert.Empty(ok.ForwardedHeaders)
+		s.AssertNumberOfCalls(t, "SendWRP", 1)
+	})
+
+	t.Run("NoCache", func(t *testing.T) {
+		caching.SendWRP(cacheContext(http.MethodGet, http.Header{"Cache-Control": []string{"No-Cache"}}), get, "")
+		s.AssertNumberOfCalls(t, "SendWRP", 2)
+	})
+
+	t.Run("Invalidated", func(t *testing.T) {
+		caching.SendWRP(cacheContext(http.MethodPatch, nil), set, "")
+		s.AssertNumberOfCalls(t, "SendWRP", 3)
+
+		caching.SendWRP(cacheContext(http.MethodGet, nil), get, "")
+		s.AssertNumberOfCalls(t, "SendWRP", 4)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		now = now.Add(10 * time.Second)
+		caching.SendWRP(cacheContext(http.MethodGet, nil), get, "")
+		s.AssertNumberOfCalls(t, "SendWRP", 5)
+	})
+}
+
+func TestCachingServiceKey(t *testing.T) {
+	var (
+		s       = new(MockService)
+		get     = &wrp.Message{Destination: "mac:112233445566/config", Payload: []byte(`{"command":"GET","names":["p0"]}`)}
+		ok      = &common.XmidtResponse{Code: http.StatusOK, Body: []byte("p0"), ForwardedHeaders: http.Header{}}
+		caching = NewCachingService(s, CacheOptions{TTL: time.Minute})
+	)
+
+	s.On("SendWRP", mock.Anything, get, mock.Anything).Return(ok, nil)
+
+	caching.SendWRP(cacheContext(http.MethodGet, nil), get, "Bearer a")
+	caching.SendWRP(cacheContext(http.MethodGet, nil), get, "Bearer a")
+	s.AssertNumberOfCalls(t, "SendWRP", 1)
+
+	t.Run("Credentials", func(t *testing.T) {
+		caching.SendWRP(cacheContext(http.MethodGet, nil), get, "Bearer b")
+		s.AssertNumberOfCalls(t, "SendWRP", 2)
+	})
+
+	t.Run("Tenant", func(t *testing.T) {
+		caching.SendWRP(cacheContext(http.MethodGet, http.Header{common.HeaderXmidtPartnerID: []string{"comcast"}}), get, "Bearer a")
+		s.AssertNumberOfCalls(t, "SendWRP", 3)
+	})
+
+	t.Run("Cluster", func(t *testing.T) {
+		caching.SendWRP(cacheContext(http.MethodGet, http.Header{common.HeaderXmidtCluster: []string{"staging"}}), get, "Bearer a")
+		s.AssertNumberOfCalls(t, "SendWRP", 4)
+	})
+}
+
+func TestCachingServiceFailures(t *testing.T) {
+	var (
+		s        = new(MockService)
+		get      = &wrp.Message{Destination: "mac:112233445566/config", Payload: []byte(`{"command":"GET","names":["p0"]}`)}
+		notFound = &common.XmidtResponse{Code: http.StatusNotFound}
+		caching  = NewCachingService(s, CacheOptions{TTL: time.Minute, MaxEntries: 1})
+	)
+
+	s.On("SendWRP", mock.Anything, get, "").Return(notFound, nil)
+
+	caching.SendWRP(cacheContext(http.MethodGet, nil), get, "")
+	caching.SendWRP(cacheContext(http.MethodGet, nil), get, "")
+	s.AssertNumberOfCalls(t, "SendWRP", 2)
+}
+
+func TestCachingServiceInvalidatedInFlight(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		s       = new(MockService)
+		get     = &wrp.Message{Destination: "mac:112233445566/config", Payload: []byte(`{"command":"GET","names":["p0"]}`)}
+		set     = &wrp.Message{Destination: "mac:112233445566/config", Payload: []byte(`{"command":"SET"}`)}
+		stale   = &common.XmidtResponse{Code: http.StatusOK, Body: []byte("before"), ForwardedHeaders: http.Header{}}
+		fresh   = &common.XmidtResponse{Code: http.StatusOK, Body: []byte("after"), ForwardedHeaders: http.Header{}}
+		caching = NewCachingService(s, CacheOptions{TTL: time.Minute}).(*cachingService)
+	)
+
+	//the device changes while its GET request is in flight
+	s.On("SendWRP", mock.Anything, get, "").Return(stale, nil).Once().Run(func(mock.Arguments) {
+		caching.SendWRP(cacheContext(http.MethodPatch, nil), set, "")
+	})
+
+	s.On("SendWRP", mock.Anything, set, "").Return(fresh, nil)
+	s.On("SendWRP", mock.Anything, get, "").Return(fresh, nil)
+
+	result, err := caching.SendWRP(cacheContext(http.MethodGet, nil), get, "")
+	assert.Nil(err)
+	assert.Equal(stale, result)
+
+	//the response read before the change isn't cached
+	result, err = caching.SendWRP(cacheContext(http.MethodGet, nil), get, "")
+	assert.Nil(err)
+	assert.Equal(fresh, result)
+	s.AssertNumberOfCalls(t, "SendWRP", 3)
+
+	result, err = caching.SendWRP(cacheContext(http.MethodGet, nil), get, "")
+	assert.Nil(err)
+	assert.EqualValues("after", result.Body)
+	s.AssertNumberOfCalls(t, "SendWRP", 3)
+	assert.Empty(caching.pending)
+}
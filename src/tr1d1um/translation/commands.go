@@ -0,0 +1,96 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+//Command is a custom WDMP command plugged into the translation service at startup so that experimental device
+//commands can be exposed without changes to the built-in ones
+type Command struct {
+	//Name identifies the command in configuration errors. It is usually the WDMP command it sends
+	Name string
+
+	//Method is the HTTP method the command is bound to
+	Method string
+
+	//Path is the route the command is bound to, relative to the API router. It must have the {deviceid} and {service}
+	//variables (i.e. /device/{deviceid}/{service}/reboot). Commands take precedence over the built-in routes
+	Path string
+
+	//Validate checks the incoming request before its payload is built (optional)
+	Validate func(*http.Request) error
+
+	//Payload builds the JSON WDMP payload sent to the device out of the incoming request
+	Payload func(*http.Request) ([]byte, error)
+}
+
+//Commands are the custom commands of the translation service
+type Commands []Command
+
+//Validate returns an error if any of the commands could not be routed or would not produce a payload
+func (c Commands) Validate() error {
+	var routes = make(map[string]string)
+
+	for _, command := range c {
+		switch {
+		case command.Name == "":
+			return errors.New("custom commands must have a name")
+		case command.Method == "":
+			return fmt.Errorf("custom command '%s' has no method", command.Name)
+		case !strings.Contains(command.Path, "{deviceid}") || !strings.Contains(command.Path, "{service}"):
+			return fmt.Errorf("custom command '%s' path should have the {deviceid} and {service} variables", command.Name)
+		case command.Payload == nil:
+			return fmt.Errorf("custom command '%s' has no payload builder", command.Name)
+		}
+
+		var route = command.Method + " " + command.Path
+		if other, taken := routes[route]; taken {
+			return fmt.Errorf("custom commands '%s' and '%s' are both bound to %s", other, command.Name, route)
+		}
+		routes[route] = command.Name
+	}
+
+	return nil
+}
+
+//decodeCommandRequest returns the function that decodes requests for the given custom command into WRP requests
+func decodeCommandRequest(command Command, addressing *WRPAddressing, aliases *Aliases) kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		if command.Validate != nil {
+			if err := command.Validate(r); err != nil {
+				return nil, err
+			}
+		}
+
+		payload, err := command.Payload(r)
+		if err != nil {
+			return nil, err
+		}
+
+		payload = aliases.expand(payload)
+
+		var wrpMsg *wrp.Message
+		if wrpMsg, err = wrap(payload, common.RequestTID(ctx), mux.Vars(r), common.RequestPartner(ctx), addressing); err != nil {
+			return nil, err
+		}
+
+		if err = encodeWDMP(ctx, wrpMsg); err != nil {
+			return nil, err
+		}
+
+		return &wrpRequest{
+			WRPMessage:      wrpMsg,
+			AuthHeaderValue: r.Header.Get(authHeaderKey),
+		}, nil
+	}
+}
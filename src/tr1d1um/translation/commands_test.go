@@ -0,0 +1,88 @@
+package translation
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var rebootCommand = Command{
+	Name:   "REBOOT",
+	Method: http.MethodPost,
+	Path:   "/device/{deviceid}/{service}/reboot",
+	Validate: func(r *http.Request) error {
+		if r.FormValue("reason") == "" {
+			return common.NewBadRequestError(errors.New("reason is required"))
+		}
+		return nil
+	},
+	Payload: func(r *http.Request) ([]byte, error) {
+		return []byte(`{"command":"REBOOT","reason":"` + r.FormValue("reason") + `"}`), nil
+	},
+}
+
+func TestCommandsValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(Commands{rebootCommand}.Validate())
+	assert.NotNil(Commands{{Method: http.MethodPost, Path: rebootCommand.Path, Payload: rebootCommand.Payload}}.Validate())
+	assert.NotNil(Commands{{Name: "REBOOT", Path: rebootCommand.Path, Payload: rebootCommand.Payload}}.Validate())
+	assert.NotNil(Commands{{Name: "REBOOT", Method: http.MethodPost, Path: "/reboot", Payload: rebootCommand.Payload}}.Validate())
+	assert.NotNil(Commands{{Name: "REBOOT", Method: http.MethodPost, Path: rebootCommand.Path}}.Validate())
+	assert.NotNil(Commands{rebootCommand, rebootCommand}.Validate())
+}
+
+func TestCommandRoutes(t *testing.T) {
+	var (
+		s            = new(MockService)
+		router       = mux.NewRouter()
+		authenticate = alice.New()
+		sent         *wrp.Message
+	)
+
+	s.On("SendWRP", mock.Anything, mock.Anything, "").Run(func(args mock.Arguments) {
+		sent = args.Get(1).(*wrp.Message)
+	}).Return(&common.XmidtResponse{Code: http.StatusAccepted}, nil)
+
+	ConfigHandler(&Options{
+		S:             s,
+		APIRouter:     router.PathPrefix("/api/v2/").Subrouter(),
+		Authenticate:  &authenticate,
+		Log:           logging.DefaultLogger(),
+		ValidServices: []string{"config"},
+		Commands:      Commands{rebootCommand},
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v2/device/mac:112233445566/config/reboot", nil))
+		assert.EqualValues(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("Ideal", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v2/device/mac:112233445566/config/reboot?reason=maintenance", nil))
+		assert.EqualValues(http.StatusAccepted, recorder.Code)
+		assert.EqualValues("mac:112233445566/config", sent.Destination)
+		assert.JSONEq(`{"command":"REBOOT","reason":"maintenance"}`, string(sent.Payload))
+	})
+
+	t.Run("BuiltIn", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/api/v2/device/mac:112233445566/config/Device.Table.1.", nil))
+		assert.EqualValues(t, http.StatusAccepted, recorder.Code)
+		assert.Contains(t, string(sent.Payload), "DELETE_ROW")
+	})
+}
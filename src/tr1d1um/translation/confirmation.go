@@ -0,0 +1,171 @@
+package translation
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+//HeaderWPAConfirmation is the header through which API consumers confirm a destructive table operation
+const HeaderWPAConfirmation = "X-Webpa-Confirmation"
+
+//DefaultConfirmationTTL is the time confirmation tokens are valid for when none is configured
+const DefaultConfirmationTTL = 5 * time.Minute
+
+//ErrInvalidConfirmation is returned for confirmation tokens which are malformed, expired or issued for another request
+var ErrInvalidConfirmation = common.NewBadRequestError(errors.New("invalid or expired confirmation token"))
+
+//Confirmations makes destructive table operations (DELETE_ROW and REPLACE_ROWS) take two steps: the first request
+//is answered with a token describing the rows affected and only the same request carrying the token is executed
+type Confirmations struct {
+	//Secret signs the confirmation tokens so that instances sharing it accept each other's tokens. It is required
+	Secret string
+
+	//TTL is the time confirmation tokens are valid for. Defaults to DefaultConfirmationTTL
+	TTL time.Duration
+}
+
+//confirmer issues and verifies the confirmation tokens of requests
+type confirmer struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+//newConfirmer returns nil if confirmations are not required
+func newConfirmer(c *Confirmations) *confirmer {
+	if c == nil {
+		return nil
+	}
+
+	var o = &confirmer{secret: []byte(c.Secret), ttl: c.TTL, now: time.Now}
+	if o.ttl <= 0 {
+		o.ttl = DefaultConfirmationTTL
+	}
+
+	return o
+}
+
+//confirmationError is the CodedError returned for destructive table operations which have yet to be confirmed
+type confirmationError struct {
+	Token     string    `json:"token"`
+	Expires   time.Time `json:"expires"`
+	Operation string    `json:"operation"`
+	Row       string    `json:"row,omitempty"`
+	Table     string    `json:"table,omitempty"`
+	Rows      *int      `json:"rows,omitempty"`
+}
+
+func (c *confirmationError) Error() string {
+	return "the operation must be confirmed by repeating the request with the " + HeaderWPAConfirmation + " header"
+}
+
+//StatusCode is 428 Precondition Required
+func (c *confirmationError) StatusCode() int {
+	return http.StatusPreconditionRequired
+}
+
+func (c *confirmationError) ErrorCode() string {
+	return "confirmation_required"
+}
+
+//decodeConfirmedRequest returns the function that decodes the destructive table operations of API consumers only once they
+//confirmed them, if confirmations are required. Any other request is decoded as is
+func decodeConfirmedRequest(c *confirmer, decoder kithttp.DecodeRequestFunc) kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		decoded, err := decoder(ctx, r)
+		if err != nil || c == nil || (r.Method != http.MethodDelete && r.Method != http.MethodPut) {
+			return decoded, err
+		}
+
+		var (
+			wrpMsg  = decoded.(*wrpRequest).WRPMessage
+			subject = [][]byte{[]byte(common.RequestPrincipal(ctx)), []byte(r.Method), []byte(wrpMsg.Destination), wrpMsg.Payload}
+		)
+
+		if token := r.Header.Get(HeaderWPAConfirmation); token != "" {
+			if !c.verify(token, subject) {
+				return nil, ErrInvalidConfirmation
+			}
+
+			return decoded, nil
+		}
+
+		return nil, c.confirmation(r, wrpMsg.Payload, subject)
+	}
+}
+
+//confirmation returns the error which describes the rows affected by the given request along with its confirmation token
+func (c *confirmer) confirmation(r *http.Request, payload []byte, subject [][]byte) *confirmationError {
+	var (
+		expires = c.now().Add(c.ttl).Truncate(time.Second)
+		result  = &confirmationError{Expires: expires.UTC(), Token: c.sign(expires, subject)}
+		target  = mux.Vars(r)["parameter"]
+	)
+
+	if r.Method == http.MethodDelete {
+		result.Operation, result.Row = "DELETE_ROW", target
+		return result
+	}
+
+	result.Operation, result.Table = "REPLACE_ROWS", target
+
+	var replacement struct {
+		Rows map[string]interface{} `json:"rows"`
+	}
+
+	//payloads in other WDMP encodings than JSON are not described
+	if json.Unmarshal(payload, &replacement) == nil {
+		var rows = len(replacement.Rows)
+		result.Rows = &rows
+	}
+
+	return result
+}
+
+//sign returns the token which confirms the given request until it expires
+func (c *confirmer) sign(expires time.Time, subject [][]byte) string {
+	var token = make([]byte, 8, 8+sha256.Size)
+	binary.BigEndian.PutUint64(token, uint64(expires.Unix()))
+
+	return base64.RawURLEncoding.EncodeToString(append(token, c.mac(token, subject)...))
+}
+
+//verify returns true if the given token was issued for the given request and has not expired
+func (c *confirmer) verify(token string, subject [][]byte) bool {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(decoded) != 8+sha256.Size {
+		return false
+	}
+
+	if expires := time.Unix(int64(binary.BigEndian.Uint64(decoded[:8])), 0); c.now().After(expires) {
+		return false
+	}
+
+	return hmac.Equal(decoded[8:], c.mac(decoded[:8], subject))
+}
+
+func (c *confirmer) mac(expires []byte, subject [][]byte) []byte {
+	var h = hmac.New(sha256.New, c.secret)
+	h.Write(expires)
+
+	for _, part := range subject {
+		var length = make([]byte, 8)
+		binary.BigEndian.PutUint64(length, uint64(len(part)))
+		h.Write(length)
+		h.Write(part)
+	}
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,95 @@
+package translation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeConfirmedRequest(t *testing.T) {
+	var (
+		now       = time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+		confirmer = newConfirmer(&Confirmations{Secret: "s3cr3t"})
+		payload   = []byte(`{"command":"REPLACE_ROWS","table":"Device.NAT.PortMapping.","rows":{"0":{},"1":{}}}`)
+		decoder   = func(_ context.Context, r *http.Request) (interface{}, error) {
+			return &wrpRequest{WRPMessage: &wrp.Message{Destination: "mac:112233445566/config", Payload: payload}}, nil
+		}
+		decode = decodeConfirmedRequest(confirmer, decoder)
+	)
+
+	confirmer.now = func() time.Time { return now }
+
+	newRequest := func(method, token string) *http.Request {
+		r := mux.SetURLVars(httptest.NewRequest(method, "http://localhost/device/mac:112233445566/config/Device.NAT.PortMapping.", nil),
+			map[string]string{"deviceid": "mac:112233445566", "service": "config", "parameter": "Device.NAT.PortMapping."})
+		if token != "" {
+			r.Header.Set(HeaderWPAConfirmation, token)
+		}
+		return r
+	}
+
+	t.Run("NotRequired", func(t *testing.T) {
+		assert := assert.New(t)
+		assert.Nil(newConfirmer(nil))
+
+		decoded, err := decodeConfirmedRequest(nil, decoder)(context.TODO(), newRequest(http.MethodPut, ""))
+		assert.Nil(err)
+		assert.NotNil(decoded)
+
+		decoded, err = decode(context.TODO(), newRequest(http.MethodGet, ""))
+		assert.Nil(err)
+		assert.NotNil(decoded)
+	})
+
+	t.Run("Confirmed", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := decode(context.TODO(), newRequest(http.MethodPut, ""))
+		assert.IsType(&confirmationError{}, err)
+
+		confirmation := err.(*confirmationError)
+		assert.EqualValues(http.StatusPreconditionRequired, confirmation.StatusCode())
+		assert.EqualValues("REPLACE_ROWS", confirmation.Operation)
+		assert.EqualValues("Device.NAT.PortMapping.", confirmation.Table)
+		assert.EqualValues(2, *confirmation.Rows)
+		assert.EqualValues(now.Add(DefaultConfirmationTTL), confirmation.Expires)
+
+		decoded, err := decode(context.TODO(), newRequest(http.MethodPut, confirmation.Token))
+		assert.Nil(err)
+		assert.NotNil(decoded)
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := decode(context.TODO(), newRequest(http.MethodDelete, ""))
+		assert.EqualValues("DELETE_ROW", err.(*confirmationError).Operation)
+		assert.Nil(err.(*confirmationError).Rows)
+
+		//a token of another operation
+		_, err = decode(context.TODO(), newRequest(http.MethodPut, err.(*confirmationError).Token))
+		assert.Equal(ErrInvalidConfirmation, err)
+
+		_, err = decode(context.TODO(), newRequest(http.MethodPut, "garbage"))
+		assert.Equal(ErrInvalidConfirmation, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := decode(context.TODO(), newRequest(http.MethodDelete, ""))
+		token := err.(*confirmationError).Token
+
+		confirmer.now = func() time.Time { return now.Add(DefaultConfirmationTTL + time.Second) }
+		defer func() { confirmer.now = func() time.Time { return now } }()
+
+		_, err = decode(context.TODO(), newRequest(http.MethodDelete, token))
+		assert.Equal(ErrInvalidConfirmation, err)
+	})
+}
@@ -0,0 +1,144 @@
+package translation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+)
+
+//typesQueryParam is the query parameter through which API consumers ask for the TR-181 data type of each
+//parameter of GET responses (i.e. ?types=true)
+const typesQueryParam = "types"
+
+//instancePlaceholder stands for the instance numbers of table rows in the names of the data model
+const instancePlaceholder = "{i}"
+
+//dataTypes are the TR-181 data types parameters may have
+var dataTypes = []string{"string", "boolean", "int", "unsignedInt", "long", "unsignedLong", "dateTime", "base64", "hexBinary"}
+
+//ParameterType is the TR-181 data type of a parameter of the data model
+type ParameterType struct {
+	//Name is the TR-181 name of the parameter, with {i} in place of instance numbers (i.e. Device.WiFi.SSID.{i}.SSID)
+	Name string
+
+	//Type is the TR-181 data type of the parameter (i.e. unsignedInt)
+	Type string
+}
+
+//DataModel holds the TR-181 data types of parameters so that GET responses can tell API consumers how to render
+//and set their values
+type DataModel struct {
+	types map[string]string
+}
+
+//NewDataModel builds a DataModel out of the given parameter types. Parameters must be defined once and with
+//one of the TR-181 data types
+func NewDataModel(parameters []ParameterType) (*DataModel, error) {
+	d := &DataModel{types: make(map[string]string, len(parameters))}
+
+	for _, parameter := range parameters {
+		switch {
+		case parameter.Name == "" || parameter.Type == "":
+			return nil, fmt.Errorf("data model parameters require both a name and a type")
+		case !contains(parameter.Type, dataTypes):
+			return nil, fmt.Errorf("parameter '%s' has unknown data type '%s'", parameter.Name, parameter.Type)
+		case d.types[parameter.Name] != "":
+			return nil, fmt.Errorf("parameter '%s' is defined more than once in the data model", parameter.Name)
+		}
+
+		d.types[parameter.Name] = parameter.Type
+	}
+
+	return d, nil
+}
+
+//dataType returns the data type of the given parameter, if it is part of the model
+//Instance numbers in the name match the {i} of the model
+func (d *DataModel) dataType(name string) (string, bool) {
+	segments := strings.Split(name, ".")
+	for i, segment := range segments {
+		if _, err := strconv.ParseUint(segment, 10, 32); err == nil && segment != "" {
+			segments[i] = instancePlaceholder
+		}
+	}
+
+	dataType, ok := d.types[strings.Join(segments, ".")]
+	return dataType, ok
+}
+
+type dataModelContextKey struct{}
+
+//captureDataModel returns the function that records in the context that the parameters of the response must be
+//annotated with their data types, if the API consumer asked for them
+func captureDataModel(model *DataModel) kithttp.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		if model == nil || len(model.types) == 0 {
+			return ctx
+		}
+
+		if annotate, _ := strconv.ParseBool(r.URL.Query().Get(typesQueryParam)); !annotate {
+			return ctx
+		}
+
+		return context.WithValue(ctx, dataModelContextKey{}, model)
+	}
+}
+
+//annotateTypes returns the device payload with the data type of the parameters of the model added to them
+//as "type", if required. Payloads which don't hold parameters are returned untouched
+func annotateTypes(ctx context.Context, payload []byte) []byte {
+	model, ok := ctx.Value(dataModelContextKey{}).(*DataModel)
+	if !ok {
+		return payload
+	}
+
+	var (
+		document map[string]interface{}
+		decoder  = json.NewDecoder(bytes.NewReader(payload))
+	)
+
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return payload
+	}
+
+	parameters, ok := document["parameters"].([]interface{})
+	if !ok || !model.annotate(parameters) {
+		return payload
+	}
+
+	if annotated, err := marshalJSON(document); err == nil {
+		return annotated
+	}
+
+	return payload
+}
+
+//annotate adds the data types of the given parameters and of their children. It reports whether any was added
+func (d *DataModel) annotate(parameters []interface{}) (annotated bool) {
+	for _, p := range parameters {
+		parameter, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if children, ok := parameter["value"].([]interface{}); ok {
+			annotated = d.annotate(children) || annotated
+			continue
+		}
+
+		name, _ := parameter["name"].(string)
+		if dataType, ok := d.dataType(name); ok {
+			parameter["type"] = dataType
+			annotated = true
+		}
+	}
+
+	return
+}
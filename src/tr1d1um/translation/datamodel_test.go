@@ -0,0 +1,71 @@
+package translation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDataModel(t *testing.T) {
+	assert := assert.New(t)
+
+	model, err := NewDataModel([]ParameterType{{Name: "Device.WiFi.SSID.{i}.SSID", Type: "string"}})
+	assert.Nil(err)
+	assert.NotNil(model)
+
+	_, err = NewDataModel([]ParameterType{{Name: "Device.WiFi.SSID.{i}.SSID"}})
+	assert.NotNil(err)
+
+	_, err = NewDataModel([]ParameterType{{Name: "Device.WiFi.SSID.{i}.SSID", Type: "text"}})
+	assert.NotNil(err)
+
+	_, err = NewDataModel([]ParameterType{{Name: "Device.WiFi.SSID.{i}.SSID", Type: "string"}, {Name: "Device.WiFi.SSID.{i}.SSID", Type: "string"}})
+	assert.NotNil(err)
+}
+
+func TestAnnotateTypes(t *testing.T) {
+	var (
+		model, _ = NewDataModel([]ParameterType{
+			{Name: "Device.WiFi.SSID.{i}.SSID", Type: "string"},
+			{Name: "Device.WiFi.Radio.{i}.Channel", Type: "unsignedInt"},
+			{Name: "Device.DeviceInfo.UpTime", Type: "unsignedInt"},
+		})
+
+		payload = `{"parameters":[
+			{"name":"Device.WiFi.SSID.1.SSID","value":"home","dataType":0},
+			{"name":"Device.DeviceInfo.Manufacturer","value":"ACME","dataType":0},
+			{"name":"Device.WiFi.Radio.","value":[{"name":"Device.WiFi.Radio.10000.Channel","value":"6","dataType":2}],"dataType":11}],
+			"statusCode":200}`
+
+		annotated = `{"parameters":[
+			{"name":"Device.WiFi.SSID.1.SSID","value":"home","dataType":0,"type":"string"},
+			{"name":"Device.DeviceInfo.Manufacturer","value":"ACME","dataType":0},
+			{"name":"Device.WiFi.Radio.","value":[{"name":"Device.WiFi.Radio.10000.Channel","value":"6","dataType":2,"type":"unsignedInt"}],"dataType":11}],
+			"statusCode":200}`
+	)
+
+	capture := func(model *DataModel, target string) context.Context {
+		return captureDataModel(model)(context.Background(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	t.Run("NotConfigured", func(t *testing.T) {
+		assert.EqualValues(t, payload, annotateTypes(capture(nil, "/?types=true"), []byte(payload)))
+	})
+
+	t.Run("NotRequested", func(t *testing.T) {
+		assert := assert.New(t)
+		assert.EqualValues(payload, annotateTypes(capture(model, "/"), []byte(payload)))
+		assert.EqualValues(payload, annotateTypes(capture(model, "/?types=false"), []byte(payload)))
+	})
+
+	t.Run("Annotated", func(t *testing.T) {
+		assert.JSONEq(t, annotated, string(annotateTypes(capture(model, "/?types=true"), []byte(payload))))
+	})
+
+	t.Run("NoParameters", func(t *testing.T) {
+		assert.EqualValues(t, `{"statusCode":520}`, annotateTypes(capture(model, "/?types=1"), []byte(`{"statusCode":520}`)))
+	})
+}
@@ -0,0 +1,158 @@
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
+
+	"github.com/Comcast/webpa-common/wrp"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+//ErrRebootNotConfirmed is returned for reboot requests without confirm=true so that devices aren't rebooted by mistake
+var ErrRebootNotConfirmed = common.NewCodedError(errors.New("reboots must be confirmed with the confirm=true query parameter"), http.StatusPreconditionRequired)
+
+//Diagnostics configures the convenience routes which send well-known operations to devices so that API consumers
+//don't need to know the parameters behind them:
+//POST /device/{deviceid}/reboot?confirm=true reboots the device, GET /device/{deviceid}/ping checks it responds and
+//POST /device/{deviceid}/nudge prompts a sleepy device to sync
+type Diagnostics struct {
+	//Service is the device service the operations are sent to. Defaults to config
+	Service string
+
+	//RebootParameter and RebootValue make up the SET which reboots devices.
+	//Default to Device.X_CISCO_COM_DeviceControl.RebootDevice and Device
+	RebootParameter string
+	RebootValue     string
+
+	//PingParameters are the parameters read to check that devices respond. Defaults to Device.DeviceInfo.UpTime
+	PingParameters []string
+
+	//NudgeService and NudgePayload make up the event which prompts devices to sync. As devices don't respond to
+	//events, nudges return as soon as the XMiDT API accepted them. Default to Service and {"event":"nudge"}
+	NudgeService string
+	NudgePayload string
+}
+
+func (d *Diagnostics) withDefaults() Diagnostics {
+	var o Diagnostics
+	if d != nil {
+		o = *d
+	}
+
+	if o.Service == "" {
+		o.Service = "config"
+	}
+
+	if o.RebootParameter == "" {
+		o.RebootParameter = "Device.X_CISCO_COM_DeviceControl.RebootDevice"
+	}
+
+	if o.RebootValue == "" {
+		o.RebootValue = "Device"
+	}
+
+	if len(o.PingParameters) == 0 {
+		o.PingParameters = []string{"Device.DeviceInfo.UpTime"}
+	}
+
+	if o.NudgeService == "" {
+		o.NudgeService = o.Service
+	}
+
+	if o.NudgePayload == "" {
+		o.NudgePayload = `{"event":"nudge"}`
+	}
+
+	return o
+}
+
+//rebootPayload builds the WDMP SET which reboots a device, once the request is confirmed
+func (d Diagnostics) rebootPayload(r *http.Request) ([]byte, error) {
+	if r.URL.Query().Get("confirm") != "true" {
+		return nil, ErrRebootNotConfirmed
+	}
+
+	var dataType int8
+	return json.Marshal(&wdmp.SetRequest{
+		Command:    wdmp.CommandSet,
+		Parameters: []wdmp.SetParam{{Name: &d.RebootParameter, DataType: &dataType, Value: d.RebootValue}},
+	})
+}
+
+//pingPayload builds the WDMP GET of the ping parameters
+func (d Diagnostics) pingPayload(_ *http.Request) ([]byte, error) {
+	return wdmp.GetPayload(strings.Join(d.PingParameters, ","), "")
+}
+
+//decodeDiagnosticRequest returns the function that decodes requests for a well-known device operation
+//(i.e. reboot, ping or firmware download) into WRP requests to the given service
+func decodeDiagnosticRequest(service string, payload func(*http.Request) ([]byte, error), addressing *WRPAddressing) kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		p, err := payload(r)
+		if err != nil {
+			return nil, err
+		}
+
+		var vars = map[string]string{"deviceid": mux.Vars(r)["deviceid"], "service": service}
+
+		wrpMsg, err := wrap(p, common.RequestTID(ctx), vars, common.RequestPartner(ctx), addressing)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = encodeWDMP(ctx, wrpMsg); err != nil {
+			return nil, err
+		}
+
+		return &wrpRequest{
+			WRPMessage:      wrpMsg,
+			AuthHeaderValue: r.Header.Get(authHeaderKey),
+		}, nil
+	}
+}
+
+//decodeNudgeRequest returns the function that decodes nudge requests into the WRP event which prompts devices to
+//sync. Unlike other operations, its payload is sent as is rather than as a WDMP document
+func decodeNudgeRequest(d Diagnostics, addressing *WRPAddressing) kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		var vars = map[string]string{"deviceid": mux.Vars(r)["deviceid"], "service": d.NudgeService}
+
+		wrpMsg, err := wrap([]byte(d.NudgePayload), common.RequestTID(ctx), vars, common.RequestPartner(ctx), addressing)
+		if err != nil {
+			return nil, err
+		}
+
+		wrpMsg.Type, wrpMsg.ContentType = wrp.SimpleEventMessageType, "application/json"
+
+		return &wrpRequest{
+			WRPMessage:      wrpMsg,
+			AuthHeaderValue: r.Header.Get(authHeaderKey),
+		}, nil
+	}
+}
+
+//encodeNudgeResponse returns the function that reports the nudges the XMiDT API accepted with a 202, as there is no
+//device response to relay. Failures are encoded like any other response
+func encodeNudgeResponse(o *encodeOptions) kithttp.EncodeResponseFunc {
+	var encode = encodeResponse(o)
+
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		if response.(*common.XmidtResponse).Code != http.StatusOK {
+			return encode(ctx, w, response)
+		}
+
+		w.Header().Set(contentTypeHeaderKey, "application/json; charset=utf-8")
+		w.Header().Set(common.TIDHeader(ctx), common.RequestTID(ctx))
+		w.WriteHeader(http.StatusAccepted)
+
+		_, err := w.Write([]byte(`{"message":"nudge sent"}`))
+		return err
+	}
+}
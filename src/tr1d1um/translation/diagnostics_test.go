@@ -0,0 +1,95 @@
+package translation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDiagnosticRoutes(t *testing.T) {
+	var (
+		s            = new(MockService)
+		router       = mux.NewRouter()
+		authenticate = alice.New()
+		sent         *wrp.Message
+		uptime       = `{"statusCode":200,"parameters":[{"name":"Device.DeviceInfo.UpTime","value":"123","dataType":2}]}`
+	)
+
+	s.On("SendWRP", mock.Anything, mock.Anything, "").Run(func(args mock.Arguments) {
+		sent = args.Get(1).(*wrp.Message)
+	}).Return(&common.XmidtResponse{
+		Code: http.StatusOK,
+		Body: wrp.MustEncode(&wrp.Message{Type: wrp.SimpleRequestResponseMessageType, Payload: []byte(uptime)}, wrp.Msgpack),
+	}, nil)
+
+	ConfigHandler(&Options{
+		S:             s,
+		APIRouter:     router.PathPrefix("/api/v2/").Subrouter(),
+		Authenticate:  &authenticate,
+		Log:           logging.DefaultLogger(),
+		ValidServices: []string{"config"},
+		Diagnostics:   &Diagnostics{RebootValue: "Router"},
+	})
+
+	t.Run("RebootNotConfirmed", func(t *testing.T) {
+		assert := assert.New(t)
+		sent = nil
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v2/device/mac:112233445566/reboot", nil))
+		assert.EqualValues(http.StatusPreconditionRequired, recorder.Code)
+		assert.Nil(sent)
+	})
+
+	t.Run("Reboot", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v2/device/mac:112233445566/reboot?confirm=true", nil))
+		assert.EqualValues(http.StatusOK, recorder.Code)
+		assert.EqualValues("mac:112233445566/config", sent.Destination)
+		assert.JSONEq(`{"command":"SET","parameters":[{"name":"Device.X_CISCO_COM_DeviceControl.RebootDevice","dataType":0,"value":"Router"}]}`, string(sent.Payload))
+	})
+
+	t.Run("Ping", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v2/device/mac:112233445566/ping", nil))
+		assert.EqualValues(http.StatusOK, recorder.Code)
+		assert.EqualValues("mac:112233445566/config", sent.Destination)
+		assert.JSONEq(`{"command":"GET","names":["Device.DeviceInfo.UpTime"]}`, string(sent.Payload))
+		assert.JSONEq(uptime, recorder.Body.String())
+	})
+
+	t.Run("Nudge", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v2/device/mac:112233445566/nudge", nil))
+		assert.EqualValues(http.StatusAccepted, recorder.Code)
+		assert.EqualValues(wrp.SimpleEventMessageType, sent.Type)
+		assert.EqualValues("mac:112233445566/config", sent.Destination)
+		assert.JSONEq(`{"event":"nudge"}`, string(sent.Payload))
+		assert.JSONEq(`{"message":"nudge sent"}`, recorder.Body.String())
+	})
+}
+
+func TestEncodeNudgeResponse(t *testing.T) {
+	assert := assert.New(t)
+	recorder := httptest.NewRecorder()
+
+	err := encodeNudgeResponse(nil)(ctxTID, recorder, &common.XmidtResponse{Code: http.StatusNotFound, Body: []byte("device not found")})
+	assert.Nil(err)
+	assert.EqualValues(http.StatusNotFound, recorder.Code)
+	assert.EqualValues("device not found", recorder.Body.String())
+}
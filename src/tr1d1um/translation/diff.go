@@ -0,0 +1,281 @@
+package translation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
+
+	"github.com/Comcast/webpa-common/device"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+//Diff decoding errors
+var (
+	ErrInvalidDiff        = common.NewBadRequestError(errors.New("invalid diff request body"))
+	ErrDiffBaseline       = common.NewBadRequestError(errors.New("either a baseline document or a device to compare against is required"))
+	ErrInvalidDiffAgainst = common.NewBadRequestError(errors.New("invalid device to compare against"))
+)
+
+//DiffBody is the request body of diffs: the parameters to get from the device and what to compare them with
+type DiffBody struct {
+	Names []string `json:"names"`
+
+	//Against is the ID of the device the parameters are compared with
+	Against string `json:"against,omitempty"`
+
+	//Baseline is the document the parameters are compared with: either a GET response ({"parameters": [...]})
+	//or an object of parameter names to values
+	Baseline json.RawMessage `json:"baseline,omitempty"`
+}
+
+type diffRequest struct {
+	DeviceID        string
+	Against         string
+	Baseline        map[string]interface{}
+	WRPMessages     []*wrp.Message
+	AuthHeaderValue string
+}
+
+//diffOutcome holds the outcomes of the GETs of a diff
+type diffOutcome struct {
+	Request  *diffRequest
+	Outcomes []wrpOutcome
+}
+
+//ParameterChange is a parameter whose value differs from the baseline
+type ParameterChange struct {
+	Name     string      `json:"name"`
+	Value    interface{} `json:"value,omitempty"`
+	Baseline interface{} `json:"baseline,omitempty"`
+}
+
+//ParameterDiff is the structured diff of the parameter values of a device against a baseline
+type ParameterDiff struct {
+	//Changed are the parameters whose values differ
+	Changed []ParameterChange `json:"changed"`
+
+	//Added are the parameters the device has but the baseline doesn't
+	Added []ParameterChange `json:"added"`
+
+	//Removed are the parameters the baseline has but the device doesn't
+	Removed []ParameterChange `json:"removed"`
+
+	//Unchanged is the number of parameters with the same value
+	Unchanged int `json:"unchanged"`
+}
+
+//decodeDiffRequest returns the function that decodes a diff into the GETs of the parameters of the device
+//and, if compared against another device, of the parameters of that device
+func decodeDiffRequest(validServices []string, addressing *WRPAddressing, aliases *Aliases) kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		var body DiffBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return nil, ErrInvalidDiff
+		}
+
+		var (
+			vars    = mux.Vars(r)
+			tid     = common.RequestTID(ctx)
+			partner = common.RequestPartner(ctx)
+			request = &diffRequest{
+				DeviceID:        vars["deviceid"],
+				AuthHeaderValue: r.Header.Get(authHeaderKey),
+			}
+			devices = []string{vars["deviceid"]}
+		)
+
+		if !contains(vars["service"], validServices) {
+			return nil, ErrInvalidService
+		}
+
+		switch {
+		case (body.Against == "") == (len(body.Baseline) == 0):
+			return nil, ErrDiffBaseline
+		case body.Against != "":
+			if _, err := device.ParseID(body.Against); err != nil {
+				return nil, ErrInvalidDiffAgainst
+			}
+
+			request.Against = body.Against
+			devices = append(devices, body.Against)
+		default:
+			baseline, err := flattenBaseline(body.Baseline)
+			if err != nil {
+				return nil, ErrInvalidDiff
+			}
+
+			request.Baseline = baseline
+		}
+
+		payload, err := wdmp.GetPayload(strings.Join(body.Names, ","), "")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, deviceID := range devices {
+			wrpMsg, err := wrap(aliases.expand(payload), tid, map[string]string{"deviceid": deviceID, "service": vars["service"]}, partner, addressing)
+			if err != nil {
+				return nil, err
+			}
+
+			if err = encodeWDMP(ctx, wrpMsg); err != nil {
+				return nil, err
+			}
+
+			request.WRPMessages = append(request.WRPMessages, wrpMsg)
+		}
+
+		return request, nil
+	}
+}
+
+//makeDiffEndpoint sends the GETs of a diff concurrently
+func makeDiffEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		var (
+			diffReq  = request.(*diffRequest)
+			outcomes = sendAll(ctx, s, diffReq.WRPMessages, diffReq.AuthHeaderValue, len(diffReq.WRPMessages))
+		)
+
+		if ctx.Err() == context.Canceled {
+			return nil, common.ErrClientCanceled
+		}
+
+		return &diffOutcome{Request: diffReq, Outcomes: outcomes}, nil
+	}
+}
+
+//encodeDiffResponse returns the function that encodes the diff of the parameters of the device against the baseline
+//If any of the GETs failed, its failure is reported instead
+func encodeDiffResponse(o *encodeOptions) kithttp.EncodeResponseFunc {
+	o = o.withDefaults()
+
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) (err error) {
+		var (
+			outcome   = response.(*diffOutcome)
+			documents = make([]map[string]interface{}, len(outcome.Outcomes))
+			deviceIDs = []string{outcome.Request.DeviceID, outcome.Request.Against}
+		)
+
+		for i, wrpOutcome := range outcome.Outcomes {
+			result := o.outcomeResult(ctx, wrpOutcome)
+			if result.StatusCode != http.StatusOK {
+				message := result.Message
+				if message == "" {
+					message = fmt.Sprintf("%v", result.Response)
+				}
+
+				return common.NewCodedError(fmt.Errorf("GET of %s failed: %s", deviceIDs[i], message), result.StatusCode)
+			}
+
+			raw, _ := result.Response.(json.RawMessage)
+			if documents[i], err = flattenBaseline(raw); err != nil {
+				return ErrMalformedUpstreamResponse
+			}
+		}
+
+		baseline := outcome.Request.Baseline
+		if len(documents) > 1 {
+			baseline = documents[1]
+		}
+
+		w.Header().Set(contentTypeHeaderKey, "application/json; charset=utf-8")
+		w.Header().Set(common.TIDHeader(ctx), common.RequestTID(ctx))
+		reportWDMPVersion(ctx, w.Header())
+
+		if err = json.NewEncoder(w).Encode(diffParameters(documents[0], baseline)); err != nil && ctx.Err() == context.Canceled {
+			err = common.ErrClientCanceled
+		}
+
+		return
+	}
+}
+
+//flattenBaseline turns a GET response or an object of parameter names to values into the values of the leaf parameters
+func flattenBaseline(raw json.RawMessage) (map[string]interface{}, error) {
+	var (
+		document map[string]interface{}
+		decoder  = json.NewDecoder(bytes.NewReader(raw))
+	)
+
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil || document == nil {
+		return nil, errors.New("baseline should be a JSON object")
+	}
+
+	parameters, ok := document["parameters"].([]interface{})
+	if !ok {
+		return document, nil
+	}
+
+	var values = make(map[string]interface{})
+	flattenParameters(parameters, values)
+	return values, nil
+}
+
+//flattenParameters collects the values of the given parameters and of their children by name
+func flattenParameters(parameters []interface{}, values map[string]interface{}) {
+	for _, p := range parameters {
+		parameter, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, _ := parameter["name"].(string)
+		if children, ok := parameter["value"].([]interface{}); ok {
+			flattenParameters(children, values)
+			continue
+		}
+
+		if value, ok := parameter["value"]; ok && name != "" {
+			values[name] = value
+		}
+	}
+}
+
+//diffParameters compares the values of the device parameters with the baseline ones, by name
+//Values are compared by their text so that i.e. "true" and true are the same
+func diffParameters(values, baseline map[string]interface{}) ParameterDiff {
+	var diff = ParameterDiff{
+		Changed: []ParameterChange{},
+		Added:   []ParameterChange{},
+		Removed: []ParameterChange{},
+	}
+
+	for name, value := range values {
+		baselineValue, ok := baseline[name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, ParameterChange{Name: name, Value: value})
+		case fmt.Sprint(value) != fmt.Sprint(baselineValue):
+			diff.Changed = append(diff.Changed, ParameterChange{Name: name, Value: value, Baseline: baselineValue})
+		default:
+			diff.Unchanged++
+		}
+	}
+
+	for name, baselineValue := range baseline {
+		if _, ok := values[name]; !ok {
+			diff.Removed = append(diff.Removed, ParameterChange{Name: name, Baseline: baselineValue})
+		}
+	}
+
+	for _, changes := range [][]ParameterChange{diff.Changed, diff.Added, diff.Removed} {
+		sort.Slice(changes, func(i, j int) bool {
+			return changes[i].Name < changes[j].Name
+		})
+	}
+
+	return diff
+}
@@ -0,0 +1,132 @@
+package translation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func diffHTTPRequest(body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "http://localhost/api/v2/device/mac:112233445566/config/diff", strings.NewReader(body))
+	r.Header.Set(authHeaderKey, "Basic xyz")
+	return mux.SetURLVars(r, map[string]string{"deviceid": "mac:112233445566", "service": "config"})
+}
+
+func diffResponse(payload string) wrpOutcome {
+	return wrpOutcome{Response: &common.XmidtResponse{
+		Code: http.StatusOK,
+		Body: wrp.MustEncode(&wrp.Message{Type: wrp.SimpleRequestResponseMessageType, Payload: []byte(payload)}, wrp.Msgpack),
+	}}
+}
+
+func TestDecodeDiffRequest(t *testing.T) {
+	var (
+		decode    = decodeDiffRequest([]string{"config"}, nil, nil)
+		testCases = []struct {
+			name string
+			body string
+			err  error
+		}{
+			{"InvalidBody", `{"names":`, ErrInvalidDiff},
+			{"NoBaseline", `{"names":["p"]}`, ErrDiffBaseline},
+			{"BothBaselines", `{"names":["p"],"against":"mac:665544332211","baseline":{"p":"v"}}`, ErrDiffBaseline},
+			{"InvalidAgainst", `{"names":["p"],"against":"nope"}`, ErrInvalidDiffAgainst},
+			{"InvalidBaseline", `{"names":["p"],"baseline":[1]}`, ErrInvalidDiff},
+			{"EmptyNames", `{"names":[],"baseline":{"p":"v"}}`, ErrEmptyNames},
+		}
+	)
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := decode(ctxTID, diffHTTPRequest(testCase.body))
+			assert.EqualValues(t, testCase.err, err)
+		})
+	}
+
+	t.Run("Against", func(t *testing.T) {
+		assert := assert.New(t)
+
+		decoded, err := decode(ctxTID, diffHTTPRequest(`{"names":["p0","p1"],"against":"mac:665544332211"}`))
+		assert.Nil(err)
+
+		request := decoded.(*diffRequest)
+		assert.Len(request.WRPMessages, 2)
+		assert.EqualValues("mac:112233445566/config", request.WRPMessages[0].Destination)
+		assert.EqualValues("mac:665544332211/config", request.WRPMessages[1].Destination)
+		assert.EqualValues(`{"command":"GET","names":["p0","p1"]}`, request.WRPMessages[1].Payload)
+	})
+
+	t.Run("Baseline", func(t *testing.T) {
+		assert := assert.New(t)
+
+		decoded, err := decode(ctxTID, diffHTTPRequest(`{"names":["p0"],"baseline":{"parameters":[{"name":"p0","value":"v","dataType":0}]}}`))
+		assert.Nil(err)
+
+		request := decoded.(*diffRequest)
+		assert.Len(request.WRPMessages, 1)
+		assert.EqualValues(map[string]interface{}{"p0": "v"}, request.Baseline)
+	})
+}
+
+func TestEncodeDiffResponse(t *testing.T) {
+	device := diffResponse(`{"parameters":[
+		{"name":"Device.WiFi.","value":[
+			{"name":"Device.WiFi.SSID.1.SSID","value":"home","dataType":0},
+			{"name":"Device.WiFi.SSID.1.Enable","value":"true","dataType":3},
+			{"name":"Device.WiFi.SSID.2.SSID","value":"guest","dataType":0}],"dataType":11}],
+		"statusCode":200}`)
+
+	t.Run("Baseline", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		outcome := &diffOutcome{
+			Request: &diffRequest{
+				DeviceID: "mac:112233445566",
+				Baseline: map[string]interface{}{"Device.WiFi.SSID.1.SSID": "office", "Device.WiFi.SSID.1.Enable": true, "Device.WiFi.SSID.3.SSID": "iot"},
+			},
+			Outcomes: []wrpOutcome{device},
+		}
+
+		assert.Nil(encodeDiffResponse(nil)(ctxTID, recorder, outcome))
+		assert.EqualValues(http.StatusOK, recorder.Code)
+		assert.JSONEq(`{
+			"changed":[{"name":"Device.WiFi.SSID.1.SSID","value":"home","baseline":"office"}],
+			"added":[{"name":"Device.WiFi.SSID.2.SSID","value":"guest"}],
+			"removed":[{"name":"Device.WiFi.SSID.3.SSID","baseline":"iot"}],
+			"unchanged":1}`, recorder.Body.String())
+	})
+
+	t.Run("Against", func(t *testing.T) {
+		assert := assert.New(t)
+		recorder := httptest.NewRecorder()
+
+		outcome := &diffOutcome{
+			Request:  &diffRequest{DeviceID: "mac:112233445566", Against: "mac:665544332211"},
+			Outcomes: []wrpOutcome{device, device},
+		}
+
+		assert.Nil(encodeDiffResponse(nil)(ctxTID, recorder, outcome))
+		assert.JSONEq(`{"changed":[],"added":[],"removed":[],"unchanged":3}`, recorder.Body.String())
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		assert := assert.New(t)
+
+		outcome := &diffOutcome{
+			Request:  &diffRequest{DeviceID: "mac:112233445566", Against: "mac:665544332211"},
+			Outcomes: []wrpOutcome{device, {Err: &common.TimeoutError{Stage: common.TimeoutStageBackend}}},
+		}
+
+		err := encodeDiffResponse(nil)(ctxTID, httptest.NewRecorder(), outcome)
+		assert.EqualValues(http.StatusGatewayTimeout, err.(common.CodedError).StatusCode())
+		assert.Contains(err.Error(), "mac:665544332211")
+	})
+}
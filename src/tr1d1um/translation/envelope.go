@@ -0,0 +1,107 @@
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/device"
+	"github.com/gorilla/mux"
+)
+
+//API consumers ask for enveloped responses either through the envelope query parameter (i.e. ?envelope=true)
+//or through the profile parameter of an accepted media type (i.e. Accept: application/json; profile=envelope)
+const (
+	envelopeQueryParam = "envelope"
+	envelopeProfile    = "envelope"
+)
+
+type envelopeContextKey struct{}
+
+//envelope wraps a device payload with metadata about the transaction that produced it
+type envelope struct {
+	TID              string      `json:"tid"`
+	DeviceID         string      `json:"deviceId"`
+	StatusCode       int         `json:"statusCode"`
+	BackendLatencyMs float64     `json:"backendLatencyMs"`
+	ReceivedAt       *time.Time  `json:"receivedAt,omitempty"`
+	RespondedAt      time.Time   `json:"respondedAt"`
+	Payload          interface{} `json:"payload"`
+
+	//BackendSentAt and BackendRespondedAt are the times the request was sent to the XMiDT API and it responded,
+	//which is before the request was received for responses served from cache
+	BackendSentAt      *time.Time `json:"backendSentAt,omitempty"`
+	BackendRespondedAt *time.Time `json:"backendRespondedAt,omitempty"`
+}
+
+//captureEnvelope marks the context of requests that ask for an enveloped response
+func captureEnvelope(ctx context.Context, r *http.Request) context.Context {
+	if !wantsEnvelope(r) {
+		return ctx
+	}
+
+	var deviceID = mux.Vars(r)["deviceid"]
+	if canonicalDeviceID, err := device.ParseID(deviceID); err == nil {
+		deviceID = string(canonicalDeviceID)
+	}
+
+	return context.WithValue(ctx, envelopeContextKey{}, &envelope{DeviceID: deviceID})
+}
+
+func wantsEnvelope(r *http.Request) bool {
+	if requested, err := strconv.ParseBool(r.URL.Query().Get(envelopeQueryParam)); err == nil {
+		return requested
+	}
+
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		if _, params, err := mime.ParseMediaType(accepted); err == nil && params["profile"] == envelopeProfile {
+			return true
+		}
+	}
+
+	return false
+}
+
+//wrapInEnvelope returns the given device payload wrapped in an envelope if one was requested
+//rdkCode is the status code reported by the device
+func wrapInEnvelope(ctx context.Context, resp *common.XmidtResponse, rdkCode int, payload []byte) ([]byte, error) {
+	requested, ok := ctx.Value(envelopeContextKey{}).(*envelope)
+	if !ok {
+		return payload, nil
+	}
+
+	e := *requested
+	e.TID = common.RequestTID(ctx)
+	e.StatusCode = rdkCode
+	e.BackendLatencyMs = float64(resp.Latency) / float64(time.Millisecond)
+	e.RespondedAt = time.Now().UTC()
+
+	if receivedAt, ok := common.RequestArrivalTime(ctx); ok {
+		e.ReceivedAt = utcTime(receivedAt)
+	}
+
+	if !resp.SentAt.IsZero() {
+		e.BackendSentAt = utcTime(resp.SentAt)
+	}
+
+	if !resp.RespondedAt.IsZero() {
+		e.BackendRespondedAt = utcTime(resp.RespondedAt)
+	}
+
+	//devices are expected to respond with JSON but anything else is still delivered
+	e.Payload = jsonOrString(payload)
+
+	return json.Marshal(&e)
+}
+
+//utcTime returns a pointer to the given time, in UTC
+func utcTime(t time.Time) *time.Time {
+	t = t.UTC()
+	return &t
+}
@@ -0,0 +1,102 @@
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWantsEnvelope(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		accept   string
+		expected bool
+	}{
+		{"NotRequested", "http://localhost/device", "application/json", false},
+		{"QueryParam", "http://localhost/device?envelope=true", "", true},
+		{"QueryParamOverridesProfile", "http://localhost/device?envelope=false", "application/json; profile=envelope", false},
+		{"AcceptProfile", "http://localhost/device", "text/plain, application/json; profile=envelope", true},
+		{"OtherProfile", "http://localhost/device", "application/json; profile=raw", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, test.url, nil)
+			r.Header.Set("Accept", test.accept)
+			assert.EqualValues(t, test.expected, wantsEnvelope(r))
+		})
+	}
+}
+
+func TestEncodeResponseEnvelope(t *testing.T) {
+	assert := assert.New(t)
+
+	r := httptest.NewRequest(http.MethodGet, "http://localhost/device?envelope=true", nil)
+	r = mux.SetURLVars(r, map[string]string{"deviceid": "MAC:112233445566"})
+
+	arrival := time.Now().Add(-time.Second)
+	ctx := captureEnvelope(context.WithValue(ctxTID, common.ContextKeyRequestArrivalTime, arrival), r)
+
+	recorder := httptest.NewRecorder()
+	response := &common.XmidtResponse{
+		Code:        http.StatusOK,
+		Latency:     1500 * time.Microsecond,
+		SentAt:      arrival.Add(time.Millisecond),
+		RespondedAt: arrival.Add(time.Millisecond + 1500*time.Microsecond),
+		Body: wrp.MustEncode(&wrp.Message{
+			Type:    wrp.SimpleRequestResponseMessageType,
+			Payload: []byte(`{"statusCode": 520}`),
+		}, wrp.Msgpack),
+	}
+
+	assert.Nil(encodeResponse(nil)(ctx, recorder, response))
+	assert.EqualValues(520, recorder.Code)
+
+	var e struct {
+		envelope
+		Payload json.RawMessage `json:"payload"`
+	}
+
+	assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &e))
+	assert.EqualValues("test-tid", e.TID)
+	assert.EqualValues("mac:112233445566", e.DeviceID)
+	assert.EqualValues(520, e.StatusCode)
+	assert.EqualValues(1.5, e.BackendLatencyMs)
+	assert.True(arrival.Equal(*e.ReceivedAt))
+	assert.False(e.RespondedAt.Before(arrival))
+	assert.True(response.SentAt.Equal(*e.BackendSentAt))
+	assert.True(response.RespondedAt.Equal(*e.BackendRespondedAt))
+	assert.Equal(common.FormatTimestamp(response.RespondedAt), recorder.Header().Get(common.HeaderWPABackendRespondedAt))
+	assert.JSONEq(`{"statusCode": 520}`, string(e.Payload))
+}
+
+func TestWrapInEnvelope(t *testing.T) {
+	t.Run("NotRequested", func(t *testing.T) {
+		p, err := wrapInEnvelope(ctxTID, new(common.XmidtResponse), 200, []byte("raw"))
+		assert.Nil(t, err)
+		assert.EqualValues(t, "raw", string(p))
+	})
+
+	t.Run("NonJSONPayload", func(t *testing.T) {
+		assert := assert.New(t)
+		ctx := context.WithValue(ctxTID, envelopeContextKey{}, &envelope{DeviceID: "mac:112233445566"})
+
+		p, err := wrapInEnvelope(ctx, new(common.XmidtResponse), 0, []byte("raw"))
+		assert.Nil(err)
+
+		var e map[string]interface{}
+		assert.Nil(json.Unmarshal(p, &e))
+		assert.EqualValues("raw", e["payload"])
+		assert.NotContains(e, "receivedAt")
+	})
+}
@@ -2,24 +2,35 @@ package translation
 
 import (
 	"errors"
+	"net/http"
 
 	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
 )
 
 // Error values definitions for the translation service
 var (
-	ErrEmptyNames        = common.NewBadRequestError(errors.New("names parameter is required"))
 	ErrInvalidService    = common.NewBadRequestError(errors.New("unsupported Service"))
 	ErrUnsupportedMethod = common.NewBadRequestError(errors.New("unsupported method. Could not decode request payload"))
 
-	//Set command errors
-	ErrInvalidSetWDMP = common.NewBadRequestError(errors.New("invalid XPC SET message"))
-	ErrNewCIDRequired = common.NewBadRequestError(errors.New("newCid is required for TEST_AND_SET"))
+	//ErrUnsupportedMediaType is returned when the request body of a mutating request is not in an accepted format
+	ErrUnsupportedMediaType = common.NewCodedError(errors.New("unsupported Content-Type for the request body"), http.StatusUnsupportedMediaType)
 
-	//Add/Delete command  errors
-	ErrMissingTable = common.NewBadRequestError(errors.New("table property is required"))
-	ErrMissingRow   = common.NewBadRequestError(errors.New("row property is required"))
+	//WDMP building errors
+	ErrEmptyNames        = wdmp.ErrEmptyNames
+	ErrInvalidAttributes = wdmp.ErrInvalidAttributes
+	ErrInvalidSetWDMP    = wdmp.ErrInvalidSetWDMP
+	ErrNewCIDRequired    = wdmp.ErrNewCIDRequired
+	ErrInvalidNewCID     = wdmp.ErrInvalidNewCID
+	ErrInvalidOldCID     = wdmp.ErrInvalidOldCID
+	ErrInvalidSyncCMC    = wdmp.ErrInvalidSyncCMC
+	ErrMissingTable      = wdmp.ErrMissingTable
+	ErrMissingRow        = wdmp.ErrMissingRow
+	ErrMissingRows       = wdmp.ErrMissingRows
 
-	//Replace command error
-	ErrMissingRows = common.NewBadRequestError(errors.New("rows property is required"))
+	//ErrUnsupportedWDMPVersion is returned when the API consumer asks for a WDMP version tr1d1um does not speak
+	ErrUnsupportedWDMPVersion = wdmp.ErrUnsupportedVersion
+
+	//ErrMalformedUpstreamResponse is returned when the XMiDT API responds successfully but not with a msgpack-encoded WRP message
+	ErrMalformedUpstreamResponse = common.NewCodedError(errors.New("malformed upstream response"), http.StatusBadGateway)
 )
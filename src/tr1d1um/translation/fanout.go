@@ -0,0 +1,111 @@
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+//wrpOutcome is the result of sending one of the WRP messages of a fanned out request
+type wrpOutcome struct {
+	Response *common.XmidtResponse
+	Err      error
+}
+
+//outcomeResult is the part of an aggregated response for one of the WRP messages of a fanned out request
+type outcomeResult struct {
+	StatusCode int         `json:"statusCode"`
+	Response   interface{} `json:"response,omitempty"`
+	Message    string      `json:"message,omitempty"`
+}
+
+//sendAll sends the given WRP messages, at most concurrency at a time, and returns their outcomes in the same order
+func sendAll(ctx context.Context, s Service, messages []*wrp.Message, authHeaderValue string, concurrency int) []wrpOutcome {
+	var outcomes = make([]wrpOutcome, len(messages))
+
+	errs, _ := common.FanOut{Concurrency: concurrency}.Run(ctx, len(messages), func(ctx context.Context, i int) (err error) {
+		outcomes[i].Response, err = s.SendWRP(ctx, messages[i], authHeaderValue)
+		return
+	})
+
+	for i, err := range errs {
+		outcomes[i].Err = err
+	}
+
+	return outcomes
+}
+
+//writeMultiStatus writes the given aggregated result of a fanned out request as a 207 Multi-Status
+func writeMultiStatus(ctx context.Context, w http.ResponseWriter, body interface{}) (err error) {
+	w.Header().Set(contentTypeHeaderKey, "application/json; charset=utf-8")
+	w.Header().Set(common.TIDHeader(ctx), common.RequestTID(ctx))
+	reportWDMPVersion(ctx, w.Header())
+	w.WriteHeader(http.StatusMultiStatus)
+
+	if err = json.NewEncoder(w).Encode(body); err != nil && ctx.Err() == context.Canceled {
+		err = common.ErrClientCanceled
+	}
+
+	return
+}
+
+//outcomeResult summarizes the outcome of one of the WRP messages of a fanned out request
+func (o *encodeOptions) outcomeResult(ctx context.Context, outcome wrpOutcome) (result outcomeResult) {
+	switch err := outcome.Err.(type) {
+	case nil:
+	case common.CodedError:
+		result.StatusCode, result.Message = err.StatusCode(), err.Error()
+		return
+	default:
+		//the real error is not meant for the API consumer
+		result.StatusCode, result.Message = http.StatusInternalServerError, common.ErrTr1d1umInternal.Error()
+		return
+	}
+
+	var resp = outcome.Response
+	if resp.Code != http.StatusOK {
+		result.StatusCode, result.Response = resp.Code, jsonOrString(resp.Body)
+		return
+	}
+
+	wrpModel := new(wrp.Message)
+	if err := common.DecodeWRP(resp.Body, wrpModel); err != nil {
+		result.StatusCode, result.Message = ErrMalformedUpstreamResponse.StatusCode(), ErrMalformedUpstreamResponse.Error()
+		return
+	}
+
+	payload, err := decodeWDMP(ctx, wrpModel.Payload)
+	if err != nil {
+		result.StatusCode, result.Message = ErrMalformedUpstreamResponse.StatusCode(), ErrMalformedUpstreamResponse.Error()
+		return
+	}
+
+	var deviceResponseModel struct {
+		StatusCode int `json:"statusCode"`
+	}
+
+	result.StatusCode = http.StatusOK
+	if errUnmarshall := json.Unmarshal(payload, &deviceResponseModel); errUnmarshall == nil {
+		result.StatusCode = o.statusMapping.httpStatus(deviceResponseModel.StatusCode)
+	}
+
+	payload = restoreAliases(ctx, projectFields(ctx, redactValues(ctx, payload)))
+	if o.canonicalJSON {
+		payload = canonicalJSON(payload)
+	}
+
+	result.Response = jsonOrString(payload)
+	return
+}
+
+//jsonOrString returns the given payload as raw JSON if it is valid JSON or as a string otherwise
+func jsonOrString(payload []byte) interface{} {
+	if json.Valid(payload) {
+		return json.RawMessage(payload)
+	}
+	return string(payload)
+}
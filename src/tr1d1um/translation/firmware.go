@@ -0,0 +1,151 @@
+package translation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
+)
+
+//Firmware download request errors
+var (
+	ErrInvalidFirmwareRequest = common.NewBadRequestError(errors.New("invalid firmware download request. Expected a JSON object with url, and optionally protocol and immediate"))
+	ErrFirmwareURLNotAllowed  = common.NewCodedError(errors.New("firmware URL is not allowed"), http.StatusForbidden)
+)
+
+//Firmware configures the route which triggers firmware downloads on devices:
+//POST /device/{deviceid}/firmware with {"url": "...", "protocol": "http", "immediate": true}
+//The route is only registered if some firmware URLs are allowed
+type Firmware struct {
+	//AllowedURLs are the prefixes firmware URLs must start with (i.e. https://firmware.example.com/images/)
+	AllowedURLs []string
+
+	//Service is the device service the download is requested from. Defaults to config
+	Service string
+
+	//URLParameter, ProtocolParameter and ImmediateParameter are the parameters set, in that order, to request
+	//the download. They default to the RDK ones (Device.DeviceInfo.X_RDKCENTRAL-COM_FirmwareDownload*)
+	URLParameter       string
+	ProtocolParameter  string
+	ImmediateParameter string
+}
+
+//firmwareRequest is the body of firmware download requests
+type firmwareRequest struct {
+	URL string `json:"url"`
+
+	//Protocol is http or https. It defaults to the scheme of URL
+	Protocol string `json:"protocol"`
+
+	//Immediate indicates whether the device should download and install the firmware right away
+	Immediate bool `json:"immediate"`
+}
+
+func (f *Firmware) withDefaults() Firmware {
+	var o Firmware
+	if f != nil {
+		o = *f
+	}
+
+	if o.Service == "" {
+		o.Service = "config"
+	}
+
+	if o.URLParameter == "" {
+		o.URLParameter = "Device.DeviceInfo.X_RDKCENTRAL-COM_FirmwareDownloadURL"
+	}
+
+	if o.ProtocolParameter == "" {
+		o.ProtocolParameter = "Device.DeviceInfo.X_RDKCENTRAL-COM_FirmwareDownloadProtocol"
+	}
+
+	if o.ImmediateParameter == "" {
+		o.ImmediateParameter = "Device.DeviceInfo.X_RDKCENTRAL-COM_FirmwareDownloadNow"
+	}
+
+	return o
+}
+
+//Validate returns an error if any of the allowed URLs is not an absolute http(s) URL
+func (f *Firmware) Validate() error {
+	if f == nil {
+		return nil
+	}
+
+	for _, prefix := range f.AllowedURLs {
+		if u, err := url.Parse(prefix); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("allowed firmware URL '%s' should be an absolute http(s) URL", prefix)
+		}
+	}
+
+	return nil
+}
+
+//allowed returns true if the given firmware URL has the scheme and host of one of the allowed URLs and its path
+//is under the allowed one. URLs with credentials are never allowed as they would end up in the logs of devices
+func (f Firmware) allowed(u *url.URL) bool {
+	if u.User != nil || u.Host == "" {
+		return false
+	}
+
+	var urlPath = path.Clean("/" + u.Path)
+
+	for _, prefix := range f.AllowedURLs {
+		allowed, err := url.Parse(prefix)
+		if err != nil || allowed.Scheme != u.Scheme || !strings.EqualFold(allowed.Host, u.Host) {
+			continue
+		}
+
+		var allowedPath = path.Clean("/" + allowed.Path)
+		if allowedPath == "/" || urlPath == allowedPath || strings.HasPrefix(urlPath, allowedPath+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+//payload builds the WDMP SET which requests the download of the firmware of the request body
+func (f Firmware) payload(r *http.Request) ([]byte, error) {
+	var request firmwareRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.URL == "" {
+		return nil, ErrInvalidFirmwareRequest
+	}
+
+	firmwareURL, err := url.Parse(request.URL)
+	if err != nil || !f.allowed(firmwareURL) {
+		return nil, ErrFirmwareURLNotAllowed
+	}
+
+	//devices get the path which was checked
+	firmwareURL.Path, firmwareURL.RawPath = path.Clean("/"+firmwareURL.Path), ""
+
+	switch request.Protocol {
+	case "":
+		request.Protocol = firmwareURL.Scheme
+	case "http", "https":
+	default:
+		return nil, ErrInvalidFirmwareRequest
+	}
+
+	var (
+		stringType  int8
+		booleanType int8 = 3
+	)
+
+	return json.Marshal(&wdmp.SetRequest{
+		Command: wdmp.CommandSet,
+		Parameters: []wdmp.SetParam{
+			{Name: &f.URLParameter, DataType: &stringType, Value: firmwareURL.String()},
+			{Name: &f.ProtocolParameter, DataType: &stringType, Value: request.Protocol},
+			{Name: &f.ImmediateParameter, DataType: &booleanType, Value: strconv.FormatBool(request.Immediate)},
+		},
+	})
+}
@@ -0,0 +1,159 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
+
+	"github.com/Comcast/webpa-common/device"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+//DefaultGroupConcurrency is the maximum number of group members a request is sent to at a time
+//when no concurrency is configured
+const DefaultGroupConcurrency = 10
+
+//ErrUnknownGroup is returned when a group operation targets a group that is not configured
+var ErrUnknownGroup = common.NewCodedError(errors.New("unknown device group"), http.StatusNotFound)
+
+//Groups maps device group names to the IDs of their member devices
+//Group names are case-insensitive
+type Groups map[string][]string
+
+//Validate returns an error if any of the group members is not a valid device ID
+func (g Groups) Validate() error {
+	for name, members := range g {
+		if len(members) == 0 {
+			return fmt.Errorf("device group '%s' has no members", name)
+		}
+
+		for _, member := range members {
+			if _, err := device.ParseID(member); err != nil {
+				return fmt.Errorf("device group '%s' has invalid member '%s': %s", name, member, err)
+			}
+		}
+	}
+	return nil
+}
+
+//members returns the device IDs of the given group, if it exists
+func (g Groups) members(name string) (members []string, ok bool) {
+	for groupName, groupMembers := range g {
+		if strings.EqualFold(groupName, name) {
+			return groupMembers, true
+		}
+	}
+	return
+}
+
+type groupRequest struct {
+	Members         []string
+	WRPMessages     []*wrp.Message
+	AuthHeaderValue string
+}
+
+//groupOutcome is the result of sending a group request to one of its members
+type groupOutcome struct {
+	DeviceID string
+	wrpOutcome
+}
+
+//groupResult is the part of the aggregated group response for a single member
+type groupResult struct {
+	DeviceID string `json:"deviceId"`
+	outcomeResult
+}
+
+//decodeGroupRequest returns the function that decodes a SET on a device group into a WRP message for each member
+func decodeGroupRequest(groups Groups, addressing *WRPAddressing, aliases *Aliases) kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		var vars = mux.Vars(r)
+
+		members, ok := groups.members(vars["group"])
+		if !ok {
+			return nil, ErrUnknownGroup
+		}
+
+		payload, err := wdmp.LimitedSetPayload(r.Body, setLimits(ctx), r.Header.Get(HeaderWPASyncNewCID), r.Header.Get(HeaderWPASyncOldCID), r.Header.Get(HeaderWPASyncCMC))
+		if err != nil {
+			return nil, err
+		}
+
+		payload = aliases.expand(payload)
+
+		var (
+			tid     = common.RequestTID(ctx)
+			partner = common.RequestPartner(ctx)
+			request = &groupRequest{
+				Members:         members,
+				AuthHeaderValue: r.Header.Get(authHeaderKey),
+			}
+		)
+
+		for _, member := range members {
+			wrpMsg, err := wrap(payload, tid, map[string]string{"deviceid": member, "service": vars["service"]}, partner, addressing)
+			if err != nil {
+				return nil, err
+			}
+
+			if err = encodeWDMP(ctx, wrpMsg); err != nil {
+				return nil, err
+			}
+
+			request.WRPMessages = append(request.WRPMessages, wrpMsg)
+		}
+
+		return request, nil
+	}
+}
+
+//makeGroupEndpoint fans a group request out to its members, sending to at most concurrency members at a time
+func makeGroupEndpoint(s Service, concurrency int) endpoint.Endpoint {
+	if concurrency < 1 {
+		concurrency = DefaultGroupConcurrency
+	}
+
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		var (
+			groupReq    = request.(*groupRequest)
+			wrpOutcomes = sendAll(ctx, s, groupReq.WRPMessages, groupReq.AuthHeaderValue, concurrency)
+			outcomes    = make([]groupOutcome, len(groupReq.Members))
+		)
+
+		if ctx.Err() == context.Canceled {
+			return nil, common.ErrClientCanceled
+		}
+
+		for i, member := range groupReq.Members {
+			outcomes[i] = groupOutcome{DeviceID: member, wrpOutcome: wrpOutcomes[i]}
+		}
+
+		return outcomes, nil
+	}
+}
+
+//encodeGroupResponse returns the function that encodes the aggregated result of a group request as a 207 Multi-Status
+func encodeGroupResponse(o *encodeOptions) kithttp.EncodeResponseFunc {
+	o = o.withDefaults()
+
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		var (
+			outcomes = response.([]groupOutcome)
+			results  = make([]groupResult, 0, len(outcomes))
+		)
+
+		for _, outcome := range outcomes {
+			results = append(results, groupResult{DeviceID: outcome.DeviceID, outcomeResult: o.outcomeResult(ctx, outcome.wrpOutcome)})
+		}
+
+		return writeMultiStatus(ctx, w, map[string]interface{}{"results": results})
+	}
+}
@@ -0,0 +1,149 @@
+package translation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var testGroups = Groups{
+	"lab": []string{"mac:112233445566", "mac:112233445577"},
+}
+
+func TestGroupsValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(testGroups.Validate())
+	assert.NotNil(Groups{"empty": nil}.Validate())
+	assert.NotNil(Groups{"lab": []string{"not a device"}}.Validate())
+}
+
+func TestDecodeGroupRequest(t *testing.T) {
+	t.Run("UnknownGroup", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPatch, "http://localhost", nil)
+		r = mux.SetURLVars(r, map[string]string{"group": "prod", "service": "config"})
+
+		_, err := decodeGroupRequest(testGroups, nil, nil)(ctxTID, r)
+		assert.EqualValues(t, ErrUnknownGroup, err)
+	})
+
+	t.Run("InvalidSet", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPatch, "http://localhost", bytes.NewBufferString(`{}`))
+		r = mux.SetURLVars(r, map[string]string{"group": "lab", "service": "config"})
+
+		_, err := decodeGroupRequest(testGroups, nil, nil)(ctxTID, r)
+		assert.EqualValues(t, ErrInvalidSetWDMP, err)
+	})
+
+	t.Run("Ideal", func(t *testing.T) {
+		assert := assert.New(t)
+		r := httptest.NewRequest(http.MethodPatch, "http://localhost",
+			bytes.NewBufferString(`{"parameters": [{"name": "p0", "dataType": 0, "value": "a"}]}`))
+		r = mux.SetURLVars(r, map[string]string{"group": "LAB", "service": "config"})
+		r.Header.Set(authHeaderKey, "Basic xyz")
+
+		decoded, err := decodeGroupRequest(testGroups, nil, nil)(ctxTID, r)
+		assert.Nil(err)
+
+		request := decoded.(*groupRequest)
+		assert.EqualValues(testGroups["lab"], request.Members)
+		assert.EqualValues("Basic xyz", request.AuthHeaderValue)
+		assert.Len(request.WRPMessages, 2)
+		assert.EqualValues("mac:112233445577/config", request.WRPMessages[1].Destination)
+		assert.EqualValues(request.WRPMessages[0].Payload, request.WRPMessages[1].Payload)
+	})
+}
+
+func TestGroupEndpoint(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		inFlight, maxInFlight int32
+		s                     = new(MockService)
+		request               = &groupRequest{
+			Members:     []string{"mac:1", "mac:2", "mac:3", "mac:4"},
+			WRPMessages: []*wrp.Message{{}, {}, {}, {Destination: "fail"}},
+		}
+	)
+
+	track := func(context.Context, *wrp.Message, string) *common.XmidtResponse {
+		if current := atomic.AddInt32(&inFlight, 1); current > atomic.LoadInt32(&maxInFlight) {
+			atomic.StoreInt32(&maxInFlight, current)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return &common.XmidtResponse{Code: http.StatusOK}
+	}
+
+	s.On("SendWRP", mock.Anything, &wrp.Message{Destination: "fail"}, "").Return(nil, errors.New("network"))
+	s.On("SendWRP", mock.Anything, &wrp.Message{}, "").Return(track, nil)
+
+	response, err := makeGroupEndpoint(s, 2)(ctxTID, request)
+	assert.Nil(err)
+
+	outcomes := response.([]groupOutcome)
+	assert.Len(outcomes, 4)
+	assert.EqualValues("mac:1", outcomes[0].DeviceID)
+	assert.EqualValues(http.StatusOK, outcomes[0].Response.Code)
+	assert.EqualValues("mac:4", outcomes[3].DeviceID)
+	assert.NotNil(outcomes[3].Err)
+	assert.True(atomic.LoadInt32(&maxInFlight) <= 2)
+}
+
+func TestEncodeGroupResponse(t *testing.T) {
+	assert := assert.New(t)
+	recorder := httptest.NewRecorder()
+
+	outcomes := []groupOutcome{
+		{
+			DeviceID: "mac:1",
+			wrpOutcome: wrpOutcome{
+				Response: &common.XmidtResponse{
+					Code: http.StatusOK,
+					Body: wrp.MustEncode(&wrp.Message{
+						Type:    wrp.SimpleRequestResponseMessageType,
+						Payload: []byte(`{"statusCode": 200, "message": "Success"}`),
+					}, wrp.Msgpack),
+				},
+			},
+		},
+		{DeviceID: "mac:2", wrpOutcome: wrpOutcome{Response: &common.XmidtResponse{Code: http.StatusNotFound, Body: []byte("device not found")}}},
+		{DeviceID: "mac:3", wrpOutcome: wrpOutcome{Err: &common.TimeoutError{Stage: common.TimeoutStageBackend}}},
+		{DeviceID: "mac:4", wrpOutcome: wrpOutcome{Err: errors.New("internal details")}},
+	}
+
+	assert.Nil(encodeGroupResponse(nil)(ctxTID, recorder, outcomes))
+	assert.EqualValues(http.StatusMultiStatus, recorder.Code)
+	assert.EqualValues("test-tid", recorder.Header().Get(common.HeaderWPATID))
+
+	var body struct {
+		Results []map[string]interface{} `json:"results"`
+	}
+
+	assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Len(body.Results, 4)
+
+	assert.EqualValues(http.StatusOK, body.Results[0]["statusCode"])
+	assert.EqualValues(map[string]interface{}{"statusCode": float64(200), "message": "Success"}, body.Results[0]["response"])
+
+	assert.EqualValues(http.StatusNotFound, body.Results[1]["statusCode"])
+	assert.EqualValues("device not found", body.Results[1]["response"])
+
+	assert.EqualValues(http.StatusGatewayTimeout, body.Results[2]["statusCode"])
+	assert.EqualValues("mac:4", body.Results[3]["deviceId"])
+	assert.EqualValues(common.ErrTr1d1umInternal.Error(), body.Results[3]["message"])
+}
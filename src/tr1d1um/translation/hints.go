@@ -0,0 +1,107 @@
+package translation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/device"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+type hintsContextKey struct{}
+
+//lazyHints looks up the hints of the target device at most once and only if needed
+type lazyHints struct {
+	once   sync.Once
+	lookup func() *common.DeviceHints
+	hints  *common.DeviceHints
+}
+
+func (l *lazyHints) get() *common.DeviceHints {
+	l.once.Do(func() {
+		l.hints = l.lookup()
+	})
+
+	return l.hints
+}
+
+//captureDeviceHints returns the function that makes the hints of the target device available
+//to the response and error encoders. Hints are only looked up if a failure needs them
+func captureDeviceHints(hinter common.DeviceHinter) kithttp.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		if hinter == nil {
+			return ctx
+		}
+
+		deviceID, err := device.ParseID(mux.Vars(r)["deviceid"])
+		if err != nil {
+			return ctx
+		}
+
+		var authHeaderValue = r.Header.Get(authHeaderKey)
+
+		return context.WithValue(ctx, hintsContextKey{}, &lazyHints{
+			lookup: func() *common.DeviceHints {
+				hints, _ := hinter.DeviceHints(ctx, authHeaderValue, string(deviceID))
+				return hints
+			},
+		})
+	}
+}
+
+//deviceHints returns the hints of the target device of the request, if available
+func deviceHints(ctx context.Context) *common.DeviceHints {
+	if l, ok := ctx.Value(hintsContextKey{}).(*lazyHints); ok {
+		return l.get()
+	}
+	return nil
+}
+
+//hintLabels returns the device hints as metric label values
+func hintLabels(hints *common.DeviceHints) (firmware, model string) {
+	firmware, model = "unknown", "unknown"
+
+	if hints != nil {
+		if hints.Firmware != "" {
+			firmware = hints.Firmware
+		}
+
+		if hints.Model != "" {
+			model = hints.Model
+		}
+	}
+
+	return
+}
+
+//mergeHints adds the given device hints to a JSON object payload under the "device" key
+//Payloads that are not JSON objects are returned untouched
+func mergeHints(payload []byte, hints *common.DeviceHints) []byte {
+	if hints == nil {
+		return payload
+	}
+
+	var (
+		document map[string]interface{}
+		decoder  = json.NewDecoder(bytes.NewReader(payload))
+	)
+
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil || document == nil {
+		return payload
+	}
+
+	document["device"] = hints
+
+	if merged, err := marshalJSON(document); err == nil {
+		return merged
+	}
+
+	return payload
+}
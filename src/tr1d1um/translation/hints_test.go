@@ -0,0 +1,143 @@
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/go-kit/kit/metrics"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+//hinterFunc allows plain functions to act as a DeviceHinter
+type hinterFunc func(context.Context, string, string) (*common.DeviceHints, error)
+
+func (f hinterFunc) DeviceHints(ctx context.Context, authHeaderValue, deviceID string) (*common.DeviceHints, error) {
+	return f(ctx, authHeaderValue, deviceID)
+}
+
+//labeledCounter records the label values of the observations made through it
+type labeledCounter struct {
+	labelValues []string
+	value       float64
+}
+
+func (c *labeledCounter) With(labelValues ...string) metrics.Counter {
+	c.labelValues = labelValues
+	return c
+}
+
+func (c *labeledCounter) Add(delta float64) {
+	c.value += delta
+}
+
+//hintsContext returns a context for a request to the given device whose hints are looked up through a counting hinter
+func hintsContext(lookups *int) context.Context {
+	r := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+	r = mux.SetURLVars(r, map[string]string{"deviceid": "mac:112233445566"})
+	r.Header.Set(authHeaderKey, "Basic xyz")
+
+	return captureDeviceHints(hinterFunc(func(_ context.Context, auth, deviceID string) (*common.DeviceHints, error) {
+		*lookups++
+		return &common.DeviceHints{Firmware: "fw-1.2", Model: "XB6"}, nil
+	}))(ctxTID, r)
+}
+
+func TestDeviceHintsLookup(t *testing.T) {
+	t.Run("NoHinter", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+		assert.Nil(t, deviceHints(captureDeviceHints(nil)(ctxTID, r)))
+	})
+
+	t.Run("Lazy", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var lookups int
+		ctx := hintsContext(&lookups)
+		assert.Zero(lookups)
+
+		assert.EqualValues(&common.DeviceHints{Firmware: "fw-1.2", Model: "XB6"}, deviceHints(ctx))
+		deviceHints(ctx)
+		assert.EqualValues(1, lookups)
+	})
+}
+
+func TestMergeHints(t *testing.T) {
+	var hints = &common.DeviceHints{Firmware: "fw-1.2"}
+
+	assert.JSONEq(t, `{"statusCode": 520, "device": {"firmware": "fw-1.2"}}`, string(mergeHints([]byte(`{"statusCode": 520}`), hints)))
+	assert.EqualValues(t, `[1]`, string(mergeHints([]byte(`[1]`), hints)))
+	assert.EqualValues(t, `{}`, string(mergeHints([]byte(`{}`), nil)))
+}
+
+func TestEncodeResponseDeviceHints(t *testing.T) {
+	t.Run("DeviceFailure", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var (
+			lookups  int
+			recorder = httptest.NewRecorder()
+			measures = common.NewMeasures(nil)
+			counter  = new(labeledCounter)
+		)
+
+		measures.DeviceErrors = counter
+
+		response := &common.XmidtResponse{
+			Code: http.StatusOK,
+			Body: wrp.MustEncode(&wrp.Message{
+				Type:    wrp.SimpleRequestResponseMessageType,
+				Payload: []byte(`{"statusCode": 404, "message": "Invalid parameter"}`),
+			}, wrp.Msgpack),
+		}
+
+		assert.Nil(encodeResponse(&encodeOptions{measures: measures})(hintsContext(&lookups), recorder, response))
+		assert.EqualValues(http.StatusNotFound, recorder.Code)
+		assert.JSONEq(`{"statusCode": 404, "message": "Invalid parameter", "device": {"firmware": "fw-1.2", "model": "XB6"}}`, recorder.Body.String())
+		assert.EqualValues(1, counter.value)
+		assert.EqualValues([]string{common.StatusLabel, "404", common.FirmwareLabel, "fw-1.2", common.ModelLabel, "XB6"}, counter.labelValues)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		var lookups int
+
+		response := &common.XmidtResponse{
+			Code: http.StatusOK,
+			Body: wrp.MustEncode(&wrp.Message{
+				Type:    wrp.SimpleRequestResponseMessageType,
+				Payload: []byte(`{"statusCode": 200}`),
+			}, wrp.Msgpack),
+		}
+
+		assert.Nil(t, encodeResponse(nil)(hintsContext(&lookups), httptest.NewRecorder(), response))
+		assert.Zero(t, lookups)
+	})
+}
+
+func TestEncodeErrorDeviceHints(t *testing.T) {
+	t.Run("ServerSide", func(t *testing.T) {
+		var (
+			lookups  int
+			recorder = httptest.NewRecorder()
+			body     map[string]interface{}
+		)
+
+		encodeError(hintsContext(&lookups), &common.TimeoutError{Stage: common.TimeoutStageBackend}, recorder)
+
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+		assert.EqualValues(t, map[string]interface{}{"firmware": "fw-1.2", "model": "XB6"}, body["device"])
+	})
+
+	t.Run("BadRequest", func(t *testing.T) {
+		var lookups int
+
+		encodeError(hintsContext(&lookups), ErrEmptyNames, httptest.NewRecorder())
+		assert.Zero(t, lookups)
+	})
+}
@@ -0,0 +1,29 @@
+package translation
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+)
+
+type setLimitsContextKey struct{}
+
+//captureSetLimits returns the function that records in the context the limits the bodies of SET requests are bound by
+func captureSetLimits(limits *wdmp.SetLimits) kithttp.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		if limits == nil || r.Method != http.MethodPatch {
+			return ctx
+		}
+
+		return context.WithValue(ctx, setLimitsContextKey{}, limits)
+	}
+}
+
+//setLimits returns the limits recorded in the given context, nil if there are none
+func setLimits(ctx context.Context) *wdmp.SetLimits {
+	limits, _ := ctx.Value(setLimitsContextKey{}).(*wdmp.SetLimits)
+	return limits
+}
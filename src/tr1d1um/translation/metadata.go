@@ -0,0 +1,77 @@
+package translation
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/device"
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+//Keys of the WRP metadata of device responses which hold the firmware and model of devices, as reported by
+//them when they connected
+const (
+	firmwareMetadataKey = "fw-name"
+	modelMetadataKey    = "hw-model"
+)
+
+//NewMetadataService decorates the given service so that what the WRP responses of devices tell about them (that
+//they are connected, their partner, firmware and model) is recorded into the given store
+func NewMetadataService(s Service, store common.DeviceMetadataStore) Service {
+	if store == nil {
+		return s
+	}
+
+	return &metadataService{
+		Service: s,
+		store:   store,
+		now:     time.Now,
+	}
+}
+
+type metadataService struct {
+	Service
+
+	store common.DeviceMetadataStore
+	now   func() time.Time
+}
+
+func (m *metadataService) SendWRP(ctx context.Context, wrpMsg *wrp.Message, authValue string) (*common.XmidtResponse, error) {
+	result, err := m.Service.SendWRP(ctx, wrpMsg, authValue)
+	if err != nil || result.Code != http.StatusOK {
+		return result, err
+	}
+
+	deviceID, errID := device.ParseID(wrpMsg.Destination)
+	if errID != nil {
+		return result, err
+	}
+
+	var (
+		response wrp.Message
+		metadata = common.DeviceMetadata{DeviceID: string(deviceID), LastSeen: m.now()}
+	)
+
+	if common.DecodeWRP(result.Body, &response) == nil {
+		if len(response.PartnerIDs) > 0 {
+			metadata.Partner = response.PartnerIDs[0]
+		}
+
+		for key, value := range response.Metadata {
+			switch strings.TrimPrefix(key, "/") {
+			case firmwareMetadataKey:
+				metadata.Firmware = value
+			case modelMetadataKey:
+				metadata.Model = value
+			}
+		}
+	}
+
+	//the metadata is best effort so failures of the store don't fail the request
+	m.store.Merge(metadata)
+	return result, err
+}
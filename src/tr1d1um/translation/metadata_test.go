@@ -0,0 +1,57 @@
+package translation
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMetadataService(t *testing.T) {
+	s := new(MockService)
+	assert.Equal(t, s, NewMetadataService(s, nil))
+
+	var (
+		now       = time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+		store     = common.NewMemoryMetadataStore(common.MetadataOptions{})
+		decorated = NewMetadataService(s, store)
+		wrpMsg    = &wrp.Message{Destination: "mac:112233445566/config"}
+	)
+
+	decorated.(*metadataService).now = func() time.Time { return now }
+
+	t.Run("Failure", func(t *testing.T) {
+		assert := assert.New(t)
+		s.On("SendWRP", mock.Anything, wrpMsg, "").Return(&common.XmidtResponse{Code: http.StatusNotFound}, nil).Once()
+
+		_, err := decorated.SendWRP(ctxTID, wrpMsg, "")
+		assert.Nil(err)
+
+		metadata, _ := store.Get("mac:112233445566")
+		assert.Nil(metadata)
+	})
+
+	t.Run("Recorded", func(t *testing.T) {
+		assert := assert.New(t)
+		s.On("SendWRP", mock.Anything, wrpMsg, "").Return(&common.XmidtResponse{
+			Code: http.StatusOK,
+			Body: wrp.MustEncode(&wrp.Message{
+				Type:       wrp.SimpleRequestResponseMessageType,
+				PartnerIDs: []string{"comcast"},
+				Metadata:   map[string]string{"/fw-name": "fw-1.2", "/hw-model": "XB6"},
+			}, wrp.Msgpack),
+		}, nil).Once()
+
+		_, err := decorated.SendWRP(ctxTID, wrpMsg, "")
+		assert.Nil(err)
+
+		metadata, _ := store.Get("mac:112233445566")
+		assert.EqualValues(&common.DeviceMetadata{DeviceID: "mac:112233445566", LastSeen: now, Firmware: "fw-1.2", Model: "XB6", Partner: "comcast"}, metadata)
+		s.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,136 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+//ErrMissingServices is returned when a multi-service query does not name any service
+var ErrMissingServices = common.NewBadRequestError(errors.New("at least one service is required"))
+
+type multiServiceRequest struct {
+	Services        []string
+	WRPMessages     []*wrp.Message
+	AuthHeaderValue string
+}
+
+//multiServiceOutcome maps the services of a multi-service query to the outcome of querying them
+type multiServiceOutcome map[string]wrpOutcome
+
+//decodeMultiServiceRequest returns the function that decodes a GET on several services of the same device
+//into a WRP message for each of them. Services are given as a comma-separated list in the 'services' query parameter
+func decodeMultiServiceRequest(validServices []string, addressing *WRPAddressing, aliases *Aliases) kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		services, err := parseServices(r.FormValue("services"), validServices)
+		if err != nil {
+			return nil, err
+		}
+
+		payload, err := wdmp.GetPayload(r.FormValue("names"), r.FormValue("attributes"))
+		if err != nil {
+			return nil, err
+		}
+
+		payload = aliases.expand(payload)
+
+		var (
+			tid      = common.RequestTID(ctx)
+			partner  = common.RequestPartner(ctx)
+			deviceID = mux.Vars(r)["deviceid"]
+			request  = &multiServiceRequest{
+				Services:        services,
+				AuthHeaderValue: r.Header.Get(authHeaderKey),
+			}
+		)
+
+		for _, service := range services {
+			wrpMsg, err := wrap(payload, tid, map[string]string{"deviceid": deviceID, "service": service}, partner, addressing)
+			if err != nil {
+				return nil, err
+			}
+
+			if err = encodeWDMP(ctx, wrpMsg); err != nil {
+				return nil, err
+			}
+
+			request.WRPMessages = append(request.WRPMessages, wrpMsg)
+		}
+
+		return request, nil
+	}
+}
+
+//parseServices returns the distinct services in the given comma-separated list
+//All of them must be valid services
+func parseServices(list string, validServices []string) (services []string, err error) {
+	var seen = make(map[string]bool)
+
+	for _, service := range strings.Split(list, ",") {
+		if service = strings.TrimSpace(service); service == "" || seen[service] {
+			continue
+		}
+
+		if !contains(service, validServices) {
+			return nil, ErrInvalidService
+		}
+
+		seen[service] = true
+		services = append(services, service)
+	}
+
+	if len(services) == 0 {
+		return nil, ErrMissingServices
+	}
+
+	return
+}
+
+//makeMultiServiceEndpoint queries all the services of a multi-service request concurrently
+func makeMultiServiceEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		var (
+			multiReq    = request.(*multiServiceRequest)
+			wrpOutcomes = sendAll(ctx, s, multiReq.WRPMessages, multiReq.AuthHeaderValue, len(multiReq.WRPMessages))
+			outcomes    = make(multiServiceOutcome, len(multiReq.Services))
+		)
+
+		if ctx.Err() == context.Canceled {
+			return nil, common.ErrClientCanceled
+		}
+
+		for i, service := range multiReq.Services {
+			outcomes[service] = wrpOutcomes[i]
+		}
+
+		return outcomes, nil
+	}
+}
+
+//encodeMultiServiceResponse returns the function that encodes the results of a multi-service query,
+//keyed by service, as a 207 Multi-Status
+func encodeMultiServiceResponse(o *encodeOptions) kithttp.EncodeResponseFunc {
+	o = o.withDefaults()
+
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		var (
+			outcomes = response.(multiServiceOutcome)
+			results  = make(map[string]outcomeResult, len(outcomes))
+		)
+
+		for service, outcome := range outcomes {
+			results[service] = o.outcomeResult(ctx, outcome)
+		}
+
+		return writeMultiStatus(ctx, w, results)
+	}
+}
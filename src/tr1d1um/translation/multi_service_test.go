@@ -0,0 +1,111 @@
+package translation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestParseServices(t *testing.T) {
+	assert := assert.New(t)
+	validServices := []string{"config", "iot"}
+
+	services, err := parseServices("config, iot,config,", validServices)
+	assert.Nil(err)
+	assert.EqualValues([]string{"config", "iot"}, services)
+
+	_, err = parseServices("config,stat", validServices)
+	assert.EqualValues(ErrInvalidService, err)
+
+	_, err = parseServices(" , ", validServices)
+	assert.EqualValues(ErrMissingServices, err)
+}
+
+func TestDecodeMultiServiceRequest(t *testing.T) {
+	t.Run("EmptyNames", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "http://localhost?services=config", nil)
+		r = mux.SetURLVars(r, map[string]string{"deviceid": "mac:112233445566"})
+
+		_, err := decodeMultiServiceRequest([]string{"config"}, nil, nil)(ctxTID, r)
+		assert.EqualValues(t, ErrEmptyNames, err)
+	})
+
+	t.Run("Ideal", func(t *testing.T) {
+		assert := assert.New(t)
+		r := httptest.NewRequest(http.MethodGet, "http://localhost?services=config,iot&names=p0", nil)
+		r = mux.SetURLVars(r, map[string]string{"deviceid": "mac:112233445566"})
+		r.Header.Set(authHeaderKey, "Basic xyz")
+
+		decoded, err := decodeMultiServiceRequest([]string{"config", "iot"}, nil, nil)(ctxTID, r)
+		assert.Nil(err)
+
+		request := decoded.(*multiServiceRequest)
+		assert.EqualValues([]string{"config", "iot"}, request.Services)
+		assert.EqualValues("Basic xyz", request.AuthHeaderValue)
+		assert.Len(request.WRPMessages, 2)
+		assert.EqualValues("mac:112233445566/iot", request.WRPMessages[1].Destination)
+		assert.EqualValues(request.WRPMessages[0].Payload, request.WRPMessages[1].Payload)
+	})
+}
+
+func TestMultiServiceEndpoint(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		s       = new(MockService)
+		request = &multiServiceRequest{
+			Services:    []string{"config", "iot"},
+			WRPMessages: []*wrp.Message{{Destination: "config"}, {Destination: "iot"}},
+		}
+	)
+
+	s.On("SendWRP", mock.Anything, &wrp.Message{Destination: "config"}, "").Return(&common.XmidtResponse{Code: http.StatusOK}, nil)
+	s.On("SendWRP", mock.Anything, &wrp.Message{Destination: "iot"}, "").Return(nil, errors.New("network"))
+
+	response, err := makeMultiServiceEndpoint(s)(ctxTID, request)
+	assert.Nil(err)
+
+	outcomes := response.(multiServiceOutcome)
+	assert.Len(outcomes, 2)
+	assert.EqualValues(http.StatusOK, outcomes["config"].Response.Code)
+	assert.NotNil(outcomes["iot"].Err)
+}
+
+func TestEncodeMultiServiceResponse(t *testing.T) {
+	assert := assert.New(t)
+	recorder := httptest.NewRecorder()
+
+	outcomes := multiServiceOutcome{
+		"config": {
+			Response: &common.XmidtResponse{
+				Code: http.StatusOK,
+				Body: wrp.MustEncode(&wrp.Message{
+					Type:    wrp.SimpleRequestResponseMessageType,
+					Payload: []byte(`{"statusCode": 200, "message": "Success"}`),
+				}, wrp.Msgpack),
+			},
+		},
+		"iot": {Err: &common.TimeoutError{Stage: common.TimeoutStageBackend}},
+	}
+
+	assert.Nil(encodeMultiServiceResponse(nil)(ctxTID, recorder, outcomes))
+	assert.EqualValues(http.StatusMultiStatus, recorder.Code)
+	assert.EqualValues("test-tid", recorder.Header().Get(common.HeaderWPATID))
+
+	var body map[string]map[string]interface{}
+
+	assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Len(body, 2)
+	assert.EqualValues(http.StatusOK, body["config"]["statusCode"])
+	assert.EqualValues(map[string]interface{}{"statusCode": float64(200), "message": "Success"}, body["config"]["response"])
+	assert.EqualValues(http.StatusGatewayTimeout, body["iot"]["statusCode"])
+}
@@ -0,0 +1,132 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/device"
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+//DefaultOrderingMaxWait is the time writes wait for the previous ones to the same device by default
+const DefaultOrderingMaxWait = 30 * time.Second
+
+//ErrWriteOrderTimeout is returned when a write waited too long for the previous ones to the same device
+var ErrWriteOrderTimeout = common.NewCodedError(errors.New("timed out waiting for previous writes to the device"), http.StatusServiceUnavailable)
+
+//OrderingOptions configures the serialization of the writes to each device
+type OrderingOptions struct {
+	//Enabled turns on the serialization
+	Enabled bool
+
+	//MaxWait is the longest a write waits for the previous ones to the same device. Defaults to DefaultOrderingMaxWait
+	MaxWait time.Duration
+}
+
+//NewOrderedService decorates the given service so that writes (requests other than GET) to a device are sent to it
+//one at a time, in the order they were received. This keeps two overlapping SETs from reaching the device in an
+//order other than the one the API consumers sent them in. Reads are not ordered
+func NewOrderedService(s Service, o OrderingOptions) Service {
+	if !o.Enabled {
+		return s
+	}
+
+	if o.MaxWait <= 0 {
+		o.MaxWait = DefaultOrderingMaxWait
+	}
+
+	return &orderedService{
+		Service: s,
+		maxWait: o.MaxWait,
+		queues:  make(map[string][]chan struct{}),
+	}
+}
+
+type orderedService struct {
+	Service
+
+	maxWait time.Duration
+	lock    sync.Mutex
+
+	//queues holds, by device, a channel for each pending write in the order they were received
+	//The channel of the write at the head of the queue is closed as that write may be sent
+	queues map[string][]chan struct{}
+}
+
+func (o *orderedService) SendWRP(ctx context.Context, wrpMsg *wrp.Message, authValue string) (*common.XmidtResponse, error) {
+	if method := common.RequestMethod(ctx); method == "" || method == http.MethodGet || method == http.MethodHead {
+		return o.Service.SendWRP(ctx, wrpMsg, authValue)
+	}
+
+	var deviceID = wrpMsg.Destination
+	if canonicalID, err := device.ParseID(wrpMsg.Destination); err == nil {
+		deviceID = string(canonicalID)
+	}
+
+	turn := o.enqueue(deviceID)
+	defer o.dequeue(deviceID, turn)
+
+	timer := time.NewTimer(o.maxWait)
+	defer timer.Stop()
+
+	select {
+	case <-turn:
+	case <-ctx.Done():
+		//the request ran out of time before its transaction with the XMiDT API could start
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, &common.TimeoutError{Stage: common.TimeoutStageWriteOrder, Err: ctx.Err()}
+		}
+
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, ErrWriteOrderTimeout
+	}
+
+	return o.Service.SendWRP(ctx, wrpMsg, authValue)
+}
+
+//enqueue adds a write to the queue of the given device and returns the channel closed once it is its turn
+func (o *orderedService) enqueue(deviceID string) chan struct{} {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	var turn = make(chan struct{})
+	if len(o.queues[deviceID]) == 0 {
+		close(turn)
+	}
+
+	o.queues[deviceID] = append(o.queues[deviceID], turn)
+	return turn
+}
+
+//dequeue removes a write, sent or given up on, from the queue of the given device and lets the next one be sent
+func (o *orderedService) dequeue(deviceID string, turn chan struct{}) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	var queue = o.queues[deviceID]
+	for i, pending := range queue {
+		if pending != turn {
+			continue
+		}
+
+		queue = append(queue[:i], queue[i+1:]...)
+		if i == 0 && len(queue) > 0 {
+			close(queue[0])
+		}
+
+		break
+	}
+
+	if len(queue) == 0 {
+		delete(o.queues, deviceID)
+		return
+	}
+
+	o.queues[deviceID] = queue
+}
@@ -0,0 +1,138 @@
+package translation
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+)
+
+//blockingService records the payloads of the messages it sends, blocking on release for the "blocked" one
+type blockingService struct {
+	lock    sync.Mutex
+	sent    []string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingService) SendWRP(_ context.Context, wrpMsg *wrp.Message, _ string) (*common.XmidtResponse, error) {
+	if string(wrpMsg.Payload) == "blocked" {
+		close(b.started)
+		<-b.release
+	}
+
+	b.lock.Lock()
+	b.sent = append(b.sent, string(wrpMsg.Payload))
+	b.lock.Unlock()
+
+	return &common.XmidtResponse{Code: http.StatusOK}, nil
+}
+
+func methodContext(method string) context.Context {
+	return context.WithValue(context.Background(), common.ContextKeyRequestMethod, method)
+}
+
+func TestOrderedServiceDisabled(t *testing.T) {
+	s := new(MockService)
+	assert.Equal(t, s, NewOrderedService(s, OrderingOptions{}))
+}
+
+func TestOrderedService(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		s       = &blockingService{started: make(chan struct{}), release: make(chan struct{})}
+		ordered = NewOrderedService(s, OrderingOptions{Enabled: true, MaxWait: time.Minute}).(*orderedService)
+		write   = methodContext(http.MethodPatch)
+		done    sync.WaitGroup
+
+		send = func(ctx context.Context, payload string) {
+			defer done.Done()
+			_, err := ordered.SendWRP(ctx, &wrp.Message{Destination: "mac:112233445566/config", Payload: []byte(payload)}, "")
+			assert.Nil(err)
+		}
+
+		pending = func() int {
+			ordered.lock.Lock()
+			defer ordered.lock.Unlock()
+			return len(ordered.queues["mac:112233445566"])
+		}
+	)
+
+	done.Add(1)
+	go send(write, "blocked")
+	<-s.started
+
+	//the writes which arrive while the first one is being sent wait for it, in order
+	for i, payload := range []string{"first", "second", "third"} {
+		done.Add(1)
+		go send(write, payload)
+		for pending() != i+2 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	//reads and writes to other devices don't wait
+	_, err := ordered.SendWRP(methodContext(http.MethodGet), &wrp.Message{Destination: "mac:112233445566/config", Payload: []byte("read")}, "")
+	assert.Nil(err)
+	_, err = ordered.SendWRP(write, &wrp.Message{Destination: "mac:665544332211/config", Payload: []byte("other")}, "")
+	assert.Nil(err)
+
+	close(s.release)
+	done.Wait()
+
+	assert.Equal([]string{"read", "other", "blocked", "first", "second", "third"}, s.sent)
+	assert.Empty(ordered.queues)
+}
+
+func TestOrderedServiceGiveUp(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		s       = &blockingService{started: make(chan struct{}), release: make(chan struct{})}
+		ordered = NewOrderedService(s, OrderingOptions{Enabled: true, MaxWait: 10 * time.Millisecond}).(*orderedService)
+		write   = methodContext(http.MethodPut)
+		message = &wrp.Message{Destination: "mac:112233445566/config", Payload: []byte("waiting")}
+		done    = make(chan struct{})
+	)
+
+	go func() {
+		defer close(done)
+		ordered.SendWRP(write, &wrp.Message{Destination: "mac:112233445566/config", Payload: []byte("blocked")}, "")
+	}()
+
+	<-s.started
+
+	_, err := ordered.SendWRP(write, message, "")
+	assert.Equal(ErrWriteOrderTimeout, err)
+
+	canceled, cancel := context.WithCancel(write)
+	cancel()
+
+	_, err = ordered.SendWRP(canceled, message, "")
+	assert.Equal(context.Canceled, err)
+
+	//writes whose deadline expires while they wait are gateway timeouts, like the transactions which time out
+	expired, cancelExpired := context.WithTimeout(write, time.Millisecond)
+	defer cancelExpired()
+
+	ordered.maxWait = time.Minute
+	_, err = ordered.SendWRP(expired, message, "")
+	if timeoutErr, ok := err.(*common.TimeoutError); assert.True(ok) {
+		assert.Equal(http.StatusGatewayTimeout, timeoutErr.StatusCode())
+		assert.Equal("write_order_timeout", timeoutErr.ErrorCode())
+	}
+
+	//writes which gave up leave the queue, so the next one is sent once the first one is done
+	close(s.release)
+	<-done
+
+	_, err = ordered.SendWRP(write, message, "")
+	assert.Nil(err)
+	assert.Equal([]string{"blocked", "waiting"}, s.sent)
+	assert.Empty(ordered.queues)
+}
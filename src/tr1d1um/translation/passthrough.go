@@ -0,0 +1,76 @@
+package translation
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+)
+
+//passthrough tells whether the given successful XMiDT response can be written as is to the API consumer, sparing
+//its decoding and re-encoding. This is the case when the API consumer accepts the media type of the response (i.e.
+//application/msgpack, for the WRP message) and nothing needs to be changed in the device payload for the request
+//(redaction, aliases, types, projections, envelopes and response transforms)
+func passthrough(ctx context.Context, resp *common.XmidtResponse) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.ContentType)
+	if err != nil || !acceptsToken(common.RequestHeaders(ctx).Get("Accept"), mediaType) {
+		return false
+	}
+
+	return !changesPayload(ctx)
+}
+
+//writePassthrough writes the given XMiDT response as is: compressed, if it was and the API consumer accepts its
+//encoding, else decompressed
+func writePassthrough(ctx context.Context, w http.ResponseWriter, resp *common.XmidtResponse) (err error) {
+	var body = resp.Body
+	if resp.ContentEncoding != "" && acceptsToken(common.RequestHeaders(ctx).Get("Accept-Encoding"), resp.ContentEncoding) {
+		body = resp.Compressed
+		w.Header().Set("Content-Encoding", resp.ContentEncoding)
+	}
+
+	w.Header().Set(contentTypeHeaderKey, resp.ContentType)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err = w.Write(body); err != nil && ctx.Err() == context.Canceled {
+		err = common.ErrClientCanceled
+	}
+
+	return
+}
+
+//changesPayload tells whether the device payload of the response to the request of the given context is modified
+//before it reaches the API consumer
+func changesPayload(ctx context.Context) bool {
+	for _, key := range []interface{}{redactionContextKey{}, aliasesContextKey{}, dataModelContextKey{}, projectionContextKey{}, envelopeContextKey{}} {
+		if ctx.Value(key) != nil {
+			return true
+		}
+	}
+
+	t, _ := ctx.Value(transformContextKey{}).(*Transform)
+	return t != nil && t.Response != nil
+}
+
+//acceptsToken tells whether the given Accept or Accept-Encoding header explicitly lists the given media type or
+//encoding with a non-zero quality. Wildcards don't count: API consumers have to ask for the XMiDT response as is
+func acceptsToken(header, token string) bool {
+	for _, accepted := range strings.Split(header, ",") {
+		var parameters = strings.Split(accepted, ";")
+		if !strings.EqualFold(strings.TrimSpace(parameters[0]), token) {
+			continue
+		}
+
+		for _, parameter := range parameters[1:] {
+			if quality := strings.Replace(parameter, " ", "", -1); strings.HasPrefix(quality, "q=") && strings.Trim(quality[2:], "0.") == "" {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,119 @@
+package translation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptsToken(t *testing.T) {
+	for _, testCase := range []struct {
+		header   string
+		expected bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"application/json", false},
+		{"application/msgpack", true},
+		{"application/json, Application/MsgPack;q=0.5", true},
+		{"application/msgpack; q=0", false},
+		{"application/msgpack;q=0.00", false},
+	} {
+		assert.Equal(t, testCase.expected, acceptsToken(testCase.header, "application/msgpack"), testCase.header)
+	}
+}
+
+func TestEncodeResponsePassthrough(t *testing.T) {
+	var (
+		body     = wrp.MustEncode(&wrp.Message{Type: wrp.SimpleRequestResponseMessageType, Payload: []byte(`{"statusCode":520}`)}, wrp.Msgpack)
+		response = &common.XmidtResponse{
+			Code:            http.StatusOK,
+			Body:            body,
+			Compressed:      []byte("gzipped"),
+			ContentEncoding: "gzip",
+			ContentType:     wrp.Msgpack.ContentType(),
+		}
+
+		requestContext = func(headers http.Header) context.Context {
+			return context.WithValue(ctxTID, common.ContextKeyRequestHeaders, headers)
+		}
+	)
+
+	for _, testCase := range []struct {
+		name            string
+		passthrough     bool
+		ctx             context.Context
+		expectedBody    string
+		expectedType    string
+		expectedEncoded bool
+	}{
+		{
+			name:         "Disabled",
+			ctx:          requestContext(http.Header{"Accept": []string{"application/msgpack"}}),
+			expectedBody: `{"statusCode":520}`,
+			expectedType: "application/json; charset=utf-8",
+		},
+		{
+			name:         "NotAccepted",
+			passthrough:  true,
+			ctx:          requestContext(http.Header{"Accept": []string{"*/*"}}),
+			expectedBody: `{"statusCode":520}`,
+			expectedType: "application/json; charset=utf-8",
+		},
+		{
+			name:         "Redacted",
+			passthrough:  true,
+			ctx:          context.WithValue(requestContext(http.Header{"Accept": []string{"application/msgpack"}}), redactionContextKey{}, new(Redaction)),
+			expectedBody: `{"statusCode":520}`,
+			expectedType: "application/json; charset=utf-8",
+		},
+		{
+			name:         "Decompressed",
+			passthrough:  true,
+			ctx:          requestContext(http.Header{"Accept": []string{"application/msgpack"}}),
+			expectedBody: string(body),
+			expectedType: wrp.Msgpack.ContentType(),
+		},
+		{
+			name:            "Compressed",
+			passthrough:     true,
+			ctx:             requestContext(http.Header{"Accept": []string{"application/msgpack"}, "Accept-Encoding": []string{"gzip"}}),
+			expectedBody:    "gzipped",
+			expectedType:    wrp.Msgpack.ContentType(),
+			expectedEncoded: true,
+		},
+	} {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				assert   = assert.New(t)
+				recorder = httptest.NewRecorder()
+			)
+
+			assert.Nil(encodeResponse(&encodeOptions{passthrough: testCase.passthrough})(testCase.ctx, recorder, response))
+			assert.Equal(testCase.expectedBody, recorder.Body.String())
+			assert.Equal(testCase.expectedType, recorder.Header().Get(contentTypeHeaderKey))
+			assert.Equal("test-tid", recorder.Header().Get(common.TIDHeader(context.Background())))
+
+			if testCase.expectedEncoded {
+				assert.Equal("gzip", recorder.Header().Get("Content-Encoding"))
+			} else {
+				assert.Empty(recorder.Header().Get("Content-Encoding"))
+			}
+
+			//passed through responses keep the status code of the XMiDT API whatever the device reported
+			if testCase.expectedType == wrp.Msgpack.ContentType() {
+				assert.Equal(http.StatusOK, recorder.Code)
+			} else {
+				assert.Equal(520, recorder.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package translation
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/device"
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+//NewPresenceService decorates the given service so that requests for devices recently found offline (i.e. through
+//their statistics) fail right away with a DeviceOfflineError instead of waiting for the XMiDT API to give up
+//Devices are known to be back online as soon as they respond
+func NewPresenceService(s Service, presence *common.PresenceCache) Service {
+	if presence == nil {
+		return s
+	}
+
+	return &presenceService{
+		Service:  s,
+		presence: presence,
+	}
+}
+
+type presenceService struct {
+	Service
+
+	presence *common.PresenceCache
+}
+
+func (p *presenceService) SendWRP(ctx context.Context, wrpMsg *wrp.Message, authValue string) (*common.XmidtResponse, error) {
+	var deviceID = wrpMsg.Destination
+	if canonicalID, err := device.ParseID(wrpMsg.Destination); err == nil {
+		deviceID = string(canonicalID)
+	}
+
+	if lastSeen, offline := p.presence.Offline(deviceID); offline {
+		return nil, &common.DeviceOfflineError{DeviceID: deviceID, LastSeen: lastSeen}
+	}
+
+	result, err := p.Service.SendWRP(ctx, wrpMsg, authValue)
+	if err == nil && result.Code == http.StatusOK {
+		p.presence.Observe(deviceID, true)
+	}
+
+	return result, err
+}
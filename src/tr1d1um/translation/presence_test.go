@@ -0,0 +1,47 @@
+package translation
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPresenceService(t *testing.T) {
+	s := new(MockService)
+	assert.Equal(t, s, NewPresenceService(s, nil))
+
+	var (
+		presence        = common.NewPresenceCache(common.PresenceOptions{TTL: time.Minute})
+		presenceService = NewPresenceService(s, presence)
+		wrpMsg          = &wrp.Message{Destination: "mac:112233445566/config"}
+		ok              = &common.XmidtResponse{Code: http.StatusOK}
+	)
+
+	t.Run("Offline", func(t *testing.T) {
+		assert := assert.New(t)
+		presence.Observe("mac:112233445566", false)
+
+		_, err := presenceService.SendWRP(ctxTID, wrpMsg, "")
+		assert.IsType(&common.DeviceOfflineError{}, err)
+		assert.EqualValues(http.StatusNotFound, err.(common.CodedError).StatusCode())
+		assert.EqualValues("device-not-connected", err.(common.ErrorCoder).ErrorCode())
+		s.AssertNotCalled(t, "SendWRP", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Online", func(t *testing.T) {
+		assert := assert.New(t)
+		presence.Observe("mac:112233445566", true)
+		s.On("SendWRP", mock.Anything, wrpMsg, "").Return(ok, nil).Once()
+
+		result, err := presenceService.SendWRP(ctxTID, wrpMsg, "")
+		assert.Nil(err)
+		assert.Equal(ok, result)
+		s.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,89 @@
+package translation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
+
+	"github.com/Comcast/webpa-common/wrp"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+//ErrUnknownProfile is returned when a request targets a parameter profile that is not configured
+var ErrUnknownProfile = common.NewCodedError(errors.New("unknown parameter profile"), http.StatusNotFound)
+
+//Profiles maps names to bundles of parameters that are set together (i.e. "guest-wifi-off")
+//Profile names are case-insensitive
+type Profiles map[string][]wdmp.SetParam
+
+//Validate returns an error if any of the profiles would not produce a valid SET
+func (p Profiles) Validate() error {
+	for name, parameters := range p {
+		if _, err := profilePayload(parameters, "", "", ""); err != nil {
+			return fmt.Errorf("parameter profile '%s' is invalid: %s", name, err)
+		}
+	}
+	return nil
+}
+
+//parameters returns the parameters of the given profile, if it exists
+func (p Profiles) parameters(name string) (parameters []wdmp.SetParam, ok bool) {
+	for profileName, profileParameters := range p {
+		if strings.EqualFold(profileName, name) {
+			return profileParameters, true
+		}
+	}
+	return
+}
+
+//profilePayload builds the SET WDMP for the given profile parameters
+func profilePayload(parameters []wdmp.SetParam, newCID, oldCID, syncCMC string) ([]byte, error) {
+	body, err := json.Marshal(&wdmp.SetRequest{Parameters: parameters})
+	if err != nil {
+		return nil, err
+	}
+
+	return wdmp.SetPayload(bytes.NewReader(body), newCID, oldCID, syncCMC)
+}
+
+//decodeProfileRequest returns the function that decodes the application of a parameter profile to a device into a WRP request
+//The WPA sync headers are honored so that profiles can be applied through TEST_AND_SET
+func decodeProfileRequest(profiles Profiles, addressing *WRPAddressing, aliases *Aliases) kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		var vars = mux.Vars(r)
+
+		parameters, ok := profiles.parameters(vars["profile"])
+		if !ok {
+			return nil, ErrUnknownProfile
+		}
+
+		payload, err := profilePayload(parameters, r.Header.Get(HeaderWPASyncNewCID), r.Header.Get(HeaderWPASyncOldCID), r.Header.Get(HeaderWPASyncCMC))
+		if err != nil {
+			return nil, err
+		}
+
+		payload = aliases.expand(payload)
+
+		var wrpMsg *wrp.Message
+		if wrpMsg, err = wrap(payload, common.RequestTID(ctx), vars, common.RequestPartner(ctx), addressing); err != nil {
+			return nil, err
+		}
+
+		if err = encodeWDMP(ctx, wrpMsg); err != nil {
+			return nil, err
+		}
+
+		return &wrpRequest{
+			WRPMessage:      wrpMsg,
+			AuthHeaderValue: r.Header.Get(authHeaderKey),
+		}, nil
+	}
+}
@@ -0,0 +1,74 @@
+package translation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestProfiles() Profiles {
+	var (
+		name     = "Device.WiFi.SSID.3.Enable"
+		dataType = int8(3)
+	)
+
+	return Profiles{
+		"guest-wifi-off": []wdmp.SetParam{{Name: &name, DataType: &dataType, Value: "false"}},
+	}
+}
+
+func TestProfilesValidate(t *testing.T) {
+	assert := assert.New(t)
+	name := "Device.WiFi.SSID.3.Enable"
+
+	assert.Nil(newTestProfiles().Validate())
+	assert.NotNil(Profiles{"empty": nil}.Validate())
+	assert.NotNil(Profiles{"untyped": []wdmp.SetParam{{Name: &name, Value: "false"}}}.Validate())
+}
+
+func TestDecodeProfileRequest(t *testing.T) {
+	var profiles = newTestProfiles()
+
+	t.Run("UnknownProfile", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPatch, "http://localhost", nil)
+		r = mux.SetURLVars(r, map[string]string{"deviceid": "mac:112233445566", "service": "config", "profile": "unknown"})
+
+		_, err := decodeProfileRequest(profiles, nil, nil)(ctxTID, r)
+		assert.EqualValues(t, ErrUnknownProfile, err)
+	})
+
+	t.Run("InvalidSyncHeaders", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPatch, "http://localhost", nil)
+		r = mux.SetURLVars(r, map[string]string{"deviceid": "mac:112233445566", "service": "config", "profile": "guest-wifi-off"})
+		r.Header.Set(HeaderWPASyncOldCID, "old")
+
+		_, err := decodeProfileRequest(profiles, nil, nil)(ctxTID, r)
+		assert.EqualValues(t, ErrNewCIDRequired, err)
+	})
+
+	t.Run("Ideal", func(t *testing.T) {
+		assert := assert.New(t)
+		r := httptest.NewRequest(http.MethodPatch, "http://localhost", nil)
+		r = mux.SetURLVars(r, map[string]string{"deviceid": "mac:112233445566", "service": "config", "profile": "Guest-WiFi-Off"})
+		r.Header.Set(authHeaderKey, "Basic xyz")
+
+		decoded, err := decodeProfileRequest(profiles, nil, nil)(ctxTID, r)
+		assert.Nil(err)
+
+		request := decoded.(*wrpRequest)
+		assert.EqualValues("Basic xyz", request.AuthHeaderValue)
+		assert.EqualValues("mac:112233445566/config", request.WRPMessage.Destination)
+
+		var set wdmp.SetRequest
+		assert.Nil(json.Unmarshal(request.WRPMessage.Payload, &set))
+		assert.EqualValues(wdmp.CommandSet, set.Command)
+		assert.EqualValues("Device.WiFi.SSID.3.Enable", *set.Parameters[0].Name)
+		assert.EqualValues("false", set.Parameters[0].Value)
+	})
+}
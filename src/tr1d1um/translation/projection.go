@@ -0,0 +1,107 @@
+package translation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+//fieldsQueryParam is the query parameter through which API consumers select the device
+//parameters they want in the response (i.e. ?fields=Device.WiFi.SSID.1.SSID,Device.WiFi.SSID.2.)
+//Fields ending with '.' select every parameter under that object
+const fieldsQueryParam = "fields"
+
+type projectionContextKey struct{}
+
+//captureProjection stores the fields requested by the API consumer, if any, in the context
+func captureProjection(ctx context.Context, r *http.Request) context.Context {
+	var fields []string
+
+	for _, field := range strings.Split(r.URL.Query().Get(fieldsQueryParam), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+
+	if len(fields) == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, projectionContextKey{}, fields)
+}
+
+//projectFields returns the device payload with only the parameters the API consumer asked for
+//Payloads are returned untouched if no fields were requested or they don't hold parameters
+func projectFields(ctx context.Context, payload []byte) []byte {
+	fields, ok := ctx.Value(projectionContextKey{}).([]string)
+	if !ok {
+		return payload
+	}
+
+	var (
+		document map[string]interface{}
+		decoder  = json.NewDecoder(bytes.NewReader(payload))
+	)
+
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return payload
+	}
+
+	parameters, ok := document["parameters"].([]interface{})
+	if !ok {
+		return payload
+	}
+
+	document["parameters"] = projectParameters(parameters, fields)
+
+	projected, err := marshalJSON(document)
+	if err != nil {
+		return payload
+	}
+
+	return projected
+}
+
+//projectParameters filters the given device parameters down to the selected fields
+//Objects (parameters whose value is a list of parameters) are kept if any of their children are selected
+func projectParameters(parameters []interface{}, fields []string) []interface{} {
+	var projected = make([]interface{}, 0, len(parameters))
+
+	for _, p := range parameters {
+		parameter, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if name, _ := parameter["name"].(string); selected(name, fields) {
+			projected = append(projected, parameter)
+			continue
+		}
+
+		if children, ok := parameter["value"].([]interface{}); ok {
+			if children = projectParameters(children, fields); len(children) > 0 {
+				parameter["value"] = children
+
+				if _, ok := parameter["parameterCount"]; ok {
+					parameter["parameterCount"] = len(children)
+				}
+
+				projected = append(projected, parameter)
+			}
+		}
+	}
+
+	return projected
+}
+
+func selected(name string, fields []string) bool {
+	for _, field := range fields {
+		if name == field || (strings.HasSuffix(field, ".") && strings.HasPrefix(name, field)) {
+			return true
+		}
+	}
+	return false
+}
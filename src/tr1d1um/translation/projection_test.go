@@ -0,0 +1,82 @@
+package translation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const objectPayload = `{"parameters":[{"name":"Device.WiFi.SSID.","value":[` +
+	`{"name":"Device.WiFi.SSID.1.SSID","value":"home","dataType":0},` +
+	`{"name":"Device.WiFi.SSID.1.Enable","value":"true","dataType":3},` +
+	`{"name":"Device.WiFi.SSID.2.SSID","value":"guest","dataType":0}],` +
+	`"dataType":11,"parameterCount":3,"message":"Success"},` +
+	`{"name":"Device.DeviceInfo.UpTime","value":"123","dataType":2,"parameterCount":1,"message":"Success"}],"statusCode":200}`
+
+func TestCaptureProjection(t *testing.T) {
+	t.Run("NoFields", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "http://localhost/device?fields=,", nil)
+		assert.Nil(t, captureProjection(context.Background(), r).Value(projectionContextKey{}))
+	})
+
+	t.Run("Fields", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "http://localhost/device?fields=a.b,%20c.", nil)
+		assert.EqualValues(t, []string{"a.b", "c."}, captureProjection(context.Background(), r).Value(projectionContextKey{}))
+	})
+}
+
+func TestProjectFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   []string
+		payload  string
+		expected string
+	}{
+		{
+			name:     "Leaf",
+			fields:   []string{"Device.DeviceInfo.UpTime"},
+			payload:  objectPayload,
+			expected: `{"parameters":[{"name":"Device.DeviceInfo.UpTime","value":"123","dataType":2,"parameterCount":1,"message":"Success"}],"statusCode":200}`,
+		},
+		{
+			name:    "ObjectChildren",
+			fields:  []string{"Device.WiFi.SSID.1.SSID", "Device.WiFi.SSID.2."},
+			payload: objectPayload,
+			expected: `{"parameters":[{"name":"Device.WiFi.SSID.","value":[` +
+				`{"name":"Device.WiFi.SSID.1.SSID","value":"home","dataType":0},` +
+				`{"name":"Device.WiFi.SSID.2.SSID","value":"guest","dataType":0}],` +
+				`"dataType":11,"parameterCount":2,"message":"Success"}],"statusCode":200}`,
+		},
+		{
+			name:     "NoMatches",
+			fields:   []string{"Device.X"},
+			payload:  objectPayload,
+			expected: `{"parameters":[],"statusCode":200}`,
+		},
+		{
+			name:     "NoParameters",
+			fields:   []string{"Device.X"},
+			payload:  `{"statusCode":200,"message":"Success"}`,
+			expected: `{"statusCode":200,"message":"Success"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), projectionContextKey{}, test.fields)
+			assert.JSONEq(t, test.expected, string(projectFields(ctx, []byte(test.payload))))
+		})
+	}
+
+	t.Run("NotJSON", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), projectionContextKey{}, []string{"Device.X"})
+		assert.EqualValues(t, "parameters", string(projectFields(ctx, []byte("parameters"))))
+	})
+
+	t.Run("NotRequested", func(t *testing.T) {
+		assert.EqualValues(t, objectPayload, string(projectFields(context.Background(), []byte(objectPayload))))
+	})
+}
@@ -0,0 +1,164 @@
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+//HeaderWebpaQoS is the header through which API consumers set the WRP QoS of their requests
+//Values are either a number between 0 and 99 or one of the level names (i.e. critical)
+const HeaderWebpaQoS = "X-Webpa-QoS"
+
+//Lower bounds of the WRP QoS levels
+const (
+	QoSLow      = 0
+	QoSMedium   = 25
+	QoSHigh     = 50
+	QoSCritical = 75
+	maxQoS      = 99
+)
+
+var qosLevels = map[string]int{
+	"low":      QoSLow,
+	"medium":   QoSMedium,
+	"high":     QoSHigh,
+	"critical": QoSCritical,
+}
+
+//ErrInvalidQoS is returned when the QoS requested by the API consumer cannot be parsed
+var ErrInvalidQoS = common.NewBadRequestError(fmt.Errorf("%s header should be a number between 0 and %d or one of low, medium, high and critical", HeaderWebpaQoS, maxQoS))
+
+//QoSRule infers the QoS of requests which don't ask for one from their WDMP operation
+type QoSRule struct {
+	//Command is the WDMP command (i.e. SET) the rule applies to. Any command matches if empty
+	Command string
+
+	//Names are prefixes of the parameter or table names the rule applies to. Any name matches if empty
+	Names []string
+
+	//QoS is the WRP QoS (0-99) of the matching requests
+	QoS int
+}
+
+//QoSRules are evaluated in order. The first matching rule sets the QoS of a request
+type QoSRules []QoSRule
+
+//Validate returns an error if any of the rules has an out of range QoS
+func (q QoSRules) Validate() error {
+	for i, rule := range q {
+		if rule.QoS < 0 || rule.QoS > maxQoS {
+			return fmt.Errorf("QoS rule %d has QoS %d which is not between 0 and %d", i, rule.QoS, maxQoS)
+		}
+	}
+
+	return nil
+}
+
+//parseQoS returns the QoS value of the given X-Webpa-QoS header value
+func parseQoS(value string) (int, error) {
+	if qos, ok := qosLevels[strings.ToLower(value)]; ok {
+		return qos, nil
+	}
+
+	qos, err := strconv.Atoi(value)
+	if err != nil || qos < 0 || qos > maxQoS {
+		return 0, ErrInvalidQoS
+	}
+
+	return qos, nil
+}
+
+//qos returns the QoS of the given outgoing message, if any: the one asked for by the API consumer,
+//else the one of the first rule matching the WDMP operation
+func (q QoSRules) qos(ctx context.Context, m *wrp.Message) (qos int, ok bool, err error) {
+	inboundHeaders := common.RequestHeaders(ctx)
+	if value := strings.TrimSpace(inboundHeaders.Get(HeaderWebpaQoS)); value != "" {
+		qos, err = parseQoS(value)
+		return qos, err == nil, err
+	}
+
+	if len(q) == 0 {
+		return
+	}
+
+	command, names, isWDMP := operation(ctx, m.Payload)
+	if !isWDMP {
+		return
+	}
+
+	for _, rule := range q {
+		if rule.matches(command, names) {
+			return rule.QoS, true, nil
+		}
+	}
+
+	return
+}
+
+func (r QoSRule) matches(command string, names []string) bool {
+	if r.Command != "" && !strings.EqualFold(r.Command, command) {
+		return false
+	}
+
+	if len(r.Names) == 0 {
+		return true
+	}
+
+	for _, name := range names {
+		for _, prefix := range r.Names {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//operation returns the command and the parameter, table or row names of the given WDMP payload
+func operation(ctx context.Context, payload []byte) (command string, names []string, ok bool) {
+	if e, err := wdmpEncoding(ctx); err == nil && e != nil {
+		if payload, err = e.Decode(payload); err != nil {
+			return
+		}
+	}
+
+	var wdmpModel struct {
+		Command    string      `json:"command"`
+		Names      []string    `json:"names"`
+		Table      string      `json:"table"`
+		Row        interface{} `json:"row"`
+		Parameters []struct {
+			Name *string `json:"name"`
+		} `json:"parameters"`
+	}
+
+	if json.Unmarshal(payload, &wdmpModel) != nil || wdmpModel.Command == "" {
+		return
+	}
+
+	names = wdmpModel.Names
+	if wdmpModel.Table != "" {
+		names = append(names, wdmpModel.Table)
+	}
+
+	//the row of DELETE_ROW is a name while the one of ADD_ROW holds its values
+	if row, ok := wdmpModel.Row.(string); ok {
+		names = append(names, row)
+	}
+
+	for _, parameter := range wdmpModel.Parameters {
+		if parameter.Name != nil {
+			names = append(names, *parameter.Name)
+		}
+	}
+
+	return wdmpModel.Command, names, true
+}
@@ -0,0 +1,111 @@
+package translation
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ugorji/go/codec"
+)
+
+func qosContext(qos string) context.Context {
+	var headers = http.Header{}
+	if qos != "" {
+		headers.Set(HeaderWebpaQoS, qos)
+	}
+
+	return context.WithValue(ctxTID, common.ContextKeyRequestHeaders, headers)
+}
+
+func TestQoSRulesValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(QoSRules{{Command: "SET", QoS: 99}}.Validate())
+	assert.NotNil(QoSRules{{QoS: 100}}.Validate())
+	assert.NotNil(QoSRules{{QoS: -1}}.Validate())
+}
+
+func TestQoSRulesQoS(t *testing.T) {
+	var rules = QoSRules{
+		{Command: "SET", Names: []string{"Device.X_CISCO_COM_DeviceControl.RebootDevice"}, QoS: QoSCritical},
+		{Command: "add_row", Names: []string{"Device.NAT."}, QoS: QoSHigh},
+		{Command: "SET", QoS: 10},
+	}
+
+	testCases := []struct {
+		name    string
+		header  string
+		payload string
+		qos     int
+		ok      bool
+		err     error
+	}{
+		{"HeaderLevel", "Critical", `{"command":"GET","names":["p"]}`, QoSCritical, true, nil},
+		{"HeaderNumber", "42", `{"command":"SET"}`, 42, true, nil},
+		{"HeaderOutOfRange", "100", `{"command":"GET","names":["p"]}`, 0, false, ErrInvalidQoS},
+		{"HeaderInvalid", "urgent", `{"command":"GET","names":["p"]}`, 0, false, ErrInvalidQoS},
+		{"Reboot", "", `{"command":"SET","parameters":[{"name":"Device.X_CISCO_COM_DeviceControl.RebootDevice","value":"Device"}]}`, QoSCritical, true, nil},
+		{"Table", "", `{"command":"ADD_ROW","table":"Device.NAT.PortMapping.","row":{}}`, QoSHigh, true, nil},
+		{"BulkSet", "", `{"command":"SET","parameters":[{"name":"Device.WiFi.SSID.1.SSID","value":"x"}]}`, 10, true, nil},
+		{"NoMatch", "", `{"command":"GET","names":["Device.NAT."]}`, 0, false, nil},
+		{"NotWDMP", "", `raw`, 0, false, nil},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert := assert.New(t)
+			qos, ok, err := rules.qos(qosContext(testCase.header), &wrp.Message{Payload: []byte(testCase.payload)})
+
+			assert.Equal(testCase.qos, qos)
+			assert.Equal(testCase.ok, ok)
+			assert.Equal(testCase.err, err)
+		})
+	}
+}
+
+func TestSendWRPQoS(t *testing.T) {
+	var (
+		m = new(common.MockTr1d1umTransactor)
+		s = NewService(&ServiceOptions{
+			XmidtWrpURL:       "http://localhost/wrp",
+			WRPSource:         "local",
+			Tr1d1umTransactor: m,
+			QoSRules:          QoSRules{{Command: "SET", QoS: 10}},
+		})
+
+		sent map[string]interface{}
+	)
+
+	m.On("Transact", mock.MatchedBy(func(r *http.Request) bool {
+		data, _ := ioutil.ReadAll(r.Body)
+		sent = nil
+		return codec.NewDecoderBytes(data, new(codec.MsgpackHandle)).Decode(&sent) == nil
+	})).Return(nil, nil)
+
+	t.Run("Inferred", func(t *testing.T) {
+		assert := assert.New(t)
+		_, err := s.SendWRP(qosContext(""), &wrp.Message{Source: "test", Payload: []byte(`{"command":"SET"}`)}, "token")
+
+		assert.Nil(err)
+		assert.EqualValues(10, sent["qos"])
+		assert.EqualValues("local/test", sent["source"])
+	})
+
+	t.Run("None", func(t *testing.T) {
+		_, err := s.SendWRP(qosContext(""), &wrp.Message{Payload: []byte(`{"command":"GET","names":["p"]}`)}, "token")
+		assert.Nil(t, err)
+		assert.NotContains(t, sent, "qos")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := s.SendWRP(qosContext("-1"), &wrp.Message{}, "token")
+		assert.Equal(t, ErrInvalidQoS, err)
+		m.AssertNumberOfCalls(t, "Transact", 2)
+	})
+}
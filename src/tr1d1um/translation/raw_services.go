@@ -0,0 +1,64 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/go-kit/kit/metrics"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+)
+
+//DefaultRawServices are the device services whose requests carry raw data rather than WDMP, unless configured otherwise
+var DefaultRawServices = []string{"iot"}
+
+//ErrRawServiceQuery is returned for requests to raw services which pass data through query parameters
+//rather than in the request body, which is all that is sent to devices
+var ErrRawServiceQuery = common.NewBadRequestError(errors.New("this service only accepts data in the request body. Query parameters are not allowed"))
+
+type rawServiceContextKey struct{}
+
+//captureRawService records whether the request is for one of the given raw services
+func captureRawService(services []string) kithttp.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		if contains(mux.Vars(r)["service"], services) {
+			return context.WithValue(ctx, rawServiceContextKey{}, true)
+		}
+
+		return ctx
+	}
+}
+
+//isRawService returns true if the request of the given context is for a raw service: its body is passed through
+//to the device untouched rather than as a WDMP document
+func isRawService(ctx context.Context) bool {
+	raw, _ := ctx.Value(rawServiceContextKey{}).(bool)
+	return raw
+}
+
+//decodeRawServiceRequest rejects the requests to raw services which come with query parameters
+//as they would otherwise be silently dropped. Rejections are counted by service
+func decodeRawServiceRequest(rejections metrics.Counter, decoder kithttp.DecodeRequestFunc) kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		if isRawService(ctx) && r.URL.RawQuery != "" {
+			rejections.With(common.ServiceLabel, mux.Vars(r)["service"]).Add(1)
+			return nil, ErrRawServiceQuery
+		}
+
+		return decoder(ctx, r)
+	}
+}
+
+//rawServicesPattern returns the mux pattern of the service path variable of raw service routes
+func rawServicesPattern(services []string) string {
+	var quoted = make([]string, len(services))
+	for i, service := range services {
+		quoted[i] = regexp.QuoteMeta(service)
+	}
+
+	return "{service:" + strings.Join(quoted, "|") + "}"
+}
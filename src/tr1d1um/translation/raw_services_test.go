@@ -0,0 +1,72 @@
+package translation
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeRawServiceRequest(t *testing.T) {
+	var (
+		rejections = new(labeledCounter)
+		decode     = decodeRawServiceRequest(rejections, decodeRequest(nil, nil))
+	)
+
+	t.Run("Body", func(t *testing.T) {
+		assert := assert.New(t)
+		r := httptest.NewRequest(http.MethodPost, "http://localhost", bytes.NewBufferString("raw"))
+		r = mux.SetURLVars(r, map[string]string{"deviceid": "mac:112233445566", "service": "iot"})
+
+		decoded, err := decode(captureRawService(DefaultRawServices)(ctxTID, r), r)
+		assert.Nil(err)
+		assert.EqualValues("raw", decoded.(*wrpRequest).WRPMessage.Payload)
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		assert := assert.New(t)
+		r := httptest.NewRequest(http.MethodPost, "http://localhost?names=p", bytes.NewBufferString("raw"))
+		r = mux.SetURLVars(r, map[string]string{"deviceid": "mac:112233445566", "service": "iot"})
+
+		_, err := decode(captureRawService(DefaultRawServices)(ctxTID, r), r)
+		assert.Equal(ErrRawServiceQuery, err)
+		assert.EqualValues(1, rejections.value)
+		assert.EqualValues([]string{common.ServiceLabel, "iot"}, rejections.labelValues)
+	})
+
+	t.Run("NotRaw", func(t *testing.T) {
+		assert := assert.New(t)
+		r := httptest.NewRequest(http.MethodPost, "http://localhost?names=p", bytes.NewBufferString("raw"))
+		r = mux.SetURLVars(r, map[string]string{"deviceid": "mac:112233445566", "service": "iot"})
+
+		//iot is not a raw service in this configuration so the body is expected to be a WDMP table row
+		_, err := decode(captureRawService([]string{"ble"})(ctxTID, r), r)
+		assert.Equal(ErrMissingTable, err)
+		assert.EqualValues(1, rejections.value)
+	})
+}
+
+func TestRawServicesRoute(t *testing.T) {
+	assert := assert.New(t)
+	router := mux.NewRouter()
+	router.Handle("/device/{deviceid}/"+rawServicesPattern([]string{"iot", "ble.v2"}), http.NotFoundHandler())
+
+	var match mux.RouteMatch
+	assert.True(router.Match(httptest.NewRequest(http.MethodPost, "/device/mac:112233445566/ble.v2", nil), &match))
+	assert.Equal("ble.v2", match.Vars["service"])
+	assert.False(router.Match(httptest.NewRequest(http.MethodPost, "/device/mac:112233445566/blexv2", nil), &match))
+	assert.False(router.Match(httptest.NewRequest(http.MethodPost, "/device/mac:112233445566/config", nil), &match))
+}
+
+func TestRawServiceQueriesMetric(t *testing.T) {
+	var found bool
+	for _, metric := range common.Metrics() {
+		found = found || metric.Name == common.RawServiceQueriesCounter
+	}
+
+	assert.True(t, found)
+}
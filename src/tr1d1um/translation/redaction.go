@@ -0,0 +1,120 @@
+package translation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+)
+
+//DefaultRedactionMask replaces the values of sensitive parameters when no mask is configured
+const DefaultRedactionMask = "********"
+
+//Redaction masks the values of sensitive parameters (i.e. Wi-Fi passphrases) in device responses so that
+//low-privilege API consumers can view the configuration of devices without seeing secrets
+type Redaction struct {
+	//Parameters are the TR-181 names of the sensitive parameters. Names ending with '.' cover all the parameters below
+	Parameters []string
+
+	//Capabilities let the API consumers holding any of them in their JWT see the values of sensitive parameters
+	Capabilities []string
+
+	//Mask replaces sensitive values. Defaults to DefaultRedactionMask
+	Mask string
+}
+
+type redactionContextKey struct{}
+
+//captureRedaction returns the function that records in the context that sensitive values must be masked in
+//the response, unless the API consumer holds one of the capabilities that lets them see them
+func captureRedaction(redaction *Redaction) kithttp.RequestFunc {
+	return func(ctx context.Context, _ *http.Request) context.Context {
+		if redaction == nil || len(redaction.Parameters) == 0 {
+			return ctx
+		}
+
+		for _, capability := range common.Claim(ctx, common.CapabilitiesClaim) {
+			if contains(capability, redaction.Capabilities) {
+				return ctx
+			}
+		}
+
+		return context.WithValue(ctx, redactionContextKey{}, redaction)
+	}
+}
+
+//redactValues returns the device payload with the values of the sensitive parameters masked, if required
+//Payloads which don't hold parameters are returned untouched
+func redactValues(ctx context.Context, payload []byte) []byte {
+	redaction, ok := ctx.Value(redactionContextKey{}).(*Redaction)
+	if !ok {
+		return payload
+	}
+
+	var (
+		document map[string]interface{}
+		decoder  = json.NewDecoder(bytes.NewReader(payload))
+	)
+
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return payload
+	}
+
+	parameters, ok := document["parameters"].([]interface{})
+	if !ok || !redaction.redact(parameters) {
+		return payload
+	}
+
+	if redacted, err := marshalJSON(document); err == nil {
+		return redacted
+	}
+
+	return payload
+}
+
+//redact masks the values of the sensitive parameters among the given ones and their children
+//It reports whether any value was masked
+func (r *Redaction) redact(parameters []interface{}) (redacted bool) {
+	var mask = r.Mask
+	if mask == "" {
+		mask = DefaultRedactionMask
+	}
+
+	for _, p := range parameters {
+		parameter, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if children, ok := parameter["value"].([]interface{}); ok {
+			redacted = r.redact(children) || redacted
+			continue
+		}
+
+		if _, ok := parameter["value"]; !ok {
+			continue
+		}
+
+		if name, _ := parameter["name"].(string); selected(name, r.Parameters) {
+			parameter["value"] = mask
+			redacted = true
+		}
+	}
+
+	return
+}
+
+//redactedSample returns a sample of the given undecodable payload for logging unless sensitive values must be masked,
+//in which case they can't be told apart in the sample
+func redactedSample(ctx context.Context, body []byte) string {
+	if _, ok := ctx.Value(redactionContextKey{}).(*Redaction); ok {
+		return "(redacted)"
+	}
+
+	return bodySample(body)
+}
@@ -0,0 +1,68 @@
+package translation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/comcast-bascule/bascule"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactValues(t *testing.T) {
+	var (
+		redaction = &Redaction{
+			Parameters:   []string{"Device.WiFi.AccessPoint.1.Security.KeyPassphrase", "Device.Users.User."},
+			Capabilities: []string{"x1:webpa:api:sensitive:all"},
+		}
+
+		payload = `{"parameters":[
+			{"name":"Device.WiFi.SSID.1.SSID","value":"home","dataType":0},
+			{"name":"Device.WiFi.AccessPoint.1.Security.KeyPassphrase","value":"secret","dataType":0},
+			{"name":"Device.Users.User.","value":[{"name":"Device.Users.User.1.Password","value":"hunter2","dataType":0}],"dataType":11}],
+			"statusCode":200}`
+
+		redacted = `{"parameters":[
+			{"name":"Device.WiFi.SSID.1.SSID","value":"home","dataType":0},
+			{"name":"Device.WiFi.AccessPoint.1.Security.KeyPassphrase","value":"********","dataType":0},
+			{"name":"Device.Users.User.","value":[{"name":"Device.Users.User.1.Password","value":"********","dataType":0}],"dataType":11}],
+			"statusCode":200}`
+	)
+
+	capture := func(capabilities ...interface{}) context.Context {
+		ctx := context.Background()
+		if capabilities != nil {
+			ctx = bascule.WithAuthentication(ctx, bascule.Authentication{
+				Token: bascule.NewToken("jwt", "dashboard", bascule.Attributes{"capabilities": capabilities}),
+			})
+		}
+
+		return captureRedaction(redaction)(ctx, httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	t.Run("NotConfigured", func(t *testing.T) {
+		ctx := captureRedaction(nil)(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.EqualValues(t, payload, redactValues(ctx, []byte(payload)))
+	})
+
+	t.Run("Redacted", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.JSONEq(redacted, string(redactValues(capture(), []byte(payload))))
+		assert.JSONEq(redacted, string(redactValues(capture("x1:webpa:api:device:all"), []byte(payload))))
+		assert.EqualValues("(redacted)", redactedSample(capture(), []byte(payload)))
+	})
+
+	t.Run("Capability", func(t *testing.T) {
+		assert := assert.New(t)
+		ctx := capture("x1:webpa:api:device:all", "x1:webpa:api:sensitive:all")
+
+		assert.EqualValues(payload, redactValues(ctx, []byte(payload)))
+		assert.EqualValues(bodySample([]byte(payload)), redactedSample(ctx, []byte(payload)))
+	})
+
+	t.Run("NoParameters", func(t *testing.T) {
+		assert.EqualValues(t, `{"statusCode":520}`, redactValues(capture(), []byte(`{"statusCode":520}`)))
+	})
+}
@@ -0,0 +1,69 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
+
+	"github.com/gorilla/mux"
+)
+
+const schemaContentType = "application/schema+json"
+
+//ErrUnknownSchema is returned when the requested request body schema does not exist
+var ErrUnknownSchema = common.NewCodedError(errors.New("unknown request body schema"), http.StatusNotFound)
+
+//requestBodySchemas are the JSON Schemas of the request bodies API consumers send, keyed by the name they are served under
+//Names are part of the schema URLs and should therefore never change
+var requestBodySchemas = map[string]map[string]interface{}{
+	"set":          wdmp.Schema("SET request body", wdmp.SetBody{}),
+	"add-row":      wdmp.Schema("ADD_ROW request body", wdmp.AddRowBody{}),
+	"replace-rows": wdmp.Schema("REPLACE_ROWS request body", wdmp.ReplaceRowsBody{}),
+	"group-set":    wdmp.Schema("Device group SET request body", wdmp.SetBody{}),
+	"batch":        wdmp.Schema("Batch request body", BatchBody{}),
+}
+
+type schemaIndex struct {
+	Schemas map[string]string `json:"schemas"`
+}
+
+//decodeSchemaRequest decodes requests for either the index of request body schemas or one of them
+func decodeSchemaRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	name, ok := mux.Vars(r)["schema"]
+	if !ok {
+		index := schemaIndex{Schemas: make(map[string]string, len(requestBodySchemas))}
+		for name := range requestBodySchemas {
+			index.Schemas[name] = path.Join(r.URL.Path, name)
+		}
+
+		return index, nil
+	}
+
+	if schema, ok := requestBodySchemas[name]; ok {
+		return schema, nil
+	}
+
+	return nil, ErrUnknownSchema
+}
+
+//encodeSchemaResponse writes the requested schema or schema index
+func encodeSchemaResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	body, err := marshalJSON(response)
+	if err != nil {
+		return err
+	}
+
+	var contentType = schemaContentType
+	if _, ok := response.(schemaIndex); ok {
+		contentType = "application/json"
+	}
+
+	w.Header().Set(contentTypeHeaderKey, contentType+"; charset=utf-8")
+	w.Header().Set(common.TIDHeader(ctx), common.RequestTID(ctx))
+	_, err = w.Write(body)
+	return err
+}
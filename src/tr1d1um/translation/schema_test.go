@@ -0,0 +1,54 @@
+package translation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeSchemaRequest(t *testing.T) {
+	t.Run("Index", func(t *testing.T) {
+		assert := assert.New(t)
+		r := httptest.NewRequest(http.MethodGet, "http://localhost/api/v2/schemas", nil)
+
+		decoded, err := decodeSchemaRequest(ctxTID, r)
+		assert.Nil(err)
+		assert.EqualValues("/api/v2/schemas/add-row", decoded.(schemaIndex).Schemas["add-row"])
+		assert.Len(decoded.(schemaIndex).Schemas, len(requestBodySchemas))
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "http://localhost/api/v2/schemas/get", nil), map[string]string{"schema": "get"})
+
+		_, err := decodeSchemaRequest(ctxTID, r)
+		assert.EqualValues(t, ErrUnknownSchema, err)
+	})
+
+	t.Run("Set", func(t *testing.T) {
+		assert := assert.New(t)
+		r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "http://localhost/api/v2/schemas/set", nil), map[string]string{"schema": "set"})
+		recorder := httptest.NewRecorder()
+
+		decoded, err := decodeSchemaRequest(ctxTID, r)
+		assert.Nil(err)
+		assert.Nil(encodeSchemaResponse(ctxTID, recorder, decoded))
+		assert.EqualValues("application/schema+json; charset=utf-8", recorder.Header().Get(contentTypeHeaderKey))
+
+		var schema struct {
+			Properties struct {
+				Parameters struct {
+					Items struct {
+						Required []string `json:"required"`
+					} `json:"items"`
+				} `json:"parameters"`
+			} `json:"properties"`
+		}
+
+		assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &schema))
+		assert.EqualValues([]string{"name"}, schema.Properties.Parameters.Items.Required)
+	})
+}
@@ -1,7 +1,7 @@
 package translation
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 
@@ -13,12 +13,13 @@ import (
 //Service represents the Webpa-Tr1d1um component that translates WDMP data into WRP
 //which is compatible with the XMiDT API
 type Service interface {
-	SendWRP(*wrp.Message, string) (*common.XmidtResponse, error)
+	SendWRP(context.Context, *wrp.Message, string) (*common.XmidtResponse, error)
 }
 
 //ServiceOptions defines the options needed to build a new translation WRP service
 type ServiceOptions struct {
 	//XmidtWrpURL is the URL of the XMiDT API which takes in WRP messages
+	//Only used when no Backend is given
 	XmidtWrpURL string
 
 	//WRPSource is currently the prefix of "Source" field in outgoing WRP Messages
@@ -26,42 +27,96 @@ type ServiceOptions struct {
 
 	//Tr1d1umTransactor is the component that's responsible to make the HTTP
 	//request to the XMiDT API and return only data we care about
+	//Only used when no Backend is given
 	common.Tr1d1umTransactor
+
+	//Backend delivers the WRP messages to devices
+	//If nil, messages are sent to the XMiDT API at XmidtWrpURL through the Tr1d1umTransactor
+	Backend common.Backend
+
+	//QoSRules infer the WRP QoS of requests which don't ask for one through the X-Webpa-QoS header (optional)
+	QoSRules QoSRules
+
+	//MaxMessageSize is the size in bytes of the largest msgpack encoded WRP message the XMiDT API and devices
+	//(parodus) accept. Larger messages are rejected before being sent. There is no limit if not positive
+	MaxMessageSize int
 }
 
 //NewService constructs a new translation service instance given some options
 func NewService(o *ServiceOptions) Service {
+	backend := o.Backend
+	if backend == nil {
+		backend = common.NewXmidtBackend(o.XmidtWrpURL, "", wrp.Msgpack, o.Tr1d1umTransactor)
+	}
+
 	return &service{
-		XmidtWrpURL:       o.XmidtWrpURL,
-		WRPSource:         o.WRPSource,
-		Tr1d1umTransactor: o.Tr1d1umTransactor,
+		Backend:   backend,
+		WRPSource: o.WRPSource,
+		QoSRules:  o.QoSRules,
+
+		MaxMessageSize: o.MaxMessageSize,
 	}
 }
 
 type service struct {
-	common.Tr1d1umTransactor
-
-	XmidtWrpURL string
+	common.Backend
 
 	WRPSource string
+
+	QoSRules QoSRules
+
+	MaxMessageSize int
+}
+
+//MessageSizeError is the CodedError returned for the WRP messages which exceed the size limit
+type MessageSizeError struct {
+	//Size is the size in bytes of the msgpack encoded message
+	Size int
+
+	//Limit is the size of the largest message accepted
+	Limit int
+}
+
+func (m *MessageSizeError) Error() string {
+	return fmt.Sprintf("WRP message of %d bytes exceeds the limit of %d bytes", m.Size, m.Limit)
+}
+
+//StatusCode is 413 Request Entity Too Large
+func (m *MessageSizeError) StatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+//ErrorCode lets API consumers tell oversized messages apart from oversized request bodies
+func (m *MessageSizeError) ErrorCode() string {
+	return "wrp_message_too_large"
 }
 
 //SendWRP sends the given wrpMsg to the XMiDT cluster and returns the response if any
-func (w *service) SendWRP(wrpMsg *wrp.Message, authValue string) (result *common.XmidtResponse, err error) {
-	var payload []byte
+func (w *service) SendWRP(ctx context.Context, wrpMsg *wrp.Message, authValue string) (result *common.XmidtResponse, err error) {
+	qos, hasQoS, err := w.QoSRules.qos(ctx, wrpMsg)
+	if err != nil {
+		return
+	}
 
 	// fill in the rest of the source property
 	wrpMsg.Source = fmt.Sprintf("%s/%s", w.WRPSource, wrpMsg.Source)
 
-	if err = wrp.NewEncoderBytes(&payload, wrp.Msgpack).Encode(wrpMsg); err == nil {
-		var req *http.Request
-		if req, err = http.NewRequest(http.MethodPost, w.XmidtWrpURL, bytes.NewBuffer(payload)); err == nil {
-
-			req.Header.Add("Content-Type", wrp.Msgpack.ContentType())
-			req.Header.Add("Authorization", authValue)
+	//the size is the one of the msgpack encoding, in which messages reach devices whatever the backend
+	if w.MaxMessageSize > 0 {
+		var encoded []byte
+		if encoded, err = common.EncodeWRP(wrpMsg); err != nil {
+			return
+		}
 
-			result, err = w.Tr1d1umTransactor.Transact(req)
+		if len(encoded) > w.MaxMessageSize {
+			return nil, &MessageSizeError{Size: len(encoded), Limit: w.MaxMessageSize}
 		}
 	}
-	return
+
+	return w.Backend.SendWRP(ctx, &common.WRPRequest{
+		Message:       wrpMsg,
+		QoS:           qos,
+		HasQoS:        hasQoS,
+		Authorization: authValue,
+	})
 }
@@ -1,6 +1,7 @@
 package translation
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
 	"testing"
@@ -41,7 +42,7 @@ func TestSendWRP(t *testing.T) {
 	}
 
 	m.On("Transact", mock.MatchedBy(argMatcher)).Return(nil, nil)
-	_, e := s.SendWRP(
+	_, e := s.SendWRP(context.TODO(),
 		&wrp.Message{
 			Type:   wrp.SimpleRequestResponseMessageType,
 			Source: "test",
@@ -49,3 +50,48 @@ func TestSendWRP(t *testing.T) {
 
 	assert.Nil(e)
 }
+
+func TestSendWRPBackend(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		backend = new(common.MockBackend)
+		s       = NewService(&ServiceOptions{
+			WRPSource: "local",
+			Backend:   backend,
+			QoSRules:  QoSRules{{Command: "SET", QoS: 10}},
+		})
+	)
+
+	backend.On("SendWRP", mock.Anything, &common.WRPRequest{
+		Message:       &wrp.Message{Source: "local/test", Payload: []byte(`{"command":"SET"}`)},
+		QoS:           10,
+		HasQoS:        true,
+		Authorization: "token",
+	}).Return(&common.XmidtResponse{Code: http.StatusOK}, nil)
+
+	response, err := s.SendWRP(context.TODO(), &wrp.Message{Source: "test", Payload: []byte(`{"command":"SET"}`)}, "token")
+
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, response.Code)
+	backend.AssertExpectations(t)
+}
+
+func TestSendWRPMaxMessageSize(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		backend = new(common.MockBackend)
+		message = &wrp.Message{Source: "test", Payload: []byte(`{"command":"SET"}`)}
+		size    = len(wrp.MustEncode(&wrp.Message{Source: "local/test", Payload: []byte(`{"command":"SET"}`)}, wrp.Msgpack))
+	)
+
+	backend.On("SendWRP", mock.Anything, mock.Anything).Return(&common.XmidtResponse{Code: http.StatusOK}, nil).Once()
+
+	_, err := NewService(&ServiceOptions{WRPSource: "local", Backend: backend, MaxMessageSize: size}).SendWRP(context.TODO(), message, "token")
+	assert.Nil(err)
+
+	message.Source = "test"
+	_, err = NewService(&ServiceOptions{WRPSource: "local", Backend: backend, MaxMessageSize: size - 1}).SendWRP(context.TODO(), message, "token")
+	assert.Equal(&MessageSizeError{Size: size, Limit: size - 1}, err)
+	assert.EqualValues(http.StatusRequestEntityTooLarge, err.(common.CodedError).StatusCode())
+	backend.AssertExpectations(t)
+}
@@ -0,0 +1,258 @@
+package translation
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+//MetadataKeySignature is the WRP metadata entry which holds the detached JWS of the payload of outgoing messages
+const MetadataKeySignature = "/tr1d1um-jws"
+
+//Supported JWS algorithms
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+//SigningKey is a key tr1d1um signs outgoing WRP messages with
+type SigningKey struct {
+	//ID is advertised as the kid of the signatures so consumers can pick the key to verify them with
+	ID string
+
+	//Algorithm is one of HS256, RS256 or ES256
+	Algorithm string
+
+	//Secret is the shared secret of HS256 keys
+	Secret string
+
+	//PrivateKeyFile is the path of the PEM encoded private key of RS256 and ES256 keys
+	PrivateKeyFile string
+
+	//Partners are the partner IDs whose messages are signed with this key, as granted by the token of API consumers
+	//The service key, used for the messages of any other API consumer, has no partners
+	Partners []string
+}
+
+//SigningOptions configures the signing of outgoing WRP messages
+type SigningOptions struct {
+	Keys []SigningKey
+}
+
+//jwsHeader is the protected header of signatures
+//The destination and transaction ID bind the signature to the message it was issued for
+type jwsHeader struct {
+	Algorithm   string `json:"alg"`
+	KeyID       string `json:"kid,omitempty"`
+	Destination string `json:"dest"`
+	TID         string `json:"tid,omitempty"`
+}
+
+type signingKey struct {
+	id        string
+	algorithm string
+	sign      func(signingInput []byte) ([]byte, error)
+}
+
+//Signer issues detached JWS (RFC 7515, appendix F) of the payloads of WRP messages so that downstream consumers
+//can verify commands originated from tr1d1um and were not altered in transit
+type Signer struct {
+	service   *signingKey
+	byPartner map[string]*signingKey
+}
+
+//NewSigner loads the configured keys. A nil Signer is returned if there are none
+func NewSigner(o SigningOptions) (*Signer, error) {
+	if len(o.Keys) == 0 {
+		return nil, nil
+	}
+
+	var s = &Signer{byPartner: make(map[string]*signingKey)}
+
+	for _, k := range o.Keys {
+		key, err := loadSigningKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("signing key '%s': %s", k.ID, err)
+		}
+
+		if len(k.Partners) == 0 {
+			if s.service != nil {
+				return nil, fmt.Errorf("signing keys '%s' and '%s' are both service keys", s.service.id, k.ID)
+			}
+			s.service = key
+		}
+
+		for _, partner := range k.Partners {
+			if owner, taken := s.byPartner[partner]; taken {
+				return nil, fmt.Errorf("partner '%s' has both signing keys '%s' and '%s'", partner, owner.id, k.ID)
+			}
+			s.byPartner[partner] = key
+		}
+	}
+
+	return s, nil
+}
+
+func loadSigningKey(k SigningKey) (*signingKey, error) {
+	var key = &signingKey{id: k.ID, algorithm: k.Algorithm}
+
+	if k.Algorithm == HS256 {
+		if k.Secret == "" {
+			return nil, errors.New("HS256 keys need a secret")
+		}
+
+		key.sign = func(signingInput []byte) ([]byte, error) {
+			mac := hmac.New(sha256.New, []byte(k.Secret))
+			mac.Write(signingInput)
+			return mac.Sum(nil), nil
+		}
+
+		return key, nil
+	}
+
+	privateKey, err := loadPrivateKey(k.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	switch k.Algorithm {
+	case RS256:
+		rsaKey, ok := privateKey.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("RS256 keys must be RSA keys")
+		}
+
+		key.sign = func(signingInput []byte) ([]byte, error) {
+			digest := sha256.Sum256(signingInput)
+			return rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		}
+
+	case ES256:
+		ecKey, ok := privateKey.(*ecdsa.PrivateKey)
+		if !ok || ecKey.Curve != elliptic.P256() {
+			return nil, errors.New("ES256 keys must be P-256 EC keys")
+		}
+
+		key.sign = func(signingInput []byte) ([]byte, error) {
+			digest := sha256.Sum256(signingInput)
+			r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+			if err != nil {
+				return nil, err
+			}
+
+			//JWS signatures are the fixed size concatenation of r and s
+			signature := make([]byte, 64)
+			rBytes, sBytes := r.Bytes(), s.Bytes()
+			copy(signature[32-len(rBytes):32], rBytes)
+			copy(signature[64-len(sBytes):], sBytes)
+			return signature, nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported algorithm '%s'", k.Algorithm)
+	}
+
+	return key, nil
+}
+
+//loadPrivateKey reads a PEM encoded PKCS1, PKCS8 or EC private key
+func loadPrivateKey(path string) (crypto.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in '%s'", path)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return x509.ParsePKCS8PrivateKey(block.Bytes)
+}
+
+//Sign adds the detached JWS of the payload of the given message to its metadata
+//Messages are signed with the key of the given partner, else with the service key. Without either they are left unsigned
+func (s *Signer) Sign(m *wrp.Message, partner string) error {
+	key, ok := s.byPartner[partner]
+	if !ok {
+		key = s.service
+	}
+
+	if key == nil {
+		return nil
+	}
+
+	header, err := json.Marshal(jwsHeader{Algorithm: key.algorithm, KeyID: key.id, Destination: m.Destination, TID: m.TransactionUUID})
+	if err != nil {
+		return err
+	}
+
+	var (
+		encodedHeader = base64.RawURLEncoding.EncodeToString(header)
+		signingInput  = encodedHeader + "." + base64.RawURLEncoding.EncodeToString(m.Payload)
+	)
+
+	signature, err := key.sign([]byte(signingInput))
+	if err != nil {
+		return err
+	}
+
+	//messages may share metadata so it is copied before being written to
+	var metadata = make(map[string]string, len(m.Metadata)+1)
+	for k, v := range m.Metadata {
+		metadata[k] = v
+	}
+
+	metadata[MetadataKeySignature] = encodedHeader + ".." + base64.RawURLEncoding.EncodeToString(signature)
+	m.Metadata = metadata
+	return nil
+}
+
+//NewSigningService decorates the given service so that outgoing WRP messages are signed. A nil signer disables signing
+func NewSigningService(s Service, signer *Signer) Service {
+	if signer == nil {
+		return s
+	}
+
+	return &signingService{Service: s, signer: signer}
+}
+
+type signingService struct {
+	Service
+	signer *Signer
+}
+
+func (s *signingService) SendWRP(ctx context.Context, wrpMsg *wrp.Message, authValue string) (*common.XmidtResponse, error) {
+	//the key is only selected by the token of the API consumer as the partner IDs of messages and headers are
+	//chosen by the API consumer itself
+	if err := s.signer.Sign(wrpMsg, common.AuthenticatedPartner(ctx)); err != nil {
+		return nil, err
+	}
+
+	return s.Service.SendWRP(ctx, wrpMsg, authValue)
+}
@@ -0,0 +1,208 @@
+package translation
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/comcast-bascule/bascule"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+//writePEM writes the given DER encoded private key into a temporary PEM file
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	f, err := ioutil.TempFile("", "signing")
+	assert.Nil(t, err)
+	defer f.Close()
+
+	assert.Nil(t, pem.Encode(f, &pem.Block{Type: blockType, Bytes: der}))
+	return f.Name()
+}
+
+//detached returns the decoded protected header, signing input and signature of a detached JWS
+func detached(t *testing.T, jws string, payload []byte) (header jwsHeader, signingInput []byte, signature []byte) {
+	parts := strings.Split(jws, ".")
+	assert.Len(t, parts, 3)
+	assert.Empty(t, parts[1])
+
+	decodedHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(decodedHeader, &header))
+
+	signature, err = base64.RawURLEncoding.DecodeString(parts[2])
+	assert.Nil(t, err)
+
+	return header, []byte(parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload)), signature
+}
+
+func TestNewSigner(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	rsaFile := writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	defer os.Remove(rsaFile)
+
+	testCases := []struct {
+		name    string
+		options SigningOptions
+		valid   bool
+	}{
+		{"Disabled", SigningOptions{}, true},
+		{"HS256", SigningOptions{Keys: []SigningKey{{ID: "k", Algorithm: HS256, Secret: "s"}}}, true},
+		{"MissingSecret", SigningOptions{Keys: []SigningKey{{ID: "k", Algorithm: HS256}}}, false},
+		{"MissingFile", SigningOptions{Keys: []SigningKey{{ID: "k", Algorithm: RS256, PrivateKeyFile: "/nonexistent"}}}, false},
+		{"WrongKeyType", SigningOptions{Keys: []SigningKey{{ID: "k", Algorithm: ES256, PrivateKeyFile: rsaFile}}}, false},
+		{"UnknownAlgorithm", SigningOptions{Keys: []SigningKey{{ID: "k", Algorithm: "none", PrivateKeyFile: rsaFile}}}, false},
+		{"TwoServiceKeys", SigningOptions{Keys: []SigningKey{{ID: "a", Algorithm: HS256, Secret: "s"}, {ID: "b", Algorithm: HS256, Secret: "s"}}}, false},
+		{"SharedPartner", SigningOptions{Keys: []SigningKey{
+			{ID: "a", Algorithm: HS256, Secret: "s", Partners: []string{"p"}},
+			{ID: "b", Algorithm: RS256, PrivateKeyFile: rsaFile, Partners: []string{"p"}},
+		}}, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := NewSigner(testCase.options)
+			assert.Equal(t, testCase.valid, err == nil)
+		})
+	}
+}
+
+func TestSignerSign(t *testing.T) {
+	var (
+		rsaKey, _ = rsa.GenerateKey(rand.Reader, 1024)
+		ecKey, _  = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		ecDER, _  = x509.MarshalECPrivateKey(ecKey)
+		rsaFile   = writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+		ecFile    = writePEM(t, "EC PRIVATE KEY", ecDER)
+	)
+
+	defer os.Remove(rsaFile)
+	defer os.Remove(ecFile)
+
+	signer, err := NewSigner(SigningOptions{Keys: []SigningKey{
+		{ID: "service", Algorithm: ES256, PrivateKeyFile: ecFile},
+		{ID: "shared", Algorithm: HS256, Secret: "secret", Partners: []string{"comcast"}},
+		{ID: "partner", Algorithm: RS256, PrivateKeyFile: rsaFile, Partners: []string{"other"}},
+	}})
+	assert.Nil(t, err)
+
+	newMessage := func() *wrp.Message {
+		return &wrp.Message{Destination: "mac:112233445566/config", TransactionUUID: "tid", Payload: []byte(`{"command":"GET"}`), Metadata: map[string]string{"a": "b"}}
+	}
+
+	t.Run("HS256", func(t *testing.T) {
+		assert := assert.New(t)
+		m := newMessage()
+		assert.Nil(signer.Sign(m, "comcast"))
+
+		header, signingInput, signature := detached(t, m.Metadata[MetadataKeySignature], m.Payload)
+		assert.Equal(jwsHeader{Algorithm: HS256, KeyID: "shared", Destination: m.Destination, TID: "tid"}, header)
+
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(signingInput)
+		assert.Equal(mac.Sum(nil), signature)
+		assert.Equal("b", m.Metadata["a"])
+	})
+
+	t.Run("RS256", func(t *testing.T) {
+		assert := assert.New(t)
+		m := newMessage()
+		assert.Nil(signer.Sign(m, "other"))
+
+		header, signingInput, signature := detached(t, m.Metadata[MetadataKeySignature], m.Payload)
+		assert.Equal("partner", header.KeyID)
+
+		digest := sha256.Sum256(signingInput)
+		assert.Nil(rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], signature))
+	})
+
+	t.Run("ES256", func(t *testing.T) {
+		assert := assert.New(t)
+		m := newMessage()
+		assert.Nil(signer.Sign(m, "unknown"))
+
+		header, signingInput, signature := detached(t, m.Metadata[MetadataKeySignature], m.Payload)
+		assert.Equal(ES256, header.Algorithm)
+		assert.Len(signature, 64)
+
+		digest := sha256.Sum256(signingInput)
+		assert.True(ecdsa.Verify(&ecKey.PublicKey, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])))
+	})
+
+	t.Run("Altered", func(t *testing.T) {
+		m := newMessage()
+		assert.Nil(t, signer.Sign(m, "comcast"))
+
+		_, signingInput, signature := detached(t, m.Metadata[MetadataKeySignature], []byte(`{"command":"SET"}`))
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(signingInput)
+		assert.NotEqual(t, mac.Sum(nil), signature)
+	})
+
+	t.Run("NoServiceKey", func(t *testing.T) {
+		partnerOnly, _ := NewSigner(SigningOptions{Keys: []SigningKey{{ID: "shared", Algorithm: HS256, Secret: "secret", Partners: []string{"comcast"}}}})
+		m := newMessage()
+
+		assert.Nil(t, partnerOnly.Sign(m, ""))
+		assert.NotContains(t, m.Metadata, MetadataKeySignature)
+	})
+}
+
+func TestSigningService(t *testing.T) {
+	s := new(MockService)
+	assert.Equal(t, s, NewSigningService(s, nil))
+
+	signer, _ := NewSigner(SigningOptions{Keys: []SigningKey{{ID: "shared", Algorithm: HS256, Secret: "secret", Partners: []string{"comcast"}}}})
+
+	s.On("SendWRP", mock.Anything, mock.MatchedBy(func(m *wrp.Message) bool {
+		return m.Metadata[MetadataKeySignature] != ""
+	}), "auth").Return(&common.XmidtResponse{Code: http.StatusOK}, nil)
+
+	s.On("SendWRP", mock.Anything, mock.MatchedBy(func(m *wrp.Message) bool {
+		return m.Metadata[MetadataKeySignature] == ""
+	}), "unsigned").Return(&common.XmidtResponse{Code: http.StatusOK}, nil)
+
+	t.Run("PartnerClaim", func(t *testing.T) {
+		ctx := bascule.WithAuthentication(ctxTID, bascule.Authentication{
+			Token: bascule.NewToken("jwt", "client", bascule.Attributes{common.DefaultPartnerClaim: "comcast"}),
+		})
+
+		_, err := NewSigningService(s, signer).SendWRP(ctx, &wrp.Message{Payload: []byte("{}")}, "auth")
+		assert.Nil(t, err)
+	})
+
+	t.Run("PartnerIDs", func(t *testing.T) {
+		_, err := NewSigningService(s, signer).SendWRP(ctxTID, &wrp.Message{Payload: []byte("{}"), PartnerIDs: []string{"comcast"}}, "unsigned")
+		assert.Nil(t, err)
+	})
+
+	t.Run("PartnerHeader", func(t *testing.T) {
+		var (
+			headers = http.Header{common.HeaderXmidtPartnerID: []string{"comcast"}}
+			ctx     = context.WithValue(ctxTID, common.ContextKeyRequestHeaders, headers)
+		)
+
+		_, err := NewSigningService(s, signer).SendWRP(ctx, &wrp.Message{Payload: []byte("{}")}, "unsigned")
+		assert.Nil(t, err)
+	})
+
+	s.AssertNumberOfCalls(t, "SendWRP", 3)
+}
@@ -0,0 +1,73 @@
+package translation
+
+import (
+	"fmt"
+	"net/http"
+)
+
+//Policies for RDK status codes that have no explicit HTTP status mapping
+const (
+	//DeviceStatusPolicy uses the RDK status code as the HTTP status code, except for 500 and codes that
+	//are not valid HTTP status codes which become 200. This is the original tr1d1um behavior
+	DeviceStatusPolicy = "device"
+
+	//OKStatusPolicy always responds with 200, leaving the RDK status code to the response body
+	OKStatusPolicy = "ok"
+)
+
+//StatusMapping translates the RDK status codes found in device responses into HTTP status codes
+type StatusMapping struct {
+	//Codes maps RDK status codes to HTTP status codes
+	Codes map[int]int
+
+	//Default is the policy for RDK status codes not found in Codes. Defaults to DeviceStatusPolicy
+	Default string
+}
+
+//Validate returns an error if the mapping would produce unusable HTTP responses
+func (m *StatusMapping) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	switch m.Default {
+	case "", DeviceStatusPolicy, OKStatusPolicy:
+	default:
+		return fmt.Errorf("unknown default status policy '%s'", m.Default)
+	}
+
+	for rdkCode, httpCode := range m.Codes {
+		if !validHTTPStatus(httpCode) {
+			return fmt.Errorf("RDK status code %d is mapped to invalid HTTP status code %d", rdkCode, httpCode)
+		}
+	}
+
+	return nil
+}
+
+//httpStatus returns the HTTP status code tr1d1um should respond with given a device's RDK status code
+func (m *StatusMapping) httpStatus(rdkCode int) int {
+	var policy = DeviceStatusPolicy
+
+	if m != nil {
+		if httpCode, ok := m.Codes[rdkCode]; ok {
+			return httpCode
+		}
+
+		if m.Default != "" {
+			policy = m.Default
+		}
+	}
+
+	if policy == DeviceStatusPolicy && rdkCode != http.StatusInternalServerError && validHTTPStatus(rdkCode) {
+		return rdkCode
+	}
+
+	return http.StatusOK
+}
+
+//validHTTPStatus tells whether the given code is a final HTTP status code. 1xx ones are informational and would
+//leave the API consumer waiting for the actual response
+func validHTTPStatus(code int) bool {
+	return code >= http.StatusOK && code <= 599
+}
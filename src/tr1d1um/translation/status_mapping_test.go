@@ -0,0 +1,44 @@
+package translation
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusMappingValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	var nilMapping *StatusMapping
+	assert.Nil(nilMapping.Validate())
+	assert.Nil((&StatusMapping{Default: OKStatusPolicy, Codes: map[int]int{520: 503}}).Validate())
+	assert.NotNil((&StatusMapping{Default: "teapot"}).Validate())
+	assert.NotNil((&StatusMapping{Codes: map[int]int{520: 1000}}).Validate())
+	assert.NotNil((&StatusMapping{Codes: map[int]int{520: http.StatusContinue}}).Validate())
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		mapping  *StatusMapping
+		rdkCode  int
+		expected int
+	}{
+		{"NilMapping", nil, http.StatusNotFound, http.StatusNotFound},
+		{"NilMappingInternalError", nil, http.StatusInternalServerError, http.StatusOK},
+		{"NilMappingMissingCode", nil, 0, http.StatusOK},
+		{"NilMappingInformationalCode", nil, http.StatusProcessing, http.StatusOK},
+		{"DeviceRDKSpecificCode", &StatusMapping{}, 9003, http.StatusOK},
+		{"Mapped", &StatusMapping{Codes: map[int]int{520: http.StatusServiceUnavailable}}, 520, http.StatusServiceUnavailable},
+		{"MappedInternalError", &StatusMapping{Codes: map[int]int{500: http.StatusBadGateway}}, 500, http.StatusBadGateway},
+		{"OKPolicy", &StatusMapping{Default: OKStatusPolicy}, http.StatusNotFound, http.StatusOK},
+		{"OKPolicyMapped", &StatusMapping{Default: OKStatusPolicy, Codes: map[int]int{404: 404}}, 404, 404},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.EqualValues(t, test.expected, test.mapping.httpStatus(test.rdkCode))
+		})
+	}
+}
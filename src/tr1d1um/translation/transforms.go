@@ -0,0 +1,99 @@
+package translation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"plugin"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+)
+
+//Names of the functions transform plugins may export. Both have the TransformFunc signature
+const (
+	TransformRequestSymbol  = "TransformRequest"
+	TransformResponseSymbol = "TransformResponse"
+)
+
+//TransformFunc transforms a JSON WDMP document
+type TransformFunc func([]byte) ([]byte, error)
+
+//Transform holds the hooks applied to the WDMP documents of a group of routes for bespoke normalization
+//(i.e. unit conversion, field renaming)
+type Transform struct {
+	//Request transforms the WDMP document built out of the incoming request before it is sent to the device (optional)
+	Request TransformFunc
+
+	//Response transforms the WDMP document returned by the device before it is served (optional)
+	Response TransformFunc
+}
+
+//LoadTransform loads the transform hooks exported by the Go plugin at the given path
+//The plugin should export at least one of the TransformRequest and TransformResponse functions
+func LoadTransform(path string) (*Transform, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var t = new(Transform)
+	for symbol, hook := range map[string]*TransformFunc{TransformRequestSymbol: &t.Request, TransformResponseSymbol: &t.Response} {
+		exported, err := p.Lookup(symbol)
+		if err != nil {
+			continue
+		}
+
+		f, ok := exported.(func([]byte) ([]byte, error))
+		if !ok {
+			return nil, fmt.Errorf("transform plugin '%s' exports %s with an unexpected signature", path, symbol)
+		}
+
+		*hook = f
+	}
+
+	if t.Request == nil && t.Response == nil {
+		return nil, fmt.Errorf("transform plugin '%s' exports neither %s nor %s", path, TransformRequestSymbol, TransformResponseSymbol)
+	}
+
+	return t, nil
+}
+
+type transformContextKey struct{}
+
+//captureTransform returns the function that makes the transform hooks of a group of routes available
+//to the request decoders and response encoders. t may be nil
+func captureTransform(t *Transform) kithttp.RequestFunc {
+	return func(ctx context.Context, _ *http.Request) context.Context {
+		if t == nil {
+			return ctx
+		}
+		return context.WithValue(ctx, transformContextKey{}, t)
+	}
+}
+
+//transformRequest applies the request hook of the route, if any, to the given WDMP document
+func transformRequest(ctx context.Context, document []byte) ([]byte, error) {
+	t, _ := ctx.Value(transformContextKey{}).(*Transform)
+	if t == nil || t.Request == nil {
+		return document, nil
+	}
+
+	transformed, err := t.Request(document)
+	if _, coded := err.(common.CodedError); err != nil && !coded {
+		err = common.NewBadRequestError(err)
+	}
+
+	return transformed, err
+}
+
+//transformResponse applies the response hook of the route, if any, to the given WDMP document
+func transformResponse(ctx context.Context, document []byte) ([]byte, error) {
+	t, _ := ctx.Value(transformContextKey{}).(*Transform)
+	if t == nil || t.Response == nil {
+		return document, nil
+	}
+
+	return t.Response(document)
+}
@@ -0,0 +1,70 @@
+package translation
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTransform(t *testing.T) {
+	_, err := LoadTransform("/does/not/exist.so")
+	assert.NotNil(t, err)
+}
+
+func TestTransforms(t *testing.T) {
+	var (
+		transform = &Transform{
+			Request: func(document []byte) ([]byte, error) {
+				if bytes.Contains(document, []byte("forbidden")) {
+					return nil, errors.New("forbidden parameter")
+				}
+				return bytes.Replace(document, []byte("celsius"), []byte("Device.Temperature"), -1), nil
+			},
+			Response: func(document []byte) ([]byte, error) {
+				return bytes.Replace(document, []byte("Device.Temperature"), []byte("celsius"), -1), nil
+			},
+		}
+
+		r = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "http://localhost", nil), map[string]string{"service": "config"})
+	)
+
+	ctx := captureWDMPVersion(captureTransform(transform)(ctxTID, r), r)
+
+	t.Run("Request", func(t *testing.T) {
+		assert := assert.New(t)
+		m := &wrp.Message{Payload: []byte(`{"command":"GET","names":["celsius"]}`)}
+
+		assert.Nil(encodeWDMP(ctx, m))
+		assert.EqualValues(`{"command":"GET","names":["Device.Temperature"]}`, string(m.Payload))
+	})
+
+	t.Run("RequestError", func(t *testing.T) {
+		err := encodeWDMP(ctx, &wrp.Message{Payload: []byte(`{"command":"GET","names":["forbidden"]}`)})
+		assert.EqualValues(t, http.StatusBadRequest, err.(common.CodedError).StatusCode())
+	})
+
+	t.Run("Response", func(t *testing.T) {
+		assert := assert.New(t)
+
+		document, err := decodeWDMP(ctx, []byte(`{"parameters":[{"name":"Device.Temperature","value":"21"}]}`))
+		assert.Nil(err)
+		assert.EqualValues(`{"parameters":[{"name":"celsius","value":"21"}]}`, string(document))
+	})
+
+	t.Run("NoTransform", func(t *testing.T) {
+		assert := assert.New(t)
+		ctx := captureWDMPVersion(captureTransform(nil)(ctxTID, r), r)
+
+		document, err := decodeWDMP(ctx, []byte(`{"names":["Device.Temperature"]}`))
+		assert.Nil(err)
+		assert.EqualValues(`{"names":["Device.Temperature"]}`, string(document))
+	})
+}
@@ -3,16 +3,19 @@ package translation
 import (
 	"context"
 	"encoding/json"
-	"io"
 	"io/ioutil"
 	"net/http"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
 
+	"github.com/Comcast/webpa-common/logging"
 	"github.com/Comcast/webpa-common/wrp"
 	"github.com/justinas/alice"
 
+	"github.com/go-kit/kit/endpoint"
 	kitlog "github.com/go-kit/kit/log"
 	kithttp "github.com/go-kit/kit/transport/http"
 
@@ -25,6 +28,13 @@ const (
 	authHeaderKey            = "Authorization"
 )
 
+//WebPA headers through which the TEST_AND_SET values are received
+const (
+	HeaderWPASyncOldCID = wdmp.HeaderWPASyncOldCID
+	HeaderWPASyncNewCID = wdmp.HeaderWPASyncNewCID
+	HeaderWPASyncCMC    = wdmp.HeaderWPASyncCMC
+)
+
 type xmidtResponse struct {
 	Body             []byte
 	ForwardedHeaders http.Header
@@ -41,81 +51,328 @@ type Options struct {
 	Authenticate  *alice.Chain
 	Log           kitlog.Logger
 	ValidServices []string
+
+	//RawServices are the services whose POST bodies are passed through to devices untouched rather than as WDMP
+	//Defaults to DefaultRawServices. Requests to them may not carry query parameters
+	RawServices []string
+
+	//WRPAddressing configures the source and destination of outgoing WRP messages (optional)
+	WRPAddressing *WRPAddressing
+
+	//AcceptMsgpack indicates whether msgpack-encoded request bodies are accepted in addition to JSON
+	AcceptMsgpack bool
+
+	//CanonicalJSON indicates whether device payloads should be re-marshaled with sorted keys and stable formatting
+	//so that successive responses for the same device data are byte-for-byte comparable
+	CanonicalJSON bool
+
+	//StatusMapping translates the RDK status codes of device responses into HTTP status codes
+	//If nil, the device status code is used unless it is 500
+	StatusMapping *StatusMapping
+
+	//Groups are the device groups SET requests can be fanned out to (optional)
+	Groups Groups
+
+	//GroupConcurrency is the maximum number of group members a request is sent to at a time
+	//Defaults to DefaultGroupConcurrency
+	GroupConcurrency int
+
+	//Aliases are the friendly names API consumers may use in place of TR-181 parameter names (optional)
+	Aliases *Aliases
+
+	//DataModel holds the TR-181 data types GET responses are annotated with when API consumers ask for them (optional)
+	DataModel *DataModel
+
+	//Hinter looks up the firmware and model of devices to help diagnose the failures they report (optional)
+	Hinter common.DeviceHinter
+
+	//Profiles are the named parameter bundles that can be applied to devices (optional)
+	Profiles Profiles
+
+	//Measures are the metric instruments tr1d1um reports to
+	Measures *common.Measures
+
+	//Extensions are the custom middleware and server options registered by route group (optional)
+	//Keys are DeviceRoutes, GroupRoutes, ProfileRoutes, MultiServiceRoutes, BatchRoutes, DiffRoutes, SchemaRoutes, CommandRoutes,
+	//DiagnosticRoutes and FirmwareRoutes
+	Extensions map[string]common.Extensions
+
+	//Transforms are the hooks applied to the WDMP documents of each route group (optional)
+	//Keys are the same as the ones of Extensions
+	Transforms map[string]*Transform
+
+	//Commands are custom WDMP commands exposed in addition to the built-in ones (optional)
+	//They are assumed to be valid (see Commands.Validate)
+	Commands Commands
+
+	//Diagnostics configures the reboot and ping routes (optional)
+	Diagnostics *Diagnostics
+
+	//CacheHeaders are the caching headers of the successful responses of each route group (optional)
+	//Keys are the same as the ones of Extensions
+	CacheHeaders common.CachePolicy
+
+	//ResponseHeaders are the response headers of the backend forwarded to API consumers
+	//Defaults to common.DefaultResponseHeaderRules
+	ResponseHeaders *common.HeaderForwardingRules
+
+	//Firmware configures the firmware download route (optional)
+	//It is assumed to be valid (see Firmware.Validate)
+	Firmware *Firmware
+
+	//Redaction masks the values of sensitive parameters in device responses (optional)
+	Redaction *Redaction
+
+	//Confirmations makes DELETE_ROW and REPLACE_ROWS requests take two steps (optional)
+	Confirmations *Confirmations
+
+	//Passthrough lets API consumers get the successful XMiDT responses as is, compressed or not, by accepting their
+	//media type (i.e. application/msgpack) and encoding. Their payloads are then neither decoded nor re-encoded
+	Passthrough bool
+
+	//SetLimits bounds the bodies of SET requests and the string values they hold (optional)
+	SetLimits *wdmp.SetLimits
 }
 
+//Groups of routes of the translation service custom Extensions may be registered for
+const (
+	//DeviceRoutes are the routes through which single device parameters and tables are managed
+	DeviceRoutes = "device"
+
+	//GroupRoutes are the routes through which device groups are managed
+	GroupRoutes = "group"
+
+	//ProfileRoutes are the routes through which parameter profiles are applied
+	ProfileRoutes = "profile"
+
+	//MultiServiceRoutes are the routes through which several services of a device are queried at once
+	MultiServiceRoutes = "multi-service"
+
+	//BatchRoutes are the routes through which batches of queries are sent to a device at once
+	BatchRoutes = "batch"
+
+	//DiffRoutes are the routes through which the parameters of a device are compared with a baseline
+	DiffRoutes = "diff"
+
+	//SchemaRoutes are the routes which serve the request body schemas
+	SchemaRoutes = "schema"
+
+	//CommandRoutes are the routes of the custom Commands
+	CommandRoutes = "command"
+
+	//DiagnosticRoutes are the reboot and ping routes
+	DiagnosticRoutes = "diagnostic"
+
+	//FirmwareRoutes are the routes through which firmware downloads are triggered
+	FirmwareRoutes = "firmware"
+)
+
 //ConfigHandler sets up the server that powers the translation service
 func ConfigHandler(c *Options) {
+	var (
+		rawServices = c.RawServices
+		measures    = c.Measures
+	)
+
+	if rawServices == nil {
+		rawServices = DefaultRawServices
+	}
+
+	if measures == nil {
+		measures = common.NewMeasures(nil)
+	}
+
 	opts := []kithttp.ServerOption{
-		kithttp.ServerBefore(common.Capture),
-		kithttp.ServerErrorEncoder(common.ErrorLogEncoder(c.Log, encodeError)),
+		kithttp.ServerBefore(common.Capture, captureRawService(rawServices), captureEnvelope, captureProjection, captureAliases(c.Aliases), captureRedaction(c.Redaction), captureDataModel(c.DataModel), captureDeviceHints(c.Hinter), captureWDMPVersion, captureSetLimits(c.SetLimits)),
+		kithttp.ServerErrorEncoder(common.ErrorLogEncoder(c.Log, common.ClientCanceledEncoder(c.Measures, encodeError))),
 		kithttp.ServerFinalizer(common.TransactionLogging(c.Log)),
 	}
 
-	WRPHandler := kithttp.NewServer(
+	encoding := &encodeOptions{
+		canonicalJSON:   c.CanonicalJSON,
+		statusMapping:   c.StatusMapping,
+		measures:        c.Measures,
+		responseHeaders: common.NewResponseHeaderFilter(c.ResponseHeaders),
+		passthrough:     c.Passthrough,
+	}
+
+	handler := func(group string, ep endpoint.Endpoint, dec kithttp.DecodeRequestFunc, enc kithttp.EncodeResponseFunc) http.Handler {
+		var groupOpts = append([]kithttp.ServerOption{kithttp.ServerBefore(captureTransform(c.Transforms[group]))}, opts...)
+		return c.Extensions[group].Handler(c.Authenticate, ep, dec, common.CacheHeadersEncoder(c.CacheHeaders, group, enc), groupOpts)
+	}
+
+	//custom commands are registered first so that they take precedence over the built-in routes
+	for _, command := range c.Commands {
+		commandHandler := handler(CommandRoutes,
+			makeTranslationEndpoint(c.S),
+			decodeValidServiceRequest(c.ValidServices, decodeCommandRequest(command, c.WRPAddressing, c.Aliases)),
+			encodeResponse(encoding),
+		)
+
+		c.APIRouter.Handle(command.Path, commandHandler).
+			Methods(command.Method)
+	}
+
+	diagnostics := c.Diagnostics.withDefaults()
+
+	c.APIRouter.Handle("/device/{deviceid}/reboot", handler(DiagnosticRoutes,
+		makeTranslationEndpoint(c.S),
+		decodeDiagnosticRequest(diagnostics.Service, diagnostics.rebootPayload, c.WRPAddressing),
+		encodeResponse(encoding),
+	)).Methods(http.MethodPost)
+
+	c.APIRouter.Handle("/device/{deviceid}/ping", handler(DiagnosticRoutes,
+		makeTranslationEndpoint(c.S),
+		decodeDiagnosticRequest(diagnostics.Service, diagnostics.pingPayload, c.WRPAddressing),
+		encodeResponse(encoding),
+	)).Methods(http.MethodGet)
+
+	c.APIRouter.Handle("/device/{deviceid}/nudge", handler(DiagnosticRoutes,
+		makeTranslationEndpoint(c.S),
+		decodeNudgeRequest(diagnostics, c.WRPAddressing),
+		encodeNudgeResponse(encoding),
+	)).Methods(http.MethodPost)
+
+	if c.Firmware != nil && len(c.Firmware.AllowedURLs) > 0 {
+		firmware := c.Firmware.withDefaults()
+
+		c.APIRouter.Handle("/device/{deviceid}/firmware", handler(FirmwareRoutes,
+			makeTranslationEndpoint(c.S),
+			decodeDiagnosticRequest(firmware.Service, firmware.payload, c.WRPAddressing),
+			encodeResponse(encoding),
+		)).Methods(http.MethodPost)
+	}
+
+	WRPHandler := handler(DeviceRoutes,
 		makeTranslationEndpoint(c.S),
-		decodeValidServiceRequest(c.ValidServices, decodeRequest),
-		encodeResponse,
-		opts...,
+		decodeValidServiceRequest(c.ValidServices, decodeRawServiceRequest(measures.RawServiceQueries, decodeAcceptedContentType(c.AcceptMsgpack, decodeConfirmedRequest(newConfirmer(c.Confirmations), decodeRequest(c.WRPAddressing, c.Aliases))))),
+		encodeResponse(encoding),
 	)
 
-	//TODO: TMP IOT HACK
-	c.APIRouter.Handle("/device/{deviceid}/{service:iot}", c.Authenticate.Then(common.Welcome(WRPHandler))).
+	if len(c.Groups) > 0 {
+		groupHandler := handler(GroupRoutes,
+			makeGroupEndpoint(c.S, c.GroupConcurrency),
+			decodeValidServiceRequest(c.ValidServices, decodeAcceptedContentType(c.AcceptMsgpack, decodeGroupRequest(c.Groups, c.WRPAddressing, c.Aliases))),
+			encodeGroupResponse(encoding),
+		)
+
+		c.APIRouter.Handle("/group/{group}/{service}", groupHandler).
+			Methods(http.MethodPatch)
+	}
+
+	if len(c.Profiles) > 0 {
+		profileHandler := handler(ProfileRoutes,
+			makeTranslationEndpoint(c.S),
+			decodeValidServiceRequest(c.ValidServices, decodeProfileRequest(c.Profiles, c.WRPAddressing, c.Aliases)),
+			encodeResponse(encoding),
+		)
+
+		c.APIRouter.Handle("/device/{deviceid}/{service}/profile/{profile}", profileHandler).
+			Methods(http.MethodPatch)
+	}
+
+	multiServiceHandler := handler(MultiServiceRoutes,
+		makeMultiServiceEndpoint(c.S),
+		decodeMultiServiceRequest(c.ValidServices, c.WRPAddressing, c.Aliases),
+		encodeMultiServiceResponse(encoding),
+	)
+
+	c.APIRouter.Handle("/device/{deviceid}", multiServiceHandler).
+		Methods(http.MethodGet)
+
+	batchHandler := handler(BatchRoutes,
+		makeBatchEndpoint(c.S),
+		decodeBatchRequest(c.ValidServices, c.WRPAddressing, c.Aliases),
+		encodeBatchResponse(encoding),
+	)
+
+	c.APIRouter.Handle("/device/{deviceid}", batchHandler).
 		Methods(http.MethodPost)
 
-	c.APIRouter.Handle("/device/{deviceid}/{service}", c.Authenticate.Then(common.Welcome(WRPHandler))).
+	//registered before the device routes, which would otherwise take diff for a parameter
+	diffHandler := handler(DiffRoutes,
+		makeDiffEndpoint(c.S),
+		decodeDiffRequest(c.ValidServices, c.WRPAddressing, c.Aliases),
+		encodeDiffResponse(encoding),
+	)
+
+	c.APIRouter.Handle("/device/{deviceid}/{service}/diff", diffHandler).
+		Methods(http.MethodPost)
+
+	schemaHandler := handler(SchemaRoutes,
+		func(_ context.Context, request interface{}) (interface{}, error) { return request, nil },
+		decodeSchemaRequest,
+		encodeSchemaResponse,
+	)
+
+	c.APIRouter.Handle("/schemas", schemaHandler).
+		Methods(http.MethodGet)
+
+	c.APIRouter.Handle("/schemas/{schema}", schemaHandler).
+		Methods(http.MethodGet)
+
+	//raw services take their data as is, in the body of POST requests
+	if len(rawServices) > 0 {
+		c.APIRouter.Handle("/device/{deviceid}/"+rawServicesPattern(rawServices), WRPHandler).
+			Methods(http.MethodPost)
+	}
+
+	c.APIRouter.Handle("/device/{deviceid}/{service}", WRPHandler).
 		Methods(http.MethodGet, http.MethodPatch)
 
-	c.APIRouter.Handle("/device/{deviceid}/{service}/{parameter}", c.Authenticate.Then(common.Welcome(WRPHandler))).
+	c.APIRouter.Handle("/device/{deviceid}/{service}/{parameter}", WRPHandler).
 		Methods(http.MethodDelete, http.MethodPut, http.MethodPost)
 }
 
 /* Request Decoding */
 
-func decodeRequest(ctx context.Context, r *http.Request) (decodedRequest interface{}, err error) {
-	var (
-		payload []byte
-		wrpMsg  *wrp.Message
-	)
+//decodeRequest returns the function that decodes translation requests into WRP requests
+//addressing drives the construction of the WRP source and destination and aliases are expanded in
+//the requested parameter names. Both may be nil
+func decodeRequest(addressing *WRPAddressing, aliases *Aliases) kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (decodedRequest interface{}, err error) {
+		var (
+			payload []byte
+			wrpMsg  *wrp.Message
+		)
+
+		if isRawService(ctx) && r.Method == http.MethodPost {
+			payload, err = ioutil.ReadAll(r.Body)
+		} else if payload, err = requestPayload(ctx, r); err == nil {
+			payload = aliases.expand(payload)
+		}
 
-	if payload, err = requestPayload(r); err == nil {
-		var tid = ctx.Value(common.ContextKeyRequestTID).(string)
-		if wrpMsg, err = wrap(payload, tid, mux.Vars(r)); err == nil {
-			decodedRequest = &wrpRequest{
-				WRPMessage:      wrpMsg,
-				AuthHeaderValue: r.Header.Get(authHeaderKey),
+		if err == nil {
+			var tid = common.RequestTID(ctx)
+			if wrpMsg, err = wrap(payload, tid, mux.Vars(r), common.RequestPartner(ctx), addressing); err != nil {
+				return
+			}
+
+			if err = encodeWDMP(ctx, wrpMsg); err == nil {
+				decodedRequest = &wrpRequest{
+					WRPMessage:      wrpMsg,
+					AuthHeaderValue: r.Header.Get(authHeaderKey),
+				}
 			}
 		}
-	}
 
-	return
+		return
+	}
 }
 
-func requestPayload(r *http.Request) (payload []byte, err error) {
+func requestPayload(ctx context.Context, r *http.Request) (payload []byte, err error) {
 
 	switch r.Method {
 	case http.MethodGet:
-		payload, err = requestGetPayload(r.FormValue("names"), r.FormValue("attributes"))
+		payload, err = wdmp.GetPayload(r.FormValue("names"), r.FormValue("attributes"))
 	case http.MethodPatch:
-		payload, err = requestSetPayload(r.Body, r.Header.Get(HeaderWPASyncNewCID), r.Header.Get(HeaderWPASyncOldCID), r.Header.Get(HeaderWPASyncCMC))
+		payload, err = wdmp.LimitedSetPayload(r.Body, setLimits(ctx), r.Header.Get(HeaderWPASyncNewCID), r.Header.Get(HeaderWPASyncOldCID), r.Header.Get(HeaderWPASyncCMC))
 	case http.MethodDelete:
-		payload, err = requestDeletePayload(mux.Vars(r))
+		payload, err = wdmp.DeleteRowPayload(mux.Vars(r)["parameter"])
 	case http.MethodPut:
-		payload, err = requestReplacePayload(mux.Vars(r), r.Body)
+		payload, err = wdmp.ReplaceRowsPayload(mux.Vars(r)["parameter"], r.Body)
 	case http.MethodPost:
-
-		/****TODO: TMP IOT ENDPOINT HACK****/
-		v := mux.Vars(r)
-		if v["service"] == "iot" {
-			if v["parameters"] == "" {
-				return ioutil.ReadAll(r.Body)
-			}
-			//TODO: this might also be doable at the mux level
-			// /iot endpoint should not pass anything through queries (all request data is in the body)
-			return nil, ErrInvalidService
-		}
-		/********/
-
-		payload, err = requestAddPayload(v, r.Body)
+		payload, err = wdmp.AddRowPayload(mux.Vars(r)["parameter"], r.Body)
 
 	default:
 		//Unwanted methods should be filtered at the mux level. Thus, we "should" never get here
@@ -127,24 +384,77 @@ func requestPayload(r *http.Request) (payload []byte, err error) {
 
 /* Response Encoding */
 
-func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) (err error) {
-	var resp = response.(*common.XmidtResponse)
+//encodeOptions drives the encoding of device responses
+type encodeOptions struct {
+	//canonicalJSON indicates whether JSON device payloads should be re-marshaled with sorted keys and stable formatting
+	canonicalJSON bool
 
-	//equivalent to forwarding all headers
-	common.ForwardHeadersByPrefix("", resp.ForwardedHeaders, w.Header())
+	//statusMapping translates the RDK status code of device responses into the HTTP response status code
+	statusMapping *StatusMapping
 
-	// Write TransactionID for all requests
-	w.Header().Set(common.HeaderWPATID, ctx.Value(common.ContextKeyRequestTID).(string))
+	//measures are the metric instruments failures reported by devices are counted in
+	measures *common.Measures
 
-	if resp.Code != http.StatusOK { //just forward the XMiDT cluster response {
-		w.WriteHeader(resp.Code)
-		_, err = w.Write(resp.Body)
-		return
-	}
+	//responseHeaders selects the response headers of the backend forwarded to the API consumer
+	responseHeaders *common.HeaderFilter
 
-	wrpModel := new(wrp.Message)
+	//passthrough lets API consumers get the XMiDT responses as is (see passthrough)
+	passthrough bool
+}
+
+//encodeResponse returns the function that encodes XMiDT responses for the API consumer
+//o may be nil in which case device payloads are forwarded as received
+func encodeResponse(o *encodeOptions) kithttp.EncodeResponseFunc {
+	o = o.withDefaults()
+
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) (err error) {
+		var resp = response.(*common.XmidtResponse)
 
-	if err = wrp.NewDecoderBytes(resp.Body, wrp.Msgpack).Decode(wrpModel); err == nil {
+		//backends other than the XMiDT API may not filter their headers so internal ones are kept from API consumers here
+		o.responseHeaders.Forward(resp.ForwardedHeaders, w.Header())
+
+		// Write TransactionID for all requests
+		w.Header().Set(common.TIDHeader(ctx), common.RequestTID(ctx))
+		common.SetTimestamps(ctx, w.Header(), resp)
+		reportWDMPVersion(ctx, w.Header())
+
+		//status codes outside of the final ones HTTP defines can't be forwarded (and 1xx ones aren't final)
+		if resp.Code < http.StatusOK || resp.Code > 599 {
+			logging.Error(logging.GetLogger(ctx)).Log(logging.MessageKey(), "XMiDT response has an invalid status code",
+				"code", resp.Code, "tid", common.RequestTID(ctx))
+			return ErrMalformedUpstreamResponse
+		}
+
+		if resp.Code != http.StatusOK { //just forward the XMiDT cluster response {
+			w.WriteHeader(resp.Code)
+			_, err = w.Write(resp.Body)
+			return
+		}
+
+		//no point on decoding a (potentially large) payload no one will read
+		if ctx.Err() == context.Canceled {
+			return common.ErrClientCanceled
+		}
+
+		if o.passthrough && passthrough(ctx, resp) {
+			o.measures.Passthrough.Add(1)
+			return writePassthrough(ctx, w, resp)
+		}
+
+		wrpModel := new(wrp.Message)
+
+		if errDecode := common.DecodeWRP(resp.Body, wrpModel); errDecode != nil {
+			logging.Error(logging.GetLogger(ctx)).Log(logging.MessageKey(), "XMiDT response could not be decoded as a WRP message",
+				logging.ErrorKey(), errDecode, "tid", common.RequestTID(ctx), "bodySample", redactedSample(ctx, resp.Body))
+			return ErrMalformedUpstreamResponse
+		}
+
+		var errWDMP error
+		if wrpModel.Payload, errWDMP = decodeWDMP(ctx, wrpModel.Payload); errWDMP != nil {
+			logging.Error(logging.GetLogger(ctx)).Log(logging.MessageKey(), "device payload could not be decoded as a WDMP document",
+				logging.ErrorKey(), errWDMP, "tid", common.RequestTID(ctx), "bodySample", redactedSample(ctx, resp.Body))
+			return ErrMalformedUpstreamResponse
+		}
 
 		var deviceResponseModel struct {
 			StatusCode int `json:"statusCode"`
@@ -152,131 +462,122 @@ func encodeResponse(ctx context.Context, w http.ResponseWriter, response interfa
 
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
-		// if possible, use the device response status code
+		var status = http.StatusOK
+
+		// if possible, derive the response status code from the device response status code
 		if errUnmarshall := json.Unmarshal(wrpModel.Payload, &deviceResponseModel); errUnmarshall == nil {
-			if deviceResponseModel.StatusCode != 0 && deviceResponseModel.StatusCode != http.StatusInternalServerError {
-				w.WriteHeader(deviceResponseModel.StatusCode)
-			}
+			status = o.statusMapping.httpStatus(deviceResponseModel.StatusCode)
 		}
 
-		_, err = w.Write(wrpModel.Payload)
-	}
+		var payload = restoreAliases(ctx, projectFields(ctx, annotateTypes(ctx, redactValues(ctx, wrpModel.Payload))))
 
-	return
-}
+		//failures reported by devices are often specific to their firmware or model
+		if status >= http.StatusBadRequest {
+			hints := deviceHints(ctx)
+			firmware, model := hintLabels(hints)
 
-/* Error Encoding */
-
-func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
-	w.Header().Set(contentTypeHeaderKey, "application/json; charset=utf-8")
-	w.Header().Set(common.HeaderWPATID, ctx.Value(common.ContextKeyRequestTID).(string))
+			o.measures.DeviceErrors.With(common.StatusLabel, strconv.Itoa(status), common.FirmwareLabel, firmware, common.ModelLabel, model).Add(1)
+			payload = mergeHints(payload, hints)
+		}
 
-	if ce, ok := err.(common.CodedError); ok {
-		w.WriteHeader(ce.StatusCode())
-	} else {
-		w.WriteHeader(http.StatusInternalServerError)
+		if o.canonicalJSON {
+			payload = canonicalJSON(payload)
+		}
 
-		//the real error is logged into our system before encodeError() is called
-		//the idea behind masking it is to not send the external API consumer internal error messages
-		err = common.ErrTr1d1umInternal
-	}
+		if payload, err = wrapInEnvelope(ctx, resp, deviceResponseModel.StatusCode, payload); err != nil {
+			return
+		}
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": err.Error(),
-	})
+		w.WriteHeader(status)
+		if _, err = w.Write(payload); err != nil && ctx.Err() == context.Canceled {
+			err = common.ErrClientCanceled
+		}
 
+		return
+	}
 }
 
-/* Request-type specific decoding functions */
-
-func requestSetPayload(in io.Reader, newCID, oldCID, syncCMC string) (p []byte, err error) {
-	var (
-		wdmp = new(setWDMP)
-		data []byte
-	)
+//withDefaults returns a copy of the options with defaults applied. o may be nil
+func (o *encodeOptions) withDefaults() *encodeOptions {
+	var withDefaults encodeOptions
+	if o != nil {
+		withDefaults = *o
+	}
 
-	if data, err = ioutil.ReadAll(in); err == nil {
+	if withDefaults.measures == nil {
+		withDefaults.measures = common.NewMeasures(nil)
+	}
 
-		//read data into wdmp
-		if err = json.Unmarshal(data, wdmp); err == nil || len(data) == 0 { //len(data) == 0 case is for TEST_SET
-			if err = deduceSET(wdmp, newCID, oldCID, syncCMC); err == nil {
-				if !isValidSetWDMP(wdmp) {
-					return nil, ErrInvalidSetWDMP
-				}
-				return json.Marshal(wdmp)
-			}
-		}
+	if withDefaults.responseHeaders == nil {
+		withDefaults.responseHeaders = common.NewResponseHeaderFilter(nil)
 	}
 
-	return
+	return &withDefaults
 }
 
-func requestGetPayload(names, attributes string) ([]byte, error) {
-	if names == "" {
-		return nil, ErrEmptyNames
-	}
+/* Error Encoding */
 
-	wdmp := new(getWDMP)
+func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
+	w.Header().Set(contentTypeHeaderKey, "application/json; charset=utf-8")
+	w.Header().Set(common.TIDHeader(ctx), common.RequestTID(ctx))
 
-	//default values at this point
-	wdmp.Names, wdmp.Command = strings.Split(names, ","), CommandGet
+	if h, ok := err.(kithttp.Headerer); ok {
+		common.ForwardHeadersByPrefix("", h.Headers(), w.Header())
+	}
 
-	if attributes != "" {
-		wdmp.Command, wdmp.Attributes = CommandGetAttrs, attributes
+	var status = http.StatusInternalServerError
+	if ce, ok := err.(common.CodedError); ok {
+		status = ce.StatusCode()
+	} else {
+		//the real error is logged into our system before encodeError() is called
+		//the idea behind masking it is to not send the external API consumer internal error messages
+		err = common.ErrTr1d1umInternal
 	}
 
-	return json.Marshal(wdmp)
-}
+	message, language := common.LocalizeMessage(ctx, err.Error())
+	if language != "" {
+		w.Header().Set("Content-Language", language)
+	}
 
-func requestAddPayload(m map[string]string, input io.Reader) (p []byte, err error) {
-	var wdmp = &addRowWDMP{Command: CommandAddRow}
+	w.WriteHeader(status)
 
-	if table, ok := m["parameter"]; ok {
-		wdmp.Table = table
-	} else {
-		return nil, ErrMissingTable
+	body := map[string]interface{}{
+		"message": message,
 	}
 
-	var payload []byte
-	if payload, err = ioutil.ReadAll(input); err == nil {
-		if len(payload) == 0 {
-			return nil, ErrMissingRow
-		}
+	if ec, ok := err.(common.ErrorCoder); ok {
+		body["code"] = ec.ErrorCode()
+	}
 
-		if err = json.Unmarshal(payload, &wdmp.Row); err == nil {
-			return json.Marshal(wdmp)
-		}
+	if ve, ok := err.(*common.ValidationError); ok {
+		body["errors"] = ve.Problems
 	}
 
-	return
-}
+	if ce, ok := err.(*confirmationError); ok {
+		body["confirmation"] = ce
+	}
 
-func requestReplacePayload(m map[string]string, input io.Reader) (p []byte, err error) {
-	var wdmp = &replaceRowsWDMP{Command: CommandReplaceRows}
+	if se, ok := err.(*MessageSizeError); ok {
+		body["size"], body["limit"] = se.Size, se.Limit
+	}
 
-	if table, ok := m["parameter"]; ok {
-		wdmp.Table = table
-	} else {
-		return nil, ErrMissingTable
+	if oe, ok := err.(*common.DeviceOfflineError); ok && !oe.LastSeen.IsZero() {
+		body["lastSeen"] = oe.LastSeen.UTC().Format(time.RFC3339)
 	}
 
-	var payload []byte
-	if payload, err = ioutil.ReadAll(input); err == nil {
-		if len(payload) == 0 {
-			return nil, ErrMissingRows
+	//server-side failures may be specific to the firmware or model of the device
+	if ce, ok := err.(common.CodedError); ok && ce.StatusCode() >= http.StatusInternalServerError {
+		if hints := deviceHints(ctx); hints != nil {
+			body["device"] = hints
 		}
 
-		if err = json.Unmarshal(payload, &wdmp.Rows); err == nil {
-			return json.Marshal(wdmp)
+		if call, ok := common.RequestFailedCall(ctx); ok {
+			body["outbound"] = call
 		}
 	}
 
-	return
-}
+	json.NewEncoder(w).Encode(body)
 
-func requestDeletePayload(m map[string]string) ([]byte, error) {
-	if row, ok := m["parameter"]; ok {
-		return json.Marshal(&deleteRowDMP{Command: CommandDeleteRow, Row: row})
-	}
-	return nil, ErrMissingRow
 }
+
+/* Request-type specific decoding functions */
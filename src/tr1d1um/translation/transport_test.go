@@ -5,13 +5,17 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
 
 	"github.com/Comcast/webpa-common/wrp"
 
@@ -25,7 +29,7 @@ func TestDecodeRequest(t *testing.T) {
 	t.Run("PayloadFailure", func(t *testing.T) {
 		assert := assert.New(t)
 		r := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
-		_, e := decodeRequest(ctxTID, r)
+		_, e := decodeRequest(nil, nil)(ctxTID, r)
 		assert.EqualValues(ErrEmptyNames, e)
 	})
 
@@ -33,7 +37,7 @@ func TestDecodeRequest(t *testing.T) {
 		assert := assert.New(t)
 		r := httptest.NewRequest(http.MethodGet, "http://localhost?names='deviceField'", nil)
 		r = mux.SetURLVars(r, map[string]string{"deviceid": "mac:112233445566"})
-		wrpMsg, e := decodeRequest(ctxTID, r)
+		wrpMsg, e := decodeRequest(nil, nil)(ctxTID, r)
 		assert.Nil(e)
 		assert.NotEmpty(wrpMsg)
 	})
@@ -42,7 +46,7 @@ func TestDecodeRequest(t *testing.T) {
 		assert := assert.New(t)
 		r := httptest.NewRequest(http.MethodGet, "http://localhost?names='deviceField'", nil)
 		r = mux.SetURLVars(r, map[string]string{"deviceid": "mac:112233445566"})
-		wrpMsg, e := decodeRequest(ctxTID, r)
+		wrpMsg, e := decodeRequest(nil, nil)(ctxTID, r)
 		assert.Nil(e)
 		assert.NotEmpty(wrpMsg)
 
@@ -52,238 +56,55 @@ func TestRequestPayload(t *testing.T) {
 	t.Run("Get", func(t *testing.T) {
 		assert := assert.New(t)
 		r := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
-		_, e := requestPayload(r)
+		_, e := requestPayload(context.Background(), r)
 		assert.EqualValues(ErrEmptyNames, e)
 	})
 
 	t.Run("Set", func(t *testing.T) {
 		assert := assert.New(t)
 		r := httptest.NewRequest(http.MethodPatch, "http://localhost", nil)
-		_, e := requestPayload(r)
+		_, e := requestPayload(context.Background(), r)
 		assert.EqualValues(ErrInvalidSetWDMP, e)
 	})
 
+	t.Run("LimitedSet", func(t *testing.T) {
+		assert := assert.New(t)
+		r := httptest.NewRequest(http.MethodPatch, "http://localhost", strings.NewReader(`{"parameters": [{"name": "p", "dataType": 0, "value": "value"}]}`))
+		_, e := requestPayload(captureSetLimits(&wdmp.SetLimits{MaxBodySize: 16})(context.Background(), r), r)
+		assert.EqualValues(&wdmp.SetBodySizeError{Limit: 16}, e)
+	})
+
 	t.Run("Del", func(t *testing.T) {
 		assert := assert.New(t)
 		r := httptest.NewRequest(http.MethodDelete, "http://localhost", nil)
-		_, e := requestPayload(r)
+		_, e := requestPayload(context.Background(), r)
 		assert.EqualValues(ErrMissingRow, e)
 	})
 
 	t.Run("Replace", func(t *testing.T) {
 		assert := assert.New(t)
 		r := httptest.NewRequest(http.MethodPut, "http://localhost", nil)
-		_, e := requestPayload(r)
+		_, e := requestPayload(context.Background(), r)
 		assert.EqualValues(ErrMissingTable, e)
 	})
 
-	t.Run("Iot", func(t *testing.T) {
-		assert := assert.New(t)
-		r := httptest.NewRequest(http.MethodPost, "http://localhost", nil)
-
-		r = mux.SetURLVars(r, map[string]string{"service": "iot"})
-
-		p, e := requestPayload(r)
-		assert.Empty(p)
-		assert.Nil(e)
-	})
-
 	t.Run("Add", func(t *testing.T) {
 		assert := assert.New(t)
 		r := httptest.NewRequest(http.MethodPost, "http://localhost", nil)
 
 		r = mux.SetURLVars(r, map[string]string{"service": "add"})
-		_, e := requestPayload(r)
+		_, e := requestPayload(context.Background(), r)
 		assert.EqualValues(ErrMissingTable, e)
 	})
 
 	t.Run("Others", func(t *testing.T) {
 		assert := assert.New(t)
 		r := httptest.NewRequest(http.MethodOptions, "http://localhost", nil)
-		_, e := requestPayload(r)
+		_, e := requestPayload(context.Background(), r)
 		assert.EqualValues(ErrUnsupportedMethod, e)
 	})
 }
 
-func TestRequestGetPayload(t *testing.T) {
-	t.Run("EmptyNames", func(t *testing.T) {
-		assert := assert.New(t)
-
-		p, e := requestGetPayload("", "")
-		assert.EqualValues(ErrEmptyNames, e)
-		assert.Nil(p)
-	})
-
-	t.Run("GET", func(t *testing.T) {
-		assert := assert.New(t)
-
-		p, e := requestGetPayload("n0,n1", "")
-		assert.Nil(e)
-
-		expectedBytes, err := json.Marshal(&getWDMP{Command: CommandGet, Names: []string{"n0", "n1"}})
-
-		if err != nil {
-			panic(err)
-		}
-
-		assert.EqualValues(expectedBytes, p)
-	})
-
-	t.Run("GETAttrs", func(t *testing.T) {
-		assert := assert.New(t)
-
-		p, e := requestGetPayload("n0,n1", "attr0")
-		assert.Nil(e)
-
-		expectedBytes, err := json.Marshal(&getWDMP{Command: CommandGetAttrs, Names: []string{"n0", "n1"}, Attributes: "attr0"})
-
-		if err != nil {
-			panic(err)
-		}
-
-		assert.EqualValues(expectedBytes, p)
-	})
-}
-
-func TestRequestSetPayload(t *testing.T) {
-	t.Run("ErrAtDeduction", func(t *testing.T) {
-		assert := assert.New(t)
-		_, e := requestSetPayload(bytes.NewBufferString(""), "", "old", "sync")
-
-		assert.EqualValues(ErrNewCIDRequired, e)
-	})
-
-	t.Run("InvalidWDMP", func(t *testing.T) {
-		assert := assert.New(t)
-		_, e := requestSetPayload(bytes.NewBufferString(""), "", "", "")
-
-		assert.EqualValues(ErrInvalidSetWDMP, e)
-	})
-
-	t.Run("Ideal", func(t *testing.T) {
-		assert := assert.New(t)
-		p, e := requestSetPayload(bytes.NewBufferString(""), "new", "old", "sync")
-
-		wdmp := new(setWDMP)
-		err := json.NewDecoder(bytes.NewBuffer(p)).Decode(wdmp)
-
-		if err != nil {
-			panic(err)
-		}
-
-		assert.Nil(e)
-		assert.EqualValues(CommandTestSet, wdmp.Command)
-		assert.EqualValues("new", wdmp.NewCid)
-		assert.EqualValues("old", wdmp.OldCid)
-		assert.EqualValues("sync", wdmp.SyncCmc)
-	})
-}
-
-func TestRequestAddPayload(t *testing.T) {
-	t.Run("TableNotProvided", func(t *testing.T) {
-		assert := assert.New(t)
-
-		p, e := requestAddPayload(nil, nil)
-		assert.Nil(p)
-		assert.EqualValues(ErrMissingTable, e)
-	})
-
-	t.Run("RowNotProvided", func(t *testing.T) {
-		assert := assert.New(t)
-
-		p, e := requestAddPayload(map[string]string{"parameter": "t0"}, bytes.NewBufferString(""))
-
-		assert.Nil(p)
-		assert.EqualValues(ErrMissingRow, e)
-	})
-
-	t.Run("IdealPath", func(t *testing.T) {
-		assert := assert.New(t)
-		p, e := requestAddPayload(map[string]string{"parameter": "t0"}, bytes.NewBufferString(`{"row": "r0"}`))
-
-		assert.Nil(e)
-
-		expected, err := json.Marshal(&addRowWDMP{
-			Command: CommandAddRow,
-			Table:   "t0",
-			Row:     map[string]string{"row": "r0"},
-		})
-
-		if err != nil {
-			panic(err)
-		}
-
-		assert.EqualValues(expected, p)
-	})
-}
-
-func TestRequestReplacePayload(t *testing.T) {
-	t.Run("TableNotProvided", func(t *testing.T) {
-		assert := assert.New(t)
-
-		p, e := requestReplacePayload(nil, nil)
-		assert.Nil(p)
-		assert.EqualValues(ErrMissingTable, e)
-	})
-
-	t.Run("RowsNotProvided", func(t *testing.T) {
-		assert := assert.New(t)
-
-		p, e := requestReplacePayload(map[string]string{"parameter": "t0"}, bytes.NewBufferString(""))
-
-		assert.Nil(p)
-		assert.EqualValues(ErrMissingRows, e)
-	})
-
-	t.Run("IdealPath", func(t *testing.T) {
-		assert := assert.New(t)
-
-		rowsPayload := `{"0": {"row": "r0"}}`
-
-		p, e := requestReplacePayload(map[string]string{"parameter": "t0"}, bytes.NewBufferString(rowsPayload))
-
-		assert.Nil(e)
-
-		expected, err := json.Marshal(&replaceRowsWDMP{
-			Command: CommandReplaceRows,
-			Table:   "t0",
-			Rows:    indexRow{"0": map[string]string{"row": "r0"}},
-		})
-
-		if err != nil {
-			panic(err)
-		}
-
-		assert.EqualValues(expected, p)
-	})
-}
-
-func TestRequestDeletePayload(t *testing.T) {
-	t.Run("NoRowProvided", func(t *testing.T) {
-		assert := assert.New(t)
-		p, e := requestDeletePayload(nil)
-
-		assert.Nil(p)
-		assert.EqualValues(ErrMissingRow, e)
-	})
-
-	t.Run("IdealPath", func(t *testing.T) {
-		assert := assert.New(t)
-
-		expected, err := json.Marshal(&deleteRowDMP{Command: CommandDeleteRow,
-			Row: "0",
-		})
-		if err != nil {
-			panic(err)
-		}
-
-		p, e := requestDeletePayload(map[string]string{"parameter": "0"})
-
-		assert.Nil(e)
-		assert.EqualValues(expected, p)
-	})
-}
-
 func TestEncodeResponse(t *testing.T) {
 	assert := assert.New(t)
 
@@ -297,7 +118,7 @@ func TestEncodeResponse(t *testing.T) {
 			ForwardedHeaders: http.Header{"X-test": []string{"test"}},
 		}
 
-		err := encodeResponse(ctxTID, recorder, response)
+		err := encodeResponse(nil)(ctxTID, recorder, response)
 
 		assert.Nil(err)
 		assert.EqualValues(http.StatusServiceUnavailable, recorder.Code)
@@ -305,16 +126,36 @@ func TestEncodeResponse(t *testing.T) {
 		assert.EqualValues("test", recorder.Header().Get("X-test"))
 	})
 
-	//XMiDT response is not msgpack-encoded
-	//Since this is not expected, Tr1d1um considers it an internal error case
+	//Internal headers of the backend (i.e. its instance names) are kept from API consumers
+	t.Run("ResponseHeaders", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		response := &common.XmidtResponse{
+			Code:             http.StatusServiceUnavailable,
+			ForwardedHeaders: http.Header{"X-Xmidt-Message": {"busy"}, "X-Backend-Instance": {"scytale-3"}, "Age": {"3"}},
+		}
+
+		err := encodeResponse(&encodeOptions{
+			responseHeaders: common.NewResponseHeaderFilter(&common.HeaderForwardingRules{Allowed: []string{"X-Xmidt-Message"}}),
+		})(ctxTID, recorder, response)
+
+		assert.Nil(err)
+		assert.EqualValues("busy", recorder.Header().Get("X-Xmidt-Message"))
+		assert.EqualValues("3", recorder.Header().Get("Age"))
+		assert.Empty(recorder.Header().Get("X-Backend-Instance"))
+	})
+
+	//XMiDT response is not msgpack-encoded (i.e. some proxy returned an HTML page)
+	//Since this is a problem with the upstream server, Tr1d1um reports a bad gateway
 	t.Run("UnexpectedResponseFormat", func(t *testing.T) {
 		recorder := httptest.NewRecorder()
 		response := &common.XmidtResponse{
 			Code: http.StatusOK,
-			Body: []byte("t"),
+			Body: []byte("<html><body>Service Unavailable</body></html>"),
 		}
 
-		assert.NotNil(encodeResponse(ctxTID, recorder, response))
+		err := encodeResponse(nil)(ctxTID, recorder, response)
+		assert.EqualValues(ErrMalformedUpstreamResponse, err)
+		assert.EqualValues(http.StatusBadGateway, err.(common.CodedError).StatusCode())
 	})
 
 	//XMiDt responds with a 200 (OK) with a well-formatted RDK device response
@@ -330,7 +171,7 @@ func TestEncodeResponse(t *testing.T) {
 			}, wrp.Msgpack)).Bytes(),
 		}
 
-		err := encodeResponse(ctxTID, recorder, response)
+		err := encodeResponse(nil)(ctxTID, recorder, response)
 		assert.Nil(err)
 		assert.EqualValues(520, recorder.Code)
 		assert.EqualValues(`{"statusCode": 520}`, recorder.Body.String())
@@ -350,7 +191,7 @@ func TestEncodeResponse(t *testing.T) {
 				Payload: internalErrorResponse}, wrp.Msgpack)).Bytes(),
 		}
 
-		err := encodeResponse(ctxTID, recorder, response)
+		err := encodeResponse(nil)(ctxTID, recorder, response)
 		assert.Nil(err)
 		assert.EqualValues(http.StatusOK, recorder.Code)
 		assert.EqualValues(internalErrorResponse, recorder.Body.Bytes())
@@ -369,11 +210,97 @@ func TestEncodeResponse(t *testing.T) {
 			}, wrp.Msgpack)).Bytes(),
 		}
 
-		err := encodeResponse(ctxTID, recorder, response)
+		err := encodeResponse(nil)(ctxTID, recorder, response)
 		assert.Nil(err)
 		assert.EqualValues(http.StatusOK, recorder.Code)
 		assert.EqualValues(`{"statusCode":`, recorder.Body.String())
 	})
+
+	//Drift detection tooling asks for payloads to be canonicalized
+	t.Run("CanonicalJSON", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+
+		response := &common.XmidtResponse{
+			Code: http.StatusOK,
+			Body: wrp.MustEncode(&wrp.Message{
+				Type:    wrp.SimpleRequestResponseMessageType,
+				Payload: []byte(`{ "statusCode": 200, "parameters": [{"value": "<ssid>", "name": "n0", "dataType": 0}] }`),
+			}, wrp.Msgpack),
+		}
+
+		err := encodeResponse(&encodeOptions{canonicalJSON: true})(ctxTID, recorder, response)
+		assert.Nil(err)
+		assert.EqualValues(`{"parameters":[{"dataType":0,"name":"n0","value":"<ssid>"}],"statusCode":200}`, recorder.Body.String())
+	})
+
+	//The API consumer went away while tr1d1um was waiting for the device response
+	//Tr1d1um should not bother decoding nor writing the payload
+	t.Run("ClientCanceled", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		ctx, cancel := context.WithCancel(ctxTID)
+		cancel()
+
+		response := &common.XmidtResponse{
+			Code: http.StatusOK,
+			Body: wrp.MustEncode(&wrp.Message{
+				Type:    wrp.SimpleRequestResponseMessageType,
+				Payload: []byte(`{"statusCode": 200}`),
+			}, wrp.Msgpack),
+		}
+
+		err := encodeResponse(nil)(ctx, recorder, response)
+		assert.EqualValues(common.ErrClientCanceled, err)
+		assert.Empty(recorder.Body.String())
+	})
+}
+
+//Backends misbehave in ways tests of the happy path don't cover so the faulty backend is used to exercise them
+func TestEncodeResponseFaults(t *testing.T) {
+	var body = wrp.MustEncode(&wrp.Message{Type: wrp.SimpleRequestResponseMessageType, Payload: []byte(`{"statusCode": 200}`)}, wrp.Msgpack)
+
+	for _, testCase := range []struct {
+		fault        string
+		statusCode   int
+		expectedErr  error
+		expectedCode int
+	}{
+		{fault: common.FaultMalformedWRP, expectedErr: ErrMalformedUpstreamResponse},
+		{fault: common.FaultTruncatedWRP, expectedErr: ErrMalformedUpstreamResponse},
+		{fault: common.FaultMalformedPayload, expectedCode: http.StatusOK},
+		{fault: common.FaultExoticStatus, statusCode: 520, expectedCode: 520},
+		{fault: common.FaultExoticStatus, statusCode: 777, expectedErr: ErrMalformedUpstreamResponse},
+	} {
+		testCase := testCase
+
+		t.Run(testCase.fault, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var (
+				next     = new(common.MockBackend)
+				recorder = httptest.NewRecorder()
+				config   = map[string]interface{}{"rate": 1, "faults": []string{testCase.fault}}
+			)
+
+			if testCase.statusCode > 0 {
+				config["statusCodes"] = []int{testCase.statusCode}
+			}
+
+			next.On("SendWRP", ctxTID, new(common.WRPRequest)).Return(&common.XmidtResponse{Code: http.StatusOK, Body: body}, nil)
+			backend, err := common.NewFaultyBackend(next, config)
+			assert.Nil(err)
+
+			response, err := backend.SendWRP(ctxTID, new(common.WRPRequest))
+			assert.Nil(err)
+
+			err = encodeResponse(nil)(ctxTID, recorder, response)
+			assert.Equal(testCase.expectedErr, err)
+			assert.Equal(testCase.fault, recorder.Header().Get(common.HeaderInjectedFault))
+
+			if testCase.expectedErr == nil {
+				assert.Equal(testCase.expectedCode, recorder.Code)
+			}
+		})
+	}
 }
 
 func TestEncodeError(t *testing.T) {
@@ -388,6 +315,9 @@ func TestEncodeError(t *testing.T) {
 			ErrMissingRows,
 			ErrMissingTable,
 			ErrNewCIDRequired,
+			ErrInvalidNewCID,
+			ErrInvalidOldCID,
+			ErrInvalidSyncCMC,
 		} {
 			w := httptest.NewRecorder()
 
@@ -420,6 +350,81 @@ func TestEncodeError(t *testing.T) {
 		}
 	})
 
+	t.Run("GatewayTimeout", func(t *testing.T) {
+		assert := assert.New(t)
+
+		w := httptest.NewRecorder()
+		e := &common.TimeoutError{Stage: common.TimeoutStageConnect}
+		encodeError(ctxTID, e, w)
+
+		expected := bytes.NewBufferString("")
+		json.NewEncoder(expected).Encode(map[string]string{
+			"code":    "connect_timeout",
+			"message": e.Error()})
+
+		assert.EqualValues(expected.String(), w.Body.String())
+		assert.EqualValues(http.StatusGatewayTimeout, w.Code)
+	})
+
+	t.Run("DeviceOffline", func(t *testing.T) {
+		assert := assert.New(t)
+		w := httptest.NewRecorder()
+
+		encodeError(ctxTID, &common.DeviceOfflineError{DeviceID: "mac:112233445566", LastSeen: time.Unix(1000, 0)}, w)
+		assert.EqualValues(http.StatusNotFound, w.Code)
+		assert.JSONEq(`{"message":"device mac:112233445566 is not connected","code":"device-not-connected","lastSeen":"1970-01-01T00:16:40Z"}`, w.Body.String())
+	})
+
+	t.Run("FailedCall", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var (
+			w       = httptest.NewRecorder()
+			inbound = httptest.NewRequest(http.MethodGet, "/api/v2/device/mac:112233445566/config", nil)
+			ctx     = common.Capture(context.Background(), inbound)
+			do      = common.CountAttempts(func(*http.Request) (*http.Response, error) { return nil, errors.New("connection refused") })
+		)
+
+		for i := 0; i < 3; i++ {
+			do(httptest.NewRequest(http.MethodPost, "http://xmidt-1:6000/api/v2/device", nil).WithContext(ctx))
+		}
+
+		encodeError(ctx, common.NewCodedError(errors.New("connection refused"), http.StatusServiceUnavailable), w)
+
+		assert.EqualValues(http.StatusServiceUnavailable, w.Code)
+		assert.JSONEq(fmt.Sprintf(`{"message": "connection refused", "outbound": {"tid": "%s", "target": "http://xmidt-1:6000", "attempts": 3}}`, w.Header().Get(common.TIDHeader(context.Background()))), w.Body.String())
+	})
+
+	t.Run("Localized", func(t *testing.T) {
+		assert := assert.New(t)
+
+		w := httptest.NewRecorder()
+		ctx := context.WithValue(ctxTID, common.ContextKeyRequestHeaders, http.Header{"Accept-Language": []string{"es-MX"}})
+		encodeError(ctx, ErrInvalidService, w)
+
+		assert.EqualValues(http.StatusBadRequest, w.Code)
+		assert.EqualValues("es", w.Header().Get("Content-Language"))
+		assert.JSONEq(`{"message": "servicio no admitido"}`, w.Body.String())
+	})
+
+	t.Run("ValidationError", func(t *testing.T) {
+		assert := assert.New(t)
+
+		w := httptest.NewRecorder()
+		encodeError(ctxTID, &common.ValidationError{Problems: []common.ValidationProblem{
+			{Field: "parameters[0].dataType", Message: "is required with a value"},
+			{Field: "parameters[1].name", Message: "is required"},
+		}}, w)
+
+		assert.EqualValues(http.StatusBadRequest, w.Code)
+		assert.JSONEq(`{
+			"code": "invalid_request_body",
+			"message": "invalid request body: parameters[0].dataType: is required with a value; parameters[1].name: is required",
+			"errors": [
+				{"field": "parameters[0].dataType", "message": "is required with a value"},
+				{"field": "parameters[1].name", "message": "is required"}]}`, w.Body.String())
+	})
+
 	t.Run("InternalError", func(t *testing.T) {
 		assert := assert.New(t)
 
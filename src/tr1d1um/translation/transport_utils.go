@@ -1,104 +1,147 @@
 package translation
 
 import (
+	"bytes"
 	"context"
-	"fmt"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
+	"reflect"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
 
 	"github.com/Comcast/webpa-common/device"
 	"github.com/Comcast/webpa-common/wrp"
 	kithttp "github.com/go-kit/kit/transport/http"
 	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
 )
 
-/* Functions that help decode a given SET request to TR1D1UM */
-
-//deduceSET deduces the command for a given wdmp object
-func deduceSET(wdmp *setWDMP, newCID, oldCID, syncCMC string) (err error) {
-	if newCID == "" && oldCID != "" {
-		return ErrNewCIDRequired
-	} else if newCID == "" && oldCID == "" && syncCMC == "" {
-		wdmp.Command = getCommandForParams(wdmp.Parameters)
-	} else {
-		wdmp.Command = CommandTestSet
-		wdmp.NewCid, wdmp.OldCid, wdmp.SyncCmc = newCID, oldCID, syncCMC
-	}
-
-	return
-}
+/* Other transport-level helper functions */
 
-//isValidSetWDMP helps verify a given Set WDMP object is valid for its context
-func isValidSetWDMP(wdmp *setWDMP) (isValid bool) {
-	if emptyParams := wdmp.Parameters == nil || len(wdmp.Parameters) == 0; emptyParams {
-		return wdmp.Command == CommandTestSet //TEST_AND_SET can have empty parameters
-	}
+//wrp merges different values from a WDMP request into a WRP message
+//partner is the partner ID of the API consumer (see common.RequestPartner) which may be empty
+func wrap(WDMP []byte, tid string, pathVars map[string]string, partner string, addressing *WRPAddressing) (m *wrp.Message, err error) {
+	var canonicalDeviceID device.ID
 
-	var cmdSetAttr, cmdSet = 0, 0
+	if canonicalDeviceID, err = device.ParseID(pathVars["deviceid"]); err == nil {
+		destination, source := addressing.addresses(canonicalDeviceID, pathVars["service"], partner)
 
-	//validate parameters if it exists, even for TEST_SET
-	for _, param := range wdmp.Parameters {
-		if param.Name == nil || *param.Name == "" {
-			return
+		m = &wrp.Message{
+			Type:            wrp.SimpleRequestResponseMessageType,
+			Payload:         WDMP,
+			Destination:     destination,
+			TransactionUUID: tid,
+			Source:          source,
 		}
 
-		if param.Value != nil && (param.DataType == nil || *param.DataType < 0) {
-			return
+		if addressing != nil && addressing.IncludePartner && partner != "" {
+			m.PartnerIDs = []string{partner}
 		}
+	}
+	return
+}
 
-		if wdmp.Command == CommandSetAttrs && param.Attributes == nil {
-			return
-		}
+//canonicalJSON re-marshals the given JSON document with sorted object keys and compact formatting
+//Numbers are preserved as they were received. Payloads that are not valid JSON are returned untouched
+func canonicalJSON(payload []byte) []byte {
+	var (
+		document interface{}
+		decoder  = json.NewDecoder(bytes.NewReader(payload))
+	)
+
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil || decoder.More() {
+		return payload
+	}
 
-		if param.Attributes != nil &&
-			param.DataType == nil &&
-			param.Value == nil {
+	canonical, err := marshalJSON(document)
+	if err != nil {
+		return payload
+	}
 
-			cmdSetAttr++
-		} else {
-			cmdSet++
-		}
+	return canonical
+}
 
-		// verify that all parameters are correct for either doing a command SET_ATTRIBUTE or SET
-		if cmdSetAttr > 0 && cmdSet > 0 {
-			return
-		}
+//marshalJSON is json.Marshal without escaping HTML characters so device values are returned as they were received
+func marshalJSON(document interface{}) ([]byte, error) {
+	var (
+		output  bytes.Buffer
+		encoder = json.NewEncoder(&output)
+	)
+
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(document); err != nil {
+		return nil, err
 	}
-	return true
+
+	//Encode always terminates the document with a newline
+	return bytes.TrimSuffix(output.Bytes(), []byte("\n")), nil
 }
 
-//getCommandForParams decides whether the command for some request is a 'SET' or 'SET_ATTRS' based on a given list of parameters
-func getCommandForParams(params []setParam) (command string) {
-	command = CommandSet
-	if len(params) < 1 {
-		return
-	}
-	if wdmp := params[0]; wdmp.Attributes != nil &&
-		wdmp.Name != nil &&
-		wdmp.DataType == nil &&
-		wdmp.Value == nil {
-		command = CommandSetAttrs
+//maxBodySampleLength is the maximum number of bytes of a payload included in log messages
+const maxBodySampleLength = 256
+
+//bodySample returns a bounded prefix of the given payload suitable for logging
+func bodySample(body []byte) string {
+	if len(body) > maxBodySampleLength {
+		return string(body[:maxBodySampleLength]) + "..."
 	}
-	return
+	return string(body)
 }
 
-/* Other transport-level helper functions */
+//msgpackContentType is the media type of msgpack request bodies
+const msgpackContentType = "application/msgpack"
 
-//wrp merges different values from a WDMP request into a WRP message
-func wrap(WDMP []byte, tid string, pathVars map[string]string) (m *wrp.Message, err error) {
-	var canonicalDeviceID device.ID
+//decodeAcceptedContentType decorates the given decoder so that request bodies of mutating requests
+//are only accepted if they are JSON or, if enabled, msgpack. The latter are converted into JSON so that
+//the given decoder only needs to deal with JSON
+//Raw services take bodies which are passed through untouched
+func decodeAcceptedContentType(acceptMsgpack bool, decoder kithttp.DecodeRequestFunc) kithttp.DecodeRequestFunc {
+	return func(c context.Context, r *http.Request) (interface{}, error) {
+		if r.Method == http.MethodGet || r.Method == http.MethodDelete || r.ContentLength == 0 || isRawService(c) {
+			return decoder(c, r)
+		}
 
-	if canonicalDeviceID, err = device.ParseID(pathVars["deviceid"]); err == nil {
-		service := pathVars["service"]
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get(contentTypeHeaderKey))
+		if err != nil {
+			return nil, ErrUnsupportedMediaType
+		}
 
-		m = &wrp.Message{
-			Type:            wrp.SimpleRequestResponseMessageType,
-			Payload:         WDMP,
-			Destination:     fmt.Sprintf("%s/%s", string(canonicalDeviceID), service),
-			TransactionUUID: tid,
-			Source:          service,
+		switch {
+		case mediaType == "application/json":
+		case mediaType == msgpackContentType && acceptMsgpack:
+			var body []byte
+			if body, err = msgpackToJSON(r.Body); err != nil {
+				return nil, common.NewBadRequestError(err)
+			}
+
+			r.Body, r.ContentLength = ioutil.NopCloser(bytes.NewReader(body)), int64(len(body))
+		default:
+			return nil, ErrUnsupportedMediaType
 		}
+
+		return decoder(c, r)
 	}
-	return
+}
+
+//msgpackToJSON reads a msgpack-encoded document and re-encodes it as JSON
+func msgpackToJSON(in io.Reader) ([]byte, error) {
+	var (
+		document interface{}
+		handle   = &codec.MsgpackHandle{}
+	)
+
+	handle.RawToString = true
+	handle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+
+	if err := codec.NewDecoder(in, handle).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(document)
 }
 
 func decodeValidServiceRequest(services []string, decoder kithttp.DecodeRequestFunc) kithttp.DecodeRequestFunc {
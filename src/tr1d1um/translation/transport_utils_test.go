@@ -1,208 +1,201 @@
 package translation
 
 import (
+	"bytes"
 	"context"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
 
 	"github.com/Comcast/webpa-common/device"
 	"github.com/Comcast/webpa-common/wrp"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestValidateAndDeduceSETCommand(t *testing.T) {
-
-	t.Run("newCIDMissing", func(t *testing.T) {
+func TestWrapInWRP(t *testing.T) {
+	t.Run("EmptyVars", func(t *testing.T) {
 		assert := assert.New(t)
-		wdmp := new(setWDMP)
-		err := deduceSET(wdmp, "", "old-cid", "sync-cm")
-		assert.EqualValues(ErrNewCIDRequired, err)
-	})
 
-	t.Run("", func(t *testing.T) {
-		assert := assert.New(t)
-		wdmp := new(setWDMP)
-		err := deduceSET(wdmp, "", "", "")
-		assert.Nil(err)
-		assert.EqualValues(CommandSet, wdmp.Command)
+		w, e := wrap([]byte(""), "", nil, "", nil)
 
+		assert.Nil(w)
+		assert.EqualValues(device.ErrorInvalidDeviceName, e)
 	})
 
-	t.Run("TestSetNilValues", func(t *testing.T) {
+	t.Run("GivenTID", func(t *testing.T) {
 		assert := assert.New(t)
-		wdmp := new(setWDMP)
 
-		err := deduceSET(wdmp, "newVal", "oldVal", "")
-		assert.Nil(err)
-		assert.EqualValues(CommandTestSet, wdmp.Command)
+		w, e := wrap([]byte{'t'}, "t0", map[string]string{"deviceid": "mac:112233445566", "service": "s0"}, "comcast", nil)
+
+		assert.Nil(e)
+		assert.EqualValues(wrp.SimpleRequestResponseMessageType, w.Type)
+		assert.EqualValues([]byte{'t'}, w.Payload)
+		assert.EqualValues("mac:112233445566/s0", w.Destination)
+		assert.EqualValues("s0", w.Source)
+		assert.EqualValues("t0", w.TransactionUUID)
+		assert.Empty(w.PartnerIDs)
 	})
-}
 
-func TestIsValidSetWDMP(t *testing.T) {
-	t.Run("TestAndSetZeroParams", func(t *testing.T) {
+	t.Run("CustomAddressing", func(t *testing.T) {
 		assert := assert.New(t)
 
-		wdmp := &setWDMP{Command: CommandTestSet} //nil parameters
-		assert.True(isValidSetWDMP(wdmp))
+		w, e := wrap([]byte{'t'}, "t0", map[string]string{"deviceid": "mac:112233445566", "service": "s0"}, "comcast",
+			&WRPAddressing{
+				Destination:    "event:${id}/${service}",
+				Source:         "${service}/${partner}",
+				Service:        "config",
+				IncludePartner: true,
+			})
 
-		wdmp = &setWDMP{Command: CommandTestSet, Parameters: []setParam{}} //empty parameters
-		assert.True(isValidSetWDMP(wdmp))
+		assert.Nil(e)
+		assert.EqualValues("event:112233445566/config", w.Destination)
+		assert.EqualValues("config/comcast", w.Source)
+		assert.EqualValues([]string{"comcast"}, w.PartnerIDs)
 	})
+}
 
-	t.Run("NilNameInParam", func(t *testing.T) {
-		assert := assert.New(t)
-
-		dataType := int8(0)
-		nilNameParam := setParam{
-			Value:    "val",
-			DataType: &dataType,
-			// Name is left undefined
-		}
-		params := []setParam{nilNameParam}
-		wdmp := &setWDMP{Command: CommandSet, Parameters: params}
-		assert.False(isValidSetWDMP(wdmp))
+func TestDecodeValidServiceRequest(t *testing.T) {
+	f := decodeValidServiceRequest([]string{"s0"}, func(_ context.Context, _ *http.Request) (interface{}, error) {
+		return nil, nil
 	})
 
-	t.Run("NilDataTypeNonNilValue", func(t *testing.T) {
+	t.Run("InvalidService", func(t *testing.T) {
 		assert := assert.New(t)
-
-		name := "nameVal"
-		param := setParam{
-			Name:  &name,
-			Value: 3,
-			//DataType is left undefined
-		}
-		params := []setParam{param}
-		wdmp := &setWDMP{Command: CommandSet, Parameters: params}
-		assert.False(isValidSetWDMP(wdmp))
+		r := httptest.NewRequest(http.MethodGet, "localhost:8090/api", nil)
+		i, err := f(context.TODO(), r)
+		assert.Nil(i)
+		assert.EqualValues(ErrInvalidService, err)
 	})
 
-	t.Run("SetAttrsParamNilAttr", func(t *testing.T) {
+	t.Run("ValidService", func(t *testing.T) {
 		assert := assert.New(t)
+		r := httptest.NewRequest(http.MethodGet, "localhost:8090/api", nil)
+		r = mux.SetURLVars(r, map[string]string{"service": "s0"})
 
-		name := "nameVal"
-		param := setParam{
-			Name: &name,
-		}
-		params := []setParam{param}
-		wdmp := &setWDMP{Command: CommandSetAttrs, Parameters: params}
-		assert.False(isValidSetWDMP(wdmp))
+		i, err := f(context.TODO(), r)
+		assert.Nil(i)
+		assert.Nil(err)
 	})
+}
 
-	t.Run("MixedParams", func(t *testing.T) {
-		assert := assert.New(t)
+func TestContains(t *testing.T) {
+	assert := assert.New(t)
+	assert.False(contains("a", nil))
+	assert.False(contains("a", []string{}))
+	assert.True(contains("a", []string{"a", "b"}))
+}
 
-		name, dataType := "victorious", int8(1)
-		setAttrParam := setParam{
-			Name:       &name,
-			Attributes: map[string]interface{}{"three": 3},
-		}
-
-		sp := setParam{
-			Name:       &name,
-			Attributes: map[string]interface{}{"two": 2},
-			Value:      3,
-			DataType:   &dataType,
-		}
-		mixParams := []setParam{setAttrParam, sp}
-		wdmp := &setWDMP{Command: CommandSetAttrs, Parameters: mixParams}
-		assert.False(isValidSetWDMP(wdmp))
+func TestBodySample(t *testing.T) {
+	t.Run("Short", func(t *testing.T) {
+		assert.EqualValues(t, "<html>", bodySample([]byte("<html>")))
 	})
 
-	t.Run("IdealSet", func(t *testing.T) {
+	t.Run("Long", func(t *testing.T) {
 		assert := assert.New(t)
-
-		name := "victorious"
-		setAttrParam := setParam{
-			Name:       &name,
-			Attributes: map[string]interface{}{"three": 3},
-		}
-		params := []setParam{setAttrParam}
-		wdmp := &setWDMP{Command: CommandSetAttrs, Parameters: params}
-		assert.True(isValidSetWDMP(wdmp))
+		sample := bodySample(bytes.Repeat([]byte("a"), 2*maxBodySampleLength))
+		assert.Len(sample, maxBodySampleLength+len("..."))
 	})
 }
 
-func TestGetCommandForParam(t *testing.T) {
-	t.Run("EmptyParams", func(t *testing.T) {
-		assert := assert.New(t)
-		assert.EqualValues(CommandSet, getCommandForParams(nil))
-		assert.EqualValues(CommandSet, getCommandForParams([]setParam{}))
-	})
+func TestDecodeAcceptedContentType(t *testing.T) {
+	var decoded []byte
+	var decoder = func(_ context.Context, r *http.Request) (interface{}, error) {
+		decoded, _ = ioutil.ReadAll(r.Body)
+		return nil, nil
+	}
 
-	//Attributes and Name are required properties for SET_ATTRS
-	t.Run("SetCommandUndefinedAttributes", func(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
 		assert := assert.New(t)
-		name := "setParam"
-		setCommandParam := setParam{Name: &name}
-		assert.EqualValues(CommandSet, getCommandForParams([]setParam{setCommandParam}))
+		r := httptest.NewRequest(http.MethodPatch, "localhost:8090/api", bytes.NewBufferString(`{"parameters": []}`))
+		r.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+		_, err := decodeAcceptedContentType(false, decoder)(context.TODO(), r)
+		assert.Nil(err)
+		assert.EqualValues(`{"parameters": []}`, string(decoded))
 	})
 
-	//DataType and Value must be null for SET_ATTRS
-	t.Run("SetAttrsCommand", func(t *testing.T) {
+	t.Run("MissingContentType", func(t *testing.T) {
 		assert := assert.New(t)
-		name := "setAttrsParam"
-		setCommandParam := setParam{
-			Name:       &name,
-			Attributes: map[string]interface{}{"zero": 0},
-		}
-		assert.EqualValues(CommandSetAttrs, getCommandForParams([]setParam{setCommandParam}))
+		r := httptest.NewRequest(http.MethodPut, "localhost:8090/api", bytes.NewBufferString(`{}`))
+
+		_, err := decodeAcceptedContentType(false, decoder)(context.TODO(), r)
+		assert.EqualValues(ErrUnsupportedMediaType, err)
 	})
-}
-func TestWrapInWRP(t *testing.T) {
-	t.Run("EmptyVars", func(t *testing.T) {
-		assert := assert.New(t)
 
-		w, e := wrap([]byte(""), "", nil)
+	t.Run("EmptyBody", func(t *testing.T) {
+		assert := assert.New(t)
+		r := httptest.NewRequest(http.MethodPatch, "localhost:8090/api", nil)
 
-		assert.Nil(w)
-		assert.EqualValues(device.ErrorInvalidDeviceName, e)
+		_, err := decodeAcceptedContentType(false, decoder)(context.TODO(), r)
+		assert.Nil(err)
 	})
 
-	t.Run("GivenTID", func(t *testing.T) {
+	t.Run("RawService", func(t *testing.T) {
 		assert := assert.New(t)
+		r := httptest.NewRequest(http.MethodPost, "localhost:8090/api", bytes.NewBufferString(`raw`))
+		r.Header.Set("Content-Type", "text/plain")
+		r = mux.SetURLVars(r, map[string]string{"service": "iot"})
 
-		w, e := wrap([]byte{'t'}, "t0", map[string]string{"deviceid": "mac:112233445566", "service": "s0"})
-
-		assert.Nil(e)
-		assert.EqualValues(wrp.SimpleRequestResponseMessageType, w.Type)
-		assert.EqualValues([]byte{'t'}, w.Payload)
-		assert.EqualValues("mac:112233445566/s0", w.Destination)
-		assert.EqualValues("s0", w.Source)
-		assert.EqualValues("t0", w.TransactionUUID)
+		_, err := decodeAcceptedContentType(false, decoder)(captureRawService(DefaultRawServices)(context.TODO(), r), r)
+		assert.Nil(err)
 	})
-}
 
-func TestDecodeValidServiceRequest(t *testing.T) {
-	f := decodeValidServiceRequest([]string{"s0"}, func(_ context.Context, _ *http.Request) (interface{}, error) {
-		return nil, nil
+	t.Run("MsgpackDisabled", func(t *testing.T) {
+		assert := assert.New(t)
+		r := httptest.NewRequest(http.MethodPost, "localhost:8090/api", bytes.NewBufferString(`x`))
+		r.Header.Set("Content-Type", msgpackContentType)
+
+		_, err := decodeAcceptedContentType(false, decoder)(context.TODO(), r)
+		assert.EqualValues(ErrUnsupportedMediaType, err)
 	})
 
-	t.Run("InvalidService", func(t *testing.T) {
+	t.Run("MsgpackEnabled", func(t *testing.T) {
 		assert := assert.New(t)
-		r := httptest.NewRequest(http.MethodGet, "localhost:8090/api", nil)
-		i, err := f(context.TODO(), r)
-		assert.Nil(i)
-		assert.EqualValues(ErrInvalidService, err)
+
+		var body []byte
+		codec.NewEncoderBytes(&body, new(codec.MsgpackHandle)).Encode(map[string]interface{}{
+			"row": map[string]string{"name": "n0"},
+		})
+
+		r := httptest.NewRequest(http.MethodPost, "localhost:8090/api", bytes.NewBuffer(body))
+		r.Header.Set("Content-Type", msgpackContentType)
+
+		_, err := decodeAcceptedContentType(true, decoder)(context.TODO(), r)
+		assert.Nil(err)
+		assert.JSONEq(`{"row": {"name": "n0"}}`, string(decoded))
 	})
 
-	t.Run("ValidService", func(t *testing.T) {
+	t.Run("MalformedMsgpack", func(t *testing.T) {
 		assert := assert.New(t)
-		r := httptest.NewRequest(http.MethodGet, "localhost:8090/api", nil)
-		r = mux.SetURLVars(r, map[string]string{"service": "s0"})
+		r := httptest.NewRequest(http.MethodPost, "localhost:8090/api", bytes.NewBuffer([]byte{0xc1}))
+		r.Header.Set("Content-Type", msgpackContentType)
 
-		i, err := f(context.TODO(), r)
-		assert.Nil(i)
-		assert.Nil(err)
+		_, err := decodeAcceptedContentType(true, decoder)(context.TODO(), r)
+		assert.EqualValues(http.StatusBadRequest, err.(common.CodedError).StatusCode())
 	})
 }
 
-func TestContains(t *testing.T) {
-	assert := assert.New(t)
-	assert.False(contains("a", nil))
-	assert.False(contains("a", []string{}))
-	assert.True(contains("a", []string{"a", "b"}))
+func TestCanonicalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		expected string
+	}{
+		{"SortedKeys", `{"b": 1, "a": {"d": 2, "c": 3}}`, `{"a":{"c":3,"d":2},"b":1}`},
+		{"PreservedNumbers", `{"n": 12345678901234567890, "f": 1.50}`, `{"f":1.50,"n":12345678901234567890}`},
+		{"NotJSON", `{"statusCode":`, `{"statusCode":`},
+		{"MultipleDocuments", `{} {}`, `{} {}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.EqualValues(t, test.expected, string(canonicalJSON([]byte(test.payload))))
+		})
+	}
 }
@@ -0,0 +1,75 @@
+package translation
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
+
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+type wdmpVersionContextKey struct{}
+
+//captureWDMPVersion records the WDMP version requested by the API consumer
+func captureWDMPVersion(ctx context.Context, r *http.Request) context.Context {
+	//raw payloads are not WDMP
+	if isRawService(ctx) {
+		return ctx
+	}
+
+	return context.WithValue(ctx, wdmpVersionContextKey{}, strings.TrimSpace(r.Header.Get(wdmp.HeaderWDMPVersion)))
+}
+
+//wdmpEncoding returns the encoding of the WDMP version negotiated for the request
+//A nil encoding means the request payloads are not WDMP and should be passed through untouched
+func wdmpEncoding(ctx context.Context) (*wdmp.Encoding, error) {
+	version, ok := ctx.Value(wdmpVersionContextKey{}).(string)
+	if !ok {
+		return nil, nil
+	}
+
+	return wdmp.EncodingFor(version)
+}
+
+//encodeWDMP converts the JSON WDMP document carried by the given message into the negotiated encoding
+//The request transform hook of the route, if any, is applied first
+func encodeWDMP(ctx context.Context, m *wrp.Message) error {
+	e, err := wdmpEncoding(ctx)
+	if err != nil || e == nil {
+		return err
+	}
+
+	if m.Payload, err = transformRequest(ctx, m.Payload); err != nil {
+		return err
+	}
+
+	if m.Payload, err = e.Encode(m.Payload); err == nil {
+		m.ContentType = e.ContentType
+	}
+
+	return err
+}
+
+//decodeWDMP converts a device payload in the negotiated encoding into a JSON WDMP document
+//The response transform hook of the route, if any, is applied last
+func decodeWDMP(ctx context.Context, payload []byte) ([]byte, error) {
+	e, err := wdmpEncoding(ctx)
+	if err != nil || e == nil {
+		return payload, err
+	}
+
+	if payload, err = e.Decode(payload); err != nil {
+		return nil, err
+	}
+
+	return transformResponse(ctx, payload)
+}
+
+//reportWDMPVersion lets the API consumer know the WDMP version spoken with the device
+func reportWDMPVersion(ctx context.Context, h http.Header) {
+	if e, err := wdmpEncoding(ctx); err == nil && e != nil {
+		h.Set(wdmp.HeaderWDMPVersion, e.Version)
+	}
+}
@@ -0,0 +1,67 @@
+package translation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/wdmp"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeWDMP(t *testing.T) {
+	newRequest := func(service, version string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
+		r.Header.Set(wdmp.HeaderWDMPVersion, version)
+		return mux.SetURLVars(r, map[string]string{"service": service})
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		assert := assert.New(t)
+		ctx := captureWDMPVersion(ctxTID, newRequest("config", ""))
+		m := &wrp.Message{Payload: []byte(`{"command":"GET","names":["p0"]}`)}
+
+		assert.Nil(encodeWDMP(ctx, m))
+		assert.EqualValues(`{"command":"GET","names":["p0"]}`, string(m.Payload))
+		assert.Empty(m.ContentType)
+
+		recorder := httptest.NewRecorder()
+		reportWDMPVersion(ctx, recorder.Header())
+		assert.EqualValues(wdmp.Version1, recorder.Header().Get(wdmp.HeaderWDMPVersion))
+	})
+
+	t.Run("Version2", func(t *testing.T) {
+		assert := assert.New(t)
+		ctx := captureWDMPVersion(ctxTID, newRequest("config", "2"))
+		m := &wrp.Message{Payload: []byte(`{"command":"GET","names":["p0"]}`)}
+
+		assert.Nil(encodeWDMP(ctx, m))
+		assert.EqualValues("application/msgpack", m.ContentType)
+
+		document, err := decodeWDMP(ctx, m.Payload)
+		assert.Nil(err)
+		assert.JSONEq(`{"command":"GET","names":["p0"]}`, string(document))
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		ctx := captureWDMPVersion(ctxTID, newRequest("config", "9"))
+		assert.EqualValues(t, ErrUnsupportedWDMPVersion, encodeWDMP(ctx, &wrp.Message{Payload: []byte(`{}`)}))
+	})
+
+	t.Run("IOT", func(t *testing.T) {
+		assert := assert.New(t)
+		r := newRequest("iot", "9")
+		ctx := captureWDMPVersion(captureRawService(DefaultRawServices)(ctxTID, r), r)
+		m := &wrp.Message{Payload: []byte("raw")}
+
+		assert.Nil(encodeWDMP(ctx, m))
+		assert.EqualValues("raw", string(m.Payload))
+
+		recorder := httptest.NewRecorder()
+		reportWDMPVersion(ctx, recorder.Header())
+		assert.Empty(recorder.Header().Get(wdmp.HeaderWDMPVersion))
+	})
+}
@@ -0,0 +1,82 @@
+package translation
+
+import (
+	"strings"
+
+	"github.com/Comcast/webpa-common/device"
+)
+
+//Placeholders available to the WRP addressing templates
+const (
+	//PlaceholderDevice is replaced by the canonical device ID (i.e. mac:112233445566)
+	PlaceholderDevice = "${device}"
+
+	//PlaceholderScheme is replaced by the locator scheme of the device ID (i.e. mac)
+	PlaceholderScheme = "${scheme}"
+
+	//PlaceholderID is replaced by the device ID without its locator scheme (i.e. 112233445566)
+	PlaceholderID = "${id}"
+
+	//PlaceholderService is replaced by the target device service
+	PlaceholderService = "${service}"
+
+	//PlaceholderPartner is replaced by the partner ID of the API consumer, if any
+	PlaceholderPartner = "${partner}"
+)
+
+//Default WRP addressing templates. They preserve the original tr1d1um behavior
+const (
+	DefaultWRPDestination = PlaceholderDevice + "/" + PlaceholderService
+	DefaultWRPSource      = PlaceholderService
+)
+
+//WRPAddressing configures how the source and destination of outgoing WRP messages are built
+type WRPAddressing struct {
+	//Destination is the template for the WRP destination. Defaults to DefaultWRPDestination
+	Destination string
+
+	//Source is the template for the WRP source. Defaults to DefaultWRPSource
+	//Note that the service's WRPSource is always prepended to the result
+	Source string
+
+	//Service, if set, replaces the service name from the request path in the templates
+	//This allows tr1d1um to front device services with non-standard names
+	Service string
+
+	//IncludePartner indicates whether the partner ID of the API consumer should be set in outgoing WRP messages
+	IncludePartner bool
+}
+
+//addresses returns the WRP destination and source for a message to the given device service on behalf of partner
+func (a *WRPAddressing) addresses(deviceID device.ID, service, partner string) (destination, source string) {
+	var destinationTemplate, sourceTemplate = DefaultWRPDestination, DefaultWRPSource
+
+	if a != nil {
+		if a.Destination != "" {
+			destinationTemplate = a.Destination
+		}
+
+		if a.Source != "" {
+			sourceTemplate = a.Source
+		}
+
+		if a.Service != "" {
+			service = a.Service
+		}
+	}
+
+	var scheme, id = "", string(deviceID)
+	if i := strings.Index(id, ":"); i >= 0 {
+		scheme, id = id[:i], id[i+1:]
+	}
+
+	r := strings.NewReplacer(
+		PlaceholderDevice, string(deviceID),
+		PlaceholderScheme, scheme,
+		PlaceholderID, id,
+		PlaceholderService, service,
+		PlaceholderPartner, partner,
+	)
+
+	return r.Replace(destinationTemplate), r.Replace(sourceTemplate)
+}
@@ -0,0 +1,31 @@
+package translation
+
+import (
+	"testing"
+
+	"github.com/Comcast/webpa-common/device"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWRPAddressingAddresses(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		assert := assert.New(t)
+		var a *WRPAddressing
+
+		destination, source := a.addresses(device.ID("mac:112233445566"), "config", "")
+		assert.EqualValues("mac:112233445566/config", destination)
+		assert.EqualValues("config", source)
+	})
+
+	t.Run("Placeholders", func(t *testing.T) {
+		assert := assert.New(t)
+		a := &WRPAddressing{
+			Destination: "${scheme}|${id}|${device}|${service}|${partner}",
+			Source:      "${partner}",
+		}
+
+		destination, source := a.addresses(device.ID("uuid:abc"), "iot", "p0")
+		assert.EqualValues("uuid|abc|uuid:abc|iot|p0", destination)
+		assert.EqualValues("p0", source)
+	})
+}
@@ -0,0 +1,282 @@
+package wdmp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+)
+
+/* GET */
+
+//GetPayload builds the WDMP for reading the given comma-separated parameter names
+//If attributes is not empty, the parameter attributes are requested instead of their values
+func GetPayload(names, attributes string) ([]byte, error) {
+	if names == "" {
+		return nil, ErrEmptyNames
+	}
+
+	wdmp := new(GetRequest)
+
+	//default values at this point
+	wdmp.Names, wdmp.Command = strings.Split(names, ","), CommandGet
+
+	if attributes != "" {
+		normalized, err := NormalizeAttributes(attributes)
+		if err != nil {
+			return nil, err
+		}
+
+		wdmp.Command, wdmp.Attributes = CommandGetAttrs, normalized
+	}
+
+	return json.Marshal(wdmp)
+}
+
+//NormalizeAttributes accepts attributes either as a plain (comma-separated) list
+//or as a JSON array of strings and converts them into the comma-separated form WDMP expects
+//Each attribute name is validated against the supported set
+func NormalizeAttributes(attributes string) (string, error) {
+	var names []string
+
+	if trimmed := strings.TrimSpace(attributes); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal([]byte(trimmed), &names); err != nil {
+			return "", ErrInvalidAttributes
+		}
+	} else {
+		names = strings.Split(trimmed, ",")
+	}
+
+	var (
+		normalized = make([]string, 0, len(names))
+		seen       = make(map[string]bool)
+	)
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+
+		if !supportedAttributes[name] {
+			return "", common.NewBadRequestError(fmt.Errorf("unsupported attribute '%s'", name))
+		}
+
+		if !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+
+	return strings.Join(normalized, ","), nil
+}
+
+/* SET */
+
+//cidPattern is the format config IDs sent through the WPA sync headers must follow
+var cidPattern = regexp.MustCompile(`^[0-9A-Za-z_-]{1,64}$`)
+
+//SetPayload builds the WDMP for the JSON-encoded SET request read from in
+//The command is deduced from the parameters and the (optional) TEST_AND_SET values
+//All the problems of the body are reported at once in a common.ValidationError
+func SetPayload(in io.Reader, newCID, oldCID, syncCMC string) ([]byte, error) {
+	return LimitedSetPayload(in, nil, newCID, oldCID, syncCMC)
+}
+
+//LimitedSetPayload is SetPayload for request bodies bound by the given limits, which may be nil
+//Oversized bodies fail with a SetBodySizeError while oversized values are reported along with the other problems
+func LimitedSetPayload(in io.Reader, limits *SetLimits, newCID, oldCID, syncCMC string) (p []byte, err error) {
+	var (
+		wdmp = new(SetRequest)
+		data []byte
+	)
+
+	if data, err = limits.read(in); err == nil {
+		if problems := validateSet(data, limits); len(problems) > 0 {
+			return nil, &common.ValidationError{Problems: problems}
+		}
+
+		//read data into wdmp
+		if err = json.Unmarshal(data, wdmp); err == nil || len(data) == 0 { //len(data) == 0 case is for TEST_SET
+			if err = DeduceSET(wdmp, newCID, oldCID, syncCMC); err == nil {
+				if !IsValidSet(wdmp) {
+					return nil, ErrInvalidSetWDMP
+				}
+
+				if duplicates := duplicateParamNames(wdmp.Parameters); len(duplicates) > 0 {
+					return nil, common.NewBadRequestError(fmt.Errorf("parameters may only be set once per request. Duplicates: %s", strings.Join(duplicates, ", ")))
+				}
+				return json.Marshal(wdmp)
+			}
+		}
+	}
+
+	return
+}
+
+//DeduceSET deduces the command for a given wdmp object
+func DeduceSET(wdmp *SetRequest, newCID, oldCID, syncCMC string) (err error) {
+	if newCID == "" && oldCID != "" {
+		return ErrNewCIDRequired
+	} else if newCID == "" && oldCID == "" && syncCMC == "" {
+		wdmp.Command = getCommandForParams(wdmp.Parameters)
+	} else {
+		if err = validateSyncHeaders(newCID, oldCID, syncCMC); err != nil {
+			return
+		}
+
+		wdmp.Command = CommandTestSet
+		wdmp.NewCid, wdmp.OldCid, wdmp.SyncCmc = newCID, oldCID, syncCMC
+	}
+
+	return
+}
+
+//validateSyncHeaders verifies the format of the given (non-empty) WPA sync header values
+//so that the API consumer learns exactly which header is wrong
+func validateSyncHeaders(newCID, oldCID, syncCMC string) error {
+	if newCID != "" && !cidPattern.MatchString(newCID) {
+		return ErrInvalidNewCID
+	}
+
+	if oldCID != "" && !cidPattern.MatchString(oldCID) {
+		return ErrInvalidOldCID
+	}
+
+	if syncCMC != "" {
+		if _, err := strconv.ParseUint(syncCMC, 10, 32); err != nil {
+			return ErrInvalidSyncCMC
+		}
+	}
+
+	return nil
+}
+
+//IsValidSet helps verify a given Set WDMP object is valid for its context
+func IsValidSet(wdmp *SetRequest) (isValid bool) {
+	if emptyParams := wdmp.Parameters == nil || len(wdmp.Parameters) == 0; emptyParams {
+		return wdmp.Command == CommandTestSet //TEST_AND_SET can have empty parameters
+	}
+
+	var cmdSetAttr, cmdSet = 0, 0
+
+	//validate parameters if it exists, even for TEST_SET
+	for _, param := range wdmp.Parameters {
+		if param.Name == nil || *param.Name == "" {
+			return
+		}
+
+		if param.Value != nil && (param.DataType == nil || *param.DataType < 0) {
+			return
+		}
+
+		if wdmp.Command == CommandSetAttrs && param.Attributes == nil {
+			return
+		}
+
+		if param.Attributes != nil &&
+			param.DataType == nil &&
+			param.Value == nil {
+
+			cmdSetAttr++
+		} else {
+			cmdSet++
+		}
+
+		// verify that all parameters are correct for either doing a command SET_ATTRIBUTE or SET
+		if cmdSetAttr > 0 && cmdSet > 0 {
+			return
+		}
+	}
+	return true
+}
+
+//duplicateParamNames returns the names that appear more than once in the given parameters
+//in the order in which they were first repeated
+//Parameters are assumed to have been validated to have names
+func duplicateParamNames(params []SetParam) (duplicates []string) {
+	var seen = make(map[string]int, len(params))
+
+	for _, param := range params {
+		seen[*param.Name]++
+		if seen[*param.Name] == 2 {
+			duplicates = append(duplicates, *param.Name)
+		}
+	}
+	return
+}
+
+//getCommandForParams decides whether the command for some request is a 'SET' or 'SET_ATTRS' based on a given list of parameters
+func getCommandForParams(params []SetParam) (command string) {
+	command = CommandSet
+	if len(params) < 1 {
+		return
+	}
+	if wdmp := params[0]; wdmp.Attributes != nil &&
+		wdmp.Name != nil &&
+		wdmp.DataType == nil &&
+		wdmp.Value == nil {
+		command = CommandSetAttrs
+	}
+	return
+}
+
+/* Table rows */
+
+//AddRowPayload builds the WDMP for adding the JSON-encoded row read from in to the given table
+func AddRowPayload(table string, in io.Reader) (p []byte, err error) {
+	if table == "" {
+		return nil, ErrMissingTable
+	}
+
+	var (
+		wdmp    = &AddRowRequest{Command: CommandAddRow, Table: table}
+		payload []byte
+	)
+
+	if payload, err = ioutil.ReadAll(in); err == nil {
+		if len(payload) == 0 {
+			return nil, ErrMissingRow
+		}
+
+		if err = json.Unmarshal(payload, &wdmp.Row); err == nil {
+			return json.Marshal(wdmp)
+		}
+	}
+
+	return
+}
+
+//ReplaceRowsPayload builds the WDMP for replacing the rows of the given table with the JSON-encoded rows read from in
+func ReplaceRowsPayload(table string, in io.Reader) (p []byte, err error) {
+	if table == "" {
+		return nil, ErrMissingTable
+	}
+
+	var (
+		wdmp    = &ReplaceRowsRequest{Command: CommandReplaceRows, Table: table}
+		payload []byte
+	)
+
+	if payload, err = ioutil.ReadAll(in); err == nil {
+		if len(payload) == 0 {
+			return nil, ErrMissingRows
+		}
+
+		if err = json.Unmarshal(payload, &wdmp.Rows); err == nil {
+			return json.Marshal(wdmp)
+		}
+	}
+
+	return
+}
+
+//DeleteRowPayload builds the WDMP for deleting the given row
+func DeleteRowPayload(row string) ([]byte, error) {
+	if row == "" {
+		return nil, ErrMissingRow
+	}
+	return json.Marshal(&DeleteRowRequest{Command: CommandDeleteRow, Row: row})
+}
@@ -0,0 +1,447 @@
+package wdmp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPayload(t *testing.T) {
+	t.Run("EmptyNames", func(t *testing.T) {
+		assert := assert.New(t)
+
+		p, e := GetPayload("", "")
+		assert.EqualValues(ErrEmptyNames, e)
+		assert.Nil(p)
+	})
+
+	t.Run("GET", func(t *testing.T) {
+		assert := assert.New(t)
+
+		p, e := GetPayload("n0,n1", "")
+		assert.Nil(e)
+
+		expectedBytes, err := json.Marshal(&GetRequest{Command: CommandGet, Names: []string{"n0", "n1"}})
+
+		if err != nil {
+			panic(err)
+		}
+
+		assert.EqualValues(expectedBytes, p)
+	})
+
+	t.Run("GETAttrs", func(t *testing.T) {
+		assert := assert.New(t)
+
+		p, e := GetPayload("n0,n1", "notify")
+		assert.Nil(e)
+
+		expectedBytes, err := json.Marshal(&GetRequest{Command: CommandGetAttrs, Names: []string{"n0", "n1"}, Attributes: "notify"})
+
+		if err != nil {
+			panic(err)
+		}
+
+		assert.EqualValues(expectedBytes, p)
+	})
+
+	t.Run("GETAttrsUnsupported", func(t *testing.T) {
+		assert := assert.New(t)
+
+		p, e := GetPayload("n0,n1", "attr0")
+		assert.Nil(p)
+		assert.EqualValues(http.StatusBadRequest, e.(common.CodedError).StatusCode())
+	})
+}
+
+func TestSetPayload(t *testing.T) {
+	t.Run("ErrAtDeduction", func(t *testing.T) {
+		assert := assert.New(t)
+		_, e := SetPayload(bytes.NewBufferString(""), "", "old", "sync")
+
+		assert.EqualValues(ErrNewCIDRequired, e)
+	})
+
+	t.Run("InvalidWDMP", func(t *testing.T) {
+		assert := assert.New(t)
+		_, e := SetPayload(bytes.NewBufferString(""), "", "", "")
+
+		assert.EqualValues(ErrInvalidSetWDMP, e)
+	})
+
+	t.Run("Ideal", func(t *testing.T) {
+		assert := assert.New(t)
+		p, e := SetPayload(bytes.NewBufferString(""), "new", "old", "512")
+
+		wdmp := new(SetRequest)
+		err := json.NewDecoder(bytes.NewBuffer(p)).Decode(wdmp)
+
+		if err != nil {
+			panic(err)
+		}
+
+		assert.Nil(e)
+		assert.EqualValues(CommandTestSet, wdmp.Command)
+		assert.EqualValues("new", wdmp.NewCid)
+		assert.EqualValues("old", wdmp.OldCid)
+		assert.EqualValues("512", wdmp.SyncCmc)
+	})
+
+	t.Run("DuplicateParams", func(t *testing.T) {
+		assert := assert.New(t)
+		body := `{"parameters": [
+			{"name": "p0", "dataType": 0, "value": "a"},
+			{"name": "p1", "dataType": 0, "value": "b"},
+			{"name": "p0", "dataType": 0, "value": "c"}]}`
+
+		p, e := SetPayload(bytes.NewBufferString(body), "", "", "")
+
+		assert.Nil(p)
+		assert.EqualValues(http.StatusBadRequest, e.(common.CodedError).StatusCode())
+		assert.Contains(e.Error(), "p0")
+		assert.NotContains(e.Error(), "p1")
+	})
+}
+
+func TestAddRowPayload(t *testing.T) {
+	t.Run("TableNotProvided", func(t *testing.T) {
+		assert := assert.New(t)
+
+		p, e := AddRowPayload("", nil)
+		assert.Nil(p)
+		assert.EqualValues(ErrMissingTable, e)
+	})
+
+	t.Run("RowNotProvided", func(t *testing.T) {
+		assert := assert.New(t)
+
+		p, e := AddRowPayload("t0", bytes.NewBufferString(""))
+
+		assert.Nil(p)
+		assert.EqualValues(ErrMissingRow, e)
+	})
+
+	t.Run("IdealPath", func(t *testing.T) {
+		assert := assert.New(t)
+		p, e := AddRowPayload("t0", bytes.NewBufferString(`{"row": "r0"}`))
+
+		assert.Nil(e)
+
+		expected, err := json.Marshal(&AddRowRequest{
+			Command: CommandAddRow,
+			Table:   "t0",
+			Row:     map[string]string{"row": "r0"},
+		})
+
+		if err != nil {
+			panic(err)
+		}
+
+		assert.EqualValues(expected, p)
+	})
+}
+
+func TestReplaceRowsPayload(t *testing.T) {
+	t.Run("TableNotProvided", func(t *testing.T) {
+		assert := assert.New(t)
+
+		p, e := ReplaceRowsPayload("", nil)
+		assert.Nil(p)
+		assert.EqualValues(ErrMissingTable, e)
+	})
+
+	t.Run("RowsNotProvided", func(t *testing.T) {
+		assert := assert.New(t)
+
+		p, e := ReplaceRowsPayload("t0", bytes.NewBufferString(""))
+
+		assert.Nil(p)
+		assert.EqualValues(ErrMissingRows, e)
+	})
+
+	t.Run("IdealPath", func(t *testing.T) {
+		assert := assert.New(t)
+
+		rowsPayload := `{"0": {"row": "r0"}}`
+
+		p, e := ReplaceRowsPayload("t0", bytes.NewBufferString(rowsPayload))
+
+		assert.Nil(e)
+
+		expected, err := json.Marshal(&ReplaceRowsRequest{
+			Command: CommandReplaceRows,
+			Table:   "t0",
+			Rows:    IndexRow{"0": map[string]string{"row": "r0"}},
+		})
+
+		if err != nil {
+			panic(err)
+		}
+
+		assert.EqualValues(expected, p)
+	})
+}
+
+func TestDeleteRowPayload(t *testing.T) {
+	t.Run("NoRowProvided", func(t *testing.T) {
+		assert := assert.New(t)
+		p, e := DeleteRowPayload("")
+
+		assert.Nil(p)
+		assert.EqualValues(ErrMissingRow, e)
+	})
+
+	t.Run("IdealPath", func(t *testing.T) {
+		assert := assert.New(t)
+
+		expected, err := json.Marshal(&DeleteRowRequest{Command: CommandDeleteRow,
+			Row: "0",
+		})
+		if err != nil {
+			panic(err)
+		}
+
+		p, e := DeleteRowPayload("0")
+
+		assert.Nil(e)
+		assert.EqualValues(expected, p)
+	})
+}
+
+func TestDeduceSET(t *testing.T) {
+
+	t.Run("newCIDMissing", func(t *testing.T) {
+		assert := assert.New(t)
+		wdmp := new(SetRequest)
+		err := DeduceSET(wdmp, "", "old-cid", "sync-cm")
+		assert.EqualValues(ErrNewCIDRequired, err)
+	})
+
+	t.Run("", func(t *testing.T) {
+		assert := assert.New(t)
+		wdmp := new(SetRequest)
+		err := DeduceSET(wdmp, "", "", "")
+		assert.Nil(err)
+		assert.EqualValues(CommandSet, wdmp.Command)
+
+	})
+
+	t.Run("TestSetNilValues", func(t *testing.T) {
+		assert := assert.New(t)
+		wdmp := new(SetRequest)
+
+		err := DeduceSET(wdmp, "newVal", "oldVal", "")
+		assert.Nil(err)
+		assert.EqualValues(CommandTestSet, wdmp.Command)
+	})
+}
+
+func TestIsValidSet(t *testing.T) {
+	t.Run("TestAndSetZeroParams", func(t *testing.T) {
+		assert := assert.New(t)
+
+		wdmp := &SetRequest{Command: CommandTestSet} //nil parameters
+		assert.True(IsValidSet(wdmp))
+
+		wdmp = &SetRequest{Command: CommandTestSet, Parameters: []SetParam{}} //empty parameters
+		assert.True(IsValidSet(wdmp))
+	})
+
+	t.Run("NilNameInParam", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dataType := int8(0)
+		nilNameParam := SetParam{
+			Value:    "val",
+			DataType: &dataType,
+			// Name is left undefined
+		}
+		params := []SetParam{nilNameParam}
+		wdmp := &SetRequest{Command: CommandSet, Parameters: params}
+		assert.False(IsValidSet(wdmp))
+	})
+
+	t.Run("NilDataTypeNonNilValue", func(t *testing.T) {
+		assert := assert.New(t)
+
+		name := "nameVal"
+		param := SetParam{
+			Name:  &name,
+			Value: 3,
+			//DataType is left undefined
+		}
+		params := []SetParam{param}
+		wdmp := &SetRequest{Command: CommandSet, Parameters: params}
+		assert.False(IsValidSet(wdmp))
+	})
+
+	t.Run("SetAttrsParamNilAttr", func(t *testing.T) {
+		assert := assert.New(t)
+
+		name := "nameVal"
+		param := SetParam{
+			Name: &name,
+		}
+		params := []SetParam{param}
+		wdmp := &SetRequest{Command: CommandSetAttrs, Parameters: params}
+		assert.False(IsValidSet(wdmp))
+	})
+
+	t.Run("MixedParams", func(t *testing.T) {
+		assert := assert.New(t)
+
+		name, dataType := "victorious", int8(1)
+		setAttrParam := SetParam{
+			Name:       &name,
+			Attributes: map[string]interface{}{"three": 3},
+		}
+
+		sp := SetParam{
+			Name:       &name,
+			Attributes: map[string]interface{}{"two": 2},
+			Value:      3,
+			DataType:   &dataType,
+		}
+		mixParams := []SetParam{setAttrParam, sp}
+		wdmp := &SetRequest{Command: CommandSetAttrs, Parameters: mixParams}
+		assert.False(IsValidSet(wdmp))
+	})
+
+	t.Run("IdealSet", func(t *testing.T) {
+		assert := assert.New(t)
+
+		name := "victorious"
+		setAttrParam := SetParam{
+			Name:       &name,
+			Attributes: map[string]interface{}{"three": 3},
+		}
+		params := []SetParam{setAttrParam}
+		wdmp := &SetRequest{Command: CommandSetAttrs, Parameters: params}
+		assert.True(IsValidSet(wdmp))
+	})
+}
+
+func TestGetCommandForParam(t *testing.T) {
+	t.Run("EmptyParams", func(t *testing.T) {
+		assert := assert.New(t)
+		assert.EqualValues(CommandSet, getCommandForParams(nil))
+		assert.EqualValues(CommandSet, getCommandForParams([]SetParam{}))
+	})
+
+	//Attributes and Name are required properties for SET_ATTRS
+	t.Run("SetCommandUndefinedAttributes", func(t *testing.T) {
+		assert := assert.New(t)
+		name := "setParam"
+		setCommandParam := SetParam{Name: &name}
+		assert.EqualValues(CommandSet, getCommandForParams([]SetParam{setCommandParam}))
+	})
+
+	//DataType and Value must be null for SET_ATTRS
+	t.Run("SetAttrsCommand", func(t *testing.T) {
+		assert := assert.New(t)
+		name := "setAttrsParam"
+		setCommandParam := SetParam{
+			Name:       &name,
+			Attributes: map[string]interface{}{"zero": 0},
+		}
+		assert.EqualValues(CommandSetAttrs, getCommandForParams([]SetParam{setCommandParam}))
+	})
+}
+func TestNormalizeAttributes(t *testing.T) {
+	tests := []struct {
+		name       string
+		attributes string
+		expected   string
+		valid      bool
+	}{
+		{"Plain", "notify", "notify", true},
+		{"CommaList", "notify, access", "notify,access", true},
+		{"JSONArray", `["notify","access"]`, "notify,access", true},
+		{"Duplicates", "notify,notify", "notify", true},
+		{"Unsupported", "notify,color", "", false},
+		{"MalformedJSON", `["notify"`, "", false},
+		{"EmptyElement", "notify,", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			actual, err := NormalizeAttributes(test.attributes)
+
+			assert.EqualValues(test.expected, actual)
+			assert.EqualValues(test.valid, err == nil)
+		})
+	}
+}
+
+func TestValidateSyncHeaders(t *testing.T) {
+	tests := []struct {
+		name                    string
+		newCID, oldCID, syncCMC string
+		expected                error
+	}{
+		{"Valid", "abc-123", "abc_122", "512", nil},
+		{"OnlyCMC", "", "", "0", nil},
+		{"InvalidNewCID", "abc 123", "", "", ErrInvalidNewCID},
+		{"NewCIDTooLong", strings.Repeat("a", 65), "", "", ErrInvalidNewCID},
+		{"InvalidOldCID", "abc", "<old>", "", ErrInvalidOldCID},
+		{"NonNumericCMC", "abc", "", "sync", ErrInvalidSyncCMC},
+		{"NegativeCMC", "abc", "", "-1", ErrInvalidSyncCMC},
+		{"CMCOutOfRange", "abc", "", "4294967296", ErrInvalidSyncCMC},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.EqualValues(t, test.expected, validateSyncHeaders(test.newCID, test.oldCID, test.syncCMC))
+		})
+	}
+}
+
+func TestDuplicateParamNames(t *testing.T) {
+	var names = []string{"a", "b", "a", "c", "b", "a"}
+	var params []SetParam
+
+	for i := range names {
+		params = append(params, SetParam{Name: &names[i]})
+	}
+
+	assert.EqualValues(t, []string{"a", "b"}, duplicateParamNames(params))
+	assert.Empty(t, duplicateParamNames(params[:2]))
+}
+
+
+//TestSetPayloadArbitraryInput complements the go-fuzz targets (see fuzz.go) with random inputs on every test run
+func TestSetPayloadArbitraryInput(t *testing.T) {
+	accepted := func(body []byte, newCID, oldCID, syncCMC string) bool {
+		p, err := SetPayload(bytes.NewReader(body), newCID, oldCID, syncCMC)
+		if err != nil {
+			return p == nil
+		}
+
+		wdmp := new(SetRequest)
+		return json.Unmarshal(p, wdmp) == nil && len(duplicateParamNames(wdmp.Parameters)) == 0
+	}
+
+	assert.Nil(t, quick.Check(accepted, nil))
+}
+
+func TestGetPayloadArbitraryInput(t *testing.T) {
+	accepted := func(names, attributes string) bool {
+		p, err := GetPayload(names, attributes)
+		if err != nil {
+			return p == nil
+		}
+
+		wdmp := new(GetRequest)
+		return json.Unmarshal(p, wdmp) == nil && strings.Join(wdmp.Names, ",") == names
+	}
+
+	assert.Nil(t, quick.Check(accepted, nil))
+}
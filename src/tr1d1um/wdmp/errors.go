@@ -0,0 +1,29 @@
+package wdmp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+)
+
+//Error values returned while building WDMP payloads
+var (
+	//Get command errors
+	ErrEmptyNames        = common.NewBadRequestError(errors.New("names parameter is required"))
+	ErrInvalidAttributes = common.NewBadRequestError(errors.New("attributes should be a comma-separated list or a JSON array of strings"))
+
+	//Set command errors
+	ErrInvalidSetWDMP = common.NewBadRequestError(errors.New("invalid XPC SET message"))
+	ErrNewCIDRequired = common.NewBadRequestError(errors.New("newCid is required for TEST_AND_SET"))
+	ErrInvalidNewCID  = common.NewBadRequestError(fmt.Errorf("%s header should be 1 to 64 alphanumeric, '-' or '_' characters", HeaderWPASyncNewCID))
+	ErrInvalidOldCID  = common.NewBadRequestError(fmt.Errorf("%s header should be 1 to 64 alphanumeric, '-' or '_' characters", HeaderWPASyncOldCID))
+	ErrInvalidSyncCMC = common.NewBadRequestError(fmt.Errorf("%s header should be an unsigned 32-bit integer", HeaderWPASyncCMC))
+
+	//Add/Delete command  errors
+	ErrMissingTable = common.NewBadRequestError(errors.New("table property is required"))
+	ErrMissingRow   = common.NewBadRequestError(errors.New("row property is required"))
+
+	//Replace command error
+	ErrMissingRows = common.NewBadRequestError(errors.New("rows property is required"))
+)
@@ -0,0 +1,112 @@
+// +build gofuzz
+
+package wdmp
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+//Fuzz targets for go-fuzz (https://github.com/dvyukov/go-fuzz). For instance:
+//	go-fuzz-build -func FuzzSet github.com/Comcast/tr1d1um/src/tr1d1um/wdmp && go-fuzz -bin wdmp-fuzz.zip
+
+//FuzzSet feeds arbitrary SET request bodies to SetPayload and checks that accepted payloads are valid WDMP
+func FuzzSet(data []byte) int {
+	p, err := SetPayload(bytes.NewReader(data), "", "", "")
+	if err != nil {
+		return 0
+	}
+
+	checkSet(p)
+	return 1
+}
+
+//FuzzTestAndSet is FuzzSet for TEST_AND_SET requests. The first line of data holds the sync values
+//separated by spaces and the rest is the request body
+func FuzzTestAndSet(data []byte) int {
+	var (
+		lines  = bytes.SplitN(data, []byte("\n"), 2)
+		values = bytes.SplitN(lines[0], []byte(" "), 3)
+		body   []byte
+	)
+
+	if len(lines) > 1 {
+		body = lines[1]
+	}
+
+	for len(values) < 3 {
+		values = append(values, nil)
+	}
+
+	p, err := SetPayload(bytes.NewReader(body), string(values[0]), string(values[1]), string(values[2]))
+	if err != nil {
+		return 0
+	}
+
+	if wdmp := checkSet(p); wdmp.Command != CommandTestSet && len(values[0]) > 0 {
+		panic("sync values were provided but the command is " + wdmp.Command)
+	}
+	return 1
+}
+
+//FuzzGet feeds arbitrary names and attributes (separated by the first newline) to GetPayload
+func FuzzGet(data []byte) int {
+	var (
+		lines      = bytes.SplitN(data, []byte("\n"), 2)
+		attributes string
+	)
+
+	if len(lines) > 1 {
+		attributes = string(lines[1])
+	}
+
+	p, err := GetPayload(string(lines[0]), attributes)
+	if err != nil {
+		return 0
+	}
+
+	var wdmp GetRequest
+	if err = json.Unmarshal(p, &wdmp); err != nil {
+		panic(err)
+	}
+
+	if len(wdmp.Names) == 0 || (wdmp.Command != CommandGet && wdmp.Command != CommandGetAttrs) {
+		panic("unexpected GET WDMP: " + string(p))
+	}
+	return 1
+}
+
+//FuzzRows feeds arbitrary bodies to the row builders
+func FuzzRows(data []byte) int {
+	var accepted int
+
+	if _, err := AddRowPayload("t0", bytes.NewReader(data)); err == nil {
+		accepted = 1
+	}
+
+	if _, err := ReplaceRowsPayload("t0", bytes.NewReader(data)); err == nil {
+		accepted = 1
+	}
+
+	return accepted
+}
+
+//checkSet panics if the given payload does not decode back into a SET WDMP
+func checkSet(p []byte) *SetRequest {
+	wdmp := new(SetRequest)
+	if err := json.Unmarshal(p, wdmp); err != nil {
+		panic(err)
+	}
+
+	switch wdmp.Command {
+	case CommandSet, CommandSetAttrs, CommandTestSet:
+	default:
+		panic("unexpected SET WDMP command: " + string(p))
+	}
+
+	if len(duplicateParamNames(wdmp.Parameters)) > 0 {
+		panic("SET WDMP with duplicate parameters was built: " + string(p))
+	}
+
+	return wdmp
+}
@@ -0,0 +1,85 @@
+package wdmp
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+//SetLimits bounds the SET request bodies API consumers send so that oversized values are rejected up front, along
+//with the parameters at fault, rather than by the device with a generic failure. Zero values mean no limit
+type SetLimits struct {
+	//MaxBodySize is the maximum size in bytes of SET request bodies, independently of the size limit of WRP messages
+	MaxBodySize int
+
+	//MaxValueLength is the maximum length in characters of string parameter values
+	MaxValueLength int
+
+	//Parameters override MaxValueLength for some parameters
+	Parameters []ParameterLimit
+}
+
+//ParameterLimit is the maximum length in characters of the string values of a parameter or, if Name ends with a
+//dot, of the parameters of an object. The most specific limit applies to a parameter
+type ParameterLimit struct {
+	Name           string
+	MaxValueLength int
+}
+
+//SetBodySizeError is the CodedError returned for the SET request bodies which exceed their size limit
+type SetBodySizeError struct {
+	//Limit is the size of the largest SET request body accepted
+	Limit int
+}
+
+func (s *SetBodySizeError) Error() string {
+	return fmt.Sprintf("SET request body exceeds the limit of %d bytes", s.Limit)
+}
+
+//StatusCode is 413 Request Entity Too Large
+func (s *SetBodySizeError) StatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+//ErrorCode lets API consumers tell oversized request bodies apart from oversized messages
+func (s *SetBodySizeError) ErrorCode() string {
+	return "set_body_too_large"
+}
+
+//read reads the SET request body from in, failing with a SetBodySizeError as soon as it exceeds MaxBodySize
+func (l *SetLimits) read(in io.Reader) ([]byte, error) {
+	if l == nil || l.MaxBodySize <= 0 {
+		return ioutil.ReadAll(in)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(in, int64(l.MaxBodySize)+1))
+	if err == nil && len(data) > l.MaxBodySize {
+		return nil, &SetBodySizeError{Limit: l.MaxBodySize}
+	}
+
+	return data, err
+}
+
+//maxValueLength returns the maximum length of the string values of the given parameter, 0 if there is none
+func (l *SetLimits) maxValueLength(name string) int {
+	if l == nil {
+		return 0
+	}
+
+	var (
+		maxLength = l.MaxValueLength
+		matched   = -1
+	)
+
+	for _, p := range l.Parameters {
+		if p.Name == name || strings.HasSuffix(p.Name, ".") && strings.HasPrefix(name, p.Name) {
+			if len(p.Name) > matched {
+				maxLength, matched = p.MaxValueLength, len(p.Name)
+			}
+		}
+	}
+
+	return maxLength
+}
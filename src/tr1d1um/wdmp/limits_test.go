@@ -0,0 +1,81 @@
+package wdmp
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetLimitsMaxValueLength(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		limits = &SetLimits{
+			MaxValueLength: 64,
+			Parameters: []ParameterLimit{
+				{Name: "Device.WiFi.", MaxValueLength: 128},
+				{Name: "Device.WiFi.SSID.", MaxValueLength: 32},
+				{Name: "Device.WiFi.SSID.1.Alias", MaxValueLength: 16},
+			},
+		}
+	)
+
+	assert.Equal(64, limits.maxValueLength("Device.DeviceInfo.ProvisioningCode"))
+	assert.Equal(128, limits.maxValueLength("Device.WiFi.Radio.1.Alias"))
+	assert.Equal(32, limits.maxValueLength("Device.WiFi.SSID.1.SSID"))
+	assert.Equal(16, limits.maxValueLength("Device.WiFi.SSID.1.Alias"))
+	assert.Equal(32, limits.maxValueLength("Device.WiFi.SSID.1.Alias2"))
+
+	var none *SetLimits
+	assert.Zero(none.maxValueLength("Device.WiFi.SSID.1.SSID"))
+}
+
+func TestLimitedSetPayload(t *testing.T) {
+	var limits = &SetLimits{
+		MaxBodySize:    512,
+		MaxValueLength: 8,
+		Parameters:     []ParameterLimit{{Name: "Device.WiFi.SSID.1.SSID", MaxValueLength: 4}},
+	}
+
+	t.Run("Ideal", func(t *testing.T) {
+		assert := assert.New(t)
+		p, err := LimitedSetPayload(bytes.NewBufferString(`{"parameters": [{"name": "Device.WiFi.SSID.1.SSID", "dataType": 0, "value": "ssid"}]}`), limits, "", "", "")
+
+		assert.Nil(err)
+		assert.NotEmpty(p)
+	})
+
+	t.Run("Values", func(t *testing.T) {
+		assert := assert.New(t)
+		_, err := LimitedSetPayload(bytes.NewBufferString(`{"parameters": [
+			{"name": "Device.WiFi.SSID.1.SSID", "dataType": 0, "value": "ssid-1"},
+			{"name": "Device.WiFi.SSID.1.Enable", "dataType": 3, "value": true},
+			{"name": "Device.DeviceInfo.ProvisioningCode", "dataType": 0, "value": "ééééééé"},
+			{"name": "Device.DeviceInfo.X_Label", "dataType": 0, "value": "too long!"}]}`), limits, "", "", "")
+
+		validationError, ok := err.(*common.ValidationError)
+		if assert.True(ok) {
+			assert.Equal([]common.ValidationProblem{
+				{Field: "parameters[0].value", Message: "should be at most 4 characters long"},
+				{Field: "parameters[3].value", Message: "should be at most 8 characters long"},
+			}, validationError.Problems)
+		}
+	})
+
+	t.Run("Body", func(t *testing.T) {
+		assert := assert.New(t)
+		_, err := LimitedSetPayload(strings.NewReader(`{"parameters": [`+strings.Repeat(" ", 512)+`]}`), limits, "", "", "")
+
+		assert.Equal(&SetBodySizeError{Limit: 512}, err)
+		assert.Equal(http.StatusRequestEntityTooLarge, err.(*SetBodySizeError).StatusCode())
+	})
+
+	t.Run("NoLimits", func(t *testing.T) {
+		assert := assert.New(t)
+		_, err := LimitedSetPayload(bytes.NewBufferString(`{"parameters": [{"name": "p", "dataType": 0, "value": "`+strings.Repeat("a", 1024)+`"}]}`), nil, "", "", "")
+		assert.Nil(err)
+	})
+}
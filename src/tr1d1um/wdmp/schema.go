@@ -0,0 +1,104 @@
+package wdmp
+
+import (
+	"reflect"
+	"strings"
+)
+
+//SchemaDraft is the JSON Schema specification the generated schemas conform to
+const SchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+//SetBody is the request body of the SET, SET_ATTRIBUTES and TEST_AND_SET operations
+//The command is deduced from the parameters and the WPA sync headers
+type SetBody struct {
+	Parameters []SetParam `json:"parameters,omitempty"`
+}
+
+//AddRowBody is the request body of the ADD_ROW operation: the values of the new row keyed by column
+type AddRowBody map[string]string
+
+//ReplaceRowsBody is the request body of the REPLACE_ROWS operation: the new rows keyed by index
+type ReplaceRowsBody IndexRow
+
+//Schema generates the JSON Schema of the JSON encoding of the given value's type
+//Struct fields are required unless they are tagged with omitempty
+func Schema(title string, v interface{}) map[string]interface{} {
+	schema := schemaOf(reflect.TypeOf(v))
+	schema["$schema"] = SchemaDraft
+	schema["title"] = title
+	return schema
+}
+
+func schemaOf(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		//interface{} values may be anything
+		return map[string]interface{}{}
+	}
+}
+
+func structSchema(t reflect.Type) map[string]interface{} {
+	var (
+		properties = make(map[string]interface{})
+		required   []string
+	)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		var (
+			tagParts = strings.Split(field.Tag.Get("json"), ",")
+			name     = tagParts[0]
+		)
+
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = schemaOf(field.Type)
+
+		if !contains(tagParts[1:], "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+func contains(elements []string, e string) bool {
+	for _, element := range elements {
+		if element == e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package wdmp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	schema := Schema("SET request body", SetBody{})
+	assert.EqualValues(SchemaDraft, schema["$schema"])
+	assert.EqualValues("object", schema["type"])
+	assert.Nil(schema["required"])
+
+	parameters := schema["properties"].(map[string]interface{})["parameters"].(map[string]interface{})
+	assert.EqualValues("array", parameters["type"])
+
+	param := parameters["items"].(map[string]interface{})
+	assert.EqualValues([]string{"name"}, param["required"])
+
+	properties := param["properties"].(map[string]interface{})
+	assert.EqualValues(map[string]interface{}{"type": "string"}, properties["name"])
+	assert.EqualValues(map[string]interface{}{"type": "integer"}, properties["dataType"])
+	assert.EqualValues(map[string]interface{}{}, properties["value"])
+	assert.EqualValues(map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{}}, properties["attributes"])
+
+	rows := Schema("REPLACE_ROWS request body", ReplaceRowsBody{})
+	assert.EqualValues("object", rows["type"])
+	assert.EqualValues(map[string]interface{}{
+		"type": "object", "additionalProperties": map[string]interface{}{"type": "string"},
+	}, rows["additionalProperties"])
+}
@@ -0,0 +1,81 @@
+//Package wdmp builds and validates the WebPA Device Management Protocol (WDMP) payloads
+//that tr1d1um sends to devices inside WRP messages
+package wdmp
+
+//All the supported commands
+const (
+	CommandGet         = "GET"
+	CommandGetAttrs    = "GET_ATTRIBUTES"
+	CommandSet         = "SET"
+	CommandSetAttrs    = "SET_ATTRIBUTES"
+	CommandTestSet     = "TEST_AND_SET"
+	CommandAddRow      = "ADD_ROW"
+	CommandDeleteRow   = "DELETE_ROW"
+	CommandReplaceRows = "REPLACE_ROWS"
+)
+
+//WebPA headers through which the TEST_AND_SET values are received
+const (
+	HeaderWPASyncOldCID = "X-Webpa-Sync-Old-Cid"
+	HeaderWPASyncNewCID = "X-Webpa-Sync-New-Cid"
+	HeaderWPASyncCMC    = "X-Webpa-Sync-Cmc"
+)
+
+//Parameter attributes that can be requested through GET_ATTRIBUTES
+const (
+	AttributeNotify = "notify"
+	AttributeAccess = "access"
+)
+
+//supportedAttributes are the parameter attribute names that can be requested through GET_ATTRIBUTES
+var supportedAttributes = map[string]bool{
+	AttributeNotify: true,
+	AttributeAccess: true,
+}
+
+//GetRequest is the WDMP for the GET and GET_ATTRIBUTES commands
+type GetRequest struct {
+	Command    string   `json:"command"`
+	Names      []string `json:"names"`
+	Attributes string   `json:"attributes,omitempty"`
+}
+
+//SetRequest is the WDMP for the SET, SET_ATTRIBUTES and TEST_AND_SET commands
+type SetRequest struct {
+	Command    string     `json:"command"`
+	OldCid     string     `json:"old-cid,omitempty"`
+	NewCid     string     `json:"new-cid,omitempty"`
+	SyncCmc    string     `json:"sync-cmc,omitempty"`
+	Parameters []SetParam `json:"parameters,omitempty"`
+}
+
+//SetParam is a single parameter of a SetRequest
+type SetParam struct {
+	Name       *string                `json:"name"`
+	DataType   *int8                  `json:"dataType,omitempty"`
+	Value      interface{}            `json:"value,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+//AddRowRequest is the WDMP for the ADD_ROW command
+type AddRowRequest struct {
+	Command string            `json:"command"`
+	Table   string            `json:"table"`
+	Row     map[string]string `json:"row"`
+}
+
+//IndexRow facilitates data transfer from json data of the form {index1: {key:val}, index2: {key:val}, ... }
+type IndexRow map[string]map[string]string
+
+//ReplaceRowsRequest is the WDMP for the REPLACE_ROWS command
+type ReplaceRowsRequest struct {
+	Command string   `json:"command"`
+	Table   string   `json:"table"`
+	Rows    IndexRow `json:"rows"`
+}
+
+//DeleteRowRequest is the WDMP for the DELETE_ROW command
+type DeleteRowRequest struct {
+	Command string `json:"command"`
+	Row     string `json:"row"`
+}
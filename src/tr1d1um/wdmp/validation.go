@@ -0,0 +1,141 @@
+package wdmp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+)
+
+//setFields and setParamFields are the JSON fields SET request bodies may have
+var (
+	setFields      = map[string]bool{"command": true, "old-cid": true, "new-cid": true, "sync-cmc": true, "parameters": true}
+	setParamFields = map[string]bool{"name": true, "dataType": true, "value": true, "attributes": true}
+)
+
+//validateSet collects all the problems of the given JSON SET request body rather than stopping at the first one
+//It returns nil if the body isn't a JSON object so that the decoding error is reported instead
+//String values longer than allowed by the given limits, which may be nil, are reported as well
+func validateSet(data []byte, limits *SetLimits) []common.ValidationProblem {
+	var (
+		body     map[string]json.RawMessage
+		problems []common.ValidationProblem
+	)
+
+	if json.Unmarshal(data, &body) != nil {
+		return nil
+	}
+
+	problems = append(problems, unknownFields("", body, setFields)...)
+
+	for _, field := range []string{"command", "old-cid", "new-cid", "sync-cmc"} {
+		var s string
+		if raw, ok := body[field]; ok && json.Unmarshal(raw, &s) != nil {
+			problems = append(problems, common.ValidationProblem{Field: field, Message: "should be a string"})
+		}
+	}
+
+	raw, ok := body["parameters"]
+	if !ok || isNull(raw) {
+		return problems
+	}
+
+	var params []map[string]json.RawMessage
+	if json.Unmarshal(raw, &params) != nil {
+		return append(problems, common.ValidationProblem{Field: "parameters", Message: "should be an array of objects"})
+	}
+
+	var (
+		names              = make(map[string]bool, len(params))
+		attributes, values int
+	)
+
+	for i, param := range params {
+		var path = fmt.Sprintf("parameters[%d]", i)
+		problems = append(problems, unknownFields(path+".", param, setParamFields)...)
+
+		var name string
+		if raw, ok := param["name"]; !ok || isNull(raw) {
+			problems = append(problems, common.ValidationProblem{Field: path + ".name", Message: "is required"})
+		} else if json.Unmarshal(raw, &name) != nil || name == "" {
+			problems = append(problems, common.ValidationProblem{Field: path + ".name", Message: "should be a non-empty string"})
+		} else if names[name] {
+			problems = append(problems, common.ValidationProblem{Field: path + ".name", Message: fmt.Sprintf("%s may only be set once per request", name)})
+		} else {
+			names[name] = true
+		}
+
+		var (
+			dataType, hasDataType = param["dataType"]
+			value, hasValue       = param["value"]
+			attrs, hasAttributes  = param["attributes"]
+		)
+
+		hasDataType, hasValue, hasAttributes = hasDataType && !isNull(dataType), hasValue && !isNull(value), hasAttributes && !isNull(attrs)
+
+		if hasDataType {
+			var t float64
+			if json.Unmarshal(dataType, &t) != nil || t != math.Trunc(t) || t < 0 || t > math.MaxInt8 {
+				problems = append(problems, common.ValidationProblem{Field: path + ".dataType", Message: fmt.Sprintf("should be an integer between 0 and %d", math.MaxInt8)})
+			}
+		}
+
+		if hasAttributes {
+			var a map[string]interface{}
+			if json.Unmarshal(attrs, &a) != nil {
+				problems = append(problems, common.ValidationProblem{Field: path + ".attributes", Message: "should be an object"})
+			}
+		}
+
+		if maxLength := limits.maxValueLength(name); hasValue && maxLength > 0 {
+			var s string
+			if json.Unmarshal(value, &s) == nil && utf8.RuneCountInString(s) > maxLength {
+				problems = append(problems, common.ValidationProblem{Field: path + ".value", Message: fmt.Sprintf("should be at most %d characters long", maxLength)})
+			}
+		}
+
+		switch {
+		case hasValue && !hasDataType:
+			problems = append(problems, common.ValidationProblem{Field: path + ".dataType", Message: "is required with a value"})
+		case hasDataType && !hasValue:
+			problems = append(problems, common.ValidationProblem{Field: path + ".value", Message: "is required with a dataType"})
+		case !hasValue && !hasAttributes:
+			problems = append(problems, common.ValidationProblem{Field: path + ".value", Message: "a value or attributes are required"})
+		}
+
+		if hasAttributes && !hasValue && !hasDataType {
+			attributes++
+		} else {
+			values++
+		}
+	}
+
+	if attributes > 0 && values > 0 {
+		problems = append(problems, common.ValidationProblem{Field: "parameters", Message: "values and attributes can't be set in the same request"})
+	}
+
+	return problems
+}
+
+//unknownFields reports the fields of the given object which are not among the known ones, in alphabetical order
+func unknownFields(prefix string, object map[string]json.RawMessage, known map[string]bool) (problems []common.ValidationProblem) {
+	var unknown []string
+	for field := range object {
+		if !known[field] {
+			unknown = append(unknown, field)
+		}
+	}
+
+	sort.Strings(unknown)
+	for _, field := range unknown {
+		problems = append(problems, common.ValidationProblem{Field: prefix + field, Message: "is not a known field"})
+	}
+	return
+}
+
+func isNull(raw json.RawMessage) bool {
+	return string(raw) == "null"
+}
@@ -0,0 +1,67 @@
+package wdmp
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSet(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		problems []common.ValidationProblem
+	}{
+		{"NotAnObject", `[1, 2]`, nil},
+		{"Empty", `{}`, nil},
+		{"Ideal", `{"parameters": [{"name": "p0", "dataType": 0, "value": "a"}, {"name": "p1", "dataType": 3, "value": true}]}`, nil},
+		{"Attributes", `{"parameters": [{"name": "p0", "attributes": {"notify": 1}}]}`, nil},
+		{
+			"Multiple",
+			`{"parameter": [], "parameters": [
+				{"name": "p0", "dataType": "string", "value": "a"},
+				{"name": "p1", "dataType": 0},
+				{"value": "c", "datatype": 0},
+				{"name": "p0", "dataType": 0, "value": "d"}]}`,
+			[]common.ValidationProblem{
+				{Field: "parameter", Message: "is not a known field"},
+				{Field: "parameters[0].dataType", Message: "should be an integer between 0 and 127"},
+				{Field: "parameters[1].value", Message: "is required with a dataType"},
+				{Field: "parameters[2].datatype", Message: "is not a known field"},
+				{Field: "parameters[2].name", Message: "is required"},
+				{Field: "parameters[2].dataType", Message: "is required with a value"},
+				{Field: "parameters[3].name", Message: "p0 may only be set once per request"},
+			},
+		},
+		{
+			"Mixed",
+			`{"parameters": [{"name": "p0", "dataType": 0, "value": "a"}, {"name": "p1", "attributes": []}]}`,
+			[]common.ValidationProblem{
+				{Field: "parameters[1].attributes", Message: "should be an object"},
+				{Field: "parameters", Message: "values and attributes can't be set in the same request"},
+			},
+		},
+		{"NotAnArray", `{"parameters": {"name": "p0"}}`, []common.ValidationProblem{{Field: "parameters", Message: "should be an array of objects"}}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.EqualValues(t, testCase.problems, validateSet([]byte(testCase.body), nil))
+		})
+	}
+}
+
+func TestSetPayloadValidationError(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := SetPayload(bytes.NewBufferString(`{"parameters": [{"name": "p0", "value": "a"}, {"dataType": 0, "value": "b"}]}`), "", "", "")
+
+	validationError, ok := err.(*common.ValidationError)
+	if assert.True(ok) {
+		assert.Len(validationError.Problems, 2)
+		assert.EqualValues(http.StatusBadRequest, validationError.StatusCode())
+	}
+}
@@ -0,0 +1,143 @@
+package wdmp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/Comcast/tr1d1um/src/tr1d1um/common"
+
+	"github.com/ugorji/go/codec"
+)
+
+//HeaderWDMPVersion is the header through which API consumers choose the WDMP version spoken with the device
+//and through which tr1d1um reports the version it used
+const HeaderWDMPVersion = "X-Wdmp-Version"
+
+//Supported WDMP versions
+const (
+	//Version1 documents travel as JSON in WRP payloads which carry no content type
+	Version1 = "1"
+
+	//Version2 documents travel as msgpack in WRP payloads
+	Version2 = "2"
+
+	//DefaultVersion is the version used when none is requested so that older devices keep working
+	DefaultVersion = Version1
+)
+
+//ErrUnsupportedVersion is returned when the requested WDMP version is not one of the supported versions
+var ErrUnsupportedVersion = common.NewBadRequestError(errors.New("unsupported WDMP version. Supported versions: " + Version1 + ", " + Version2))
+
+//Encoding describes how the WDMP documents of a given version are carried in WRP payloads
+//tr1d1um always builds and serves WDMP documents as JSON
+type Encoding struct {
+	//Version is the WDMP version of this encoding
+	Version string
+
+	//ContentType is the content type of the WRP messages carrying documents in this encoding
+	ContentType string
+
+	encode func([]byte) ([]byte, error)
+	decode func([]byte) ([]byte, error)
+}
+
+//Encode converts the given JSON WDMP document into this encoding
+func (e *Encoding) Encode(document []byte) ([]byte, error) {
+	return e.encode(document)
+}
+
+//Decode converts the given device payload into a JSON WDMP document
+//Payloads which already are JSON are returned untouched as devices might answer in an older version
+func (e *Encoding) Decode(payload []byte) ([]byte, error) {
+	if json.Valid(payload) {
+		return payload, nil
+	}
+	return e.decode(payload)
+}
+
+var encodings = map[string]*Encoding{
+	Version1: {
+		Version: Version1,
+		encode:  identity,
+		decode:  identity,
+	},
+
+	Version2: {
+		Version:     Version2,
+		ContentType: "application/msgpack",
+		encode:      jsonToMsgpack,
+		decode:      msgpackToJSON,
+	},
+}
+
+//EncodingFor returns the encoding of the given WDMP version
+//An empty version selects DefaultVersion
+func EncodingFor(version string) (*Encoding, error) {
+	if version == "" {
+		version = DefaultVersion
+	}
+
+	if e, ok := encodings[version]; ok {
+		return e, nil
+	}
+
+	return nil, ErrUnsupportedVersion
+}
+
+func identity(document []byte) ([]byte, error) {
+	return document, nil
+}
+
+func jsonToMsgpack(document []byte) (payload []byte, err error) {
+	var decoded interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+
+	if err = decoder.Decode(&decoded); err != nil {
+		return
+	}
+
+	err = codec.NewEncoderBytes(&payload, new(codec.MsgpackHandle)).Encode(toMsgpackValue(decoded))
+	return
+}
+
+//toMsgpackValue replaces the JSON numbers of a decoded document with their integer or float values
+func toMsgpackValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = toMsgpackValue(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = toMsgpackValue(item)
+		}
+	}
+
+	return v
+}
+
+func msgpackToJSON(payload []byte) ([]byte, error) {
+	var (
+		decoded interface{}
+		handle  = &codec.MsgpackHandle{}
+	)
+
+	handle.RawToString = true
+	handle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+
+	if err := codec.NewDecoderBytes(payload, handle).Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(decoded)
+}
@@ -0,0 +1,58 @@
+package wdmp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodingFor(t *testing.T) {
+	assert := assert.New(t)
+
+	e, err := EncodingFor("")
+	assert.Nil(err)
+	assert.EqualValues(DefaultVersion, e.Version)
+
+	e, err = EncodingFor(Version2)
+	assert.Nil(err)
+	assert.EqualValues("application/msgpack", e.ContentType)
+
+	_, err = EncodingFor("3")
+	assert.EqualValues(ErrUnsupportedVersion, err)
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	document := []byte(`{"command":"SET","parameters":[{"dataType":2,"name":"p0","value":-17}]}`)
+
+	for _, version := range []string{Version1, Version2} {
+		t.Run("Version"+version, func(t *testing.T) {
+			assert := assert.New(t)
+			e, _ := EncodingFor(version)
+
+			payload, err := e.Encode(document)
+			assert.Nil(err)
+
+			decoded, err := e.Decode(payload)
+			assert.Nil(err)
+			assert.JSONEq(string(document), string(decoded))
+		})
+	}
+}
+
+func TestEncodingDecode(t *testing.T) {
+	t.Run("LegacyJSON", func(t *testing.T) {
+		assert := assert.New(t)
+		e, _ := EncodingFor(Version2)
+
+		decoded, err := e.Decode([]byte(`{"statusCode":200}`))
+		assert.Nil(err)
+		assert.EqualValues(`{"statusCode":200}`, string(decoded))
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		e, _ := EncodingFor(Version2)
+
+		_, err := e.Decode([]byte{0xc1})
+		assert.NotNil(t, err)
+	})
+}